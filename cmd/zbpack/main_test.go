@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -82,4 +83,135 @@ func TestCLI(t *testing.T) {
 			t.Fatal("expected info output, but got: ", string(out))
 		}
 	})
+
+	t.Run("print info as JSON on stdout when give --format json", func(t *testing.T) {
+		path, _ := os.Getwd()
+		path = filepath.Join(path, "../../")
+		testFilePath := filepath.Join(path, "/tests/bun-plain")
+		cmd := exec.Command(binName, "--info", "--format", "json", testFilePath)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var info struct {
+			PlanType      string            `json:"planType"`
+			PlanMeta      map[string]string `json:"planMeta"`
+			Submodule     string            `json:"submodule"`
+			ConfigSources []string          `json:"configSources"`
+			Warnings      []string          `json:"warnings"`
+		}
+		if err := json.Unmarshal(out, &info); err != nil {
+			t.Fatalf("expected JSON output, but got: %s (%v)", string(out), err)
+		}
+
+		if info.PlanType != "bun" || info.Submodule != "bun-plain" {
+			t.Fatal("unexpected info output: ", string(out))
+		}
+	})
+
+	t.Run("show the shared options in info", func(t *testing.T) {
+		projectPath := t.TempDir()
+		if err := os.WriteFile(filepath.Join(projectPath, "go.mod"), []byte("module example.com/app\n\ngo 1.22\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(projectPath, "zbpack.json"), []byte(`{"cache_mounts": true, "hardening": "true"}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := exec.Command(binName, "--info", "--format", "json", projectPath)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var info struct {
+			PlanType string            `json:"planType"`
+			PlanMeta map[string]string `json:"planMeta"`
+		}
+		if err := json.Unmarshal(out, &info); err != nil {
+			t.Fatalf("expected JSON output, but got: %s (%v)", string(out), err)
+		}
+
+		if info.PlanType != "go" || info.PlanMeta["cacheMounts"] != "true" || info.PlanMeta["hardening"] != "true" {
+			t.Fatal("unexpected info output: ", string(out))
+		}
+	})
+
+	t.Run("show error when give an unsupported format", func(t *testing.T) {
+		path, _ := os.Getwd()
+		path = filepath.Join(path, "../../")
+		testFilePath := filepath.Join(path, "/tests/bun-plain")
+		cmd := exec.Command(binName, "--info", "--format", "xml", testFilePath)
+		if _, err := cmd.Output(); err == nil {
+			t.Fatal("expected error, but got nil")
+		}
+	})
+
+	t.Run("explain the decisions when run explain command", func(t *testing.T) {
+		path, _ := os.Getwd()
+		path = filepath.Join(path, "../../")
+		testFilePath := filepath.Join(path, "/tests/bun-plain")
+		cmd := exec.Command(binName, "explain", testFilePath)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(out), "bun        matched") || !strings.Contains(string(out), "Plan: bun") {
+			t.Fatal("expected explain output, but got: ", string(out))
+		}
+	})
+
+	t.Run("exit with error when build a project with a broken config", func(t *testing.T) {
+		projectPath := t.TempDir()
+		if err := os.WriteFile(filepath.Join(projectPath, "index.html"), []byte("<h1>hello</h1>"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(projectPath, "zbpack.json"), []byte(`{"build_command": `), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := exec.Command(binName, "--export-dir", t.TempDir(), projectPath)
+		out, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Fatal("expected error, but got nil: ", string(out))
+		}
+
+		if !strings.Contains(string(out), "parse config file zbpack.json") {
+			t.Fatal("expected config error output, but got: ", string(out))
+		}
+	})
+
+	t.Run("lint the Dockerfile when run lint command", func(t *testing.T) {
+		dockerfilePath := filepath.Join(t.TempDir(), "Dockerfile")
+		if err := os.WriteFile(dockerfilePath, []byte("FROM node\nENV API_TOKEN=abc\nCMD node index.js\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := exec.Command(binName, "lint", "--format", "json", dockerfilePath)
+		out, err := cmd.Output()
+		if err == nil {
+			t.Fatal("expected error for the secret, but got nil")
+		}
+
+		var result struct {
+			Findings []struct {
+				Rule     string `json:"rule"`
+				Severity string `json:"severity"`
+				Line     int    `json:"line"`
+			} `json:"findings"`
+		}
+		if err := json.Unmarshal(out, &result); err != nil {
+			t.Fatalf("expected JSON output, but got: %s (%v)", string(out), err)
+		}
+		if len(result.Findings) != 4 || result.Findings[3].Rule != "secret-in-env" || result.Findings[3].Line != 2 {
+			t.Fatal("unexpected lint output: ", string(out))
+		}
+
+		cmd = exec.Command(binName, "lint", "--severity", "secret-in-env=warning", dockerfilePath)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("expected no error when the secret is a warning, but got: %s (%v)", string(out), err)
+		}
+	})
 }
@@ -13,6 +13,12 @@ func GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return nodejs.GenerateDockerfile(meta)
 }
 
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for Bun
+// projects from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return nodejs.GenerateDockerfileFromBuildPlan(bp)
+}
+
 type pack struct {
 	*identify
 }
@@ -28,4 +34,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
@@ -68,4 +68,17 @@ func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
 	)
 }
 
-var _ plan.Identifier = (*identify)(nil)
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
+	return GetBuildPlan(
+		GetMetaOptions{
+			Src:    options.Source,
+			Config: options.Config,
+			Bun:    true,
+		},
+	)
+}
+
+var (
+	_ plan.Identifier          = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
@@ -26,22 +26,19 @@ type GetMetaOptions nodejs.GetMetaOptions
 
 // GetMeta gets the metadata of the Node.js project.
 func GetMeta(opt GetMetaOptions) types.PlanMeta {
-	ctx := CreateBunContext(opt)
+	return GetBuildPlan(opt).PlanMeta()
+}
 
-	meta := types.PlanMeta{}
+// GetBuildPlan gets the typed build plan of the Bun project.
+func GetBuildPlan(opt GetMetaOptions) types.BuildPlan {
+	ctx := CreateBunContext(opt)
 
 	framework := DetermineFramework(ctx)
-	meta["framework"] = string(framework)
-
-	bunVersion := DetermineVersion(ctx)
-	meta["bunVersion"] = bunVersion
-
 	if framework != types.BunFrameworkNone {
 		opt.BunFramework = optional.Some(framework)
 	}
 
-	meta = nodejs.GetMeta(nodejs.GetMetaOptions(opt))
-	return meta
+	return nodejs.GetBuildPlan(nodejs.GetMetaOptions(opt))
 }
 
 // CreateBunContext creates a new [PlanContext].
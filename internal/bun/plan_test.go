@@ -6,7 +6,9 @@ import (
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/zeabur/zbpack/internal/bun"
+	"github.com/zeabur/zbpack/internal/nodejs"
 	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
 )
 
 func TestBunVersion(t *testing.T) {
@@ -57,3 +59,30 @@ func TestBunVersion(t *testing.T) {
 		assert.Equal(t, "1", version)
 	})
 }
+
+func TestGetBuildPlan(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "package.json", []byte(`{"engines":{"node":"20"},"scripts":{"start":"bun index.ts"}}`), 0o644)
+	_ = afero.WriteFile(fs, "bun.lockb", []byte{}, 0o644)
+
+	opt := bun.GetMetaOptions{
+		Src:    fs,
+		Config: plan.NewProjectConfigurationFromFs(fs, ""),
+		Bun:    true,
+	}
+	bp := bun.GetBuildPlan(opt)
+
+	assert.Equal(t, types.PlanTypeBun, bp.Type)
+	assert.Equal(t, "latest", bp.RuntimeVersion)
+	assert.Equal(t, "20", bp.Extra["nodeVersion"])
+	assert.True(t, bp.Flag(nodejs.FlagBun))
+	assert.Equal(t, bun.GetMeta(opt), bp.PlanMeta())
+
+	dockerfile, err := bun.GenerateDockerfileFromBuildPlan(bp)
+	assert.NoError(t, err)
+	expected, err := bun.GenerateDockerfile(bp.PlanMeta())
+	assert.NoError(t, err)
+	assert.Equal(t, expected, dockerfile)
+}
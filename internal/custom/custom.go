@@ -0,0 +1,118 @@
+// Package custom is the packer for the custom plan declared in zbpack.json.
+package custom
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/zeabur/zbpack/pkg/packer"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+// workdir is the working directory of the build and the runtime image.
+const workdir = "/src"
+
+// GenerateDockerfile generates the Dockerfile of the custom plan.
+func GenerateDockerfile(meta types.PlanMeta) (string, error) {
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypeCustom, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates the Dockerfile of the custom
+// plan from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	baseImage := bp.Extra[metaBaseImage]
+	if baseImage == "" {
+		return "", fmt.Errorf("custom: no base image")
+	}
+	runtimeImage := bp.Extra[metaRuntimeImage]
+
+	var b strings.Builder
+
+	if runtimeImage != "" {
+		fmt.Fprintf(&b, "FROM %s AS build\n", baseImage)
+	} else {
+		fmt.Fprintf(&b, "FROM %s\n", baseImage)
+	}
+	fmt.Fprintf(&b, "WORKDIR %s\n", workdir)
+	if packages := strings.Join(bp.SystemPackages, " "); packages != "" {
+		b.WriteString(installPackages(baseImage, packages))
+	}
+	b.WriteString("COPY . .\n")
+	for _, cmd := range strings.Split(bp.InstallCommand, "\n") {
+		if cmd != "" {
+			fmt.Fprintf(&b, "RUN %s\n", cmd)
+		}
+	}
+	for _, cmd := range strings.Split(bp.BuildCommand, "\n") {
+		if cmd != "" {
+			fmt.Fprintf(&b, "RUN %s\n", cmd)
+		}
+	}
+
+	if runtimeImage != "" {
+		fmt.Fprintf(&b, "\nFROM %s\n", runtimeImage)
+		fmt.Fprintf(&b, "WORKDIR %s\n", workdir)
+		if packages := bp.Extra[metaRuntimePackages]; packages != "" {
+			b.WriteString(installPackages(runtimeImage, packages))
+		}
+
+		var rules []CopyRule
+		if encoded := bp.Extra[metaCopy]; encoded != "" {
+			if err := json.Unmarshal([]byte(encoded), &rules); err != nil {
+				return "", fmt.Errorf("custom: decode copy rules: %w", err)
+			}
+		}
+		if len(rules) == 0 {
+			rules = []CopyRule{{From: ".", To: "."}}
+		}
+		for _, rule := range rules {
+			fmt.Fprintf(&b, "COPY --from=build %s %s\n", path.Join(workdir, rule.From), rule.To)
+		}
+	}
+
+	if bp.ExposedPort != 0 {
+		fmt.Fprintf(&b, "EXPOSE %d\n", bp.ExposedPort)
+	}
+
+	if start := bp.StartCommand; start != "" {
+		fmt.Fprintf(&b, "CMD %s\n", start)
+	}
+
+	return b.String(), nil
+}
+
+// installPackages returns the RUN instruction installing the system packages
+// with apk on Alpine-based images, and apt-get on the others.
+func installPackages(image, packages string) string {
+	if strings.Contains(image, "alpine") {
+		return fmt.Sprintf("RUN apk add --no-cache %s\n", packages)
+	}
+
+	return fmt.Sprintf("RUN apt-get update && apt-get install -y --no-install-recommends %s && rm -rf /var/lib/apt/lists/*\n", packages)
+}
+
+type pack struct {
+	*identify
+}
+
+// NewPacker returns a new custom plan packer.
+func NewPacker() packer.V2 {
+	return &pack{
+		identify: &identify{},
+	}
+}
+
+func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
+	return GenerateDockerfile(meta)
+}
+
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.V2              = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
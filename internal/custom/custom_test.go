@@ -0,0 +1,109 @@
+package custom_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gkampitakis/go-snaps/snaps"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeabur/zbpack/internal/custom"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+func TestMain(m *testing.M) {
+	v := m.Run()
+
+	// After all tests have run `go-snaps` will sort snapshots
+	snaps.Clean(m, snaps.CleanOpts{Sort: true})
+
+	os.Exit(v)
+}
+
+func getMeta(t *testing.T, config string) types.PlanMeta {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "zbpack.json", []byte(config), 0o644)
+
+	return custom.GetMeta(custom.GetMetaOptions{
+		Config: plan.NewProjectConfigurationFromFs(fs, ""),
+	})
+}
+
+func TestGetMeta(t *testing.T) {
+	meta := getMeta(t, `{
+		"custom": {
+			"base_image": "docker.io/library/golang:1.23",
+			"system_packages": ["git", "make"],
+			"install": "go mod download",
+			"build": ["make generate", "make build"],
+			"start": ["./bin/server", "--listen", ":8080"],
+			"runtime_image": "gcr.io/distroless/base",
+			"copy": [{"from": "bin"}, {"from": "config/prod.yaml", "to": "/etc/app.yaml"}],
+			"port": 8080
+		}
+	}`)
+
+	assert.Equal(t, types.PlanMeta{
+		"baseImage":      "docker.io/library/golang:1.23",
+		"systemPackages": "git make",
+		"installCmd":     "go mod download",
+		"buildCmd":       "make generate\nmake build",
+		"startCmd":       `["./bin/server","--listen",":8080"]`,
+		"runtimeImage":   "gcr.io/distroless/base",
+		"copy":           `[{"from":"bin","to":"bin"},{"from":"config/prod.yaml","to":"/etc/app.yaml"}]`,
+		"port":           "8080",
+	}, meta)
+}
+
+func TestGenerateDockerfile_SingleStage(t *testing.T) {
+	meta := getMeta(t, `{
+		"custom": {
+			"base_image": "docker.io/library/alpine:3.20",
+			"system_packages": ["python3"],
+			"build": "python3 -m compileall .",
+			"start": "python3 main.py",
+			"port": 3000
+		}
+	}`)
+
+	dockerfile, err := custom.GenerateDockerfile(meta)
+	assert.NoError(t, err)
+	snaps.MatchSnapshot(t, dockerfile)
+}
+
+func TestGenerateDockerfile_MultiStage(t *testing.T) {
+	meta := getMeta(t, `{
+		"custom": {
+			"base_image": "docker.io/library/golang:1.23",
+			"install": "go mod download",
+			"build": "go build -o bin/server .",
+			"start": ["./bin/server"],
+			"runtime_image": "docker.io/library/debian:bookworm-slim",
+			"runtime_packages": "ca-certificates",
+			"copy": [{"from": "bin"}]
+		}
+	}`)
+
+	dockerfile, err := custom.GenerateDockerfile(meta)
+	assert.NoError(t, err)
+	snaps.MatchSnapshot(t, dockerfile)
+}
+
+func TestIdentifier(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	identifier := custom.NewIdentifier()
+
+	assert.False(t, identifier.Match(plan.MatchContext{
+		Source: fs,
+		Config: plan.NewProjectConfigurationFromFs(fs, ""),
+	}))
+
+	_ = afero.WriteFile(fs, "zbpack.json", []byte(`{"custom": {"base_image": "docker.io/library/debian"}}`), 0o644)
+	assert.True(t, identifier.Match(plan.MatchContext{
+		Source: fs,
+		Config: plan.NewProjectConfigurationFromFs(fs, ""),
+	}))
+}
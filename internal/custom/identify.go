@@ -0,0 +1,35 @@
+package custom
+
+import (
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+type identify struct{}
+
+// NewIdentifier returns a new custom plan identifier.
+func NewIdentifier() plan.IdentifierV2 {
+	return &identify{}
+}
+
+func (i *identify) PlanType() types.PlanType {
+	return types.PlanTypeCustom
+}
+
+// Match checks if the custom plan is defined in the configuration.
+func (i *identify) Match(ctx plan.MatchContext) bool {
+	return ctx.Config.Get(ConfigCustomBaseImage).IsSome()
+}
+
+func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
+	return GetMeta(GetMetaOptions{Config: options.Config})
+}
+
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
+	return GetBuildPlan(GetMetaOptions{Config: options.Config})
+}
+
+var (
+	_ plan.IdentifierV2        = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
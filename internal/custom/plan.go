@@ -0,0 +1,190 @@
+package custom
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+// The configuration keys of the custom plan in zbpack.json.
+//
+//	{
+//	  "custom": {
+//	    "base_image": "docker.io/library/debian:bookworm",
+//	    "system_packages": ["build-essential"],
+//	    "install": "make deps",
+//	    "build": ["make", "make test"],
+//	    "start": "./bin/server",
+//	    "runtime_image": "docker.io/library/debian:bookworm-slim",
+//	    "runtime_packages": ["ca-certificates"],
+//	    "copy": [{"from": "bin", "to": "/src/bin"}],
+//	    "port": 8080
+//	  }
+//	}
+const (
+	// ConfigCustomBaseImage is the image to build the application in.
+	ConfigCustomBaseImage = "custom.base_image"
+	// ConfigCustomSystemPackages is the system packages installed in the base image.
+	ConfigCustomSystemPackages = "custom.system_packages"
+	// ConfigCustomInstall is the command, or the list of commands, to install the dependencies.
+	ConfigCustomInstall = "custom.install"
+	// ConfigCustomBuild is the command, or the list of commands, to build the application.
+	ConfigCustomBuild = "custom.build"
+	// ConfigCustomStart is the command to start the application.
+	// A list is used as the exec form of CMD.
+	ConfigCustomStart = "custom.start"
+	// ConfigCustomRuntimeImage is the image to run the application in.
+	// If it is not set, the application runs in the base image.
+	ConfigCustomRuntimeImage = "custom.runtime_image"
+	// ConfigCustomRuntimePackages is the system packages installed in the runtime image.
+	ConfigCustomRuntimePackages = "custom.runtime_packages"
+	// ConfigCustomCopy is the files copied from the base image to the runtime image,
+	// in the form of [{"from": "dist", "to": "/src/dist"}]. The "from" path is
+	// relative to the working directory. By default, the whole working directory is copied.
+	ConfigCustomCopy = "custom.copy"
+	// ConfigCustomPort is the port exposed by the application.
+	ConfigCustomPort = "custom.port"
+)
+
+// defaultBaseImage is the base image if plan_type is "custom" but no base image is specified.
+const defaultBaseImage = "docker.io/library/debian:bookworm"
+
+// CopyRule is a file or directory copied from the base image to the runtime image.
+type CopyRule struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// GetMetaOptions is the options for GetMeta.
+type GetMetaOptions struct {
+	Config plan.ImmutableProjectConfiguration
+}
+
+// The PlanMeta keys of the custom plan which have no typed field.
+const (
+	metaBaseImage       = "baseImage"
+	metaRuntimeImage    = "runtimeImage"
+	metaRuntimePackages = "runtimePackages"
+	// metaCopy is the copy rules encoded in JSON.
+	metaCopy = "copy"
+)
+
+// GetMeta returns the metadata of the custom plan defined in the configuration.
+func GetMeta(opt GetMetaOptions) types.PlanMeta {
+	return GetBuildPlan(opt).PlanMeta()
+}
+
+// GetBuildPlan returns the typed build plan of the custom plan defined
+// in the configuration. The install and build commands are the lists of
+// commands separated by newlines.
+func GetBuildPlan(opt GetMetaOptions) types.BuildPlan {
+	config := opt.Config
+	bp := types.BuildPlan{
+		Version:        types.BuildPlanVersion,
+		Type:           types.PlanTypeCustom,
+		InstallCommand: strings.Join(getList(config, ConfigCustomInstall), "\n"),
+		BuildCommand:   strings.Join(getList(config, ConfigCustomBuild), "\n"),
+	}
+	bp.SetExtra(metaBaseImage, plan.Cast(config.Get(ConfigCustomBaseImage), cast.ToStringE).TakeOr(defaultBaseImage))
+
+	if packages := getList(config, ConfigCustomSystemPackages); len(packages) > 0 {
+		bp.SystemPackages = packages
+	}
+
+	if start, err := config.Get(ConfigCustomStart).Take(); err == nil {
+		if s, ok := start.(string); ok {
+			bp.StartCommand = s
+		} else if args, err := cast.ToStringSliceE(start); err == nil {
+			// keep the exec form, so the arguments with spaces are not split
+			encoded, _ := json.Marshal(args)
+			bp.StartCommand = string(encoded)
+		}
+	}
+
+	if runtimeImage, err := plan.Cast(config.Get(ConfigCustomRuntimeImage), cast.ToStringE).Take(); err == nil {
+		bp.SetExtra(metaRuntimeImage, runtimeImage)
+	}
+	if packages := getList(config, ConfigCustomRuntimePackages); len(packages) > 0 {
+		bp.SetExtra(metaRuntimePackages, strings.Join(packages, " "))
+	}
+
+	if copyRules := getCopyRules(config); len(copyRules) > 0 {
+		encoded, _ := json.Marshal(copyRules)
+		bp.SetExtra(metaCopy, string(encoded))
+	}
+
+	if port, err := plan.Cast(config.Get(ConfigCustomPort), cast.ToIntE).Take(); err == nil {
+		bp.ExposedPort = port
+	}
+
+	return bp
+}
+
+// getList gets a string or a list of strings from the configuration.
+// A string is a list with a single item.
+func getList(config plan.ImmutableProjectConfiguration, key string) []string {
+	v, err := config.Get(key).Take()
+	if err != nil {
+		return nil
+	}
+
+	if s, ok := v.(string); ok {
+		if s == "" {
+			return nil
+		}
+		return []string{s}
+	}
+
+	list, err := cast.ToStringSliceE(v)
+	if err != nil {
+		log.Printf("custom: %s is not a string or a list of strings: %v\n", key, err)
+		return nil
+	}
+	return list
+}
+
+// getCopyRules gets the copy rules from the configuration.
+func getCopyRules(config plan.ImmutableProjectConfiguration) []CopyRule {
+	v, err := config.Get(ConfigCustomCopy).Take()
+	if err != nil {
+		return nil
+	}
+
+	items, err := cast.ToSliceE(v)
+	if err != nil {
+		log.Printf("custom: %s is not a list: %v\n", ConfigCustomCopy, err)
+		return nil
+	}
+
+	rules := make([]CopyRule, 0, len(items))
+	for _, item := range items {
+		rule, err := toCopyRule(item)
+		if err != nil {
+			log.Printf("custom: invalid copy rule %v: %v\n", item, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func toCopyRule(item any) (CopyRule, error) {
+	m, err := cast.ToStringMapStringE(item)
+	if err != nil {
+		return CopyRule{}, err
+	}
+
+	rule := CopyRule{From: m["from"], To: m["to"]}
+	if rule.From == "" {
+		return CopyRule{}, fmt.Errorf("missing from")
+	}
+	if rule.To == "" {
+		rule.To = rule.From
+	}
+	return rule, nil
+}
@@ -8,9 +8,15 @@ import (
 
 // GenerateDockerfile generates the Dockerfile for Dart projects.
 func GenerateDockerfile(meta types.PlanMeta) (string, error) {
-	build := meta["build"]
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypeDart, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for Dart
+// projects from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	build := bp.BuildCommand
 
-	if meta["framework"] == "flutter" {
+	if bp.Framework == "flutter" {
 		dockerfile := `FROM ubuntu:latest
 RUN apt-get update && apt-get install -y curl git unzip xz-utils zip libglu1-mesa
 RUN git clone https://github.com/flutter/flutter.git /usr/local/flutter
@@ -32,7 +38,7 @@ COPY --from=1 / /usr/share/caddy
 		return dockerfile, nil
 	}
 
-	if meta["framework"] == "serverpod" {
+	if bp.Framework == "serverpod" {
 		return `FROM dart:3.2.5 AS build
 WORKDIR /app
 COPY . .
@@ -67,4 +73,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
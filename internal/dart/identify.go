@@ -95,26 +95,30 @@ func determineOutputDir(ctx planContext) string {
 }
 
 func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
+	return i.BuildPlan(options).PlanMeta()
+}
+
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
 	ctx := planContext{
 		Src:    options.Source,
 		Config: options.Config,
 	}
 
-	meta := types.PlanMeta{}
-
-	if framework := determineFramework(ctx); framework != types.DartFrameworkNone {
-		meta["framework"] = string(framework)
-	}
-
-	if build := determineBuildCommand(ctx); build != "" {
-		meta["build"] = build
+	bp := types.BuildPlan{
+		Version:      types.BuildPlanVersion,
+		Type:         types.PlanTypeDart,
+		BuildCommand: determineBuildCommand(ctx),
+		OutputDir:    determineOutputDir(ctx),
 	}
 
-	if od := determineOutputDir(ctx); od != "" {
-		meta["outputDir"] = od
+	if framework := determineFramework(ctx); framework != types.DartFrameworkNone {
+		bp.Framework = string(framework)
 	}
 
-	return meta
+	return bp
 }
 
-var _ plan.Identifier = (*identify)(nil)
+var (
+	_ plan.Identifier          = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
@@ -8,15 +8,31 @@ import (
 
 // GenerateDockerfile generates the Dockerfile for Deno projects.
 func GenerateDockerfile(meta types.PlanMeta) (string, error) {
-	framework := meta["framework"]
-	entry := meta["entry"]
-	startCmd := meta["startCommand"]
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypeDeno, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for Deno
+// projects from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	framework := bp.Framework
+	entry := bp.Entry
+	startCmd := bp.StartCommand
 
 	dockerfile := `FROM docker.io/denoland/deno
 WORKDIR /app
 COPY . .
 EXPOSE 8080
 RUN deno cache ` + entry
+	if bp.Flag(types.FlagHardening) {
+		// The deno image has the deno user, who owns the cache directory.
+		dockerfile = `FROM docker.io/denoland/deno
+RUN mkdir /app && chown deno:deno /app
+WORKDIR /app
+COPY --chown=deno:deno . .
+USER deno
+EXPOSE 8080
+RUN deno cache ` + entry
+	}
 
 	switch framework {
 	case string(types.DenoFrameworkFresh):
@@ -49,4 +65,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
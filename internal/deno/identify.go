@@ -24,15 +24,27 @@ func (i *identify) Match(fs afero.Fs) bool {
 }
 
 func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
+	return i.BuildPlan(options).PlanMeta()
+}
+
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
 	framework := DetermineFramework(options.Source)
 	entry := DetermineEntry(options.Source)
 	startCmd := GetStartCommand(options.Source)
 
-	return types.PlanMeta{
-		"framework":    string(framework),
-		"entry":        entry,
-		"startCommand": startCmd,
+	bp := types.BuildPlan{
+		Version:      types.BuildPlanVersion,
+		Type:         types.PlanTypeDeno,
+		Framework:    string(framework),
+		Entry:        entry,
+		StartCommand: startCmd,
 	}
+	bp.KeepKeys("framework", "entry", "startCommand")
+
+	return bp
 }
 
-var _ plan.Identifier = (*identify)(nil)
+var (
+	_ plan.Identifier          = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
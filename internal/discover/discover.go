@@ -0,0 +1,116 @@
+// Package discover finds the deployable units (services) in a repository.
+package discover
+
+import (
+	"log"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// Kind is how a unit is discovered.
+type Kind string
+
+//revive:disable:exported
+const (
+	KindRoot           Kind = "root"
+	KindPnpmWorkspace  Kind = "pnpm-workspace"
+	KindYarnWorkspace  Kind = "yarn-workspace"
+	KindGoCommand      Kind = "go-cmd"
+	KindCargoWorkspace Kind = "cargo-workspace"
+	KindDotnetSolution Kind = "dotnet-solution"
+	KindPythonProject  Kind = "python-subproject"
+)
+
+//revive:enable:exported
+
+// Unit is a deployable unit in the repository, and how to plan it.
+type Unit struct {
+	// Name is the name of the unit, which is used as the submodule (service) name.
+	Name string
+	// Path is the directory of the unit relative to the repository root.
+	Path string
+	// Kind is how the unit is discovered.
+	Kind Kind
+	// Subpath is the directory to plan in. An empty Subpath means the
+	// repository root, for example, a Go command in "cmd/<name>" is
+	// planned in the root with the submodule name.
+	Subpath string
+	// Config is the configuration set when planning the unit,
+	// for example, "app_dir" of a workspace package.
+	Config map[string]any
+}
+
+// finder finds the units of an ecosystem.
+type finder func(fs afero.Fs) []Unit
+
+var finders = []finder{
+	findPnpmWorkspace,
+	findYarnWorkspace,
+	findGoCommands,
+	findCargoWorkspace,
+	findDotnetSolution,
+	findPythonProjects,
+}
+
+// Find finds the deployable units in the repository.
+//
+// If the repository is not a monorepo, it returns the repository root
+// as the only unit named rootName.
+func Find(fs afero.Fs, rootName string) []Unit {
+	var units []Unit
+
+	for _, find := range finders {
+		for _, unit := range find(fs) {
+			if slices.ContainsFunc(units, func(u Unit) bool {
+				return u.Path == unit.Path && u.Name == unit.Name
+			}) {
+				continue
+			}
+			units = append(units, unit)
+		}
+	}
+
+	if len(units) == 0 {
+		units = append(units, Unit{Name: rootName, Path: ".", Kind: KindRoot})
+	}
+
+	return units
+}
+
+// globDirs returns the directories matching the workspace globs.
+//
+// The "!" patterns exclude the directories, and "**" is treated as "*"
+// since afero.Glob does not support it.
+func globDirs(fs afero.Fs, patterns []string) []string {
+	var dirs, excluded []string
+
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(path.Clean(strings.TrimSpace(pattern)), "./")
+		exclude := strings.HasPrefix(pattern, "!")
+		pattern = strings.ReplaceAll(strings.TrimPrefix(pattern, "!"), "**", "*")
+
+		matches, err := afero.Glob(fs, pattern)
+		if err != nil {
+			log.Printf("discover: invalid glob %s: %v\n", pattern, err)
+			continue
+		}
+
+		for _, match := range matches {
+			if info, err := fs.Stat(match); err != nil || !info.IsDir() {
+				continue
+			}
+			if exclude {
+				excluded = append(excluded, match)
+			} else if !slices.Contains(dirs, match) {
+				dirs = append(dirs, match)
+			}
+		}
+	}
+
+	return slices.DeleteFunc(dirs, func(dir string) bool {
+		return slices.Contains(excluded, dir)
+	})
+}
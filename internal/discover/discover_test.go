@@ -0,0 +1,150 @@
+package discover_test
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeabur/zbpack/internal/discover"
+)
+
+func writeFiles(fs afero.Fs, files map[string]string) {
+	for name, content := range files {
+		_ = afero.WriteFile(fs, name, []byte(content), 0o644)
+	}
+}
+
+func names(units []discover.Unit) []string {
+	result := make([]string, 0, len(units))
+	for _, u := range units {
+		result = append(result, string(u.Kind)+":"+u.Name+"@"+u.Path)
+	}
+	return result
+}
+
+func TestFind_Root(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	writeFiles(fs, map[string]string{"package.json": `{"scripts": {"start": "node index.js"}}`})
+
+	assert.Equal(t, []discover.Unit{
+		{Name: "my-app", Path: ".", Kind: discover.KindRoot},
+	}, discover.Find(fs, "my-app"))
+}
+
+func TestFind_PnpmWorkspace(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	writeFiles(fs, map[string]string{
+		"pnpm-workspace.yaml":          "packages:\n  - apps/*\n  - packages/**\n  - '!apps/legacy'\n",
+		"apps/web/package.json":        `{"name": "@acme/web", "scripts": {"build": "next build"}}`,
+		"apps/api/package.json":        `{"scripts": {"start": "node server.js"}}`,
+		"apps/legacy/package.json":     `{"scripts": {"start": "node server.js"}}`,
+		"packages/ui/package.json":     `{"name": "@acme/ui", "main": "index.js", "scripts": {"build": "tsc"}}`,
+		"packages/config/package.json": `{"name": "@acme/config"}`,
+	})
+
+	units := discover.Find(fs, "root")
+	assert.ElementsMatch(t, []string{
+		"pnpm-workspace:web@apps/web",
+		"pnpm-workspace:api@apps/api",
+	}, names(units))
+	for _, u := range units {
+		assert.Equal(t, map[string]any{"app_dir": u.Path}, u.Config)
+		assert.Empty(t, u.Subpath)
+	}
+}
+
+func TestFind_YarnWorkspace(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	writeFiles(fs, map[string]string{
+		"package.json":           `{"private": true, "workspaces": {"packages": ["apps/*"]}}`,
+		"apps/docs/package.json": `{"name": "docs", "scripts": {"start": "vitepress serve"}}`,
+	})
+
+	assert.Equal(t, []string{"yarn-workspace:docs@apps/docs"}, names(discover.Find(fs, "root")))
+}
+
+func TestFind_GoCommands(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	writeFiles(fs, map[string]string{
+		"go.mod":               "module example.com/app\n\ngo 1.22\n",
+		"cmd/server/main.go":   "package main\n\nfunc main() {}\n",
+		"cmd/worker/worker.go": "// Command worker.\npackage main\n\nfunc main() {}\n",
+		"cmd/shared/shared.go": "package shared\n",
+	})
+
+	units := discover.Find(fs, "root")
+	assert.Equal(t, []string{"go-cmd:server@cmd/server", "go-cmd:worker@cmd/worker"}, names(units))
+	assert.Equal(t, "./cmd/worker", units[1].Config["go.entry"])
+	assert.Equal(t, "go", units[1].Config["plan_type"])
+}
+
+func TestFind_CargoWorkspace(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	writeFiles(fs, map[string]string{
+		"Cargo.toml":                      "[workspace]\nmembers = [\"crates/*\"]\nexclude = [\"crates/experimental\"]\n",
+		"crates/api/Cargo.toml":           "[package]\nname = \"api\"\n",
+		"crates/api/src/main.rs":          "fn main() {}",
+		"crates/core/Cargo.toml":          "[package]\nname = \"core\"\n",
+		"crates/core/src/lib.rs":          "",
+		"crates/tools/Cargo.toml":         "[package]\nname = \"tools\"\n\n[[bin]]\nname = \"migrate\"\n\n[[bin]]\nname = \"seed\"\n",
+		"crates/experimental/Cargo.toml":  "[package]\nname = \"experimental\"\n",
+		"crates/experimental/src/main.rs": "fn main() {}",
+	})
+
+	assert.Equal(t, []string{
+		"cargo-workspace:api@crates/api",
+		"cargo-workspace:migrate@crates/tools",
+		"cargo-workspace:seed@crates/tools",
+	}, names(discover.Find(fs, "root")))
+}
+
+func TestFind_DotnetSolution(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	writeFiles(fs, map[string]string{
+		"App.sln": `Microsoft Visual Studio Solution File, Format Version 12.00
+Project("{FAE04EC0-301F-11D3-BF4B-00C04F79EFBC}") = "Api", "src\Api\Api.csproj", "{1}"
+EndProject
+Project("{FAE04EC0-301F-11D3-BF4B-00C04F79EFBC}") = "Core", "src\Core\Core.csproj", "{2}"
+EndProject
+`,
+		"src/Api/Api.csproj":   `<Project Sdk="Microsoft.NET.Sdk.Web"></Project>`,
+		"src/Core/Core.csproj": `<Project Sdk="Microsoft.NET.Sdk"></Project>`,
+	})
+
+	units := discover.Find(fs, "root")
+	assert.Equal(t, []string{"dotnet-solution:Api@src/Api"}, names(units))
+	assert.Equal(t, "src/Api", units[0].Config["dotnet.submodule_dir"])
+}
+
+func TestFind_PythonProjects(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	writeFiles(fs, map[string]string{
+		"services/ml/requirements.txt": "flask",
+		"bot/pyproject.toml":           "",
+		".venv/pyproject.toml":         "",
+		"node_modules/x/Pipfile":       "",
+	})
+
+	units := discover.Find(fs, "root")
+	assert.ElementsMatch(t, []string{
+		"python-subproject:ml@services/ml",
+		"python-subproject:bot@bot",
+	}, names(units))
+	for _, u := range units {
+		assert.Equal(t, u.Path, u.Subpath)
+	}
+}
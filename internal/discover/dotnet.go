@@ -0,0 +1,57 @@
+package discover
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+// slnProjectPattern matches the project line of a .sln file, for example,
+// Project("{FAE04EC0-301F-11D3-BF4B-00C04F79EFBC}") = "Api", "src\Api\Api.csproj", "{…}"
+var slnProjectPattern = regexp.MustCompile(`(?m)^Project\("[^"]*"\)\s*=\s*"([^"]+)",\s*"([^"]+\.csproj)"`)
+
+// csprojExecutablePattern matches the SDK or the output type of an executable project.
+var csprojExecutablePattern = regexp.MustCompile(`Microsoft\.NET\.Sdk\.(Web|BlazorWebAssembly|Worker)|<OutputType>\s*(Exe|WinExe)\s*</OutputType>`)
+
+// findDotnetSolution finds the executable projects in the .sln files of the root.
+func findDotnetSolution(fs afero.Fs) []Unit {
+	solutions, err := afero.Glob(fs, "*.sln")
+	if err != nil {
+		return nil
+	}
+
+	var units []Unit
+	for _, solution := range solutions {
+		content, err := afero.ReadFile(fs, solution)
+		if err != nil {
+			continue
+		}
+
+		for _, match := range slnProjectPattern.FindAllStringSubmatch(string(content), -1) {
+			projectFile := path.Clean(strings.ReplaceAll(match[2], `\`, "/"))
+
+			project, err := afero.ReadFile(fs, projectFile)
+			if err != nil || !csprojExecutablePattern.Match(project) {
+				continue
+			}
+
+			name := strings.TrimSuffix(path.Base(projectFile), ".csproj")
+			dir := path.Dir(projectFile)
+			units = append(units, Unit{
+				Name: name,
+				Path: dir,
+				Kind: KindDotnetSolution,
+				Config: map[string]any{
+					plan.ConfigKeyPlanType: string(types.PlanTypeDotnet),
+					"dotnet.submodule_dir": dir,
+				},
+			})
+		}
+	}
+
+	return units
+}
@@ -0,0 +1,74 @@
+package discover
+
+import (
+	"bytes"
+	"path"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/zeabur/zbpack/internal/golang"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+// findGoCommands finds the main packages in "cmd/*" of a Go module.
+func findGoCommands(fs afero.Fs) []Unit {
+	if _, err := fs.Stat("go.mod"); err != nil {
+		return nil
+	}
+
+	entries, err := afero.ReadDir(fs, "cmd")
+	if err != nil {
+		return nil
+	}
+
+	var units []Unit
+	for _, entry := range entries {
+		dir := path.Join("cmd", entry.Name())
+		if !entry.IsDir() || !isGoMainPackage(fs, dir) {
+			continue
+		}
+
+		units = append(units, Unit{
+			Name: entry.Name(),
+			Path: dir,
+			Kind: KindGoCommand,
+			Config: map[string]any{
+				plan.ConfigKeyPlanType: string(types.PlanTypeGo),
+				// build the whole package instead of cmd/<name>/main.go only
+				golang.ConfigGoEntry: "./" + dir,
+			},
+		})
+	}
+
+	return units
+}
+
+// isGoMainPackage checks if the directory contains a Go file of package main.
+func isGoMainPackage(fs afero.Fs, dir string) bool {
+	files, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		return false
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".go") || strings.HasSuffix(file.Name(), "_test.go") {
+			continue
+		}
+
+		content, err := afero.ReadFile(fs, path.Join(dir, file.Name()))
+		if err != nil {
+			continue
+		}
+		for _, line := range bytes.Split(content, []byte("\n")) {
+			if fields := strings.Fields(string(line)); len(fields) >= 2 && fields[0] == "package" {
+				if fields[1] == "main" {
+					return true
+				}
+				break
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,114 @@
+package discover
+
+import (
+	"encoding/json"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/spf13/afero"
+	"github.com/zeabur/zbpack/internal/nodejs"
+)
+
+// findPnpmWorkspace finds the packages in pnpm-workspace.yaml.
+func findPnpmWorkspace(fs afero.Fs) []Unit {
+	content, err := afero.ReadFile(fs, "pnpm-workspace.yaml")
+	if err != nil {
+		return nil
+	}
+
+	var workspace struct {
+		Packages []string `yaml:"packages"`
+	}
+	if err := yaml.Unmarshal(content, &workspace); err != nil {
+		log.Printf("discover: parse pnpm-workspace.yaml: %v\n", err)
+		return nil
+	}
+
+	return nodeUnits(fs, globDirs(fs, workspace.Packages), KindPnpmWorkspace)
+}
+
+// findYarnWorkspace finds the packages in the "workspaces" of package.json,
+// which is used by yarn, npm and bun.
+func findYarnWorkspace(fs afero.Fs) []Unit {
+	if _, err := fs.Stat("pnpm-workspace.yaml"); err == nil {
+		return nil
+	}
+
+	content, err := afero.ReadFile(fs, "package.json")
+	if err != nil {
+		return nil
+	}
+
+	// "workspaces" is either a list of globs, or {"packages": [globs]}.
+	var packageJSON struct {
+		Workspaces json.RawMessage `json:"workspaces"`
+	}
+	if err := json.Unmarshal(content, &packageJSON); err != nil || len(packageJSON.Workspaces) == 0 {
+		return nil
+	}
+
+	var globs []string
+	if err := json.Unmarshal(packageJSON.Workspaces, &globs); err != nil {
+		var workspaces struct {
+			Packages []string `json:"packages"`
+		}
+		if err := json.Unmarshal(packageJSON.Workspaces, &workspaces); err != nil {
+			log.Printf("discover: parse workspaces of package.json: %v\n", err)
+			return nil
+		}
+		globs = workspaces.Packages
+	}
+
+	return nodeUnits(fs, globDirs(fs, globs), KindYarnWorkspace)
+}
+
+// nodeUnits returns the workspace packages in dirs which are applications:
+// the ones with a "start" script, or a "build" script but neither "main"
+// nor "exports", which are usually declared by the libraries.
+// They are planned in the root with "app_dir", so the dependencies of
+// the whole workspace are installed.
+func nodeUnits(fs afero.Fs, dirs []string, kind Kind) []Unit {
+	var units []Unit
+
+	for _, dir := range dirs {
+		content, err := afero.ReadFile(fs, path.Join(dir, "package.json"))
+		if err != nil {
+			continue
+		}
+
+		var packageJSON struct {
+			Name    string            `json:"name"`
+			Main    string            `json:"main"`
+			Exports json.RawMessage   `json:"exports"`
+			Scripts map[string]string `json:"scripts"`
+		}
+		if err := json.Unmarshal(content, &packageJSON); err != nil {
+			log.Printf("discover: parse %s/package.json: %v\n", dir, err)
+			continue
+		}
+		isLibrary := packageJSON.Main != "" || len(packageJSON.Exports) > 0
+		if packageJSON.Scripts["start"] == "" && (packageJSON.Scripts["build"] == "" || isLibrary) {
+			continue
+		}
+
+		// "@scope/web" -> "web"
+		name := packageJSON.Name
+		if _, unscoped, found := strings.Cut(name, "/"); found {
+			name = unscoped
+		}
+		if name == "" {
+			name = path.Base(dir)
+		}
+
+		units = append(units, Unit{
+			Name:   name,
+			Path:   dir,
+			Kind:   kind,
+			Config: map[string]any{nodejs.ConfigAppDir: dir},
+		})
+	}
+
+	return units
+}
@@ -0,0 +1,49 @@
+package discover
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/zeabur/zbpack/internal/utils"
+)
+
+// pythonManifests are the files indicating a Python project.
+var pythonManifests = []string{"pyproject.toml", "requirements.txt", "Pipfile"}
+
+// pythonSkippedDirs are not searched for Python subprojects.
+var pythonSkippedDirs = []string{"node_modules", "venv", "site-packages", "__pycache__"}
+
+// findPythonProjects finds the Python projects in the subdirectories,
+// up to two levels deep. They are planned in their own directory.
+func findPythonProjects(fs afero.Fs) []Unit {
+	var units []Unit
+
+	var walk func(dir string, depth int)
+	walk = func(dir string, depth int) {
+		entries, err := afero.ReadDir(fs, dir)
+		if err != nil {
+			return
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.IsDir() || strings.HasPrefix(name, ".") || slices.Contains(pythonSkippedDirs, name) {
+				continue
+			}
+
+			sub := path.Join(dir, name)
+			if utils.HasFile(afero.NewBasePathFs(fs, sub), pythonManifests...) {
+				units = append(units, Unit{Name: name, Path: sub, Kind: KindPythonProject, Subpath: sub})
+				continue
+			}
+			if depth < 2 {
+				walk(sub, depth+1)
+			}
+		}
+	}
+	walk(".", 1)
+
+	return units
+}
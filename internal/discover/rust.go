@@ -0,0 +1,81 @@
+package discover
+
+import (
+	"log"
+	"path"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/afero"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+type cargoManifest struct {
+	Package struct {
+		Name string `toml:"name"`
+	} `toml:"package"`
+	Workspace struct {
+		Members []string `toml:"members"`
+		Exclude []string `toml:"exclude"`
+	} `toml:"workspace"`
+	Bin []struct {
+		Name string `toml:"name"`
+	} `toml:"bin"`
+}
+
+func readCargoManifest(fs afero.Fs, name string) (cargoManifest, error) {
+	var manifest cargoManifest
+
+	content, err := afero.ReadFile(fs, name)
+	if err != nil {
+		return manifest, err
+	}
+
+	err = toml.Unmarshal(content, &manifest)
+	return manifest, err
+}
+
+// findCargoWorkspace finds the binaries of the members in a Cargo workspace.
+// They are planned in the root with the binary name as the submodule name,
+// so the whole workspace is built.
+func findCargoWorkspace(fs afero.Fs) []Unit {
+	root, err := readCargoManifest(fs, "Cargo.toml")
+	if err != nil || len(root.Workspace.Members) == 0 {
+		return nil
+	}
+
+	patterns := root.Workspace.Members
+	for _, exclude := range root.Workspace.Exclude {
+		patterns = append(patterns, "!"+exclude)
+	}
+
+	var units []Unit
+	for _, dir := range globDirs(fs, patterns) {
+		manifest, err := readCargoManifest(fs, path.Join(dir, "Cargo.toml"))
+		if err != nil {
+			log.Printf("discover: read %s/Cargo.toml: %v\n", dir, err)
+			continue
+		}
+
+		var bins []string
+		for _, bin := range manifest.Bin {
+			bins = append(bins, bin.Name)
+		}
+		if len(bins) == 0 && manifest.Package.Name != "" {
+			if _, err := fs.Stat(path.Join(dir, "src", "main.rs")); err == nil {
+				bins = append(bins, manifest.Package.Name)
+			}
+		}
+
+		for _, bin := range bins {
+			units = append(units, Unit{
+				Name:   bin,
+				Path:   dir,
+				Kind:   KindCargoWorkspace,
+				Config: map[string]any{plan.ConfigKeyPlanType: string(types.PlanTypeRust)},
+			})
+		}
+	}
+
+	return units
+}
@@ -7,7 +7,13 @@ import (
 
 // GenerateDockerfile generates the Dockerfile for static files.
 func GenerateDockerfile(meta types.PlanMeta) (string, error) {
-	return meta["content"], nil
+	return meta[metaContent], nil
+}
+
+// GenerateDockerfileFromBuildPlan returns the Dockerfile of the typed
+// build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return bp.Extra[metaContent], nil
 }
 
 type pack struct {
@@ -25,4 +31,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.V2 = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.V2              = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
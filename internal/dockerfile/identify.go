@@ -40,3 +40,12 @@ func (i *identify) Match(ctx plan.MatchContext) bool {
 func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
 	return GetMeta(options)
 }
+
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
+	return GetBuildPlan(options)
+}
+
+var (
+	_ plan.IdentifierV2        = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
@@ -34,8 +34,16 @@ func ReadDockerfile(ctx *dockerfilePlanContext) ([]byte, error) {
 	return content, nil
 }
 
+// metaContent is the PlanMeta key of the content of the Dockerfile.
+const metaContent = "content"
+
 // GetMeta gets the meta of the Dockerfile project.
 func GetMeta(opt plan.NewPlannerOptions) types.PlanMeta {
+	return GetBuildPlan(opt).PlanMeta()
+}
+
+// GetBuildPlan gets the typed build plan of the Dockerfile project.
+func GetBuildPlan(opt plan.NewPlannerOptions) types.BuildPlan {
 	ctx := &dockerfilePlanContext{
 		NewPlannerOptions: opt,
 	}
@@ -43,11 +51,14 @@ func GetMeta(opt plan.NewPlannerOptions) types.PlanMeta {
 	dockerfileContent, err := ReadDockerfile(ctx)
 	if err != nil {
 		log.Printf("read dockerfile: %s", err)
-		return plan.Continue()
+		return plan.ContinueBuildPlan()
 	}
 
-	meta := types.PlanMeta{
-		"content": string(dockerfileContent),
+	return types.BuildPlan{
+		Version: types.BuildPlanVersion,
+		Type:    types.PlanTypeDocker,
+		Extra: types.PlanMeta{
+			metaContent: string(dockerfileContent),
+		},
 	}
-	return meta
 }
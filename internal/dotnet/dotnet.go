@@ -17,6 +17,13 @@ type TemplateContext struct {
 	Out          string
 	Static       bool
 	SubmoduleDir string
+
+	// CacheMounts is a flag to mount the NuGet packages as the BuildKit
+	// cache in dotnet restore and dotnet publish.
+	CacheMounts bool
+
+	// Hardening is a flag to run the application as a non-root user.
+	Hardening bool
 }
 
 //go:embed templates
@@ -37,13 +44,21 @@ func (c TemplateContext) Execute() (string, error) {
 
 // GenerateDockerfile generates the Dockerfile for Dotnet projects.
 func GenerateDockerfile(meta types.PlanMeta) (string, error) {
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypeDotnet, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for Dotnet
+// projects from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
 	context := TemplateContext{
-		DotnetVer:    meta["sdk"],
-		Out:          strings.TrimSuffix(meta["entryPoint"], ".csproj"),
-		SubmoduleDir: meta["submoduleDir"],
+		DotnetVer:    bp.RuntimeVersion,
+		Out:          strings.TrimSuffix(bp.Entry, ".csproj"),
+		SubmoduleDir: bp.AppDir,
+		CacheMounts:  bp.Flag(types.FlagCacheMounts),
+		Hardening:    bp.Flag(types.FlagHardening),
 	}
 
-	if framework := meta["framework"]; framework == "blazorwasm" {
+	if framework := bp.Framework; framework == "blazorwasm" {
 		context.Static = true
 	} else {
 		context.Static = false
@@ -67,4 +82,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
@@ -47,3 +47,30 @@ func TestGenerateDockerFile_Valid(t *testing.T) {
 		assert.Equal(t, contains(dockerfile, test.s), test.expected)
 	}
 }
+
+func TestGenerateDockerFile_CacheMounts(t *testing.T) {
+	planMeta := types.PlanMeta{
+		"sdk":         "7.0",
+		"entryPoint":  "dotnetapp",
+		"cacheMounts": "true",
+	}
+
+	dockerfile, err := GenerateDockerfile(planMeta)
+	assert.NoError(t, err)
+
+	assert.Contains(t, dockerfile, "RUN --mount=type=cache,target=/root/.nuget/packages dotnet restore")
+	assert.Contains(t, dockerfile, "RUN --mount=type=cache,target=/root/.nuget/packages dotnet publish -c release -o /app")
+}
+
+func TestGenerateDockerFile_Hardening(t *testing.T) {
+	planMeta := types.PlanMeta{
+		"sdk":        "8.0",
+		"entryPoint": "dotnetapp",
+		"hardening":  "true",
+	}
+
+	dockerfile, err := GenerateDockerfile(planMeta)
+	assert.NoError(t, err)
+
+	assert.Contains(t, dockerfile, "RUN id -u app >/dev/null 2>&1 || useradd --uid 10001 app\nUSER app\nCMD")
+}
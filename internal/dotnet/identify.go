@@ -80,10 +80,14 @@ func (i *identify) findEntryPoint(
 }
 
 func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
+	return i.BuildPlan(options).PlanMeta()
+}
+
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
 	submoduleDir, entryPoint, err := i.findEntryPoint(options.Source, options.Config, options.SubmoduleName)
 	if err != nil {
 		log.Printf("failed to find entrypoint: %s", err)
-		return plan.Continue()
+		return plan.ContinueBuildPlan()
 	}
 
 	moduleFs := options.Source
@@ -94,21 +98,29 @@ func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
 	sdkVer, err := DetermineSDKVersion(entryPoint, moduleFs)
 	if err != nil {
 		log.Printf("failed to get sdk version: %s", err)
-		return plan.Continue()
+		return plan.ContinueBuildPlan()
 	}
 
 	framework, err := DetermineFramework(entryPoint, moduleFs)
 	if err != nil {
 		log.Printf("failed to get framework: %s", err)
-		return plan.Continue()
+		return plan.ContinueBuildPlan()
 	}
 
-	return types.PlanMeta{
-		"sdk":          sdkVer,
-		"entryPoint":   entryPoint,
-		"submoduleDir": submoduleDir,
-		"framework":    framework,
+	bp := types.BuildPlan{
+		Version:        types.BuildPlanVersion,
+		Type:           types.PlanTypeDotnet,
+		RuntimeVersion: sdkVer,
+		Entry:          entryPoint,
+		AppDir:         submoduleDir,
+		Framework:      framework,
 	}
+	bp.KeepKeys("sdk", "entryPoint", "submoduleDir", "framework")
+
+	return bp
 }
 
-var _ plan.Identifier = (*identify)(nil)
+var (
+	_ plan.Identifier          = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
@@ -4,7 +4,6 @@ package elixir
 import (
 	"bytes"
 	"embed"
-	"strconv"
 	"text/template"
 
 	"github.com/zeabur/zbpack/pkg/packer"
@@ -36,17 +35,23 @@ func (c TemplateContext) Execute() (string, error) {
 
 // GenerateDockerfile generates the Dockerfile for Elixir projects.
 func GenerateDockerfile(meta types.PlanMeta) (string, error) {
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypeElixir, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for Elixir
+// projects from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
 	context := TemplateContext{
-		ElixirVer: meta["ver"],
+		ElixirVer: bp.RuntimeVersion,
 	}
 
-	if ElixirFramework := meta["framework"]; ElixirFramework == "phoenix" {
+	if ElixirFramework := bp.Framework; ElixirFramework == "phoenix" {
 		context.ElixirPhoenix = true
 	} else {
 		context.ElixirPhoenix = false
 	}
 
-	if usesEcto, _ := strconv.ParseBool(meta["ecto"]); usesEcto {
+	if bp.Flag(FlagEcto) {
 		context.ElixirEcto = true
 	} else {
 		context.ElixirEcto = false
@@ -70,4 +75,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
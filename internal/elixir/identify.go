@@ -1,6 +1,8 @@
 package elixir
 
 import (
+	"strconv"
+
 	"github.com/spf13/afero"
 
 	"github.com/zeabur/zbpack/internal/utils"
@@ -25,6 +27,10 @@ func (i *identify) Match(fs afero.Fs) bool {
 }
 
 func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
+	return i.BuildPlan(options).PlanMeta()
+}
+
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
 	ElixirVer, err := DetermineElixirVersion(options.Source)
 	if err != nil {
 		panic(err)
@@ -40,11 +46,20 @@ func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
 		panic(err)
 	}
 
-	return types.PlanMeta{
-		"ver":       ElixirVer,
-		"framework": ElixirFramework,
-		"ecto":      ElixirEcto,
+	bp := types.BuildPlan{
+		Version:        types.BuildPlanVersion,
+		Type:           types.PlanTypeElixir,
+		RuntimeVersion: ElixirVer,
+		Framework:      ElixirFramework,
 	}
+	usesEcto, _ := strconv.ParseBool(ElixirEcto)
+	bp.SetFlag(FlagEcto, usesEcto)
+	bp.KeepKeys("ver", "framework")
+
+	return bp
 }
 
-var _ plan.Identifier = (*identify)(nil)
+var (
+	_ plan.Identifier          = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
@@ -10,6 +10,9 @@ import (
 	"github.com/zeabur/zbpack/pkg/types"
 )
 
+// FlagEcto is the build plan flag indicating if Ecto with PostgreSQL is used.
+const FlagEcto = "ecto"
+
 var elixirVersions = map[string]string{
 	"1.7":  "1.7",
 	"1.8":  "1.8",
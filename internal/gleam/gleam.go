@@ -7,7 +7,13 @@ import (
 )
 
 // GenerateDockerfile generates the Dockerfile for Gleam projects.
-func GenerateDockerfile(_ types.PlanMeta) (string, error) {
+func GenerateDockerfile(meta types.PlanMeta) (string, error) {
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypeGleam, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for Gleam
+// projects from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
 	dockerfile := `FROM ghcr.io/gleam-lang/gleam:v1.3.2-erlang-alpine
 RUN apk add --no-cache elixir
 RUN mix local.hex --force
@@ -19,7 +25,13 @@ RUN cd /build \
   && rm -r /build
 
 WORKDIR /app
-ENTRYPOINT ["/app/entrypoint.sh"]
+`
+	if bp.Flag(types.FlagHardening) {
+		dockerfile += `RUN adduser -D -H -u 10001 app
+USER app
+`
+	}
+	dockerfile += `ENTRYPOINT ["/app/entrypoint.sh"]
 CMD ["run"]`
 
 	return dockerfile, nil
@@ -40,4 +52,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
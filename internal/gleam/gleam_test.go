@@ -15,3 +15,12 @@ func TestGenerateDockerfile(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Contains(t, dockerfile, "\nWORKDIR /app")
 }
+
+func TestGenerateDockerfile_Hardening(t *testing.T) {
+	t.Parallel()
+
+	dockerfile, err := gleam.GenerateDockerfile(map[string]string{"hardening": "true"})
+
+	assert.NoError(t, err)
+	assert.Contains(t, dockerfile, "RUN adduser -D -H -u 10001 app\nUSER app\nENTRYPOINT")
+}
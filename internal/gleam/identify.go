@@ -24,10 +24,18 @@ func (i *identify) Match(fs afero.Fs) bool {
 	return utils.HasFile(fs, "gleam.toml")
 }
 
-func (i *identify) PlanMeta(_ plan.NewPlannerOptions) types.PlanMeta {
-	meta := types.PlanMeta{}
+func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
+	return i.BuildPlan(options).PlanMeta()
+}
 
-	return meta
+func (i *identify) BuildPlan(_ plan.NewPlannerOptions) types.BuildPlan {
+	return types.BuildPlan{
+		Version: types.BuildPlanVersion,
+		Type:    types.PlanTypeGleam,
+	}
 }
 
-var _ plan.Identifier = (*identify)(nil)
+var (
+	_ plan.Identifier          = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
@@ -2,39 +2,82 @@
 package golang
 
 import (
+	"github.com/zeabur/zbpack/internal/utils"
 	"github.com/zeabur/zbpack/pkg/packer"
 	"github.com/zeabur/zbpack/pkg/types"
 )
 
 // GenerateDockerfile generates the Dockerfile for Golang projects.
 func GenerateDockerfile(meta types.PlanMeta) (string, error) {
-	cgoEnvSegment := "ENV CGO_ENABLED=0\n"
-	if meta["cgo"] == "true" {
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypeGo, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for Golang projects
+// from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	cgo := bp.Flag(FlagCgo)
+
+	// Without cgo, the builder runs on the build platform and
+	// cross-compiles the server to the target platform, which is much
+	// faster than building it in an emulator for multiple platforms.
+	platformSegment := "--platform=$BUILDPLATFORM "
+	cgoEnvSegment := "ENV CGO_ENABLED=0\nARG TARGETOS TARGETARCH\nENV GOOS=$TARGETOS GOARCH=$TARGETARCH\n"
+	if cgo {
+		platformSegment = ""
 		cgoEnvSegment = "ENV CGO_ENABLED=1\n"
 	}
 
 	dependencySegment := ""
-	if meta["cgo"] == "true" {
+	if cgo {
 		dependencySegment = "RUN apk add --no-cache build-base cmake\n"
 	}
 
 	buildCommandSegment := ""
-	if meta["buildCommand"] != "" {
-		buildCommandSegment = `RUN ` + meta["buildCommand"] + "\n"
+	if bp.BuildCommand != "" {
+		buildCommandSegment = `RUN ` + bp.BuildCommand + "\n"
+	}
+
+	downloadSegment := "RUN go mod download\n"
+	goBuildSegment := "RUN go build -o ./bin/server " + bp.Entry
+	if bp.Flag(types.FlagCacheMounts) {
+		// GOMODCACHE and GOCACHE of the golang image
+		downloadSegment = utils.WithCacheMounts(downloadSegment, "/go/pkg/mod")
+		buildCommandSegment = utils.WithCacheMounts(buildCommandSegment, "/go/pkg/mod", "/root/.cache/go-build")
+		goBuildSegment = utils.WithCacheMounts(goBuildSegment, "/go/pkg/mod", "/root/.cache/go-build")
+	}
+
+	// Download the modules before copying the rest of the source,
+	// so they are cached until go.mod or go.sum changes.
+	sourceSegment := "COPY . /src/\n" + downloadSegment
+	if len(bp.DependencyFiles) > 0 {
+		sourceSegment = utils.CopyFiles(bp.DependencyFiles, "/src/") + "\n" + downloadSegment + "COPY . /src/\n"
 	}
 
-	buildStage := `FROM docker.io/library/golang:` + meta["goVersion"] + `-alpine AS builder
+	buildStage := `FROM ` + platformSegment + `docker.io/library/golang:` + bp.RuntimeVersion + `-alpine AS builder
 RUN mkdir /src
 WORKDIR /src
 ` + dependencySegment + `
-COPY . /src/
-RUN go mod download
-` + cgoEnvSegment + buildCommandSegment + `
-RUN go build -o ./bin/server ` + meta["entry"]
+` + sourceSegment + cgoEnvSegment + buildCommandSegment + `
+` + goBuildSegment
 
 	runtimeStage := `FROM alpine AS runtime
 COPY --from=builder /src/bin/server /bin/server
 CMD ["/bin/server"]`
+	if bp.Flag(types.FlagHardening) {
+		// The static server runs in the distroless image as its nonroot
+		// user, unless it links libc or the health check needs a shell.
+		if !cgo && bp.HealthcheckPath == "" {
+			runtimeStage = `FROM gcr.io/distroless/static-debian12:nonroot AS runtime
+COPY --from=builder /src/bin/server /bin/server
+CMD ["/bin/server"]`
+		} else {
+			runtimeStage = `FROM alpine AS runtime
+RUN adduser -D -H -u 10001 app
+COPY --from=builder /src/bin/server /bin/server
+USER app
+CMD ["/bin/server"]`
+		}
+	}
 
 	return buildStage + "\n" + runtimeStage, nil
 }
@@ -54,4 +97,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
@@ -2,14 +2,25 @@ package golang_test
 
 import (
 	"maps"
+	"os"
 	"testing"
 
+	"github.com/gkampitakis/go-snaps/snaps"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zeabur/zbpack/internal/golang"
 	"github.com/zeabur/zbpack/pkg/types"
 )
 
+func TestMain(m *testing.M) {
+	v := m.Run()
+
+	// After all tests have run `go-snaps` will sort snapshots
+	snaps.Clean(m, snaps.CleanOpts{Sort: true})
+
+	os.Exit(v)
+}
+
 func TestGenerateDockerfile_CGO(t *testing.T) {
 	t.Parallel()
 
@@ -90,3 +101,93 @@ func TestGenerateDockerfile_BuildCommand(t *testing.T) {
 		assert.Contains(t, dockerfile, "ENV CGO_ENABLED=1\nRUN go generate ./...\n\nRUN go build -o ./bin/server")
 	})
 }
+
+func TestGenerateDockerfile_CrossCompile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("without cgo", func(t *testing.T) {
+		t.Parallel()
+
+		dockerfile, err := golang.GenerateDockerfile(types.PlanMeta{"goVersion": "1.22", "entry": "main.go"})
+		require.NoError(t, err)
+
+		assert.Contains(t, dockerfile, "FROM --platform=$BUILDPLATFORM docker.io/library/golang:1.22-alpine AS builder")
+		assert.Contains(t, dockerfile, "ENV GOOS=$TARGETOS GOARCH=$TARGETARCH")
+	})
+
+	t.Run("cgo", func(t *testing.T) {
+		t.Parallel()
+
+		dockerfile, err := golang.GenerateDockerfile(types.PlanMeta{"goVersion": "1.22", "entry": "main.go", "cgo": "true"})
+		require.NoError(t, err)
+
+		assert.NotContains(t, dockerfile, "$BUILDPLATFORM")
+		assert.NotContains(t, dockerfile, "GOARCH")
+	})
+}
+
+func TestGenerateDockerfile_CacheMounts(t *testing.T) {
+	t.Parallel()
+
+	dockerfile, err := golang.GenerateDockerfile(types.PlanMeta{"goVersion": "1.22", "entry": "main.go", "cacheMounts": "true"})
+	require.NoError(t, err)
+
+	assert.Contains(t, dockerfile, "RUN --mount=type=cache,target=/go/pkg/mod go mod download")
+	assert.Contains(t, dockerfile, "RUN --mount=type=cache,target=/go/pkg/mod --mount=type=cache,target=/root/.cache/go-build go build -o ./bin/server main.go")
+}
+
+func TestGenerateDockerfile_DependencyFiles(t *testing.T) {
+	t.Parallel()
+
+	t.Run("with go.sum", func(t *testing.T) {
+		t.Parallel()
+
+		dockerfile, err := golang.GenerateDockerfile(types.PlanMeta{"goVersion": "1.22", "entry": "main.go", "dependencyFiles": "go.mod:go.sum"})
+		require.NoError(t, err)
+
+		snaps.MatchSnapshot(t, dockerfile)
+	})
+
+	t.Run("without dependency files", func(t *testing.T) {
+		t.Parallel()
+
+		dockerfile, err := golang.GenerateDockerfile(types.PlanMeta{"goVersion": "1.22", "entry": "main.go"})
+		require.NoError(t, err)
+
+		snaps.MatchSnapshot(t, dockerfile)
+	})
+}
+
+func TestGenerateDockerfile_Hardening(t *testing.T) {
+	t.Parallel()
+
+	t.Run("distroless", func(t *testing.T) {
+		t.Parallel()
+
+		dockerfile, err := golang.GenerateDockerfile(types.PlanMeta{"goVersion": "1.22", "entry": "main.go", "hardening": "true"})
+		require.NoError(t, err)
+
+		assert.Contains(t, dockerfile, "FROM gcr.io/distroless/static-debian12:nonroot AS runtime")
+		assert.NotContains(t, dockerfile, "FROM alpine")
+	})
+
+	t.Run("cgo", func(t *testing.T) {
+		t.Parallel()
+
+		dockerfile, err := golang.GenerateDockerfile(types.PlanMeta{"goVersion": "1.22", "entry": "main.go", "cgo": "true", "hardening": "true"})
+		require.NoError(t, err)
+
+		assert.Contains(t, dockerfile, "FROM alpine AS runtime\nRUN adduser -D -H -u 10001 app\n")
+		assert.Contains(t, dockerfile, "USER app\nCMD [\"/bin/server\"]")
+	})
+
+	t.Run("health check", func(t *testing.T) {
+		t.Parallel()
+
+		dockerfile, err := golang.GenerateDockerfile(types.PlanMeta{"goVersion": "1.22", "entry": "main.go", "hardening": "true", "healthcheckPath": "/healthz"})
+		require.NoError(t, err)
+
+		assert.Contains(t, dockerfile, "FROM alpine AS runtime")
+		assert.Contains(t, dockerfile, "USER app")
+	})
+}
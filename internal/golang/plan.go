@@ -4,7 +4,7 @@ import (
 	"bufio"
 	"os"
 	"path"
-	"strconv"
+	"strings"
 
 	"github.com/moznion/go-optional"
 	"github.com/spf13/afero"
@@ -38,6 +38,9 @@ const (
 	ConfigCgo = "go.cgo"
 )
 
+// FlagCgo is the build plan flag indicating if cgo is enabled.
+const FlagCgo = "cgo"
+
 func getBuildCommand(ctx *goPlanContext) string {
 	if buildCommand, err := plan.Cast(ctx.Config.Get(plan.ConfigBuildCommand), cast.ToStringE).Take(); err == nil {
 		return buildCommand
@@ -119,6 +122,35 @@ func getEntry(ctx *goPlanContext) string {
 	return ""
 }
 
+// getDependencyFiles returns go.mod and go.sum to download the modules
+// before copying the rest of the source. It returns nil if the modules
+// are in a workspace, vendored, or replaced with local directories,
+// which need the rest of the source.
+func getDependencyFiles(ctx *goPlanContext) []string {
+	if utils.HasFile(ctx.Src, "go.work", "vendor/modules.txt") {
+		return nil
+	}
+
+	content, err := afero.ReadFile(ctx.Src, "go.mod")
+	if err != nil {
+		return nil
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		_, replacement, ok := strings.Cut(line, "=>")
+		if !ok {
+			continue
+		}
+
+		replacement = strings.TrimSpace(replacement)
+		if strings.HasPrefix(replacement, ".") || strings.HasPrefix(replacement, "/") {
+			return nil
+		}
+	}
+
+	return append([]string{"go.mod"}, utils.FoundFiles(ctx.Src, "go.sum")...)
+}
+
 // GetMetaOptions is the options for GetMeta.
 type GetMetaOptions struct {
 	Src           afero.Fs
@@ -128,24 +160,27 @@ type GetMetaOptions struct {
 
 // GetMeta gets the metadata of the Go project.
 func GetMeta(opt GetMetaOptions) types.PlanMeta {
+	return GetBuildPlan(opt).PlanMeta()
+}
+
+// GetBuildPlan gets the typed build plan of the Go project.
+func GetBuildPlan(opt GetMetaOptions) types.BuildPlan {
 	ctx := &goPlanContext{
 		Src:           opt.Src,
 		Config:        opt.Config,
 		SubmoduleName: opt.SubmoduleName,
 	}
-	meta := types.PlanMeta{}
 
-	goVersion := getGoVersion(ctx)
-	meta["goVersion"] = goVersion
+	bp := types.BuildPlan{
+		Version:        types.BuildPlanVersion,
+		Type:           types.PlanTypeGo,
+		RuntimeVersion: getGoVersion(ctx),
+		Entry:          getEntry(ctx),
+		BuildCommand:   getBuildCommand(ctx),
 
-	entry := getEntry(ctx)
-	meta["entry"] = entry
-
-	if buildCommand := getBuildCommand(ctx); buildCommand != "" {
-		meta["buildCommand"] = buildCommand
+		DependencyFiles: getDependencyFiles(ctx),
 	}
+	bp.SetFlag(FlagCgo, isCgoEnabled(ctx))
 
-	meta["cgo"] = strconv.FormatBool(isCgoEnabled(ctx))
-
-	return meta
+	return bp
 }
@@ -158,3 +158,59 @@ func TestIsCgoEnabled(t *testing.T) {
 		assert.True(t, isCgoEnabled(ctx))
 	})
 }
+
+func TestGetDependencyFiles(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+	}{
+		{
+			name:     "go.mod and go.sum",
+			files:    map[string]string{"go.mod": "module app\n", "go.sum": ""},
+			expected: []string{"go.mod", "go.sum"},
+		},
+		{
+			name:     "without go.sum",
+			files:    map[string]string{"go.mod": "module app\n"},
+			expected: []string{"go.mod"},
+		},
+		{
+			name:     "remote replacement",
+			files:    map[string]string{"go.mod": "module app\n\nreplace example.com/a => example.com/b v1.0.0\n"},
+			expected: []string{"go.mod"},
+		},
+		{
+			name:  "local replacement",
+			files: map[string]string{"go.mod": "module app\n\nreplace (\n\texample.com/lib => ./lib\n)\n"},
+		},
+		{
+			name:  "workspace",
+			files: map[string]string{"go.mod": "module app\n", "go.work": "go 1.22\n"},
+		},
+		{
+			name:  "vendored",
+			files: map[string]string{"go.mod": "module app\n", "vendor/modules.txt": ""},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := afero.NewMemMapFs()
+			for name, content := range tc.files {
+				require.NoError(t, afero.WriteFile(fs, name, []byte(content), 0o644))
+			}
+
+			ctx := &goPlanContext{
+				Src:    fs,
+				Config: plan.NewProjectConfigurationFromFs(fs, ""),
+			}
+
+			assert.Equal(t, tc.expected, getDependencyFiles(ctx))
+		})
+	}
+}
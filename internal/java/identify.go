@@ -28,24 +28,36 @@ func (i *identify) Match(fs afero.Fs) bool {
 }
 
 func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
+	return i.BuildPlan(options).PlanMeta()
+}
+
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
 	projectType := DetermineProjectType(options.Source)
 	framework := DetermineFramework(projectType, options.Source)
 	jdkVersion := DetermineJDKVersion(projectType, options.Source)
 	targetExt := DetermineTargetExt(options.Source)
 
-	planMeta := types.PlanMeta{
-		"type":      string(projectType),
-		"framework": string(framework),
-		"targetExt": targetExt,
-		"jdk":       jdkVersion,
+	bp := types.BuildPlan{
+		Version:        types.BuildPlanVersion,
+		Type:           types.PlanTypeJava,
+		PackageManager: string(projectType),
+		Framework:      string(framework),
+		RuntimeVersion: jdkVersion,
+		Extra: types.PlanMeta{
+			metaTargetExt: targetExt,
+		},
 	}
 
 	javaArgs := plan.Cast(options.Config.Get("javaArgs"), cast.ToStringE)
 	if args, err := javaArgs.Take(); err == nil {
-		planMeta["javaArgs"] = args
+		bp.SetExtra(metaJavaArgs, args)
 	}
 
-	return planMeta
+	bp.KeepKeys("type", "framework", "jdk")
+	return bp
 }
 
-var _ plan.Identifier = (*identify)(nil)
+var (
+	_ plan.Identifier          = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
@@ -2,22 +2,38 @@
 package java
 
 import (
+	"github.com/samber/lo"
+	"github.com/zeabur/zbpack/internal/utils"
 	"github.com/zeabur/zbpack/pkg/packer"
 	"github.com/zeabur/zbpack/pkg/types"
 )
 
 // GenerateDockerfile generates the Dockerfile for Java projects.
 func GenerateDockerfile(meta types.PlanMeta) (string, error) {
-	projectType := meta["type"]
-	framework := meta["framework"]
-	jdkVersion := meta["jdk"]
-	targetExt := meta["targetExt"]
-	javaArgs := meta["javaArgs"]
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypeJava, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for Java
+// projects from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	projectType := bp.PackageManager
+	framework := bp.Framework
+	jdkVersion := bp.RuntimeVersion
+	targetExt := bp.Extra[metaTargetExt]
+	javaArgs := bp.Extra[metaJavaArgs]
 
 	isMaven := projectType == string(types.JavaProjectTypeMaven)
 	isGradle := projectType == string(types.JavaProjectTypeGradle)
 	isSpringBoot := framework == string(types.JavaFrameworkSpringBoot)
 
+	mavenBuildCmd := "RUN mvn clean dependency:list install -Dmaven.test.skip=true"
+	gradleBuildCmd := "RUN gradle build -x test"
+	if bp.Flag(types.FlagCacheMounts) {
+		// ~/.gradle itself has gradle.properties written below
+		mavenBuildCmd = utils.WithCacheMounts(mavenBuildCmd, "/root/.m2/repository")
+		gradleBuildCmd = utils.WithCacheMounts(gradleBuildCmd, "/root/.gradle/caches", "/root/.gradle/wrapper")
+	}
+
 	var dockerfile string
 	baseImage := "docker.io/library/eclipse-temurin:" + jdkVersion
 
@@ -32,7 +48,7 @@ RUN --mount=type=cache,target=/var/cache/apt,sharing=locked \
 		ca-certificates-java
 WORKDIR /src
 COPY . .
-RUN mvn clean dependency:list install -Dmaven.test.skip=true
+` + mavenBuildCmd + `
 `
 	case string(types.JavaProjectTypeGradle):
 		dockerfile += `FROM ` + baseImage + `
@@ -65,8 +81,24 @@ RUN mkdir -p ~/.gradle && echo "org.gradle.daemon=false" >> ~/.gradle/gradle.pro
 
 WORKDIR /src
 COPY . .
-RUN gradle build -x test
+` + gradleBuildCmd + `
+`
+	}
 
+	// The output of the build is run in the JRE image as a non-root
+	// user if hardening, or the JDK image for Gradle.
+	outputDir := lo.Ternary(isMaven, "/src/target", "/src/build")
+	switch {
+	case bp.Flag(types.FlagHardening) && (isMaven || isGradle):
+		dockerfile += `
+FROM ` + baseImage + `-jre
+RUN useradd --uid 10001 app
+WORKDIR /src
+COPY --from=0 ` + outputDir + ` ` + outputDir + `
+USER app
+`
+	case isGradle:
+		dockerfile += `
 FROM ` + baseImage + `
 WORKDIR /src
 COPY --from=0 /src/build /src/build
@@ -109,4 +141,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
@@ -9,6 +9,14 @@ import (
 	"github.com/zeabur/zbpack/pkg/types"
 )
 
+// The PlanMeta keys of the Java projects which have no typed field.
+const (
+	// metaTargetExt is the extension of the built archive, "jar" or "war".
+	metaTargetExt = "targetExt"
+	// metaJavaArgs is the arguments of java to start the application.
+	metaJavaArgs = "javaArgs"
+)
+
 // DetermineProjectType determines the project type of the Java project.
 func DetermineProjectType(src afero.Fs) types.JavaProjectType {
 	if utils.HasFile(src, "pom.xml", "pom.yml", "pom.yaml") {
@@ -7,6 +7,7 @@ import (
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/zeabur/zbpack/internal/java"
+	"github.com/zeabur/zbpack/pkg/types"
 )
 
 func TestDetermineTargetExt_Unsupported(t *testing.T) {
@@ -157,3 +158,17 @@ func TestDetermineTargetExt_MavenNotWar(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateDockerfile_Hardening(t *testing.T) {
+	dockerfile, err := java.GenerateDockerfile(types.PlanMeta{
+		"type":      string(types.JavaProjectTypeMaven),
+		"framework": string(types.JavaFrameworkSpringBoot),
+		"jdk":       "17",
+		"targetExt": "jar",
+		"hardening": "true",
+	})
+	assert.NoError(t, err)
+
+	assert.Contains(t, dockerfile, "FROM docker.io/library/eclipse-temurin:17-jre\nRUN useradd --uid 10001 app\n")
+	assert.Contains(t, dockerfile, "COPY --from=0 /src/target /src/target\nUSER app\nCMD java -Dserver.port=$PORT -jar target/*.jar")
+}
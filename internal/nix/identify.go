@@ -50,28 +50,37 @@ func (i *identify) Match(fs afero.Fs) bool {
 }
 
 func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
+	return i.BuildPlan(options).PlanMeta()
+}
+
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
 	arch := lo.If(runtime.GOARCH == "amd64", "x86_64").ElseIf(runtime.GOARCH == "arm64", "aarch64").Else("x86_64")
 
 	packageName, err := plan.Cast(options.Config.Get(ConfigNixDockerPackage), cast.ToStringE).Take()
 	if err != nil {
 		content, err := afero.ReadFile(options.Source, "flake.nix")
 		if err != nil {
-			return plan.Continue()
+			return plan.ContinueBuildPlan()
 		}
 
 		packageName = FindPossibleNixDockerPackage(string(content))
 		if packageName == "" {
 			log.Println("warning: no default package found; skipping Nix planner")
-			return plan.Continue()
+			return plan.ContinueBuildPlan()
 		}
 
 		// Add x86_64 prefix :D
 		packageName = "packages." + arch + "-linux." + packageName
 	}
 
-	return types.PlanMeta{
-		"package": packageName,
+	bp := types.BuildPlan{
+		Version: types.BuildPlanVersion,
+		Type:    types.PlanTypeNix,
+		Entry:   packageName,
 	}
+	bp.KeepKeys("package")
+
+	return bp
 }
 
 // FindPossibleNixDockerPackage finds the possible Nix package name for Docker.
@@ -87,4 +96,7 @@ func FindPossibleNixDockerPackage(content string) string {
 	return strings.TrimSpace(group[1])
 }
 
-var _ plan.Identifier = (*identify)(nil)
+var (
+	_ plan.Identifier          = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
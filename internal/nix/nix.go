@@ -4,6 +4,7 @@ package nix
 import (
 	"bytes"
 	_ "embed"
+	"regexp"
 	"text/template"
 
 	"github.com/zeabur/zbpack/pkg/packer"
@@ -13,10 +14,26 @@ import (
 //go:embed Dockerfile.tmpl
 var tmplRaw string
 
+// systemPackageRegex matches the package of a Linux system,
+// for example, "packages.x86_64-linux.docker".
+var systemPackageRegex = regexp.MustCompile(`^packages\.((?:x86_64|aarch64)-linux)\.(.+)$`)
+
 // GenerateDockerfile generates the Dockerfile for Nix projects.
 func GenerateDockerfile(meta types.PlanMeta) (string, error) {
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypeNix, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for Nix
+// projects from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
 	type TemplateContext struct {
 		Package string
+
+		// System and Attribute are set if Package is a package of
+		// a Linux system, for example, "packages.x86_64-linux.docker",
+		// so that the package of the target platform is built.
+		System    string
+		Attribute string
 	}
 
 	tmpl, err := template.New("Dockerfile").Parse(tmplRaw)
@@ -26,9 +43,15 @@ func GenerateDockerfile(meta types.PlanMeta) (string, error) {
 
 	out := new(bytes.Buffer)
 
-	err = tmpl.Execute(out, TemplateContext{
-		Package: meta["package"],
-	})
+	context := TemplateContext{
+		Package: bp.Entry,
+	}
+	if group := systemPackageRegex.FindStringSubmatch(context.Package); group != nil {
+		context.System = group[1]
+		context.Attribute = group[2]
+	}
+
+	err = tmpl.Execute(out, context)
 	if err != nil {
 		return "", err
 	}
@@ -51,4 +74,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
@@ -0,0 +1,31 @@
+package nix_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeabur/zbpack/internal/nix"
+)
+
+func TestGenerateDockerfile_TargetPlatform(t *testing.T) {
+	t.Parallel()
+
+	dockerfile, err := nix.GenerateDockerfile(map[string]string{"package": "packages.x86_64-linux.docker"})
+	require.NoError(t, err)
+
+	assert.Contains(t, dockerfile, "ARG TARGETARCH")
+	assert.Contains(t, dockerfile, "arm64) system=aarch64-linux ;;")
+	assert.Contains(t, dockerfile, "*) system=x86_64-linux ;;")
+	assert.Contains(t, dockerfile, `build ".#packages.${system}.docker"`)
+}
+
+func TestGenerateDockerfile_CustomPackage(t *testing.T) {
+	t.Parallel()
+
+	dockerfile, err := nix.GenerateDockerfile(map[string]string{"package": "legacyPackages.docker"})
+	require.NoError(t, err)
+
+	assert.NotContains(t, dockerfile, "TARGETARCH")
+	assert.Contains(t, dockerfile, "build .#legacyPackages.docker")
+}
@@ -1,6 +1,8 @@
 package nodejs
 
 import (
+	"strings"
+
 	"github.com/spf13/afero"
 
 	"github.com/zeabur/zbpack/internal/utils"
@@ -23,6 +25,23 @@ func (i *identify) Match(fs afero.Fs) bool {
 	return utils.HasFile(fs, "package.json")
 }
 
+// MatchScore is more confident when the lockfile of a package manager
+// or the entry point of a Node.js application is found.
+func (i *identify) MatchScore(ctx plan.MatchContext) plan.MatchResult {
+	result := plan.MatchResult{Score: 0.5, Reasons: []string{"found package.json"}}
+
+	if found := utils.FoundFiles(ctx.Source, "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "bun.lockb", "bun.lock"); len(found) > 0 {
+		result.Score += 0.3
+		result.Reasons = append(result.Reasons, "found lockfile "+strings.Join(found, ", "))
+	}
+	if found := utils.FoundFiles(ctx.Source, "index.js", "server.js", "main.js", "app.js", "index.ts"); len(found) > 0 {
+		result.Score += 0.2
+		result.Reasons = append(result.Reasons, "found entry point "+strings.Join(found, ", "))
+	}
+
+	return result
+}
+
 func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
 	return GetMeta(
 		GetMetaOptions{
@@ -32,4 +51,17 @@ func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
 	)
 }
 
-var _ plan.Identifier = (*identify)(nil)
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
+	return GetBuildPlan(
+		GetMetaOptions{
+			Src:    options.Source,
+			Config: options.Config,
+		},
+	)
+}
+
+var (
+	_ plan.Identifier          = (*identify)(nil)
+	_ plan.ScoredIdentifier    = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"text/template"
 
+	"github.com/zeabur/zbpack/internal/utils"
 	"github.com/zeabur/zbpack/pkg/packer"
 	"github.com/zeabur/zbpack/pkg/types"
 )
@@ -24,6 +25,16 @@ type TemplateContext struct {
 
 	Framework string
 	OutputDir string
+
+	// DependencyFiles is the files to copy before InstallCmd.
+	// If it is empty, the whole source is copied before InstallCmd.
+	DependencyFiles []string
+
+	// Hardening runs the server in a slim runtime stage as the node user.
+	Hardening bool
+	// WorkDir is the working directory of the server in the runtime
+	// stage of Hardening, which is the last WORKDIR of InstallCmd, or "/src".
+	WorkDir string
 }
 
 //go:embed templates
@@ -34,6 +45,7 @@ var tmpl = template.Must(
 		Funcs(template.FuncMap{
 			"prefixed": strings.HasPrefix,
 			"isNitro":  types.IsNitroBasedFramework,
+			"copy":     utils.CopyFiles,
 		}).
 		ParseFS(tmplFs, "templates/*"),
 )
@@ -47,25 +59,64 @@ func (c TemplateContext) Execute() (string, error) {
 }
 
 func getContextBasedOnMeta(meta types.PlanMeta) TemplateContext {
+	return getContextBasedOnBuildPlan(types.NewBuildPlan(types.PlanTypeNodejs, meta))
+}
+
+func getContextBasedOnBuildPlan(bp types.BuildPlan) TemplateContext {
+	// The runtime version of a Bun plan is the version of Bun.
+	nodeVersion := bp.RuntimeVersion
+	if bp.Type == types.PlanTypeBun {
+		nodeVersion = bp.Extra[metaNodeVersion]
+	}
+
 	context := TemplateContext{
-		NodeVersion: meta["nodeVersion"],
-		AppDir:      meta["appDir"],
-		InitCmd:     meta["initCmd"],
-		InstallCmd:  meta["installCmd"],
-		BuildCmd:    meta["buildCmd"],
-		StartCmd:    meta["startCmd"],
-		Framework:   meta["framework"],
-		OutputDir:   meta["outputDir"],
+		NodeVersion: nodeVersion,
+		AppDir:      bp.AppDir,
+		InitCmd:     bp.Extra[metaInitCmd],
+		InstallCmd:  bp.InstallCommand,
+		BuildCmd:    bp.BuildCommand,
+		StartCmd:    bp.StartCommand,
+		Framework:   bp.Framework,
+		OutputDir:   bp.OutputDir,
+
+		DependencyFiles: bp.DependencyFiles,
+	}
+
+	if bp.Flag(types.FlagHardening) {
+		context.Hardening = true
+		context.WorkDir = getWorkDir(context.InstallCmd)
+	}
+
+	if bp.Flag(types.FlagCacheMounts) {
+		cacheDirs := getCacheDirs(types.NodePackageManager(bp.PackageManager))
+		context.InstallCmd = utils.WithCacheMounts(context.InstallCmd, cacheDirs...)
 	}
 
 	return context
 }
 
+// getWorkDir returns the last WORKDIR of the instructions, or "/src".
+func getWorkDir(instructions string) string {
+	workDir := "/src"
+	for _, line := range strings.Split(instructions, "\n") {
+		if dir, ok := strings.CutPrefix(line, "WORKDIR "); ok {
+			workDir = strings.TrimSpace(dir)
+		}
+	}
+	return workDir
+}
+
 // GenerateDockerfile generates the Dockerfile for Node.js projects.
 func GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return getContextBasedOnMeta(meta).Execute()
 }
 
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for Node.js
+// and Bun projects from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return getContextBasedOnBuildPlan(bp).Execute()
+}
+
 type pack struct {
 	*identify
 }
@@ -81,4 +132,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
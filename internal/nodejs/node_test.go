@@ -63,3 +63,27 @@ func TestGetContextBasedOnMeta_WithOutputdirAndMPAFramework(t *testing.T) {
 		OutputDir:   "dist",
 	})
 }
+
+func TestGetContextBasedOnMeta_CacheMounts(t *testing.T) {
+	meta := getContextBasedOnMeta(types.PlanMeta{
+		"nodeVersion":    "20",
+		"packageManager": "pnpm",
+		"installCmd":     "WORKDIR /src/apps/web\nRUN pnpm install",
+		"startCmd":       "pnpm start",
+		"cacheMounts":    "true",
+	})
+
+	assert.Equal(t, "WORKDIR /src/apps/web\nRUN --mount=type=cache,target=/root/.local/share/pnpm/store pnpm install", meta.InstallCmd)
+}
+
+func TestGetContextBasedOnMeta_Hardening(t *testing.T) {
+	meta := getContextBasedOnMeta(types.PlanMeta{
+		"nodeVersion": "20",
+		"installCmd":  "WORKDIR /src/apps/web\nRUN pnpm install",
+		"startCmd":    "pnpm start",
+		"hardening":   "true",
+	})
+
+	assert.True(t, meta.Hardening)
+	assert.Equal(t, "/src/apps/web", meta.WorkDir)
+}
@@ -147,3 +147,20 @@ type UnspecifiedPackageManager struct {
 func (u UnspecifiedPackageManager) GetType() types.NodePackageManager {
 	return types.NodePackageManagerUnknown
 }
+
+// getCacheDirs returns the cache directories of the package manager
+// to mount as the BuildKit caches. An unknown package manager is
+// installed with npm.
+func getCacheDirs(pm types.NodePackageManager) []string {
+	switch pm {
+	case types.NodePackageManagerYarn:
+		// Yarn Classic, and the global cache of Yarn Berry
+		return []string{"/usr/local/share/.cache/yarn", "/root/.yarn/berry/cache"}
+	case types.NodePackageManagerPnpm:
+		return []string{"/root/.local/share/pnpm/store"}
+	case types.NodePackageManagerBun:
+		return []string{"/root/.bun/install/cache"}
+	}
+
+	return []string{"/root/.npm"}
+}
@@ -830,6 +830,119 @@ func FindAppDirByGlob(fs afero.Fs, pattern string) (match string, fnerr error) {
 	return "", fnerr
 }
 
+// nodeDependencyFiles is the manifests, lockfiles and configurations
+// of the package managers to install the dependencies.
+var nodeDependencyFiles = []string{
+	"package.json",
+	"package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml", "bun.lockb", "bun.lock",
+	".npmrc", ".yarnrc", ".yarnrc.yml", "pnpm-workspace.yaml", ".pnpmfile.cjs", "bunfig.toml",
+}
+
+// nodeDependencyDirs is the directories of the package managers to
+// install the dependencies, for example, the releases and the plugins
+// of Yarn Berry, and the patches of pnpm.
+var nodeDependencyDirs = []string{".yarn", "patches"}
+
+// lifecycleScripts is the scripts run by the package managers when
+// installing the dependencies, which may need the rest of the source.
+var lifecycleScripts = []string{"preinstall", "install", "postinstall", "preprepare", "prepare", "postprepare", "prepublish"}
+
+// localDependencyProtocols is the protocols of the dependencies
+// installed from the source of this project.
+var localDependencyProtocols = []string{"file:", "link:", "portal:"}
+
+// GetDependencyFiles gets the files to install the dependencies of the
+// Node.js app before copying the rest of the source: the package.json of
+// the project and its workspaces, the lockfiles and the configurations
+// of the package managers. It returns nil if the installation may need
+// the rest of the source, for example, with a custom install command,
+// a lifecycle script like "postinstall", or a local dependency.
+func GetDependencyFiles(ctx *nodePlanContext) []string {
+	if _, err := plan.Cast(ctx.Config.Get(plan.ConfigInstallCommand), cast.ToStringE).Take(); err == nil {
+		return nil
+	}
+
+	dirs := []string{""}
+	if _, reldir := ctx.GetAppSource(); reldir != "" {
+		dirs = append(dirs, reldir)
+	}
+
+	var workspaceGlobs []string
+	workspaceGlobs = append(workspaceGlobs, ctx.ProjectPackageJSON.Workspaces...)
+	if workspaceYAML, err := afero.ReadFile(ctx.Src, "pnpm-workspace.yaml"); err == nil {
+		var pnpmWorkspace struct {
+			Packages []string `yaml:"packages"`
+		}
+		if err := yaml.Unmarshal(workspaceYAML, &pnpmWorkspace); err != nil {
+			return nil
+		}
+		workspaceGlobs = append(workspaceGlobs, pnpmWorkspace.Packages...)
+	}
+	for _, workspaceGlob := range workspaceGlobs {
+		if strings.HasPrefix(workspaceGlob, "!") {
+			continue
+		}
+		if strings.Contains(workspaceGlob, "**") {
+			// afero.Glob does not match the nested directories,
+			// and a missing workspace changes the installation.
+			return nil
+		}
+
+		matches, err := afero.Glob(ctx.Src, strings.TrimSuffix(workspaceGlob, "/"))
+		if err != nil {
+			return nil
+		}
+		dirs = append(dirs, matches...)
+	}
+
+	var files []string
+	for _, dir := range lo.Uniq(dirs) {
+		if !utils.HasFile(ctx.Src, filepath.Join(dir, "package.json")) {
+			// not a package of the workspace
+			continue
+		}
+
+		packageJSON := ctx.ProjectPackageJSON
+		if dir != "" {
+			var err error
+			if packageJSON, err = DeserializePackageJSON(afero.NewBasePathFs(ctx.Src, dir)); err != nil {
+				return nil
+			}
+		}
+		for _, script := range lifecycleScripts {
+			if _, ok := packageJSON.Scripts[script]; ok {
+				return nil
+			}
+		}
+		for _, version := range lo.Assign(packageJSON.Dependencies, packageJSON.DevDependencies) {
+			for _, protocol := range localDependencyProtocols {
+				if strings.HasPrefix(version, protocol) {
+					return nil
+				}
+			}
+		}
+
+		for _, file := range nodeDependencyFiles {
+			if file := filepath.ToSlash(filepath.Join(dir, file)); utils.HasFile(ctx.Src, file) {
+				files = append(files, file)
+			}
+		}
+		for _, depDir := range nodeDependencyDirs {
+			if depDir := filepath.ToSlash(filepath.Join(dir, depDir)); isDir(ctx.Src, depDir) {
+				files = append(files, depDir+"/")
+			}
+		}
+	}
+
+	return files
+}
+
+// isDir checks if the path is a directory in the filesystem.
+func isDir(fs afero.Fs, path string) bool {
+	isDir, _ := afero.IsDir(fs, path)
+	return isDir
+}
+
 // GetStartCmd gets the start command of the Node.js app.
 func GetStartCmd(ctx *nodePlanContext) string {
 	cmd := &ctx.StartCmd
@@ -1012,8 +1125,25 @@ type GetMetaOptions struct {
 	BunFramework optional.Option[types.BunFramework]
 }
 
+// FlagBun is the build plan flag indicating if the project runs on Bun.
+const FlagBun = "bun"
+
+// The PlanMeta keys of the Node.js projects which have no typed field.
+const (
+	// metaNodeVersion is the Node.js version of a Bun project, whose
+	// runtime version is the Bun version.
+	metaNodeVersion = "nodeVersion"
+	metaInitCmd     = "initCmd"
+)
+
 // GetMeta gets the metadata of the Node.js project.
 func GetMeta(opt GetMetaOptions) types.PlanMeta {
+	return GetBuildPlan(opt).PlanMeta()
+}
+
+// GetBuildPlan gets the typed build plan of the Node.js project.
+// The plan type is Bun if GetMetaOptions.Bun is set.
+func GetBuildPlan(opt GetMetaOptions) types.BuildPlan {
 	packageJSON, err := DeserializePackageJSON(opt.Src)
 	if err != nil {
 		log.Printf("Failed to read package.json: %v", err)
@@ -1032,46 +1162,39 @@ func GetMeta(opt GetMetaOptions) types.PlanMeta {
 		ctx.Framework = optional.Some(types.NodeProjectFramework(bunFramework))
 	}
 
-	meta := types.PlanMeta{
-		"bun": strconv.FormatBool(opt.Bun),
+	bp := types.BuildPlan{
+		Version: types.BuildPlanVersion,
+		Type:    types.PlanTypeNodejs,
 	}
-	if opt.Bun {
-		meta["bunVersion"] = "latest"
-	}
-
-	_, reldir := ctx.GetAppSource()
-	meta["appDir"] = reldir
+	bp.SetFlag(FlagBun, opt.Bun)
 
-	pkgManager := DeterminePackageManager(ctx)
-	meta["packageManager"] = string(pkgManager.GetType())
-
-	framework := DetermineAppFramework(ctx)
-	meta["framework"] = string(framework)
+	_, bp.AppDir = ctx.GetAppSource()
+	bp.PackageManager = string(DeterminePackageManager(ctx).GetType())
+	bp.Framework = string(DetermineAppFramework(ctx))
 
 	nodeVersion := GetNodeVersion(ctx)
-	meta["nodeVersion"] = nodeVersion
-
-	initCmd := GetInitCmd(ctx)
-	meta["initCmd"] = initCmd
-
-	installCmd := GetInstallCmd(ctx)
-	meta["installCmd"] = installCmd
-
-	buildCmd := GetBuildCmd(ctx)
-	meta["buildCmd"] = buildCmd
+	if opt.Bun {
+		bp.Type = types.PlanTypeBun
+		bp.RuntimeVersion = "latest"
+		bp.SetExtra(metaNodeVersion, nodeVersion)
+	} else {
+		bp.RuntimeVersion = nodeVersion
+	}
 
-	startCmd := GetStartCmd(ctx)
-	meta["startCmd"] = startCmd
+	bp.SetExtra(metaInitCmd, GetInitCmd(ctx))
+	bp.InstallCommand = GetInstallCmd(ctx)
+	bp.BuildCommand = GetBuildCmd(ctx)
+	bp.StartCommand = GetStartCmd(ctx)
+	if dependencyFiles := GetDependencyFiles(ctx); len(dependencyFiles) > 0 {
+		bp.DependencyFiles = dependencyFiles
+	}
 
 	// only set outputDir if there is no start command
 	// (because if there is, it shouldn't be a static project)
-	if startCmd == "" {
-		staticOutputDir := GetStaticOutputDir(ctx)
-		if staticOutputDir != "" {
-			meta["outputDir"] = staticOutputDir
-			return meta
-		}
+	if bp.StartCommand == "" {
+		bp.OutputDir = GetStaticOutputDir(ctx)
 	}
 
-	return meta
+	bp.KeepKeys("appDir", "packageManager", "framework", "nodeVersion", "installCmd", "buildCmd", "startCmd")
+	return bp
 }
@@ -836,3 +836,73 @@ func TestDeterminePackageManager(t *testing.T) {
 		assert.Equal(t, types.NodePackageManagerUnknown, pm.GetType())
 	})
 }
+
+func TestGetDependencyFiles(t *testing.T) {
+	t.Parallel()
+
+	newContext := func(fs afero.Fs) *nodePlanContext {
+		return &nodePlanContext{
+			Src:                fs,
+			Config:             plan.NewProjectConfigurationFromFs(fs, ""),
+			ProjectPackageJSON: lo.Must(DeserializePackageJSON(fs)),
+		}
+	}
+
+	t.Run("npm", func(t *testing.T) {
+		t.Parallel()
+
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "package.json", []byte(`{"scripts": {"build": "vite build"}}`), 0o644)
+		_ = afero.WriteFile(fs, "package-lock.json", []byte(`{}`), 0o644)
+		_ = afero.WriteFile(fs, ".npmrc", []byte(""), 0o644)
+		_ = afero.WriteFile(fs, "src/index.js", []byte(""), 0o644)
+
+		assert.Equal(t, []string{"package.json", "package-lock.json", ".npmrc"}, GetDependencyFiles(newContext(fs)))
+	})
+
+	t.Run("pnpm workspace", func(t *testing.T) {
+		t.Parallel()
+
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "package.json", []byte(`{}`), 0o644)
+		_ = afero.WriteFile(fs, "pnpm-lock.yaml", []byte(""), 0o644)
+		_ = afero.WriteFile(fs, "pnpm-workspace.yaml", []byte(`packages: [apps/*, packages/*]`), 0o644)
+		_ = afero.WriteFile(fs, "apps/web/package.json", []byte(`{}`), 0o644)
+		_ = afero.WriteFile(fs, "packages/ui/package.json", []byte(`{"dependencies": {"react": "^18"}}`), 0o644)
+		_ = afero.WriteFile(fs, "packages/docs/README", []byte(""), 0o644)
+		_ = afero.WriteFile(fs, "patches/react.patch", []byte(""), 0o644)
+
+		assert.Equal(t, []string{
+			"package.json", "pnpm-lock.yaml", "pnpm-workspace.yaml", "patches/",
+			"apps/web/package.json", "packages/ui/package.json",
+		}, GetDependencyFiles(newContext(fs)))
+	})
+
+	t.Run("lifecycle script", func(t *testing.T) {
+		t.Parallel()
+
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "package.json", []byte(`{"scripts": {"postinstall": "prisma generate"}}`), 0o644)
+
+		assert.Nil(t, GetDependencyFiles(newContext(fs)))
+	})
+
+	t.Run("local dependency", func(t *testing.T) {
+		t.Parallel()
+
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "package.json", []byte(`{"dependencies": {"lib": "file:./lib"}}`), 0o644)
+
+		assert.Nil(t, GetDependencyFiles(newContext(fs)))
+	})
+
+	t.Run("custom install command", func(t *testing.T) {
+		t.Parallel()
+
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "package.json", []byte(`{}`), 0o644)
+		_ = afero.WriteFile(fs, "zbpack.json", []byte(`{"install_command": "npm ci && npm run codegen"}`), 0o644)
+
+		assert.Nil(t, GetDependencyFiles(newContext(fs)))
+	})
+}
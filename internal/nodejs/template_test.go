@@ -126,3 +126,69 @@ func TestTemplate_WithOutputDir(t *testing.T) {
 	require.Contains(t, result, "FROM scratch AS output")
 	require.Contains(t, result, "FROM zeabur/caddy-static AS runtime")
 }
+
+func TestTemplate_DependencyFiles(t *testing.T) {
+	ctx := nodejs.TemplateContext{
+		NodeVersion:     "18",
+		InitCmd:         "RUN npm install -g pnpm@9",
+		InstallCmd:      "RUN pnpm install",
+		BuildCmd:        "pnpm build",
+		StartCmd:        "pnpm start",
+		DependencyFiles: []string{"package.json", "pnpm-lock.yaml", ".npmrc"},
+	}
+
+	result, err := ctx.Execute()
+	assert.NoError(t, err)
+	snaps.MatchSnapshot(t, result)
+}
+
+func TestTemplate_DependencyFiles_Monorepo(t *testing.T) {
+	ctx := nodejs.TemplateContext{
+		NodeVersion: "18",
+		AppDir:      "apps/web",
+		InitCmd:     "RUN npm install -f -g yarn@latest && yarn set version berry",
+		InstallCmd:  "WORKDIR /src/apps/web\nRUN yarn install",
+		BuildCmd:    "yarn build",
+		StartCmd:    "yarn start",
+		DependencyFiles: []string{
+			"package.json", "yarn.lock", ".yarnrc.yml", ".yarn/",
+			"apps/web/package.json", "packages/ui/package.json",
+		},
+	}
+
+	result, err := ctx.Execute()
+	assert.NoError(t, err)
+	snaps.MatchSnapshot(t, result)
+}
+
+func TestTemplate_Hardening(t *testing.T) {
+	ctx := nodejs.TemplateContext{
+		NodeVersion: "18",
+		InitCmd:     "RUN npm install -g pnpm@9",
+		InstallCmd:  "RUN pnpm install",
+		BuildCmd:    "pnpm build",
+		StartCmd:    "pnpm start",
+		Hardening:   true,
+		WorkDir:     "/src",
+	}
+
+	result, err := ctx.Execute()
+	assert.NoError(t, err)
+	snaps.MatchSnapshot(t, result)
+}
+
+func TestTemplate_Hardening_Monorepo(t *testing.T) {
+	ctx := nodejs.TemplateContext{
+		NodeVersion: "18",
+		AppDir:      "myservice",
+		InitCmd:     "RUN npm install -g yarn@latest",
+		InstallCmd:  "WORKDIR /src/myservice\nRUN yarn install",
+		StartCmd:    "yarn start",
+		Hardening:   true,
+		WorkDir:     "/src/myservice",
+	}
+
+	result, err := ctx.Execute()
+	assert.NoError(t, err)
+	snaps.MatchSnapshot(t, result)
+}
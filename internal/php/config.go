@@ -5,3 +5,11 @@ const ConfigPHPVersion = "php.version"
 
 // ConfigPHPOptimize decides if we should run optimization on build.
 const ConfigPHPOptimize = "php.optimize"
+
+// FlagOptimize is the build plan flag indicating if we should run
+// optimization on build.
+const FlagOptimize = "optimize"
+
+// metaExtensions is the PlanMeta key of the PHP extensions to install,
+// separated by spaces.
+const metaExtensions = "exts"
@@ -1,7 +1,6 @@
 package php
 
 import (
-	"strconv"
 	"strings"
 
 	"github.com/spf13/afero"
@@ -26,6 +25,10 @@ func (i *identify) Match(fs afero.Fs) bool {
 }
 
 func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
+	return i.BuildPlan(options).PlanMeta()
+}
+
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
 	config := options.Config
 
 	framework := DetermineProjectFramework(options.Source)
@@ -37,17 +40,25 @@ func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
 	phpOptimize := DeterminePHPOptimize(options.Config)
 
 	// Some meta will be added to the plan dynamically later.
-	meta := types.PlanMeta{
-		"framework":    string(framework),
-		"phpVersion":   phpVersion,
-		"deps":         strings.Join(deps, " "),
-		"exts":         strings.Join(exts, " "),
-		"buildCommand": buildCommand,
-		"startCommand": startCommand,
-		"optimize":     strconv.FormatBool(phpOptimize),
+	bp := types.BuildPlan{
+		Version:        types.BuildPlanVersion,
+		Type:           types.PlanTypePHP,
+		Framework:      string(framework),
+		RuntimeVersion: phpVersion,
+		SystemPackages: deps,
+		BuildCommand:   buildCommand,
+		StartCommand:   startCommand,
+		Extra: types.PlanMeta{
+			metaExtensions: strings.Join(exts, " "),
+		},
 	}
+	bp.SetFlag(FlagOptimize, phpOptimize)
+	bp.KeepKeys("framework", "phpVersion", "deps", "buildCommand", "startCommand")
 
-	return meta
+	return bp
 }
 
-var _ plan.Identifier = (*identify)(nil)
+var (
+	_ plan.Identifier          = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
@@ -3,6 +3,7 @@ package php
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/zeabur/zbpack/pkg/packer"
@@ -16,15 +17,21 @@ var dockerfile string
 
 // GenerateDockerfile generates the Dockerfile for PHP projects.
 func GenerateDockerfile(meta types.PlanMeta) (string, error) {
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypePHP, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for PHP
+// projects from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
 	compiledDockerfile := dockerfile
 
 	variables := map[string]string{
-		"PHP_VERSION":            meta["phpVersion"],
-		"APT_EXTRA_DEPENDENCIES": meta["deps"],
-		"PHP_EXTENSIONS":         meta["exts"],
-		"BUILD_COMMAND":          meta["buildCommand"],
-		"START_COMMAND":          meta["startCommand"],
-		"PHP_OPTIMIZE":           meta["optimize"],
+		"PHP_VERSION":            bp.RuntimeVersion,
+		"APT_EXTRA_DEPENDENCIES": strings.Join(bp.SystemPackages, " "),
+		"PHP_EXTENSIONS":         bp.Extra[metaExtensions],
+		"BUILD_COMMAND":          bp.BuildCommand,
+		"START_COMMAND":          bp.StartCommand,
+		"PHP_OPTIMIZE":           strconv.FormatBool(bp.Flag(FlagOptimize)),
 	}
 
 	for k, v := range variables {
@@ -49,4 +56,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
@@ -1,6 +1,8 @@
 package python
 
 import (
+	"strings"
+
 	"github.com/spf13/afero"
 
 	"github.com/zeabur/zbpack/internal/utils"
@@ -32,8 +34,33 @@ func (i *identify) Match(fs afero.Fs) bool {
 	)
 }
 
+// MatchScore is more confident when both the dependency manifest
+// and the entry point of a Python application are found.
+func (i *identify) MatchScore(ctx plan.MatchContext) plan.MatchResult {
+	result := plan.MatchResult{Score: 0.3}
+
+	if found := utils.FoundFiles(ctx.Source, "requirements.txt", "pyproject.toml", "Pipfile"); len(found) > 0 {
+		result.Score += 0.4
+		result.Reasons = append(result.Reasons, "found dependency manifest "+strings.Join(found, ", "))
+	}
+	if found := utils.FoundFiles(ctx.Source, "app.py", "main.py", "manage.py", "streamlit_app.py", "app/__init__.py"); len(found) > 0 {
+		result.Score += 0.3
+		result.Reasons = append(result.Reasons, "found entry point "+strings.Join(found, ", "))
+	}
+
+	return result
+}
+
 func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
 	return GetMeta(GetMetaOptions{Src: options.Source, Config: options.Config})
 }
 
-var _ plan.Identifier = (*identify)(nil)
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
+	return GetBuildPlan(GetMetaOptions{Src: options.Source, Config: options.Config})
+}
+
+var (
+	_ plan.Identifier          = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+	_ plan.ScoredIdentifier    = (*identify)(nil)
+)
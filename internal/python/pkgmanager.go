@@ -61,6 +61,22 @@ func getPmInstallCmd(pm types.PythonPackageManager) string {
 	return ""
 }
 
+// getPmInstallDependenciesCmd returns the command to install the
+// dependencies without the project itself, which needs the rest of the
+// source. It returns "" if getPmInstallCmd does not install the project.
+func getPmInstallDependenciesCmd(pm types.PythonPackageManager) string {
+	switch pm {
+	case types.PythonPackageManagerPoetry:
+		return "poetry install --no-root"
+	case types.PythonPackageManagerPdm:
+		return "pdm install --no-self"
+	case types.PythonPackageManagerUv:
+		return "uv sync --no-install-project"
+	}
+
+	return ""
+}
+
 func getPmPostInstallCmd(pm types.PythonPackageManager) string {
 	switch pm {
 	case types.PythonPackageManagerPip:
@@ -73,6 +89,28 @@ func getPmPostInstallCmd(pm types.PythonPackageManager) string {
 	return ""
 }
 
+// getPmCacheDirs returns the cache directories of the package manager
+// to mount as the BuildKit caches. The package managers are installed
+// with pip, so the cache of pip is always included. The virtual
+// environments of pipenv and poetry are also under ~/.cache, so only
+// the caches of the downloaded packages are mounted.
+func getPmCacheDirs(pm types.PythonPackageManager) []string {
+	dirs := []string{"/root/.cache/pip"}
+
+	switch pm {
+	case types.PythonPackageManagerPipenv:
+		dirs = append(dirs, "/root/.cache/pipenv")
+	case types.PythonPackageManagerPoetry:
+		dirs = append(dirs, "/root/.cache/pypoetry/cache", "/root/.cache/pypoetry/artifacts")
+	case types.PythonPackageManagerPdm:
+		dirs = append(dirs, "/root/.cache/pdm")
+	case types.PythonPackageManagerUv:
+		dirs = append(dirs, "/root/.cache/uv")
+	}
+
+	return dirs
+}
+
 func getPmStartCmdPrefix(pm types.PythonPackageManager) string {
 	switch pm {
 	case types.PythonPackageManagerPipenv:
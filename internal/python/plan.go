@@ -525,6 +525,63 @@ func determineInstallCmd(ctx *pythonPlanContext) string {
 	return "RUN echo \"skip install\""
 }
 
+// localRequirementPrefixes is the prefixes of the lines in requirements.txt
+// that refer to the other files in this project.
+var localRequirementPrefixes = []string{"-r", "-c", "--requirement", "--constraint", ".", "/"}
+
+// localDependencyMarkers is the texts in Pipfile and pyproject.toml
+// indicating the dependencies installed from the source of this project.
+var localDependencyMarkers = []string{"path =", "path=", "file:", "[tool.uv.workspace]"}
+
+// determineDependencyFiles determines the declaration file and the lockfiles
+// of the package manager to install the dependencies before copying the
+// rest of the source. It returns nil if the dependencies refer to the
+// other files in this project.
+func determineDependencyFiles(ctx *pythonPlanContext) []string {
+	if DetermineFramework(ctx) == types.PythonFrameworkReflex {
+		return nil
+	}
+
+	pm := DeterminePackageManager(ctx)
+	declarationFile := getPmDeclarationFile(pm)
+	if declarationFile == "" {
+		return nil
+	}
+
+	files := append([]string{declarationFile}, utils.FoundFiles(ctx.Src, getPmLockFile(pm)...)...)
+	for _, file := range files {
+		content, err := utils.ReadFileToUTF8(ctx.Src, file)
+		if err != nil {
+			return nil
+		}
+
+		switch file {
+		case "requirements.txt", "requirements.lock":
+			for _, line := range strings.Split(string(content), "\n") {
+				// the editable dependencies are installed after copying the source
+				if strings.Contains(line, "-e") {
+					continue
+				}
+
+				line = strings.TrimSpace(line)
+				if strings.Contains(line, "file:") || lo.SomeBy(localRequirementPrefixes, func(prefix string) bool {
+					return strings.HasPrefix(line, prefix)
+				}) {
+					return nil
+				}
+			}
+		case "Pipfile", "pyproject.toml":
+			if lo.SomeBy(localDependencyMarkers, func(marker string) bool {
+				return strings.Contains(string(content), marker)
+			}) {
+				return nil
+			}
+		}
+	}
+
+	return files
+}
+
 func determineAptDependencies(ctx *pythonPlanContext) []string {
 	framework := DetermineFramework(ctx)
 	if framework == types.PythonFrameworkReflex {
@@ -861,53 +918,50 @@ func determinePlaywright(ctx *pythonPlanContext) bool {
 	return HasDependency(ctx, "playwright")
 }
 
+// FlagSelenium is the build plan flag indicating if Chromium for Selenium is required.
+const FlagSelenium = "selenium"
+
 // GetMeta returns the metadata of a Python project.
 func GetMeta(opt GetMetaOptions) types.PlanMeta {
-	meta := types.PlanMeta{}
+	return GetBuildPlan(opt).PlanMeta()
+}
 
+// GetBuildPlan returns the typed build plan of a Python project.
+func GetBuildPlan(opt GetMetaOptions) types.BuildPlan {
 	ctx := &pythonPlanContext{
 		Src:    opt.Src,
 		Config: opt.Config,
 	}
 
-	pm := DeterminePackageManager(ctx)
-	meta["packageManager"] = string(pm)
-
-	version := determinePythonVersion(ctx)
-	meta["pythonVersion"] = version
+	bp := types.BuildPlan{
+		Version:        types.BuildPlanVersion,
+		Type:           types.PlanTypePython,
+		PackageManager: string(DeterminePackageManager(ctx)),
+		RuntimeVersion: determinePythonVersion(ctx),
+	}
 
-	staticPath := DetermineStaticInfo(ctx)
-	for k, v := range staticPath.Meta() {
-		meta[k] = v
+	for k, v := range DetermineStaticInfo(ctx).Meta() {
+		bp.SetExtra(k, v)
 	}
 
 	framework := DetermineFramework(ctx)
 	if framework != types.PythonFrameworkNone {
-		meta["framework"] = string(framework)
+		bp.Framework = string(framework)
 	}
 
-	installCmd := determineInstallCmd(ctx)
-	meta["install"] = installCmd
-
-	buildCmd := determineBuildCmd(ctx)
-	if buildCmd != "" {
-		meta["build"] = buildCmd
-	}
-
-	startCmd := determineStartCmd(ctx)
-	if startCmd != "" {
-		meta["start"] = startCmd
-	}
+	bp.InstallCommand = determineInstallCmd(ctx)
+	bp.DependencyFiles = determineDependencyFiles(ctx)
+	bp.BuildCommand = determineBuildCmd(ctx)
+	bp.StartCommand = determineStartCmd(ctx)
 
 	// if selenium, we need to install chromium
 	if HasDependency(ctx, "seleniumbase") || HasDependency(ctx, "selenium") {
-		meta["selenium"] = "true"
+		bp.SetFlag(FlagSelenium, true)
 	}
 
-	aptDeps := determineAptDependencies(ctx)
-	if len(aptDeps) > 0 {
-		meta["apt-deps"] = strings.Join(aptDeps, " ")
+	if aptDeps := determineAptDependencies(ctx); len(aptDeps) > 0 {
+		bp.SystemPackages = aptDeps
 	}
 
-	return meta
+	return bp
 }
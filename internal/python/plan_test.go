@@ -1266,3 +1266,162 @@ func TestDetermineStartCommand_Custom(t *testing.T) {
 	assert.Contains(t, determineStartCmd(ctx), "echo 'hello'")
 	assert.Contains(t, determineStartCmd(ctx), "_startup()") // should have the default startup function
 }
+
+func TestMatchScore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("manifest only", func(t *testing.T) {
+		t.Parallel()
+
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "requirements.txt", nil, 0o644)
+
+		result := (&identify{}).MatchScore(plan.MatchContext{Source: fs})
+		assert.InDelta(t, 0.7, result.Score, 1e-9)
+		assert.Equal(t, []string{"found dependency manifest requirements.txt"}, result.Reasons)
+	})
+
+	t.Run("manifest and entry point", func(t *testing.T) {
+		t.Parallel()
+
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "pyproject.toml", nil, 0o644)
+		_ = afero.WriteFile(fs, "main.py", nil, 0o644)
+
+		result := (&identify{}).MatchScore(plan.MatchContext{Source: fs})
+		assert.InDelta(t, 1.0, result.Score, 1e-9)
+		assert.Len(t, result.Reasons, 2)
+	})
+}
+
+func TestGenerateDockerfile_CacheMounts(t *testing.T) {
+	dockerfile, err := GenerateDockerfile(types.PlanMeta{
+		"pythonVersion":  "3.12",
+		"packageManager": string(types.PythonPackageManagerPoetry),
+		"install":        "RUN pip install poetry\nRUN poetry install",
+		"start":          "poetry run python main.py",
+		"cacheMounts":    "true",
+	})
+	assert.NoError(t, err)
+
+	mounts := "RUN --mount=type=cache,target=/root/.cache/pip --mount=type=cache,target=/root/.cache/pypoetry/cache --mount=type=cache,target=/root/.cache/pypoetry/artifacts "
+	assert.Contains(t, dockerfile, mounts+"pip install poetry\n")
+	assert.Contains(t, dockerfile, mounts+"poetry install\n")
+}
+
+func TestDetermineDependencyFiles(t *testing.T) {
+	testcases := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+	}{
+		{
+			name:     "pip",
+			files:    map[string]string{"requirements.txt": "flask==3.0.0\n-e .\n"},
+			expected: []string{"requirements.txt"},
+		},
+		{
+			name:  "pip with included requirements",
+			files: map[string]string{"requirements.txt": "-r requirements/base.txt\n"},
+		},
+		{
+			name:  "pip with local package",
+			files: map[string]string{"requirements.txt": "./packages/lib\n"},
+		},
+		{
+			name:     "poetry",
+			files:    map[string]string{"pyproject.toml": "[tool.poetry]\nname = \"app\"\n", "poetry.lock": ""},
+			expected: []string{"pyproject.toml", "poetry.lock"},
+		},
+		{
+			name:  "poetry with path dependency",
+			files: map[string]string{"pyproject.toml": "[tool.poetry.dependencies]\nlib = { path = \"./lib\" }\n", "poetry.lock": ""},
+		},
+		{
+			name:     "uv",
+			files:    map[string]string{"pyproject.toml": "[project]\nname = \"app\"\n", "uv.lock": "", ".python-version": "3.12"},
+			expected: []string{"pyproject.toml", "uv.lock", ".python-version"},
+		},
+		{
+			name:  "uv workspace",
+			files: map[string]string{"pyproject.toml": "[tool.uv.workspace]\nmembers = [\"packages/*\"]\n", "uv.lock": ""},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			for name, content := range tc.files {
+				_ = afero.WriteFile(fs, name, []byte(content), 0o644)
+			}
+
+			ctx := &pythonPlanContext{
+				Src:    fs,
+				Config: plan.NewProjectConfigurationFromFs(fs, ""),
+			}
+
+			assert.Equal(t, tc.expected, determineDependencyFiles(ctx))
+		})
+	}
+}
+
+func TestGenerateDockerfile_DependencyFiles_Snapshot(t *testing.T) {
+	testcases := map[types.PythonPackageManager]types.PlanMeta{
+		types.PythonPackageManagerPip: {
+			"install":         "RUN sed '/-e/d' requirements.txt | pip install -r /dev/stdin",
+			"build":           "RUN pip install -r requirements.txt",
+			"dependencyFiles": "requirements.txt",
+		},
+		types.PythonPackageManagerPoetry: {
+			"install":         "RUN pip install poetry\nRUN poetry add gunicorn\nRUN poetry install",
+			"dependencyFiles": "pyproject.toml:poetry.lock",
+		},
+		types.PythonPackageManagerUv: {
+			"install":         "RUN pip install uv\nRUN uv sync",
+			"dependencyFiles": "pyproject.toml:uv.lock:.python-version",
+			"cacheMounts":     "true",
+		},
+	}
+
+	for pm, meta := range testcases {
+		t.Run(string(pm), func(t *testing.T) {
+			meta["pythonVersion"] = "3.12"
+			meta["packageManager"] = string(pm)
+			meta["start"] = "python main.py"
+
+			dockerfile, err := GenerateDockerfile(meta)
+			assert.NoError(t, err)
+			snaps.MatchSnapshot(t, dockerfile)
+		})
+	}
+}
+
+func TestGenerateDockerfile_Hardening(t *testing.T) {
+	t.Run("snapshot", func(t *testing.T) {
+		dockerfile, err := GenerateDockerfile(types.PlanMeta{
+			"pythonVersion":   "3.12",
+			"packageManager":  string(types.PythonPackageManagerPoetry),
+			"install":         "RUN pip install poetry\nRUN poetry install",
+			"start":           "poetry run python main.py",
+			"dependencyFiles": "pyproject.toml:poetry.lock",
+			"hardening":       "true",
+		})
+		assert.NoError(t, err)
+		snaps.MatchSnapshot(t, dockerfile)
+	})
+
+	t.Run("nginx", func(t *testing.T) {
+		dockerfile, err := GenerateDockerfile(types.PlanMeta{
+			"pythonVersion":   "3.12",
+			"packageManager":  string(types.PythonPackageManagerPip),
+			"install":         "RUN pip install -r requirements.txt",
+			"start":           "python main.py",
+			"static-flag":     "2",
+			"static-url-path": "/static",
+			"static-host-dir": "/app/static",
+			"hardening":       "true",
+		})
+		assert.NoError(t, err)
+		assert.NotContains(t, dockerfile, "USER app")
+	})
+}
@@ -2,21 +2,58 @@ package python
 
 import (
 	"strconv"
+	"strings"
 
+	"github.com/zeabur/zbpack/internal/utils"
 	"github.com/zeabur/zbpack/pkg/packer"
 	"github.com/zeabur/zbpack/pkg/types"
 )
 
 // GenerateDockerfile generates the Dockerfile for Python projects.
 func GenerateDockerfile(meta types.PlanMeta) (string, error) {
-	installCmd := meta["install"]
-	buildCmd := meta["build"]
-	startCmd := meta["start"]
-	aptDeps := meta["apt-deps"]
-	staticMeta := staticInfoFromMeta(meta)
-	pyVer := meta["pythonVersion"]
-
-	if meta["framework"] == string(types.PythonFrameworkReflex) {
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypePython, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for Python projects
+// from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	installCmd := bp.InstallCommand
+	buildCmd := bp.BuildCommand
+	startCmd := bp.StartCommand
+	aptDeps := strings.Join(bp.SystemPackages, " ")
+	staticMeta := staticInfoFromMeta(bp.Extra)
+	pyVer := bp.RuntimeVersion
+
+	pm := types.PythonPackageManager(bp.PackageManager)
+
+	// Install the dependencies before copying the rest of the source,
+	// so they are cached until the dependency files change. If the
+	// package manager installs the project itself, only the dependencies
+	// are installed before, and the project is installed after copying.
+	sourceSegment := "COPY . .\n" + installCmd + "\n"
+	if len(bp.DependencyFiles) > 0 {
+		copyCmd := utils.CopyFiles(bp.DependencyFiles, "./") + "\n"
+		sourceSegment = copyCmd + installCmd + "\nCOPY . .\n"
+
+		if cmd := getPmInstallDependenciesCmd(pm); cmd != "" {
+			dependencyCmd := "RUN " + cmd
+			projectCmd := installCmd
+			if initCmd := getPmInitCmd(pm); initCmd != "" {
+				// the package manager is installed with the dependencies
+				dependencyCmd = "RUN " + initCmd + "\n" + dependencyCmd
+				projectCmd = strings.Replace(projectCmd, "RUN "+initCmd+"\n", "", 1)
+			}
+			sourceSegment = copyCmd + dependencyCmd + "\nCOPY . .\n" + projectCmd + "\n"
+		}
+	}
+
+	if bp.Flag(types.FlagCacheMounts) {
+		cacheDirs := getPmCacheDirs(pm)
+		installCmd = utils.WithCacheMounts(installCmd, cacheDirs...)
+		sourceSegment = utils.WithCacheMounts(sourceSegment, cacheDirs...)
+	}
+
+	if bp.Framework == string(types.PythonFrameworkReflex) {
 		return `FROM python:` + pyVer + `
 RUN apt-get update -y && apt-get install -y caddy && rm -rf /var/lib/apt/lists/*
 WORKDIR /app
@@ -41,13 +78,24 @@ STOPSIGNAL SIGKILL
 CMD ` + startCmd, nil
 	}
 
+	// nginx needs root to listen to the port, so the server is not run
+	// as the non-root user if nginx serves the static files.
+	hardening := bp.Flag(types.FlagHardening) && !staticMeta.NginxEnabled()
+	userSegment := ""
+	if hardening {
+		// The virtual environments are created in the project, so that
+		// they are not in the home directory of root.
+		userSegment = "RUN useradd --create-home --uid 10001 app\nENV POETRY_VIRTUALENVS_IN_PROJECT=true PIPENV_VENV_IN_PROJECT=1\n"
+		sourceSegment = strings.Replace(sourceSegment, "COPY . .\n", "COPY --chown=app:app . .\n", 1)
+	}
+
 	dockerfile := "FROM docker.io/library/python:" + pyVer + "-slim\n"
 	dockerfile += `WORKDIR /app
-RUN apt-get update && apt-get install -y ` + aptDeps + " && rm -rf /var/lib/apt/lists/*\n"
+` + userSegment + `RUN apt-get update && apt-get install -y ` + aptDeps + " && rm -rf /var/lib/apt/lists/*\n"
 
 	// if selenium is required, we install chromium
 	// https://github.com/SeleniumHQ/docker-selenium/blob/f39a9da86f635b21d6dff0572e7713dc80c20d69/NodeChrome/Dockerfile#L17C1-L32C50
-	if meta["selenium"] == "true" {
+	if bp.Flag(FlagSelenium) {
 		dockerfile += `RUN apt update -y \
 		&& apt install -y curl \
 		&& (curl https://dl-ssl.google.com/linux/linux_signing_key.pub | gpg --dearmor | tee /etc/apt/trusted.gpg.d/google.gpg >/dev/null) \
@@ -74,8 +122,11 @@ server { \
 }"> /etc/nginx/conf.d/default.conf` + "\n"
 	}
 
-	dockerfile += "COPY . .\n" + installCmd + "\n" + buildCmd + `
-EXPOSE 8080
+	dockerfile += sourceSegment + buildCmd + "\n"
+	if hardening {
+		dockerfile += "RUN chown app:app /app\nUSER app\n"
+	}
+	dockerfile += `EXPOSE 8080
 CMD ["/bin/bash", "-c", ` + strconv.Quote(startCmd) + `]`
 
 	return dockerfile, nil
@@ -96,4 +147,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
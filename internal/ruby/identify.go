@@ -1,6 +1,8 @@
 package ruby
 
 import (
+	"strings"
+
 	"github.com/spf13/afero"
 
 	"github.com/zeabur/zbpack/internal/utils"
@@ -44,24 +46,42 @@ func (i *identify) DetermineNodePackageManager(fs afero.Fs) types.NodePackageMan
 }
 
 func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
+	return i.BuildPlan(options).PlanMeta()
+}
+
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
 	rubyVersion := DetermineRubyVersion(options.Source, options.Config)
 	framework := DetermineRubyFramework(options.Source)
 	buildCmd := DetermineBuildCmd(framework, options.Config)
 	startCmd := DetermineStartCmd(framework, options.Config)
 
-	meta := types.PlanMeta{
-		"rubyVersion": rubyVersion,
-		"buildCmd":    buildCmd,
-		"startCmd":    startCmd,
+	bp := types.BuildPlan{
+		Version:        types.BuildPlanVersion,
+		Type:           types.PlanTypeRuby,
+		RuntimeVersion: rubyVersion,
+		BuildCommand:   buildCmd,
+		StartCommand:   startCmd,
 	}
 
 	needNode := i.DetermineNeedNode(options.Source)
 	if needNode {
-		meta["needNode"] = "true"
-		meta["nodePackageManager"] = string(i.DetermineNodePackageManager(options.Source))
+		bp.SetFlag(FlagNeedNode, true)
+		bp.SetExtra(metaNodePackageManager, string(i.DetermineNodePackageManager(options.Source)))
+	}
+
+	if dependencyFiles := DetermineDependencyFiles(options.Source, needNode); len(dependencyFiles) > 0 {
+		bp.DependencyFiles = dependencyFiles
+	}
+
+	if gems := DetermineNativeGems(options.Source); len(gems) > 0 {
+		bp.SetExtra(metaNativeGems, strings.Join(gems, ":"))
 	}
 
-	return meta
+	bp.KeepKeys("rubyVersion", "buildCmd", "startCmd")
+	return bp
 }
 
-var _ plan.Identifier = (*identify)(nil)
+var (
+	_ plan.Identifier          = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
@@ -6,7 +6,10 @@ import (
 	"testing"
 
 	"github.com/gkampitakis/go-snaps/snaps"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
 	"github.com/zeabur/zbpack/internal/ruby"
+	"github.com/zeabur/zbpack/pkg/plan"
 	"github.com/zeabur/zbpack/pkg/types"
 )
 
@@ -56,3 +59,64 @@ func TestDockerfileSnapshotTest(t *testing.T) {
 		}
 	}
 }
+
+func TestDockerfileSnapshotTest_DependencyFiles(t *testing.T) {
+	t.Parallel()
+
+	planMeta := types.PlanMeta{
+		"rubyVersion":        "3.3",
+		"needNode":           "true",
+		"nodePackageManager": string(types.NodePackageManagerYarn),
+		"buildCmd":           "bundle exec rake assets:precompile",
+		"startCmd":           "rails server",
+		"dependencyFiles":    "Gemfile:Gemfile.lock:.ruby-version:package.json:yarn.lock",
+	}
+
+	dockerfile, err := ruby.GenerateDockerfile(planMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snaps.MatchSnapshot(t, dockerfile)
+}
+
+func TestDockerfileSnapshotTest_Hardening(t *testing.T) {
+	t.Parallel()
+
+	planMeta := types.PlanMeta{
+		"rubyVersion": "3.3",
+		"buildCmd":    "bundle exec rake assets:precompile",
+		"startCmd":    "rails server",
+		"hardening":   "true",
+	}
+
+	dockerfile, err := ruby.GenerateDockerfile(planMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snaps.MatchSnapshot(t, dockerfile)
+}
+
+func TestDockerfileSnapshotTest_HardeningNativeGems(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "Gemfile", []byte("source \"https://rubygems.org\"\ngem \"rails\"\ngem \"mysql2\"\n"), 0o644)
+	_ = afero.WriteFile(fs, "Gemfile.lock", []byte("GEM\n  specs:\n    mysql2 (0.5.6)\n    rails (7.1.3)\n\nDEPENDENCIES\n  mysql2\n  rails\n"), 0o644)
+
+	planMeta := ruby.NewIdentifier().PlanMeta(plan.NewPlannerOptions{
+		Source: fs,
+		Config: plan.NewProjectConfigurationFromFs(fs, ""),
+	})
+	assert.Equal(t, "mysql2", planMeta["nativeGems"])
+
+	// mysql2 loads libmariadb, which the slim image does not have.
+	planMeta[types.FlagHardening] = "true"
+	dockerfile, err := ruby.GenerateDockerfile(planMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snaps.MatchSnapshot(t, dockerfile)
+}
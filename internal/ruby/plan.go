@@ -2,10 +2,12 @@ package ruby
 
 import (
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/spf13/afero"
 	"github.com/spf13/cast"
+	"github.com/zeabur/zbpack/internal/nodejs"
 	"github.com/zeabur/zbpack/internal/utils"
 	"github.com/zeabur/zbpack/pkg/plan"
 	"github.com/zeabur/zbpack/pkg/types"
@@ -26,6 +28,16 @@ const (
 	ConfigRubyEntry = "ruby.entry"
 )
 
+// FlagNeedNode is the build plan flag indicating if Node.js is required
+// to build the assets.
+const FlagNeedNode = "needNode"
+
+// The PlanMeta keys of the Ruby projects which have no typed field.
+const (
+	metaNodePackageManager = "nodePackageManager"
+	metaNativeGems         = "nativeGems"
+)
+
 // DetermineRubyVersion determines the version of Ruby used in the project.
 func DetermineRubyVersion(source afero.Fs, config plan.ImmutableProjectConfiguration) string {
 	if version, err := plan.Cast(config.Get(ConfigRubyVersion), cast.ToStringE).Take(); err == nil {
@@ -90,3 +102,83 @@ func DetermineStartCmd(framework types.RubyFramework, config plan.ImmutableProje
 
 	return "ruby " + entryConfig.TakeOr(DefaultRubyEntrypoint)
 }
+
+// localGemMarkers is the texts in Gemfile indicating the gems
+// installed from the source of this project.
+var localGemMarkers = []string{"gemspec", "path:", ":path", "eval_gemfile", "require_relative"}
+
+// nodeLifecycleScripts is the scripts run by the Node.js package managers
+// when installing the dependencies, which may need the rest of the source.
+var nodeLifecycleScripts = []string{"preinstall", "install", "postinstall", "prepare"}
+
+// DetermineDependencyFiles determines the files to install the gems, and
+// the Node.js packages if needNode, before copying the rest of the source.
+// It returns nil if the installation may need the rest of the source, for
+// example, a gemspec or a gem from a local path.
+func DetermineDependencyFiles(source afero.Fs, needNode bool) []string {
+	gemfile, err := utils.ReadFileToUTF8(source, "Gemfile")
+	if err != nil {
+		return nil
+	}
+	for _, marker := range localGemMarkers {
+		if strings.Contains(string(gemfile), marker) {
+			return nil
+		}
+	}
+
+	files := append([]string{"Gemfile"}, utils.FoundFiles(source, "Gemfile.lock", ".ruby-version")...)
+	if isDir, _ := afero.IsDir(source, ".bundle"); isDir {
+		files = append(files, ".bundle/")
+	}
+
+	if needNode {
+		packageJSON, err := nodejs.DeserializePackageJSON(source)
+		if err != nil {
+			return nil
+		}
+		for _, script := range nodeLifecycleScripts {
+			if _, ok := packageJSON.Scripts[script]; ok {
+				return nil
+			}
+		}
+
+		files = append(files, "package.json")
+		files = append(files, utils.FoundFiles(source, "yarn.lock", "pnpm-lock.yaml", "package-lock.json", ".npmrc", ".yarnrc")...)
+	}
+
+	return files
+}
+
+// nativeGems is the gems which load the shared libraries that the slim
+// Ruby image does not have, such as libmariadb of mysql2 and ImageMagick
+// of rmagick.
+var nativeGems = []string{"mysql2", "rmagick", "mini_magick", "ruby-vips"}
+
+// DetermineNativeGems determines the gems in Gemfile.lock, or Gemfile if
+// it is not locked, which need the shared libraries missing in the slim
+// Ruby image.
+func DetermineNativeGems(source afero.Fs) []string {
+	content, err := utils.ReadFileToUTF8(source, "Gemfile.lock")
+	if err != nil {
+		content, err = utils.ReadFileToUTF8(source, "Gemfile")
+		if err != nil {
+			return nil
+		}
+	}
+
+	var found []string
+	for _, line := range strings.Split(string(content), "\n") {
+		// "    mysql2 (0.5.6)" in Gemfile.lock, or "gem 'mysql2', '~> 0.5'" in Gemfile
+		name := strings.TrimPrefix(strings.TrimSpace(line), "gem ")
+		name = strings.TrimLeft(name, " \"'")
+		if i := strings.IndexAny(name, " \"',("); i != -1 {
+			name = name[:i]
+		}
+
+		if slices.Contains(nativeGems, name) && !slices.Contains(found, name) {
+			found = append(found, name)
+		}
+	}
+
+	return found
+}
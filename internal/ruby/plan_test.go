@@ -103,3 +103,48 @@ func TestDetermineStartCmd_CustomEntry(t *testing.T) {
 	startCmd = ruby.DetermineStartCmd(types.RubyFrameworkRails, config)
 	assert.Equal(t, "ruby app.rb", startCmd)
 }
+
+func TestDetermineDependencyFiles(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "Gemfile", []byte("source \"https://rubygems.org\"\ngem \"rails\"\n"), 0o644)
+	_ = afero.WriteFile(fs, "Gemfile.lock", nil, 0o644)
+	_ = afero.WriteFile(fs, "package.json", []byte(`{"scripts": {"build": "esbuild app.js"}}`), 0o644)
+	_ = afero.WriteFile(fs, "yarn.lock", nil, 0o644)
+
+	assert.Equal(t, []string{"Gemfile", "Gemfile.lock"}, ruby.DetermineDependencyFiles(fs, false))
+	assert.Equal(t, []string{"Gemfile", "Gemfile.lock", "package.json", "yarn.lock"}, ruby.DetermineDependencyFiles(fs, true))
+}
+
+func TestDetermineDependencyFiles_Gemspec(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "Gemfile", []byte("source \"https://rubygems.org\"\ngemspec\n"), 0o644)
+
+	assert.Nil(t, ruby.DetermineDependencyFiles(fs, false))
+}
+
+func TestDetermineDependencyFiles_NodeLifecycleScript(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "Gemfile", []byte("gem \"rails\"\n"), 0o644)
+	_ = afero.WriteFile(fs, "package.json", []byte(`{"scripts": {"postinstall": "node scripts/setup.js"}}`), 0o644)
+
+	assert.Nil(t, ruby.DetermineDependencyFiles(fs, true))
+}
+
+func TestDetermineNativeGems(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "Gemfile", []byte("gem \"rails\"\ngem 'mini_magick', '~> 4.12'\n"), 0o644)
+
+	assert.Equal(t, []string{"mini_magick"}, ruby.DetermineNativeGems(fs))
+
+	// Gemfile.lock includes the gems required by the other gems.
+	_ = afero.WriteFile(fs, "Gemfile.lock", []byte("GEM\n  specs:\n    image_processing (1.12.2)\n      mini_magick (>= 4.9.5, < 5)\n      ruby-vips (>= 2.0.17, < 3)\n    mini_magick (4.12.0)\n    ruby-vips (2.2.1)\n    rails (7.1.3)\n\nDEPENDENCIES\n  image_processing\n  rails\n"), 0o644)
+
+	assert.Equal(t, []string{"mini_magick", "ruby-vips"}, ruby.DetermineNativeGems(fs))
+}
+
+func TestDetermineNativeGems_None(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "Gemfile", []byte("gem \"rails\"\ngem \"pg\"\ngem \"mysql2_helper\"\n"), 0o644)
+
+	assert.Nil(t, ruby.DetermineNativeGems(fs))
+}
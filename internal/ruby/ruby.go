@@ -5,50 +5,100 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/zeabur/zbpack/internal/utils"
 	"github.com/zeabur/zbpack/pkg/packer"
 	"github.com/zeabur/zbpack/pkg/types"
 )
 
 // GenerateDockerfile generates the Dockerfile for Ruby projects.
 func GenerateDockerfile(meta types.PlanMeta) (string, error) {
-	rubyVersion := meta["rubyVersion"]
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypeRuby, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for Ruby
+// projects from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	rubyVersion := bp.RuntimeVersion
 
 	getRubyImage := fmt.Sprintf("FROM docker.io/library/ruby:%s\n", rubyVersion)
 
 	installSysDepCmd := []string{"RUN apt-get update -qq && apt-get install -y postgresql-client"}
 	workDir := "WORKDIR /myapp"
 	copySource := "COPY . /myapp"
+	cacheMounts := bp.Flag(types.FlagCacheMounts)
 	installDepCmd := []string{"RUN bundle install"}
-	startCmd := "CMD " + meta["startCmd"]
+	if cacheMounts {
+		// the gems are installed in the image, and the downloaded
+		// gems are cached in the global gem cache of Bundler.
+		installDepCmd = []string{utils.WithCacheMounts("RUN BUNDLE_GLOBAL_GEM_CACHE=true bundle install", "/root/.bundle/cache")}
+	}
+	startCmd := "CMD " + bp.StartCommand
 
 	var precompileCmd string
-	if buildCmd := meta["buildCmd"]; buildCmd != "" {
+	if buildCmd := bp.BuildCommand; buildCmd != "" {
 		precompileCmd = "RUN " + buildCmd
 	}
 
-	needNode := meta["needNode"] == "true"
+	needNode := bp.Flag(FlagNeedNode)
 	if needNode {
 		installSysDepCmd = append(installSysDepCmd, "RUN apt-get install -y nodejs npm")
 
-		switch meta["nodePackageManager"] {
+		var nodeInstallCmd, nodeCacheDir string
+		switch bp.Extra[metaNodePackageManager] {
 		case "yarn":
 			installSysDepCmd = append(installSysDepCmd, "RUN npm install -g yarn")
-			installDepCmd = append(installDepCmd, "RUN yarn install")
+			nodeInstallCmd, nodeCacheDir = "RUN yarn install", "/usr/local/share/.cache/yarn"
 		case "pnpm":
 			installSysDepCmd = append(installSysDepCmd, "RUN npm install -g pnpm")
-			installDepCmd = append(installDepCmd, "RUN pnpm install")
+			nodeInstallCmd, nodeCacheDir = "RUN pnpm install", "/root/.local/share/pnpm/store"
 		default:
-			installDepCmd = append(installDepCmd, "RUN npm install")
+			nodeInstallCmd, nodeCacheDir = "RUN npm install", "/root/.npm"
+		}
+
+		if cacheMounts {
+			nodeInstallCmd = utils.WithCacheMounts(nodeInstallCmd, nodeCacheDir)
+		}
+		installDepCmd = append(installDepCmd, nodeInstallCmd)
+	}
+
+	// Install the dependencies before copying the rest of the source,
+	// so they are cached until the dependency files change.
+	sourceSegment := copySource + "\n" + strings.Join(installDepCmd, "\n")
+	if dependencyFiles := bp.DependencyFiles; len(dependencyFiles) > 0 {
+		sourceSegment = utils.CopyFiles(dependencyFiles, "/myapp/") + "\n" + strings.Join(installDepCmd, "\n") + "\n" + copySource
+	}
+
+	runtimeSegment := startCmd
+	if bp.Flag(types.FlagHardening) {
+		// The installed gems and the built application are copied to
+		// the slim image, which has the runtime libraries of the system
+		// dependencies only, and run as a non-root user. The gems loading
+		// the libraries missing in the slim image, such as mysql2, run
+		// in the full image instead.
+		runtimeImage := rubyVersion + "-slim"
+		if bp.Extra[metaNativeGems] != "" {
+			runtimeImage = rubyVersion
+		}
+		runtimeDeps := "postgresql-client"
+		if needNode {
+			runtimeDeps += " nodejs"
 		}
+		runtimeSegment = fmt.Sprintf("\nFROM docker.io/library/ruby:%s\n", runtimeImage) +
+			"RUN apt-get update -qq && apt-get install -y --no-install-recommends " + runtimeDeps + " && rm -rf /var/lib/apt/lists/*\n" +
+			"RUN useradd --create-home --uid 10001 app\n" +
+			workDir + "\n" +
+			"COPY --from=0 /usr/local/bundle /usr/local/bundle\n" +
+			"COPY --from=0 --chown=app:app /myapp /myapp\n" +
+			"USER app\n" +
+			startCmd
 	}
 
 	dockerFile := getRubyImage + `
 ` + strings.Join(installSysDepCmd, "\n") + `
 ` + workDir + `
-` + copySource + `
-` + strings.Join(installDepCmd, "\n") + `
+` + sourceSegment + `
 ` + precompileCmd + `
-` + startCmd
+` + runtimeSegment
 
 	return dockerFile, nil
 }
@@ -68,4 +118,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
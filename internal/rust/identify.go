@@ -33,4 +33,17 @@ func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
 	)
 }
 
-var _ plan.Identifier = (*identify)(nil)
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
+	return GetBuildPlan(
+		GetMetaOptions{
+			Src:           options.Source,
+			SubmoduleName: options.SubmoduleName,
+			Config:        options.Config,
+		},
+	)
+}
+
+var (
+	_ plan.Identifier          = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
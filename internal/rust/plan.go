@@ -3,7 +3,6 @@ package rust
 import (
 	"log"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/spf13/afero"
@@ -39,6 +38,12 @@ const ConfigRustAssets = "rust.assets"
 // Useful for installing dependencies for runtime.
 const ConfigPreStartCommand = "pre_start_command"
 
+// FlagOpenSSL is the build plan flag indicating if OpenSSL is required.
+const FlagOpenSSL = "openssl"
+
+// metaPreStartCommand is the PlanMeta key of the pre-start command.
+const metaPreStartCommand = "preStartCommand"
+
 type rustPlanContext struct {
 	Src           afero.Fs
 	Config        plan.ImmutableProjectConfiguration
@@ -123,6 +128,38 @@ func needOpenssl(source afero.Fs) bool {
 	return false
 }
 
+// cargoTargetMarkers is the texts in Cargo.toml indicating the targets,
+// the workspace members or the dependencies in the source, which are
+// not in the placeholder to fetch the dependencies.
+var cargoTargetMarkers = []string{"[workspace]", "[lib]", "[[bin]]", "[[example]]", "[[test]]", "[[bench]]", "path =", "build ="}
+
+// getDependencyFiles gets Cargo.toml and Cargo.lock to fetch the crates
+// before copying the rest of the source. It returns nil for a workspace,
+// an application in a subdirectory, or a package with custom targets or
+// vendored crates.
+func getDependencyFiles(ctx *rustPlanContext) []string {
+	if getAppDir(ctx) != "." || utils.HasFile(ctx.Src, "vendor") {
+		return nil
+	}
+
+	cargoToml, err := utils.ReadFileToUTF8(ctx.Src, "Cargo.toml")
+	if err != nil {
+		return nil
+	}
+	for _, marker := range cargoTargetMarkers {
+		if strings.Contains(string(cargoToml), marker) {
+			return nil
+		}
+	}
+
+	files := append([]string{"Cargo.toml"}, utils.FoundFiles(ctx.Src, "Cargo.lock", "rust-toolchain", "rust-toolchain.toml")...)
+	if isDir, _ := afero.IsDir(ctx.Src, ".cargo"); isDir {
+		files = append(files, ".cargo/")
+	}
+
+	return files
+}
+
 func getBuildCommand(ctx *rustPlanContext) string {
 	return plan.Cast(ctx.Config.Get(plan.ConfigBuildCommand), cast.ToStringE).TakeOr("")
 }
@@ -137,22 +174,33 @@ func getPreStartCommand(ctx *rustPlanContext) string {
 
 // GetMeta gets the metadata of the Rust project.
 func GetMeta(options GetMetaOptions) types.PlanMeta {
+	return GetBuildPlan(options).PlanMeta()
+}
+
+// GetBuildPlan gets the typed build plan of the Rust project.
+func GetBuildPlan(options GetMetaOptions) types.BuildPlan {
 	ctx := &rustPlanContext{
 		Src:           options.Src,
 		SubmoduleName: options.SubmoduleName,
 		Config:        options.Config,
 	}
 
-	meta := types.PlanMeta{
-		"openssl": strconv.FormatBool(needOpenssl(ctx.Src)),
-		"entry":   getEntry(ctx),
-		"appDir":  getAppDir(ctx),
-		// assets/1:assets/2:...
-		"assets":          strings.Join(getAssets(ctx), ":"),
-		"buildCommand":    getBuildCommand(ctx),
-		"startCommand":    getStartCommand(ctx),
-		"preStartCommand": getPreStartCommand(ctx),
+	bp := types.BuildPlan{
+		Version:      types.BuildPlanVersion,
+		Type:         types.PlanTypeRust,
+		Entry:        getEntry(ctx),
+		AppDir:       getAppDir(ctx),
+		Assets:       getAssets(ctx),
+		BuildCommand: getBuildCommand(ctx),
+		StartCommand: getStartCommand(ctx),
+
+		DependencyFiles: getDependencyFiles(ctx),
+
+		Extra: types.PlanMeta{
+			metaPreStartCommand: getPreStartCommand(ctx),
+		},
 	}
+	bp.SetFlag(FlagOpenSSL, needOpenssl(ctx.Src))
 
-	return meta
+	return bp
 }
@@ -3,11 +3,11 @@ package rust
 
 import (
 	"bytes"
-	"strings"
 	"text/template"
 
 	_ "embed"
 
+	"github.com/zeabur/zbpack/internal/utils"
 	"github.com/zeabur/zbpack/pkg/packer"
 	"github.com/zeabur/zbpack/pkg/types"
 )
@@ -25,22 +25,50 @@ type TemplateContext struct {
 	BuildCommand    string
 	StartCommand    string
 	PreStartCommand string
+
+	// CrossCompile is a flag to build the binary on the build platform
+	// for the target platform. It is disabled with OpenSSL, whose
+	// libraries of the target platform are not in the builder.
+	CrossCompile bool
+
+	// CacheMounts is a flag to mount the cargo registry and the target
+	// directory as the BuildKit caches in cargo install.
+	CacheMounts bool
+
+	// DependencyFiles is the files to fetch the crates before copying
+	// the rest of the source. See types.BuildPlan.
+	DependencyFiles []string
+
+	// Hardening is a flag to run the binary as a non-root user.
+	Hardening bool
 }
 
 // GenerateDockerfile generates the Dockerfile for the Rust project.
 func GenerateDockerfile(meta types.PlanMeta) (string, error) {
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypeRust, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for the Rust project
+// from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
 	template := template.Must(
-		template.New("RustDockerfile").Parse(dockerTemplate),
+		template.New("RustDockerfile").
+			Funcs(template.FuncMap{"copy": utils.CopyFiles}).
+			Parse(dockerTemplate),
 	)
 
 	context := TemplateContext{
-		OpenSSL:         meta["openssl"] == "true",
-		Entry:           meta["entry"],
-		AppDir:          meta["appDir"],
-		Assets:          strings.FieldsFunc(meta["assets"], func(r rune) bool { return r == ':' }),
-		BuildCommand:    meta["buildCommand"],
-		StartCommand:    meta["startCommand"],
-		PreStartCommand: meta["preStartCommand"],
+		OpenSSL:         bp.Flag(FlagOpenSSL),
+		Entry:           bp.Entry,
+		AppDir:          bp.AppDir,
+		Assets:          bp.Assets,
+		BuildCommand:    bp.BuildCommand,
+		StartCommand:    bp.StartCommand,
+		PreStartCommand: bp.Extra[metaPreStartCommand],
+		CrossCompile:    !bp.Flag(FlagOpenSSL),
+		CacheMounts:     bp.Flag(types.FlagCacheMounts),
+		DependencyFiles: bp.DependencyFiles,
+		Hardening:       bp.Flag(types.FlagHardening),
 	}
 
 	var result bytes.Buffer
@@ -67,4 +95,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
@@ -206,3 +206,39 @@ func TestGetAssets(t *testing.T) {
 		assert.Empty(t, assets)
 	})
 }
+
+func TestGetDependencyFiles(t *testing.T) {
+	t.Parallel()
+
+	t.Run("package", func(t *testing.T) {
+		t.Parallel()
+
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "Cargo.toml", []byte("[package]\nname = \"app\"\n\n[dependencies]\nserde = \"1\"\n"), 0o644)
+		_ = afero.WriteFile(fs, "Cargo.lock", nil, 0o644)
+		_ = afero.WriteFile(fs, "src/main.rs", nil, 0o644)
+
+		ctx := &rustPlanContext{Src: fs, Config: plan.NewProjectConfigurationFromFs(fs, "")}
+		assert.Equal(t, []string{"Cargo.toml", "Cargo.lock"}, getDependencyFiles(ctx))
+	})
+
+	t.Run("workspace", func(t *testing.T) {
+		t.Parallel()
+
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "Cargo.toml", []byte("[workspace]\nmembers = [\"server\"]\n"), 0o644)
+
+		ctx := &rustPlanContext{Src: fs, Config: plan.NewProjectConfigurationFromFs(fs, "")}
+		assert.Nil(t, getDependencyFiles(ctx))
+	})
+
+	t.Run("custom binary", func(t *testing.T) {
+		t.Parallel()
+
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "Cargo.toml", []byte("[package]\nname = \"app\"\n\n[[bin]]\nname = \"server\"\npath = \"bin/server.rs\"\n"), 0o644)
+
+		ctx := &rustPlanContext{Src: fs, Config: plan.NewProjectConfigurationFromFs(fs, "")}
+		assert.Nil(t, getDependencyFiles(ctx))
+	})
+}
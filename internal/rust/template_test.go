@@ -1,15 +1,26 @@
 package rust_test
 
 import (
+	"os"
 	"slices"
 	"strings"
 	"testing"
 
+	"github.com/gkampitakis/go-snaps/snaps"
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 	"github.com/zeabur/zbpack/internal/rust"
 )
 
+func TestMain(m *testing.M) {
+	v := m.Run()
+
+	// After all tests have run `go-snaps` will sort snapshots
+	snaps.Clean(m, snaps.CleanOpts{Sort: true})
+
+	os.Exit(v)
+}
+
 func TestGenerateDockerfile_Assets(t *testing.T) {
 	t.Parallel()
 
@@ -93,6 +104,24 @@ func TestGenerateDockerfile_Runtime(t *testing.T) {
 	assert.Contains(t, dockerfile, `CMD ["/app/main"]`)
 }
 
+func TestGenerateDockerfile_Hardening(t *testing.T) {
+	t.Parallel()
+
+	meta := map[string]string{
+		"entry":     "entry",
+		"appDir":    "appDir",
+		"assets":    "",
+		"hardening": "true",
+	}
+
+	dockerfile, err := rust.GenerateDockerfile(meta)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assert.Contains(t, dockerfile, "COPY --from=post-builder /app /app\nRUN useradd --uid 10001 app\nUSER app\n")
+}
+
 func TestGenerateDockerfile_AppDir(t *testing.T) {
 	t.Parallel()
 
@@ -186,3 +215,83 @@ func TestGenerateDockerfile_Commands(t *testing.T) {
 	assert.Greater(t, preStartCommandLine, runtimeLine)
 	assert.Greater(t, startCommandLine, preStartCommandLine)
 }
+
+func TestGenerateDockerfile_CrossCompile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("without openssl", func(t *testing.T) {
+		t.Parallel()
+
+		meta := map[string]string{
+			"openssl": "false",
+			"entry":   "entry",
+			"appDir":  ".",
+		}
+
+		dockerfile, err := rust.GenerateDockerfile(meta)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Contains(t, dockerfile, "FROM --platform=$BUILDPLATFORM rust:1 AS builder")
+		assert.Contains(t, dockerfile, "arm64) target=aarch64-unknown-linux-gnu ;;")
+		assert.Contains(t, dockerfile, `cargo install --path "." --root /out --target "$target"`)
+		assert.Contains(t, dockerfile, "FROM rust:1-slim AS runtime")
+	})
+
+	t.Run("with openssl", func(t *testing.T) {
+		t.Parallel()
+
+		meta := map[string]string{
+			"openssl": "true",
+			"entry":   "entry",
+			"appDir":  ".",
+		}
+
+		dockerfile, err := rust.GenerateDockerfile(meta)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Contains(t, dockerfile, "FROM rust:1 AS builder")
+		assert.NotContains(t, dockerfile, "$BUILDPLATFORM")
+		assert.NotContains(t, dockerfile, "--target")
+	})
+}
+
+func TestGenerateDockerfile_CacheMounts(t *testing.T) {
+	t.Parallel()
+
+	meta := map[string]string{
+		"openssl":     "true",
+		"entry":       "entry",
+		"appDir":      ".",
+		"cacheMounts": "true",
+	}
+
+	dockerfile, err := rust.GenerateDockerfile(meta)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assert.True(t, strings.HasPrefix(dockerfile, "FROM rust:1 AS builder"))
+	assert.Contains(t, dockerfile, `RUN --mount=type=cache,target=/usr/local/cargo/registry --mount=type=cache,target=/usr/local/cargo/git --mount=type=cache,target=/app/target mkdir /out && cargo install --path "." --root /out --target-dir /app/target`)
+}
+
+func TestGenerateDockerfile_DependencyFiles(t *testing.T) {
+	t.Parallel()
+
+	meta := map[string]string{
+		"openssl":         "true",
+		"entry":           "entry",
+		"appDir":          ".",
+		"dependencyFiles": "Cargo.toml:Cargo.lock",
+	}
+
+	dockerfile, err := rust.GenerateDockerfile(meta)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	snaps.MatchSnapshot(t, dockerfile)
+}
@@ -0,0 +1,230 @@
+package source
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/afero"
+)
+
+type archiveFsOptions struct {
+	sizeLimit int
+}
+
+// ArchiveFsOption is the option for NewArchiveFs.
+type ArchiveFsOption func(*archiveFsOptions)
+
+// ArchiveSizeLimit sets the maximum size of the archive in bytes,
+// both before and after decompression. The default is 1 GiB.
+func ArchiveSizeLimit(limit int) ArchiveFsOption {
+	return func(opts *archiveFsOptions) {
+		opts.sizeLimit = limit
+	}
+}
+
+const archiveSizeLimit = 1024 * 1024 * 1024 /* 1 GiB */
+
+// ErrUnsupportedArchive is the error when the archive is not
+// a zip, tar, tar.gz or tar.zst file.
+var ErrUnsupportedArchive = errors.New("unsupported archive format")
+
+// archive magic numbers to detect the format.
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	zipEmpty  = []byte("PK\x05\x06")
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	tarMagic  = []byte("ustar")
+)
+
+// tarMagicOffset is the offset of the magic in a tar header.
+const tarMagicOffset = 257
+
+// NewArchiveFs creates a read-only filesystem from the archive read from r.
+// The format (zip, tar, tar.gz or tar.zst) is detected from the content.
+//
+// The files in the archive are held in memory, and never extracted to the disk.
+// If the archive exceeds the size limit, it returns ErrOverSized.
+// Symbolic links and other special files in the archive are ignored.
+func NewArchiveFs(r io.Reader, options ...ArchiveFsOption) (afero.Fs, error) {
+	opts := &archiveFsOptions{sizeLimit: archiveSizeLimit}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	br := bufio.NewReaderSize(r, tarMagicOffset+len(tarMagic))
+	header, _ := br.Peek(tarMagicOffset + len(tarMagic))
+
+	switch {
+	case bytes.HasPrefix(header, zipMagic), bytes.HasPrefix(header, zipEmpty):
+		return newZipFs(br, opts.sizeLimit)
+	case bytes.HasPrefix(header, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("new gzip reader: %w", err)
+		}
+		defer func() {
+			_ = gr.Close()
+		}()
+
+		return newTarFs(gr, opts.sizeLimit)
+	case bytes.HasPrefix(header, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("new zstd reader: %w", err)
+		}
+		defer zr.Close()
+
+		return newTarFs(zr, opts.sizeLimit)
+	case len(header) > tarMagicOffset && bytes.HasPrefix(header[tarMagicOffset:], tarMagic):
+		return newTarFs(br, opts.sizeLimit)
+	}
+
+	return nil, ErrUnsupportedArchive
+}
+
+// IsArchiveFile checks if the file name has the extension of
+// an archive which NewArchiveFs supports.
+func IsArchiveFile(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz", ".tar.zst", ".tzst"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// TrimArchiveExt removes the archive extension from the file name,
+// for example, "app" of "app.tar.gz".
+func TrimArchiveExt(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".tar.gz", ".tar.zst", ".zip", ".tar", ".tgz", ".tzst"} {
+		if strings.HasSuffix(lower, ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
+func newZipFs(r io.Reader, limit int) (afero.Fs, error) {
+	b, n, err := ReadLimited(r, limit)
+	if err != nil {
+		return nil, fmt.Errorf("read zip archive: %w", err)
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(b), n)
+	if err != nil {
+		return nil, fmt.Errorf("new zip reader: %w", err)
+	}
+
+	// The sizes in the headers are verified by archive/zip when reading,
+	// so it is safe to check the decompressed size with them.
+	var size uint64
+	for _, f := range zipReader.File {
+		size += f.UncompressedSize64
+		if size > uint64(limit) {
+			return nil, ErrOverSized
+		}
+	}
+
+	// Unlike zipfs, the parent directories are created even if the
+	// archive does not contain the entries of them.
+	fs := afero.NewMemMapFs()
+	for _, f := range zipReader.File {
+		mode := f.Mode()
+		if !mode.IsDir() && !mode.IsRegular() {
+			continue
+		}
+
+		content, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open %s in zip archive: %w", f.Name, err)
+		}
+		err = writeArchiveEntry(fs, f.Name, mode, content)
+		_ = content.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newArchiveMemFs(fs), nil
+}
+
+func newTarFs(r io.Reader, limit int) (afero.Fs, error) {
+	fs := afero.NewMemMapFs()
+	tr := tar.NewReader(r)
+
+	var size int64
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read tar archive: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeDir && header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		size += header.Size
+		if size > int64(limit) {
+			return nil, ErrOverSized
+		}
+
+		if err := writeArchiveEntry(fs, header.Name, header.FileInfo().Mode(), tr); err != nil {
+			return nil, err
+		}
+	}
+
+	return newArchiveMemFs(fs), nil
+}
+
+// writeArchiveEntry writes the directory or the regular file
+// in the archive to fs.
+func writeArchiveEntry(fs afero.Fs, name string, mode os.FileMode, r io.Reader) error {
+	// "../../etc/passwd" -> "/etc/passwd"
+	name = path.Clean("/" + name)
+	if name == "/" {
+		return nil
+	}
+
+	if mode.IsDir() {
+		return fs.MkdirAll(name, mode.Perm()|0o700)
+	}
+
+	if err := fs.MkdirAll(path.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("read %s in archive: %w", name, err)
+	}
+	return nil
+}
+
+// newArchiveMemFs returns the read-only view of the in-memory filesystem.
+// The paths are made absolute, since MemMapFs treats "a" and "/a" as
+// different files.
+func newArchiveMemFs(fs afero.Fs) afero.Fs {
+	return afero.NewReadOnlyFs(afero.NewBasePathFs(fs, "/"))
+}
@@ -0,0 +1,138 @@
+package source_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeabur/zbpack/internal/source"
+)
+
+var archiveFiles = map[string]string{
+	"package.json":      `{"name": "app"}`,
+	"src/index.js":      "console.log('hello')",
+	"../../etc/passwd":  "root",
+	"public/index.html": "<html></html>",
+}
+
+func newTar(t *testing.T, w io.Writer) {
+	t.Helper()
+
+	tw := tar.NewWriter(w)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "src/", Typeflag: tar.TypeDir, Mode: 0o755}))
+	for name, content := range archiveFiles {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}))
+	require.NoError(t, tw.Close())
+}
+
+func newArchive(t *testing.T, format string) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	switch format {
+	case "zip":
+		zw := zip.NewWriter(buf)
+		for name, content := range archiveFiles {
+			w, err := zw.Create(name)
+			require.NoError(t, err)
+			_, err = w.Write([]byte(content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+	case "tar":
+		newTar(t, buf)
+	case "tar.gz":
+		gw := gzip.NewWriter(buf)
+		newTar(t, gw)
+		require.NoError(t, gw.Close())
+	case "tar.zst":
+		zw, err := zstd.NewWriter(buf)
+		require.NoError(t, err)
+		newTar(t, zw)
+		require.NoError(t, zw.Close())
+	}
+
+	return buf.Bytes()
+}
+
+func TestNewArchiveFs(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []string{"zip", "tar", "tar.gz", "tar.zst"} {
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+
+			fs, err := source.NewArchiveFs(bytes.NewReader(newArchive(t, format)))
+			require.NoError(t, err)
+
+			content, err := afero.ReadFile(fs, "src/index.js")
+			require.NoError(t, err)
+			assert.Equal(t, "console.log('hello')", string(content))
+
+			exists, _ := afero.Exists(fs, "package.json")
+			assert.True(t, exists)
+
+			entries, err := afero.ReadDir(fs, "public")
+			require.NoError(t, err)
+			assert.Len(t, entries, 1)
+
+			err = afero.WriteFile(fs, "new.txt", []byte(""), 0o644)
+			assert.Error(t, err, "the filesystem should be read-only")
+		})
+	}
+}
+
+func TestNewArchiveFs_PathTraversal(t *testing.T) {
+	t.Parallel()
+
+	fs, err := source.NewArchiveFs(bytes.NewReader(newArchive(t, "tar.gz")))
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, "etc/passwd")
+	require.NoError(t, err)
+	assert.Equal(t, "root", string(content))
+
+	exists, _ := afero.Exists(fs, "link")
+	assert.False(t, exists)
+}
+
+func TestNewArchiveFs_OverSized(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []string{"zip", "tar.gz"} {
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := source.NewArchiveFs(bytes.NewReader(newArchive(t, format)), source.ArchiveSizeLimit(32))
+			assert.ErrorIs(t, err, source.ErrOverSized)
+		})
+	}
+}
+
+func TestNewArchiveFs_Unsupported(t *testing.T) {
+	t.Parallel()
+
+	_, err := source.NewArchiveFs(bytes.NewReader([]byte("hello, world")))
+	assert.ErrorIs(t, err, source.ErrUnsupportedArchive)
+}
+
+func TestTrimArchiveExt(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "app", source.TrimArchiveExt("app.tar.gz"))
+	assert.Equal(t, "app", source.TrimArchiveExt("app.ZIP"))
+	assert.Equal(t, "app.v1", source.TrimArchiveExt("app.v1.tzst"))
+	assert.True(t, source.IsArchiveFile("/srv/app.tar.zst"))
+	assert.False(t, source.IsArchiveFile("/srv/app.gz"))
+}
@@ -0,0 +1,386 @@
+package source
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+var _ afero.Fs = &CachedFs{}
+
+// ManifestFiles is the files which the identifiers usually read,
+// for prefetching them with CachedFs.Prefetch.
+var ManifestFiles = []string{
+	"zbpack.json", "Dockerfile", "package.json", "package-lock.json",
+	"yarn.lock", "pnpm-lock.yaml", "bun.lockb", "bun.lock", "deno.json",
+	"requirements.txt", "pyproject.toml", "Pipfile", "Pipfile.lock",
+	"poetry.lock", "pdm.lock", "uv.lock", "go.mod", "Cargo.toml",
+	"composer.json", "Gemfile", "Gemfile.lock", "pom.xml", "build.gradle",
+	"build.gradle.kts", "mix.exs", "pubspec.yaml", "build.zig", "index.html",
+}
+
+// cachedFileSizeLimit is the maximum size of a file to cache the content.
+// The larger files are read from the source every time.
+const cachedFileSizeLimit = 10 * 1024 * 1024 /* 10 MiB */
+
+// prefetchConcurrency is the number of files to prefetch at the same time.
+const prefetchConcurrency = 8
+
+// CachedFsCounters is the number of the calls to a CachedFs.
+type CachedFsCounters struct {
+	// Stat is the number of Stat calls to the source filesystem.
+	Stat int64
+	// Read is the number of files read from the source filesystem.
+	Read int64
+	// ReadDir is the number of directories listed from the source filesystem.
+	ReadDir int64
+	// Hits is the number of calls served from the cache.
+	Hits int64
+}
+
+// Requests returns the total number of calls to the source filesystem.
+func (c CachedFsCounters) Requests() int64 {
+	return c.Stat + c.Read + c.ReadDir
+}
+
+// cacheEntry is the cached result of a call, which is made only once
+// even if requested concurrently.
+type cacheEntry[T any] struct {
+	once   sync.Once
+	loaded atomic.Bool
+	value  T
+	err    error
+}
+
+// CachedFs is a read-only filesystem which memoizes the results of
+// Stat, reading files and listing directories of the source filesystem.
+//
+// It is intended for the remote sources, such as S3 and GitHub, whose
+// files do not change while planning. The errors are also cached.
+type CachedFs struct {
+	source afero.Fs
+
+	mu       sync.Mutex
+	stats    map[string]*cacheEntry[os.FileInfo]
+	contents map[string]*cacheEntry[[]byte]
+	dirs     map[string]*cacheEntry[[]os.FileInfo]
+
+	statCount    atomic.Int64
+	readCount    atomic.Int64
+	readDirCount atomic.Int64
+	hitCount     atomic.Int64
+}
+
+// NewCachedFs creates a CachedFs of the source filesystem.
+func NewCachedFs(source afero.Fs) *CachedFs {
+	return &CachedFs{
+		source:   source,
+		stats:    make(map[string]*cacheEntry[os.FileInfo]),
+		contents: make(map[string]*cacheEntry[[]byte]),
+		dirs:     make(map[string]*cacheEntry[[]os.FileInfo]),
+	}
+}
+
+// Counters returns the number of the calls made so far.
+func (fs *CachedFs) Counters() CachedFsCounters {
+	return CachedFsCounters{
+		Stat:    fs.statCount.Load(),
+		Read:    fs.readCount.Load(),
+		ReadDir: fs.readDirCount.Load(),
+		Hits:    fs.hitCount.Load(),
+	}
+}
+
+// Prefetch reads the files in parallel into the cache.
+// The files which do not exist are skipped.
+func (fs *CachedFs) Prefetch(names ...string) {
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, prefetchConcurrency)
+
+	for _, name := range names {
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func() {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			info, err := fs.Stat(name)
+			if err != nil || info.IsDir() || info.Size() > cachedFileSizeLimit {
+				return
+			}
+			_, _ = fs.readFile(cleanCachePath(name))
+		}()
+	}
+
+	wg.Wait()
+}
+
+// cleanCachePath returns the key of name in the cache, which is
+// the path relative to the root, and an empty string for the root.
+// It is also the name passed to the source filesystem.
+func cleanCachePath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// load returns the cached result of key in m, or calls fetch to get it.
+func load[T any](fs *CachedFs, m map[string]*cacheEntry[T], key string, fetch func() (T, error)) (T, error) {
+	fs.mu.Lock()
+	entry, ok := m[key]
+	if !ok {
+		entry = &cacheEntry[T]{}
+		m[key] = entry
+	}
+	fs.mu.Unlock()
+
+	hit := true
+	entry.once.Do(func() {
+		hit = false
+		entry.value, entry.err = fetch()
+		entry.loaded.Store(true)
+	})
+	if hit {
+		fs.hitCount.Add(1)
+	}
+
+	return entry.value, entry.err
+}
+
+// loaded returns the cached result of key in m if it has been loaded.
+func loaded[T any](fs *CachedFs, m map[string]*cacheEntry[T], key string) (*cacheEntry[T], bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entry, ok := m[key]
+	if !ok || !entry.loaded.Load() {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Stat returns the FileInfo of the file.
+//
+// If the parent directory has been listed, the result comes from
+// the listing without calling the source filesystem.
+func (fs *CachedFs) Stat(name string) (os.FileInfo, error) {
+	key := cleanCachePath(name)
+
+	return load(fs, fs.stats, key, func() (os.FileInfo, error) {
+		if key != "" {
+			parent := path.Dir(key)
+			if parent == "." {
+				parent = ""
+			}
+
+			if dir, ok := loaded(fs, fs.dirs, parent); ok && dir.err == nil {
+				for _, info := range dir.value {
+					if info.Name() == path.Base(key) {
+						return info, nil
+					}
+				}
+				return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+			}
+		}
+
+		fs.statCount.Add(1)
+		return fs.source.Stat(key)
+	})
+}
+
+func (fs *CachedFs) readFile(key string) ([]byte, error) {
+	return load(fs, fs.contents, key, func() ([]byte, error) {
+		fs.readCount.Add(1)
+		return afero.ReadFile(fs.source, key)
+	})
+}
+
+func (fs *CachedFs) readDir(key string) ([]os.FileInfo, error) {
+	return load(fs, fs.dirs, key, func() ([]os.FileInfo, error) {
+		fs.readDirCount.Add(1)
+
+		f, err := fs.source.Open(key)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+
+		return f.Readdir(-1)
+	})
+}
+
+// Open opens the file. The content of the file is read to the memory
+// and cached, unless it is larger than 10 MiB.
+func (fs *CachedFs) Open(name string) (afero.File, error) {
+	info, err := fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	key := cleanCachePath(name)
+	if info.IsDir() {
+		return &cachedFile{fs: fs, name: name, key: key, info: info, Reader: bytes.NewReader(nil)}, nil
+	}
+	if info.Size() > cachedFileSizeLimit {
+		fs.readCount.Add(1)
+		return fs.source.Open(key)
+	}
+
+	content, err := fs.readFile(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachedFile{fs: fs, name: name, key: key, info: info, Reader: bytes.NewReader(content)}, nil
+}
+
+// OpenFile opens the file for reading. Writing is not supported.
+func (fs *CachedFs) OpenFile(name string, flag int, _ os.FileMode) (afero.File, error) {
+	if flag&(os.O_CREATE|os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_TRUNC) != 0 {
+		return nil, ErrReadonly
+	}
+
+	return fs.Open(name)
+}
+
+// Name returns the name of this filesystem.
+func (fs *CachedFs) Name() string {
+	return "CachedFs(" + fs.source.Name() + ")"
+}
+
+// Create is not supported since CachedFs is read-only.
+func (fs *CachedFs) Create(_ string) (afero.File, error) {
+	return nil, ErrReadonly
+}
+
+// Mkdir is not supported since CachedFs is read-only.
+func (fs *CachedFs) Mkdir(_ string, _ os.FileMode) error {
+	return ErrReadonly
+}
+
+// MkdirAll is not supported since CachedFs is read-only.
+func (fs *CachedFs) MkdirAll(_ string, _ os.FileMode) error {
+	return ErrReadonly
+}
+
+// Remove is not supported since CachedFs is read-only.
+func (fs *CachedFs) Remove(_ string) error {
+	return ErrReadonly
+}
+
+// RemoveAll is not supported since CachedFs is read-only.
+func (fs *CachedFs) RemoveAll(_ string) error {
+	return ErrReadonly
+}
+
+// Rename is not supported since CachedFs is read-only.
+func (fs *CachedFs) Rename(_, _ string) error {
+	return ErrReadonly
+}
+
+// Chmod is not supported since CachedFs is read-only.
+func (fs *CachedFs) Chmod(_ string, _ os.FileMode) error {
+	return ErrReadonly
+}
+
+// Chown is not supported since CachedFs is read-only.
+func (fs *CachedFs) Chown(_ string, _, _ int) error {
+	return ErrReadonly
+}
+
+// Chtimes is not supported since CachedFs is read-only.
+func (fs *CachedFs) Chtimes(_ string, _ time.Time, _ time.Time) error {
+	return ErrReadonly
+}
+
+var _ afero.File = &cachedFile{}
+
+// cachedFile is a file or a directory opened from CachedFs.
+type cachedFile struct {
+	*bytes.Reader
+
+	fs   *CachedFs
+	name string
+	key  string
+	info os.FileInfo
+
+	// dirOffset is the number of directory entries read by Readdir.
+	dirOffset int
+}
+
+func (f *cachedFile) Close() error {
+	return nil
+}
+
+func (f *cachedFile) Name() string {
+	return f.name
+}
+
+func (f *cachedFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.info.IsDir() {
+		return nil, ErrNotDir
+	}
+
+	entries, err := f.fs.readDir(f.key)
+	if err != nil {
+		return nil, err
+	}
+
+	entries = entries[f.dirOffset:]
+	if count > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		entries = entries[:min(count, len(entries))]
+	}
+	f.dirOffset += len(entries)
+
+	return append([]os.FileInfo(nil), entries...), nil
+}
+
+func (f *cachedFile) Readdirnames(n int) ([]string, error) {
+	infos, err := f.Readdir(n)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names, nil
+}
+
+func (f *cachedFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *cachedFile) Sync() error {
+	return ErrReadonly
+}
+
+func (f *cachedFile) Truncate(_ int64) error {
+	return ErrReadonly
+}
+
+func (f *cachedFile) Write(_ []byte) (int, error) {
+	return 0, ErrReadonly
+}
+
+func (f *cachedFile) WriteAt(_ []byte, _ int64) (int, error) {
+	return 0, ErrReadonly
+}
+
+func (f *cachedFile) WriteString(_ string) (int, error) {
+	return 0, ErrReadonly
+}
@@ -0,0 +1,159 @@
+package source_test
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeabur/zbpack/internal/source"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+	"github.com/zeabur/zbpack/pkg/zeaburpack"
+)
+
+// countingFs counts the calls to the filesystem.
+type countingFs struct {
+	afero.Fs
+
+	stat atomic.Int64
+	open atomic.Int64
+}
+
+func (fs *countingFs) Stat(name string) (os.FileInfo, error) {
+	fs.stat.Add(1)
+	return fs.Fs.Stat(name)
+}
+
+func (fs *countingFs) Open(name string) (afero.File, error) {
+	fs.open.Add(1)
+	return fs.Fs.Open(name)
+}
+
+func newCountingFs(files map[string]string) *countingFs {
+	fs := afero.NewMemMapFs()
+	for name, content := range files {
+		_ = afero.WriteFile(fs, name, []byte(content), 0o644)
+	}
+	return &countingFs{Fs: fs}
+}
+
+func TestCachedFs(t *testing.T) {
+	t.Parallel()
+
+	src := newCountingFs(map[string]string{
+		"pyproject.toml": "[project]\nname = \"app\"\n",
+		"src/main.py":    "print('hello')",
+	})
+	fs := source.NewCachedFs(src)
+
+	for range 3 {
+		content, err := afero.ReadFile(fs, "pyproject.toml")
+		require.NoError(t, err)
+		assert.Equal(t, "[project]\nname = \"app\"\n", string(content))
+
+		exists, err := afero.Exists(fs, "./not-exist.txt")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	}
+
+	assert.Equal(t, int64(2), src.stat.Load())
+	assert.Equal(t, int64(1), src.open.Load())
+
+	counters := fs.Counters()
+	assert.Equal(t, source.CachedFsCounters{Stat: 2, Read: 1, ReadDir: 0, Hits: 6}, counters)
+	assert.Equal(t, int64(3), counters.Requests())
+
+	err := afero.WriteFile(fs, "new.txt", nil, 0o644)
+	assert.ErrorIs(t, err, source.ErrReadonly)
+}
+
+func TestCachedFs_ReadDir(t *testing.T) {
+	t.Parallel()
+
+	src := newCountingFs(map[string]string{
+		"src/a.py": "",
+		"src/b.py": "",
+		"src/c.py": "",
+	})
+	fs := source.NewCachedFs(src)
+
+	entries, err := afero.ReadDir(fs, "src")
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	// The files in a listed directory are stat from the listing.
+	stats := src.stat.Load()
+	for _, name := range []string{"src/a.py", "/src/b.py", "src/not-exist.py"} {
+		_, _ = fs.Stat(name)
+	}
+	assert.Equal(t, stats, src.stat.Load())
+
+	f, err := fs.Open("src")
+	require.NoError(t, err)
+	names, err := f.Readdirnames(2)
+	require.NoError(t, err)
+	assert.Len(t, names, 2)
+	names, err = f.Readdirnames(2)
+	require.NoError(t, err)
+	assert.Len(t, names, 1)
+	_, err = f.Readdirnames(2)
+	assert.Error(t, err)
+
+	assert.Equal(t, int64(1), fs.Counters().ReadDir)
+}
+
+func TestCachedFs_Prefetch(t *testing.T) {
+	t.Parallel()
+
+	src := newCountingFs(map[string]string{
+		"package.json": "{}",
+		"go.mod":       "module app",
+	})
+	fs := source.NewCachedFs(src)
+
+	fs.Prefetch(source.ManifestFiles...)
+	assert.Equal(t, int64(2), fs.Counters().Read)
+
+	// concurrent reads after prefetching should not touch the source
+	opens := src.open.Load()
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = afero.ReadFile(fs, "package.json")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, opens, src.open.Load())
+}
+
+func TestCachedFs_Plan(t *testing.T) {
+	t.Parallel()
+
+	src := newCountingFs(map[string]string{
+		"pyproject.toml": "[project]\nname = \"app\"\ndependencies = [\"fastapi\"]\n",
+		"uv.lock":        "version = 1\n",
+		"main.py":        "import fastapi\n",
+	})
+	fs := source.NewCachedFs(src)
+
+	config := plan.NewProjectConfigurationFromFs(fs, "")
+	planner := plan.NewPlanner(
+		&plan.NewPlannerOptions{Source: fs, Config: config},
+		zeaburpack.SupportedIdentifiers(config)...,
+	)
+	planType, _, err := planner.(plan.ErrorPlanner).PlanE()
+	require.NoError(t, err)
+	assert.Equal(t, types.PlanTypePython, planType)
+
+	// Every file is read at most once, however many times the
+	// identifiers read it.
+	counters := fs.Counters()
+	assert.LessOrEqual(t, counters.Read, int64(3))
+	assert.Greater(t, counters.Hits, counters.Requests())
+}
@@ -0,0 +1,196 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+type forgeFsOptions struct {
+	ref        string
+	baseURL    string
+	httpClient *http.Client
+	ctx        context.Context
+}
+
+// ForgeFsOption is the option for NewGitLabFs, NewGiteaFs and NewBitbucketFs.
+type ForgeFsOption func(*forgeFsOptions)
+
+// ForgeRef sets the branch, tag or commit SHA of the repository.
+// By default, the default branch is used.
+func ForgeRef(ref string) ForgeFsOption {
+	return func(opts *forgeFsOptions) {
+		opts.ref = ref
+	}
+}
+
+// ForgeBaseURL sets the URL of the self-hosted instance,
+// for example, "https://gitlab.example.com".
+func ForgeBaseURL(baseURL string) ForgeFsOption {
+	return func(opts *forgeFsOptions) {
+		opts.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// ForgeHTTPClient sets the HTTP client to send the requests.
+func ForgeHTTPClient(client *http.Client) ForgeFsOption {
+	return func(opts *forgeFsOptions) {
+		opts.httpClient = client
+	}
+}
+
+// ForgeContext sets the context to cancel downloading the repository.
+func ForgeContext(ctx context.Context) ForgeFsOption {
+	return func(opts *forgeFsOptions) {
+		opts.ctx = ctx
+	}
+}
+
+func newForgeFsOptions(defaultBaseURL string, options []ForgeFsOption) *forgeFsOptions {
+	opts := &forgeFsOptions{
+		baseURL:    defaultBaseURL,
+		httpClient: http.DefaultClient,
+		ctx:        context.Background(),
+	}
+	for _, opt := range options {
+		opt(opts)
+	}
+	return opts
+}
+
+// NewGitLabFs creates a filesystem from the archive of a GitLab project.
+//
+// The project is the full path of the project, for example,
+// "acme/backend/api". The token can be a personal, group or
+// project access token.
+func NewGitLabFs(project string, token *string, options ...ForgeFsOption) (afero.Fs, error) {
+	opts := newForgeFsOptions("https://gitlab.com", options)
+
+	archiveURL := opts.baseURL + "/api/v4/projects/" + url.PathEscape(project) + "/repository/archive.zip"
+	if opts.ref != "" {
+		archiveURL += "?sha=" + url.QueryEscape(opts.ref)
+	}
+
+	return opts.getZipball(archiveURL, func(req *http.Request) {
+		if token != nil && *token != "" {
+			req.Header.Set("PRIVATE-TOKEN", *token)
+		}
+	})
+}
+
+// NewGiteaFs creates a filesystem from the archive of a Gitea
+// or Forgejo repository.
+func NewGiteaFs(owner, name string, token *string, options ...ForgeFsOption) (afero.Fs, error) {
+	opts := newForgeFsOptions("https://gitea.com", options)
+	authorize := func(req *http.Request) {
+		if token != nil && *token != "" {
+			req.Header.Set("Authorization", "token "+*token)
+		}
+	}
+
+	repoURL := opts.baseURL + "/api/v1/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(name)
+
+	ref := opts.ref
+	if ref == "" {
+		var repo struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		if err := opts.getJSON(repoURL, authorize, &repo); err != nil {
+			return nil, fmt.Errorf("get default branch: %w", err)
+		}
+		ref = repo.DefaultBranch
+	}
+
+	return opts.getZipball(repoURL+"/archive/"+url.PathEscape(ref)+".zip", authorize)
+}
+
+// NewBitbucketFs creates a filesystem from the archive of a Bitbucket
+// Cloud repository.
+//
+// The token is either an access token, or "username:app-password"
+// for an app password.
+func NewBitbucketFs(workspace, name string, token *string, options ...ForgeFsOption) (afero.Fs, error) {
+	opts := newForgeFsOptions("https://bitbucket.org", options)
+	authorize := func(req *http.Request) {
+		if token == nil || *token == "" {
+			return
+		}
+		if username, password, ok := strings.Cut(*token, ":"); ok {
+			req.SetBasicAuth(username, password)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+*token)
+		}
+	}
+
+	// The API is also served at "/!api" of the website.
+	apiURL := "https://api.bitbucket.org/2.0"
+	if opts.baseURL != "https://bitbucket.org" {
+		apiURL = opts.baseURL + "/!api/2.0"
+	}
+	repoPath := "/" + url.PathEscape(workspace) + "/" + url.PathEscape(name)
+
+	ref := opts.ref
+	if ref == "" {
+		var repo struct {
+			MainBranch struct {
+				Name string `json:"name"`
+			} `json:"mainbranch"`
+		}
+		if err := opts.getJSON(apiURL+"/repositories"+repoPath, authorize, &repo); err != nil {
+			return nil, fmt.Errorf("get default branch: %w", err)
+		}
+		ref = repo.MainBranch.Name
+	}
+
+	return opts.getZipball(opts.baseURL+repoPath+"/get/"+url.PathEscape(ref)+".zip", authorize)
+}
+
+// get sends a GET request, and returns the response if it is successful.
+func (opts *forgeFsOptions) get(u string, authorize func(*http.Request)) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(opts.ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	authorize(req)
+
+	resp, err := opts.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: unexpected status %s", req.URL.Redacted(), resp.Status)
+	}
+
+	return resp, nil
+}
+
+func (opts *forgeFsOptions) getJSON(u string, authorize func(*http.Request), v any) error {
+	resp, err := opts.get(u, authorize)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(v)
+}
+
+func (opts *forgeFsOptions) getZipball(u string, authorize func(*http.Request)) (afero.Fs, error) {
+	resp, err := opts.get(u, authorize)
+	if err != nil {
+		return nil, fmt.Errorf("get archive: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	return newZipballFs(resp.Body)
+}
@@ -0,0 +1,210 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+type gitFsOptions struct {
+	ref      string
+	subpath  string
+	token    *string
+	username string
+	ctx      context.Context
+}
+
+// GitFsOption is the option for NewGitFs.
+type GitFsOption func(*gitFsOptions)
+
+// GitRef sets the branch, tag or commit SHA to check out.
+// By default, the HEAD of the remote is checked out.
+func GitRef(ref string) GitFsOption {
+	return func(opts *gitFsOptions) {
+		opts.ref = ref
+	}
+}
+
+// GitSubpath sets the directory in the repository to use as the root.
+func GitSubpath(subpath string) GitFsOption {
+	return func(opts *gitFsOptions) {
+		opts.subpath = subpath
+	}
+}
+
+// GitToken sets the token to access a repository over HTTPS.
+func GitToken(token *string) GitFsOption {
+	return func(opts *gitFsOptions) {
+		opts.token = token
+	}
+}
+
+// GitUsername sets the username to send with the token. It depends on
+// the hosting service, for example, "oauth2" for GitLab. By default, it is
+// "x-access-token", which GitHub and Gitea accept.
+func GitUsername(username string) GitFsOption {
+	return func(opts *gitFsOptions) {
+		opts.username = username
+	}
+}
+
+// GitContext sets the context to cancel fetching the repository.
+// The git processes are killed when the context is done.
+func GitContext(ctx context.Context) GitFsOption {
+	return func(opts *gitFsOptions) {
+		opts.ctx = ctx
+	}
+}
+
+// GitFs is a Git repository checked out to a temporary directory.
+//
+// Since the directory is on the disk, it can also be used as a build context.
+// Call Close to remove the directory.
+type GitFs struct {
+	afero.Fs
+
+	tempDir string
+	dir     string
+}
+
+// Dir returns the directory of the checkout on the disk,
+// including the subpath if specified.
+func (fs *GitFs) Dir() string {
+	return fs.dir
+}
+
+// Close removes the checkout.
+func (fs *GitFs) Close() error {
+	return os.RemoveAll(fs.tempDir)
+}
+
+// commitPattern matches a full commit SHA.
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// NewGitFs fetches the Git repository at url to a temporary directory,
+// with the git command-line tool.
+//
+// The url can be any remote which git supports, for example, an HTTPS URL,
+// an SSH URL, a file:// URL or the path to a local (bare) repository.
+// Only the specified ref is fetched, without the history.
+func NewGitFs(url string, options ...GitFsOption) (*GitFs, error) {
+	opts := &gitFsOptions{username: "x-access-token", ctx: context.Background()}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	tempDir, err := os.MkdirTemp("", "zbpack-git-*")
+	if err != nil {
+		return nil, fmt.Errorf("create temporary directory: %w", err)
+	}
+
+	fs := &GitFs{tempDir: tempDir, dir: tempDir}
+	if err := fs.fetch(url, opts); err != nil {
+		_ = fs.Close()
+		return nil, err
+	}
+
+	if opts.subpath != "" {
+		subpath := path.Clean("/" + opts.subpath)
+		fs.dir = filepath.Join(tempDir, filepath.FromSlash(subpath))
+
+		info, err := os.Stat(fs.dir)
+		if err != nil || !info.IsDir() {
+			_ = fs.Close()
+			return nil, fmt.Errorf("subpath %s is not a directory in the repository", opts.subpath)
+		}
+	}
+
+	fs.Fs = afero.NewBasePathFs(afero.NewOsFs(), fs.dir)
+	return fs, nil
+}
+
+func (fs *GitFs) fetch(url string, opts *gitFsOptions) error {
+	// The credential is passed with the environment variables instead of
+	// "-c", so that it is not visible in the command line of the process.
+	var env []string
+	if opts.token != nil && *opts.token != "" && strings.HasPrefix(url, "https://") {
+		credential := base64.StdEncoding.EncodeToString([]byte(opts.username + ":" + *opts.token))
+		env = append(env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+credential,
+		)
+	}
+
+	ref := opts.ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	if err := fs.git(opts.ctx, nil, "init", "--quiet"); err != nil {
+		return err
+	}
+	if err := fs.git(opts.ctx, nil, "remote", "add", "origin", url); err != nil {
+		return err
+	}
+
+	// Fetching a commit by SHA requires the server to allow it, which the
+	// common hosting services and the local repositories do. Otherwise,
+	// fall back to fetching the whole history.
+	if err := fs.git(opts.ctx, env, "fetch", "--quiet", "--depth=1", "origin", ref); err != nil {
+		if !commitPattern.MatchString(ref) || opts.ctx.Err() != nil {
+			return err
+		}
+		if err := fs.git(opts.ctx, env, "fetch", "--quiet", "origin"); err != nil {
+			return err
+		}
+		return fs.git(opts.ctx, nil, "checkout", "--quiet", ref)
+	}
+
+	return fs.git(opts.ctx, nil, "checkout", "--quiet", "FETCH_HEAD")
+}
+
+// git runs the git command in the checkout directory, with the extra
+// environment variables in env.
+func (fs *GitFs) git(ctx context.Context, env []string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = fs.tempDir
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), env...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("git %s: %w", args[0], ctxErr)
+		}
+		return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// IsGitURL checks if s is the URL of a remote Git repository: an HTTPS,
+// SSH, git:// or file:// URL, or the scp-like syntax "git@host:repo".
+func IsGitURL(s string) bool {
+	for _, prefix := range []string{"https://", "http://", "ssh://", "git://", "git+https://", "git+ssh://", "file://", "git@"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseGitURL splits the Git URL in the form of Docker build context,
+// "<url>#<ref>:<subpath>", to the URL of the remote, the ref and the subpath.
+// Both ref and subpath are optional.
+func ParseGitURL(s string) (url, ref, subpath string) {
+	url, fragment, _ := strings.Cut(s, "#")
+	ref, subpath, _ = strings.Cut(fragment, ":")
+	url = strings.TrimPrefix(url, "git+")
+	return url, ref, subpath
+}
@@ -0,0 +1,188 @@
+package source_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeabur/zbpack/internal/source"
+)
+
+// newBareRepo creates a bare repository with two commits on main,
+// a "v1" tag on the first one, and a "feature" branch.
+// It returns the path of the bare repository and the SHA of the first commit.
+func newBareRepo(t *testing.T) (string, string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	work := filepath.Join(root, "work")
+	bare := filepath.Join(root, "repo.git")
+
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=zbpack", "GIT_AUTHOR_EMAIL=zbpack@example.com",
+			"GIT_COMMITTER_NAME=zbpack", "GIT_COMMITTER_EMAIL=zbpack@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name, content string) {
+		t.Helper()
+		p := filepath.Join(work, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+
+	require.NoError(t, os.MkdirAll(work, 0o755))
+	git(work, "init", "--quiet", "--initial-branch=main")
+	write("version.txt", "1")
+	write("services/api/main.go", "package main")
+	git(work, "add", ".")
+	git(work, "commit", "--quiet", "-m", "first")
+	first := git(work, "rev-parse", "HEAD")
+	git(work, "tag", "v1")
+
+	git(work, "checkout", "--quiet", "-b", "feature")
+	write("version.txt", "feature")
+	git(work, "commit", "--quiet", "-am", "feature")
+
+	git(work, "checkout", "--quiet", "main")
+	write("version.txt", "2")
+	git(work, "commit", "--quiet", "-am", "second")
+
+	git(root, "clone", "--quiet", "--bare", work, bare)
+
+	return bare, first
+}
+
+func readVersion(t *testing.T, fs afero.Fs) string {
+	t.Helper()
+
+	content, err := afero.ReadFile(fs, "version.txt")
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestNewGitFs(t *testing.T) {
+	bare, first := newBareRepo(t)
+
+	tests := []struct {
+		name    string
+		url     string
+		options []source.GitFsOption
+		want    string
+	}{
+		{name: "default branch", url: bare, want: "2"},
+		{name: "file URL", url: "file://" + bare, want: "2"},
+		{name: "branch", url: "file://" + bare, options: []source.GitFsOption{source.GitRef("feature")}, want: "feature"},
+		{name: "tag", url: bare, options: []source.GitFsOption{source.GitRef("v1")}, want: "1"},
+		{name: "commit", url: "file://" + bare, options: []source.GitFsOption{source.GitRef(first)}, want: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, err := source.NewGitFs(tt.url, tt.options...)
+			require.NoError(t, err)
+			defer func() { _ = fs.Close() }()
+
+			assert.Equal(t, tt.want, readVersion(t, fs))
+		})
+	}
+}
+
+func TestNewGitFs_Subpath(t *testing.T) {
+	bare, _ := newBareRepo(t)
+
+	fs, err := source.NewGitFs(bare, source.GitSubpath("services/api"))
+	require.NoError(t, err)
+
+	exists, _ := afero.Exists(fs, "main.go")
+	assert.True(t, exists)
+	assert.FileExists(t, filepath.Join(fs.Dir(), "main.go"))
+
+	require.NoError(t, fs.Close())
+	assert.NoDirExists(t, fs.Dir())
+
+	_, err = source.NewGitFs(bare, source.GitSubpath("not-exist"))
+	assert.Error(t, err)
+}
+
+func TestNewGitFs_NotExist(t *testing.T) {
+	bare, _ := newBareRepo(t)
+
+	_, err := source.NewGitFs(bare, source.GitRef("not-exist"))
+	assert.Error(t, err)
+
+	_, err = source.NewGitFs(filepath.Join(t.TempDir(), "not-exist.git"))
+	assert.Error(t, err)
+}
+
+func TestParseGitURL(t *testing.T) {
+	t.Parallel()
+
+	url, ref, subpath := source.ParseGitURL("https://example.com/acme/app.git#v1.0:services/api")
+	assert.Equal(t, "https://example.com/acme/app.git", url)
+	assert.Equal(t, "v1.0", ref)
+	assert.Equal(t, "services/api", subpath)
+
+	url, ref, subpath = source.ParseGitURL("git+ssh://git@example.com/acme/app.git")
+	assert.Equal(t, "ssh://git@example.com/acme/app.git", url)
+	assert.Empty(t, ref)
+	assert.Empty(t, subpath)
+
+	assert.True(t, source.IsGitURL("file:///srv/app.git"))
+	assert.True(t, source.IsGitURL("git@example.com:acme/app.git"))
+	assert.False(t, source.IsGitURL("/srv/app"))
+	assert.False(t, source.IsGitURL("s3://bucket/app"))
+}
+
+func TestNewGitFs_Canceled(t *testing.T) {
+	bare, _ := newBareRepo(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := source.NewGitFs(bare, source.GitContext(ctx))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNewGitFs_Token(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	headers := make(chan string, 16)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	// The certificate of the test server is self-signed.
+	t.Setenv("GIT_SSL_NO_VERIFY", "true")
+
+	token := "glpat-token"
+	_, err := source.NewGitFs(server.URL+"/acme/app.git", source.GitToken(&token), source.GitUsername("oauth2"))
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), token)
+
+	require.NotEmpty(t, headers)
+	assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("oauth2:"+token)), <-headers)
+}
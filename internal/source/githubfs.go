@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/google/go-github/v63/github"
 	"github.com/spf13/afero"
@@ -14,9 +15,11 @@ import (
 )
 
 type githubFsOptions struct {
-	owner string
-	name  string
-	ref   string
+	owner   string
+	name    string
+	ref     string
+	baseURL string
+	ctx     context.Context
 }
 
 // GitHubFsOption is the option for NewGitHubFs.
@@ -29,18 +32,35 @@ func GitHubRef(ref string) GitHubFsOption {
 	}
 }
 
+// GitHubBaseURL sets the base URL of GitHub Enterprise Server,
+// for example, "https://github.example.com/". The "api/v3/" suffix
+// is added automatically if missing.
+func GitHubBaseURL(baseURL string) GitHubFsOption {
+	return func(fs *githubFsOptions) {
+		fs.baseURL = baseURL
+	}
+}
+
+// GitHubContext sets the context to cancel downloading the repository.
+func GitHubContext(ctx context.Context) GitHubFsOption {
+	return func(fs *githubFsOptions) {
+		fs.ctx = ctx
+	}
+}
+
 // NewGitHubFs creates a new github filesystem.
 func NewGitHubFs(repoOwner, repoName string, token *string, options ...GitHubFsOption) (afero.Fs, error) {
 	fsOptions := &githubFsOptions{
 		owner: repoOwner,
 		name:  repoName,
+		ctx:   context.Background(),
 	}
 
 	for _, opt := range options {
 		opt(fsOptions)
 	}
 
-	fs, err := getRefZipFs(fsOptions.owner, fsOptions.name, fsOptions.ref, token)
+	fs, err := getRefZipFs(fsOptions, token)
 	if err != nil {
 		return nil, fmt.Errorf("get ref tarball fs: %w", err)
 	}
@@ -50,20 +70,31 @@ func NewGitHubFs(repoOwner, repoName string, token *string, options ...GitHubFsO
 
 const zipFileSizeLimit = 1024 * 1024 * 1024 /* 1 GiB */
 
-func getRefZipFs(owner, name, ref string, token *string) (afero.Fs, error) {
+func getRefZipFs(opts *githubFsOptions, token *string) (afero.Fs, error) {
 	githubClient := github.NewClient(nil)
+	if opts.baseURL != "" {
+		var err error
+		githubClient, err = githubClient.WithEnterpriseURLs(opts.baseURL, opts.baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GitHub Enterprise URL: %w", err)
+		}
+	}
 	if token != nil {
 		githubClient = githubClient.WithAuthToken(*token)
 	}
 
-	repo, _, err := githubClient.Repositories.GetArchiveLink(context.Background(), owner, name, github.Zipball, &github.RepositoryContentGetOptions{
-		Ref: ref,
+	repo, _, err := githubClient.Repositories.GetArchiveLink(opts.ctx, opts.owner, opts.name, github.Zipball, &github.RepositoryContentGetOptions{
+		Ref: opts.ref,
 	}, 1)
 	if err != nil {
 		return nil, fmt.Errorf("get archive link: %w", err)
 	}
 
-	content, err := githubClient.Client().Get(repo.String())
+	req, err := http.NewRequestWithContext(opts.ctx, http.MethodGet, repo.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("get tarball: %w", err)
+	}
+	content, err := githubClient.Client().Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("get tarball: %w", err)
 	}
@@ -71,9 +102,14 @@ func getRefZipFs(owner, name, ref string, token *string) (afero.Fs, error) {
 		_ = content.Body.Close()
 	}()
 
-	b, n, err := ReadLimited(content.Body, zipFileSizeLimit+1)
+	return newZipballFs(content.Body)
+}
+
+// newZipballFs creates the filesystem of the zipball of a repository.
+func newZipballFs(r io.Reader) (afero.Fs, error) {
+	b, n, err := ReadLimited(r, zipFileSizeLimit+1)
 	if err != nil {
-		return nil, fmt.Errorf("read from GitHub response: %w", err)
+		return nil, fmt.Errorf("read zipball: %w", err)
 	}
 
 	zipReader, err := zip.NewReader(bytes.NewReader(b), n)
@@ -83,7 +119,7 @@ func getRefZipFs(owner, name, ref string, token *string) (afero.Fs, error) {
 
 	fs := zipfs.New(zipReader)
 
-	// A GitHub zipball contains a single directory that includes the repository root.
+	// A zipball contains a single directory that includes the repository root.
 	// Since the directory name is not fixed or deterministic, we need to find it.
 	directories, err := afero.ReadDir(fs, "")
 	if err != nil {
@@ -6,9 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -29,18 +32,82 @@ var (
 )
 
 // s3Fs is a read-only filesystem abstraction for Amazon S3.
+//
+// The objects in a directory are listed once and cached, and so are the
+// contents of the files read, so planning a project makes a bounded
+// number of requests: one listing per directory visited and one
+// GetObject per file read.
 type s3Fs struct {
 	S3Client *s3.Client
 	Bucket   string
 	Prefix   string
+
+	// ctx is the context of the requests, since the methods of
+	// afero.Fs do not take a context.
+	ctx context.Context
+
+	mu       sync.Mutex
+	listings map[string][]os.FileInfo
+	contents map[string][]byte
+}
+
+type s3FsOptions struct {
+	endpoint     string
+	usePathStyle bool
+	ctx          context.Context
+}
+
+// S3FsOption is the option for NewS3Fs.
+type S3FsOption func(*s3FsOptions)
+
+// S3Endpoint sets the endpoint of an S3-compatible storage,
+// for example, "https://minio.example.com" or the endpoint of
+// Cloudflare R2.
+func S3Endpoint(endpoint string) S3FsOption {
+	return func(opts *s3FsOptions) {
+		opts.endpoint = endpoint
+	}
+}
+
+// S3UsePathStyle makes the requests in the path-style
+// ("https://endpoint/bucket/key") instead of the virtual-hosted-style
+// ("https://bucket.endpoint/key"), which MinIO and Ceph usually require.
+func S3UsePathStyle(usePathStyle bool) S3FsOption {
+	return func(opts *s3FsOptions) {
+		opts.usePathStyle = usePathStyle
+	}
+}
+
+// S3Context sets the context of the requests to S3. When the context
+// is done, the filesystem returns the error of the context.
+func S3Context(ctx context.Context) S3FsOption {
+	return func(opts *s3FsOptions) {
+		opts.ctx = ctx
+	}
 }
 
 // NewS3Fs creates a new S3 filesystem with the given bucket name.
-func NewS3Fs(s3Url string, cfg *aws.Config) afero.Fs {
-	client := s3.NewFromConfig(*cfg)
-	bucket := strings.Split(strings.TrimPrefix(s3Url, "s3://"), "/")[0]
-	prefix := strings.TrimPrefix(s3Url, "s3://"+bucket+"/")
-	return &s3Fs{S3Client: client, Bucket: bucket, Prefix: prefix}
+func NewS3Fs(s3Url string, cfg *aws.Config, options ...S3FsOption) afero.Fs {
+	opts := &s3FsOptions{ctx: context.Background()}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	client := s3.NewFromConfig(*cfg, func(o *s3.Options) {
+		if opts.endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.endpoint)
+		}
+		o.UsePathStyle = opts.usePathStyle
+	})
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(s3Url, "s3://"), "/")
+	return &s3Fs{
+		S3Client: client,
+		Bucket:   bucket,
+		Prefix:   prefix,
+		ctx:      opts.ctx,
+		listings: make(map[string][]os.FileInfo),
+		contents: make(map[string][]byte),
+	}
 }
 
 func (fs *s3Fs) Create(_ string) (afero.File, error) {
@@ -64,44 +131,148 @@ func (fs *s3Fs) OpenFile(name string, flag int, _ os.FileMode) (afero.File, erro
 		return nil, ErrReadonly
 	}
 
+	rel := cleanS3Path(name)
+	info, err := fs.lookup(rel)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if info.IsDir() {
+		return &s3File{fs: fs, name: name, path: rel, Reader: bytes.NewReader(nil), info: info}, nil
+	}
+
+	content, err := fs.read(rel)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3File{fs: fs, name: name, path: rel, Reader: bytes.NewReader(content), info: info}, nil
+}
+
+// cleanS3Path returns the path relative to the root of the filesystem,
+// which is an empty string for the root.
+func cleanS3Path(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// lookup returns the information of the file or the directory at rel,
+// from the listing of its parent directory.
+func (fs *s3Fs) lookup(rel string) (os.FileInfo, error) {
+	if rel == "" {
+		return s3FileInfo{name: "/", mode: os.FileMode(0o444) | os.ModeDir, isDir: true}, nil
+	}
+
+	parent := path.Dir(rel)
+	if parent == "." {
+		parent = ""
+	}
+
+	entries, err := fs.list(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	base := path.Base(rel)
+	for _, entry := range entries {
+		if entry.Name() == base {
+			return entry, nil
+		}
+	}
+
+	return nil, os.ErrNotExist
+}
+
+// list returns the files and the directories in the directory rel.
+// The directory is listed only once, and the result is cached.
+func (fs *s3Fs) list(rel string) ([]os.FileInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if entries, ok := fs.listings[rel]; ok {
+		return entries, nil
+	}
+
+	prefix := path.Join(fs.Prefix, rel)
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var files, dirs []os.FileInfo
+	paginator := s3.NewListObjectsV2Paginator(fs.S3Client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(fs.Bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(fs.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list objects in S3: %w", err)
+		}
+
+		for _, obj := range page.Contents {
+			name := strings.TrimPrefix(aws.ToString(obj.Key), prefix)
+			// the placeholder object of the directory itself
+			if name == "" {
+				continue
+			}
+
+			files = append(files, s3FileInfo{
+				name:    name,
+				size:    aws.ToInt64(obj.Size),
+				mode:    os.FileMode(0o444),
+				modTime: aws.ToTime(obj.LastModified),
+				isDir:   false,
+			})
+		}
+
+		for _, p := range page.CommonPrefixes {
+			dirName := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(p.Prefix), prefix), "/")
+			dirs = append(dirs, s3FileInfo{
+				name:  dirName,
+				size:  0,
+				mode:  os.FileMode(0o444) | os.ModeDir,
+				isDir: true,
+			})
+		}
+	}
+
+	entries := append(files, dirs...)
+	fs.listings[rel] = entries
+	return entries, nil
+}
+
+// read returns the content of the file rel.
+// The file is downloaded only once, and the content is cached.
+func (fs *s3Fs) read(rel string) ([]byte, error) {
+	// Unlike list, the lock is not held while downloading,
+	// so the files can be read in parallel.
+	fs.mu.Lock()
+	content, ok := fs.contents[rel]
+	fs.mu.Unlock()
+	if ok {
+		return content, nil
+	}
+
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(fs.Bucket),
-		Key:    aws.String(path.Join(fs.Prefix, name)),
+		Key:    aws.String(path.Join(fs.Prefix, rel)),
 	}
 
-	result, err := fs.S3Client.GetObject(context.TODO(), input)
+	result, err := fs.S3Client.GetObject(fs.ctx, input)
 	if err != nil {
-		// if the object does not exist, maybe it's a directory
 		var noSuchKey *s3types.NoSuchKey
-		if errors.As(err, &noSuchKey) || strings.Contains(err.Error(), "NoSuchKey") {
-			listInput := &s3.ListObjectsV2Input{
-				Bucket:    aws.String(fs.Bucket),
-				Prefix:    aws.String(path.Join(fs.Prefix, name) + "/"),
-				Delimiter: aws.String("/"),
-			}
-			listResult, listErr := fs.S3Client.ListObjectsV2(context.TODO(), listInput)
-			if listErr == nil && len(listResult.CommonPrefixes) > 0 {
-				return &s3File{
-					fs: fs,
-					info: s3FileInfo{
-						name:  name,
-						size:  0,
-						mode:  os.FileMode(0o444) | os.ModeDir,
-						isDir: true,
-					},
-				}, nil
-			}
+		if errors.As(err, &noSuchKey) {
 			return nil, afero.ErrFileNotFound
 		}
 		return nil, fmt.Errorf("unable to read from S3: %w", err)
 	}
 
-	content, err := io.ReadAll(result.Body)
+	content, err = io.ReadAll(result.Body)
 
 	defer func() {
 		err := result.Body.Close()
 		if err != nil {
-			fmt.Println("error closing body", err)
+			log.Println("error closing body", err)
 		}
 	}()
 
@@ -109,11 +280,11 @@ func (fs *s3Fs) OpenFile(name string, flag int, _ os.FileMode) (afero.File, erro
 		return nil, fmt.Errorf("unable to read S3 object body: %w", err)
 	}
 
-	return &s3File{
-		fs:     fs,
-		Reader: bytes.NewReader(content),
-		info:   s3FileInfo{name: name, size: *result.ContentLength},
-	}, nil
+	fs.mu.Lock()
+	fs.contents[rel] = content
+	fs.mu.Unlock()
+
+	return content, nil
 }
 
 func (fs *s3Fs) Remove(_ string) error {
@@ -129,23 +300,12 @@ func (fs *s3Fs) Rename(_, _ string) error {
 }
 
 func (fs *s3Fs) Stat(name string) (os.FileInfo, error) {
-	input := &s3.HeadObjectInput{
-		Bucket: aws.String(fs.Bucket),
-		Key:    aws.String(path.Join(fs.Prefix, name)),
-	}
-
-	result, err := fs.S3Client.HeadObject(context.TODO(), input)
+	info, err := fs.lookup(cleanS3Path(name))
 	if err != nil {
-		return nil, fmt.Errorf("unable to stat object in S3: %w", err)
+		return nil, &os.PathError{Op: "stat", Path: name, Err: err}
 	}
 
-	return s3FileInfo{
-		name:    name,
-		size:    *result.ContentLength,
-		mode:    os.FileMode(0o444),
-		modTime: *result.LastModified,
-		isDir:   false, // S3 does not have "directories" but emulates them using keys
-	}, nil
+	return info, nil
 }
 
 func (fs *s3Fs) Name() string {
@@ -169,7 +329,12 @@ var _ afero.File = &s3File{}
 type s3File struct {
 	fs *s3Fs
 	*bytes.Reader
+	name string
+	path string
 	info os.FileInfo
+
+	// dirOffset is the number of directory entries read by Readdir.
+	dirOffset int
 }
 
 func (f *s3File) Close() error {
@@ -177,7 +342,7 @@ func (f *s3File) Close() error {
 }
 
 func (f *s3File) Name() string {
-	return f.info.Name()
+	return f.name
 }
 
 // Readdir reads the contents of the directory associated with file and returns
@@ -188,47 +353,21 @@ func (f *s3File) Readdir(count int) ([]os.FileInfo, error) {
 		return nil, ErrNotDir
 	}
 
-	prefix := path.Join(f.fs.Prefix, f.Name())
-	if prefix != "" && !strings.HasSuffix(prefix, "/") {
-		prefix += "/"
-	}
-
-	input := &s3.ListObjectsV2Input{
-		Bucket:    aws.String(f.fs.Bucket),
-		Prefix:    aws.String(prefix),
-		Delimiter: aws.String("/"),
-	}
-
-	result, err := f.fs.S3Client.ListObjectsV2(context.TODO(), input)
+	fileInfos, err := f.fs.list(f.path)
 	if err != nil {
-		return nil, fmt.Errorf("unable to list objects in S3: %w", err)
-	}
-
-	var fileInfos []os.FileInfo
-	for _, obj := range result.Contents {
-		fileInfos = append(fileInfos, s3FileInfo{
-			name:    strings.TrimPrefix(*obj.Key, prefix),
-			size:    *obj.Size,
-			mode:    os.FileMode(0o444),
-			modTime: *obj.LastModified,
-			isDir:   false,
-		})
+		return nil, err
 	}
 
-	for _, p := range result.CommonPrefixes {
-		dirName := strings.TrimSuffix(strings.TrimPrefix(*p.Prefix, prefix), "/")
-		fileInfos = append(fileInfos, s3FileInfo{
-			name:  dirName,
-			size:  0,
-			mode:  os.FileMode(0o444) | os.ModeDir,
-			isDir: true,
-		})
-	}
-	if count > 0 && len(fileInfos) > count {
-		fileInfos = fileInfos[:count]
+	fileInfos = fileInfos[min(f.dirOffset, len(fileInfos)):]
+	if count > 0 {
+		if len(fileInfos) == 0 {
+			return nil, io.EOF
+		}
+		fileInfos = fileInfos[:min(count, len(fileInfos))]
 	}
+	f.dirOffset += len(fileInfos)
 
-	return fileInfos, nil
+	return slices.Clone(fileInfos), nil
 }
 
 // Readdirnames reads and returns a slice of names from the directory f.
@@ -0,0 +1,311 @@
+package source_test
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/samber/lo"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeabur/zbpack/internal/source"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+	"github.com/zeabur/zbpack/pkg/zeaburpack"
+)
+
+// fakeS3 is a MinIO-like S3-compatible server with path-style addressing,
+// which serves ListObjectsV2 and GetObject of a single bucket.
+type fakeS3 struct {
+	*httptest.Server
+
+	bucket   string
+	objects  map[string]string
+	pageSize int
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func newFakeS3(t *testing.T, objects map[string]string) *fakeS3 {
+	t.Helper()
+
+	f := &fakeS3{bucket: "bucket", objects: objects, pageSize: 1000}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+// Requests returns the requests received, in the form of
+// "GetObject <key>" or "ListObjectsV2 <prefix> <continuation token>".
+func (f *fakeS3) Requests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.requests...)
+}
+
+func (f *fakeS3) record(request string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, request)
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Name                  string   `xml:"Name"`
+	Prefix                string   `xml:"Prefix"`
+	Delimiter             string   `xml:"Delimiter"`
+	KeyCount              int      `xml:"KeyCount"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken,omitempty"`
+	Contents              []struct {
+		Key          string `xml:"Key"`
+		Size         int    `xml:"Size"`
+		LastModified string `xml:"LastModified"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+func (f *fakeS3) handle(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	if key != "" {
+		f.record("GetObject " + key)
+
+		content, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>"))
+			return
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Header().Set("Last-Modified", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+		_, _ = w.Write([]byte(content))
+		return
+	}
+
+	query := r.URL.Query()
+	prefix, delimiter, token := query.Get("prefix"), query.Get("delimiter"), query.Get("continuation-token")
+	f.record(fmt.Sprintf("ListObjectsV2 %s %s", prefix, token))
+
+	// the keys and the common prefixes in the lexicographical order
+	seen := map[string]bool{}
+	var entries []string
+	for key := range f.objects {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if i := strings.Index(rest, delimiter); delimiter != "" && i != -1 {
+			key = prefix + rest[:i+1]
+		}
+		if !seen[key] {
+			seen[key] = true
+			entries = append(entries, key)
+		}
+	}
+	sort.Strings(entries)
+
+	start, _ := strconv.Atoi(token)
+	end := min(start+f.pageSize, len(entries))
+
+	result := listBucketResult{Name: f.bucket, Prefix: prefix, Delimiter: delimiter}
+	for _, entry := range entries[start:end] {
+		if strings.HasSuffix(entry, delimiter) && delimiter != "" {
+			result.CommonPrefixes = append(result.CommonPrefixes, struct {
+				Prefix string `xml:"Prefix"`
+			}{Prefix: entry})
+			continue
+		}
+		result.Contents = append(result.Contents, struct {
+			Key          string `xml:"Key"`
+			Size         int    `xml:"Size"`
+			LastModified string `xml:"LastModified"`
+		}{Key: entry, Size: len(f.objects[entry]), LastModified: "2024-01-01T00:00:00.000Z"})
+	}
+	result.KeyCount = end - start
+	if end < len(entries) {
+		result.IsTruncated = true
+		result.NextContinuationToken = strconv.Itoa(end)
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+func (f *fakeS3) newFs(prefix string) afero.Fs {
+	return source.NewS3Fs(
+		"s3://"+f.bucket+"/"+prefix,
+		&aws.Config{
+			Region:      "us-east-1",
+			Credentials: credentials.NewStaticCredentialsProvider("access-key", "secret-key", ""),
+		},
+		source.S3Endpoint(f.URL),
+		source.S3UsePathStyle(true),
+	)
+}
+
+func TestS3Fs(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeS3(t, map[string]string{
+		"project/package.json":       `{"name": "app"}`,
+		"project/src/index.js":       "console.log('hello')",
+		"project/src/lib/util.js":    "",
+		"other-project/package.json": "{}",
+	})
+	fs := fake.newFs("project")
+
+	content, err := afero.ReadFile(fs, "src/index.js")
+	require.NoError(t, err)
+	assert.Equal(t, "console.log('hello')", string(content))
+
+	info, err := fs.Stat("/src/lib")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	info, err = fs.Stat("package.json")
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), info.Size())
+
+	entries, err := afero.ReadDir(fs, ".")
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	_, err = fs.Stat("not-exist.json")
+	assert.ErrorIs(t, err, afero.ErrFileNotFound)
+	exists, err := afero.Exists(fs, "src/not-exist.js")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// read again, which should be served from the cache
+	_, err = afero.ReadFile(fs, "src/index.js")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"ListObjectsV2 project/src/ ",
+		"GetObject project/src/index.js",
+		"ListObjectsV2 project/ ",
+	}, fake.Requests())
+}
+
+func TestS3Fs_Pagination(t *testing.T) {
+	t.Parallel()
+
+	objects := map[string]string{}
+	for i := range 5 {
+		objects[fmt.Sprintf("project/file-%d.txt", i)] = ""
+	}
+	fake := newFakeS3(t, objects)
+	fake.pageSize = 2
+
+	entries, err := afero.ReadDir(fake.newFs("project"), "")
+	require.NoError(t, err)
+	assert.Len(t, entries, 5)
+	assert.Len(t, fake.Requests(), 3)
+}
+
+func TestS3Fs_ReaddirPaging(t *testing.T) {
+	t.Parallel()
+
+	objects := map[string]string{}
+	for i := range 5 {
+		objects[fmt.Sprintf("project/file-%d.txt", i)] = ""
+	}
+	fake := newFakeS3(t, objects)
+
+	dir, err := fake.newFs("project").Open(".")
+	require.NoError(t, err)
+
+	var names []string
+	for {
+		page, err := dir.Readdirnames(2)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page), 2)
+		names = append(names, page...)
+	}
+	assert.Len(t, names, 5)
+	assert.Len(t, lo.Uniq(names), 5)
+}
+
+func TestS3Fs_Plan(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeS3(t, map[string]string{
+		"project/package.json":      `{"name": "app", "scripts": {"start": "node index.js"}, "dependencies": {"express": "^4.0.0"}}`,
+		"project/package-lock.json": `{"lockfileVersion": 3}`,
+		"project/index.js":          "require('express')",
+		"project/public/index.html": "<html></html>",
+	})
+
+	path := "s3://bucket/project"
+	planType, _, err := zeaburpack.PlanE(zeaburpack.PlanOptions{
+		Path: &path,
+		AWSConfig: &plan.AWSConfig{
+			Region:          "us-east-1",
+			AccessKeyID:     "access-key",
+			SecretAccessKey: "secret-key",
+			Endpoint:        fake.URL,
+			UsePathStyle:    true,
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.PlanTypeNodejs, planType)
+
+	// Every directory is listed once and every file is read once,
+	// so the number of requests is bounded by the number of objects.
+	requests := fake.Requests()
+	assert.ElementsMatch(t, lo.Uniq(requests), requests, "requests should not be repeated")
+	assert.LessOrEqual(t, len(requests), 2+4)
+}
+
+func TestS3Fs_Canceled(t *testing.T) {
+	t.Parallel()
+
+	fake := newFakeS3(t, map[string]string{"project/package.json": "{}"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	fs := source.NewS3Fs(
+		"s3://"+fake.bucket+"/project",
+		&aws.Config{
+			Region:      "us-east-1",
+			Credentials: credentials.NewStaticCredentialsProvider("access-key", "secret-key", ""),
+		},
+		source.S3Endpoint(fake.URL),
+		source.S3UsePathStyle(true),
+		source.S3Context(ctx),
+	)
+
+	_, err := afero.ReadFile(fs, "package.json")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, fake.Requests())
+}
@@ -27,17 +27,40 @@ func (i *identify) Match(fs afero.Fs) bool {
 	return utils.HasFile(fs, "index.html", "config.toml", "mkdocs.yml", "hugo.toml", "hugo.json", "hugo.yaml", "config/_default/hugo.toml", "config/_default/hugo.json", "config/_default/hugo.yaml")
 }
 
+// MatchScore is low for a bare index.html, since many projects of
+// other languages also contain one, and higher for a static site generator.
+func (i *identify) MatchScore(ctx plan.MatchContext) plan.MatchResult {
+	result := plan.MatchResult{Score: 0.2}
+
+	if found := utils.FoundFiles(ctx.Source, "index.html"); len(found) > 0 {
+		result.Reasons = append(result.Reasons, "found index.html")
+	}
+	if found := utils.FoundFiles(ctx.Source, "config.toml", "mkdocs.yml", "hugo.toml", "hugo.json", "hugo.yaml", "config/_default/hugo.toml", "config/_default/hugo.json", "config/_default/hugo.yaml"); len(found) > 0 {
+		result.Score += 0.6
+		result.Reasons = append(result.Reasons, "found static site generator config "+strings.Join(found, ", "))
+	}
+
+	return result
+}
+
 func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
-	planMeta := types.PlanMeta{}
+	return i.BuildPlan(options).PlanMeta()
+}
+
+func (i *identify) BuildPlan(options plan.NewPlannerOptions) types.BuildPlan {
+	bp := types.BuildPlan{
+		Version: types.BuildPlanVersion,
+		Type:    types.PlanTypeStatic,
+	}
 
 	if utils.HasFile(options.Source, "hugo.toml", "hugo.json", "hugo.yaml", "config/_default/hugo.toml", "config/_default/hugo.json", "config/_default/hugo.yaml") {
-		planMeta["framework"] = "hugo"
-		return planMeta
+		bp.Framework = "hugo"
+		return bp
 	}
 
 	if utils.HasFile(options.Source, "mkdocs.yml") {
-		planMeta["framework"] = "mkdocs"
-		return planMeta
+		bp.Framework = "mkdocs"
+		return bp
 	}
 
 	if utils.HasFile(options.Source, "config.toml") {
@@ -51,20 +74,25 @@ func (i *identify) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
 				ver = userSetVersion
 			}
 
-			planMeta["framework"] = "zola"
-			planMeta["version"] = ver
-			return planMeta
+			bp.Framework = "zola"
+			bp.RuntimeVersion = ver
+			bp.KeepKeys("version")
+			return bp
 		}
 	}
 
 	html, err := utils.ReadFileToUTF8(options.Source, "index.html")
 
 	if err == nil && strings.Contains(string(html), "Hexo") {
-		planMeta["framework"] = "hexo"
-		return planMeta
+		bp.Framework = "hexo"
+		return bp
 	}
 
-	return planMeta
+	return bp
 }
 
-var _ plan.Identifier = (*identify)(nil)
+var (
+	_ plan.Identifier          = (*identify)(nil)
+	_ plan.ScoredIdentifier    = (*identify)(nil)
+	_ plan.BuildPlanIdentifier = (*identify)(nil)
+)
@@ -8,9 +8,15 @@ import (
 
 // GenerateDockerfile generates the Dockerfile for static files.
 func GenerateDockerfile(meta types.PlanMeta) (string, error) {
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypeStatic, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates the Dockerfile for static
+// files from the typed build plan.
+func GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
 	var dockerfile string
 
-	switch meta["framework"] {
+	switch bp.Framework {
 	case "hugo":
 		dockerfile = `FROM hugomods/hugo:exts AS builder
 WORKDIR /src
@@ -21,7 +27,7 @@ FROM scratch AS output
 COPY --from=builder /src/public /
 `
 	case "zola":
-		dockerfile = `FROM ghcr.io/getzola/zola:v` + meta["version"] + ` AS builder
+		dockerfile = `FROM ghcr.io/getzola/zola:v` + bp.RuntimeVersion + ` AS builder
 WORKDIR /app
 COPY . .
 RUN ["zola", "build"]
@@ -71,4 +77,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
@@ -46,7 +46,15 @@ type GetMetaOptions struct {
 
 // GetMeta returns the metadata of a Swift project.
 func GetMeta(opt GetMetaOptions) types.PlanMeta {
-	meta := types.PlanMeta{}
+	return GetBuildPlan(opt).PlanMeta()
+}
+
+// GetBuildPlan returns the typed build plan of a Swift project.
+func GetBuildPlan(opt GetMetaOptions) types.BuildPlan {
+	bp := types.BuildPlan{
+		Version: types.BuildPlanVersion,
+		Type:    types.PlanTypeSwift,
+	}
 
 	ctx := &swiftPlanContext{
 		Src:    opt.Src,
@@ -55,8 +63,8 @@ func GetMeta(opt GetMetaOptions) types.PlanMeta {
 
 	framework := DetermineFramework(ctx)
 	if framework != types.SwiftFrameworkNone {
-		meta["framework"] = string(framework)
+		bp.Framework = string(framework)
 	}
 
-	return meta
+	return bp
 }
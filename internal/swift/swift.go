@@ -18,7 +18,13 @@ func NewPacker() packer.Packer {
 }
 
 // GenerateDockerfile generates a Dockerfile for Swift project
-func GenerateDockerfile(_ types.PlanMeta) (string, error) {
+func GenerateDockerfile(meta types.PlanMeta) (string, error) {
+	return GenerateDockerfileFromBuildPlan(types.NewBuildPlan(types.PlanTypeSwift, meta))
+}
+
+// GenerateDockerfileFromBuildPlan generates a Dockerfile for Swift project
+// from the typed build plan.
+func GenerateDockerfileFromBuildPlan(_ types.BuildPlan) (string, error) {
 	// TODO: following dockerfile is copied from Vapor's template, need to be modified to support other Swift use cases
 	return `# ================================
 # Build image
@@ -114,4 +120,11 @@ func (p *pack) GenerateDockerfile(meta types.PlanMeta) (string, error) {
 	return GenerateDockerfile(meta)
 }
 
-var _ packer.Packer = (*pack)(nil)
+func (p *pack) GenerateDockerfileFromBuildPlan(bp types.BuildPlan) (string, error) {
+	return GenerateDockerfileFromBuildPlan(bp)
+}
+
+var (
+	_ packer.Packer          = (*pack)(nil)
+	_ packer.BuildPlanPacker = (*pack)(nil)
+)
@@ -0,0 +1,31 @@
+package utils
+
+import "strings"
+
+// CacheMount returns the BuildKit cache mount flag of RUN for the target,
+// for example, "--mount=type=cache,target=/root/.npm".
+func CacheMount(target string) string {
+	return "--mount=type=cache,target=" + target
+}
+
+// WithCacheMounts adds the cache mounts of the targets to every RUN
+// instruction in the instructions. Other lines are kept as is.
+func WithCacheMounts(instructions string, targets ...string) string {
+	if len(targets) == 0 {
+		return instructions
+	}
+
+	mounts := make([]string, 0, len(targets))
+	for _, target := range targets {
+		mounts = append(mounts, CacheMount(target))
+	}
+	prefix := "RUN " + strings.Join(mounts, " ") + " "
+
+	lines := strings.Split(instructions, "\n")
+	for i, line := range lines {
+		if rest, ok := strings.CutPrefix(line, "RUN "); ok {
+			lines[i] = prefix + rest
+		}
+	}
+	return strings.Join(lines, "\n")
+}
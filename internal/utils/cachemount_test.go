@@ -0,0 +1,16 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zeabur/zbpack/internal/utils"
+)
+
+func TestWithCacheMounts(t *testing.T) {
+	assert.Equal(t,
+		"WORKDIR /src\nRUN --mount=type=cache,target=/root/.npm --mount=type=cache,target=/tmp/cache npm ci\nRUN --mount=type=cache,target=/root/.npm --mount=type=cache,target=/tmp/cache npm run build",
+		utils.WithCacheMounts("WORKDIR /src\nRUN npm ci\nRUN npm run build", "/root/.npm", "/tmp/cache"),
+	)
+	assert.Equal(t, "RUN npm ci", utils.WithCacheMounts("RUN npm ci"))
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"path"
+	"strings"
+)
+
+// CopyFiles returns the COPY instructions to copy the files to the same
+// paths under dest, for example, "./" or "/src/". The files in the same
+// directory are copied in one instruction. A directory ends with "/"
+// and is copied in its own instruction.
+func CopyFiles(files []string, dest string) string {
+	var dirs []string
+	filesInDir := make(map[string][]string)
+
+	for _, file := range files {
+		if strings.HasSuffix(file, "/") {
+			dirs = append(dirs, file)
+			continue
+		}
+
+		dir := path.Dir(file)
+		if _, ok := filesInDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		filesInDir[dir] = append(filesInDir[dir], file)
+	}
+
+	instructions := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if files, ok := filesInDir[dir]; ok {
+			instructions = append(instructions, "COPY "+strings.Join(files, " ")+" "+joinDest(dest, dir))
+			// the files of this directory are copied once
+			delete(filesInDir, dir)
+			continue
+		}
+		if strings.HasSuffix(dir, "/") {
+			instructions = append(instructions, "COPY "+dir+" "+joinDest(dest, dir))
+		}
+	}
+
+	return strings.Join(instructions, "\n")
+}
+
+// joinDest returns the directory dir under dest, ending with "/".
+func joinDest(dest, dir string) string {
+	dir = strings.Trim(path.Clean(dir), "/")
+	if dir == "." || dir == "" {
+		return dest
+	}
+	return strings.TrimSuffix(dest, "/") + "/" + dir + "/"
+}
@@ -0,0 +1,17 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zeabur/zbpack/internal/utils"
+)
+
+func TestCopyFiles(t *testing.T) {
+	assert.Equal(t,
+		"COPY package.json pnpm-lock.yaml ./\nCOPY apps/web/package.json apps/web/.npmrc ./apps/web/\nCOPY patches/ ./patches/",
+		utils.CopyFiles([]string{"package.json", "apps/web/package.json", "pnpm-lock.yaml", "patches/", "apps/web/.npmrc"}, "./"),
+	)
+	assert.Equal(t, "COPY go.mod go.sum /src/", utils.CopyFiles([]string{"go.mod", "go.sum"}, "/src/"))
+	assert.Empty(t, utils.CopyFiles(nil, "./"))
+}
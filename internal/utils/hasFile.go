@@ -11,3 +11,14 @@ func HasFile(src afero.Fs, fileNames ...string) bool {
 	}
 	return false
 }
+
+// FoundFiles returns the given files which exist in the given filesystem.
+func FoundFiles(src afero.Fs, fileNames ...string) []string {
+	var found []string
+	for _, fileName := range fileNames {
+		if exists, _ := afero.Exists(src, fileName); exists {
+			found = append(found, fileName)
+		}
+	}
+	return found
+}
@@ -0,0 +1,85 @@
+package zbpack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/zeabur/zbpack/pkg/zeaburpack"
+)
+
+var discoverCmd = &cobra.Command{
+	Use:   "discover <directory path>",
+	Short: "Discover the services in a monorepo and their build plans",
+	Long: "Discover walks the repository, finds every deployable unit (pnpm/yarn workspace packages, " +
+		"Go commands in cmd/*, Cargo workspace members, .NET projects in a .sln and Python subprojects), " +
+		"and prints the build plan of each of them.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		return discoverServices(args[0])
+	},
+}
+
+// discoverServices is used to print the services in the repository.
+func discoverServices(path string) error {
+	submoduleName, err := GetSubmoduleName(path)
+	if err != nil {
+		return err
+	}
+
+	var githubToken *string
+	githubTokenStr := os.Getenv("GITHUB_ACCESS_TOKEN")
+	if githubTokenStr != "" {
+		githubToken = &githubTokenStr
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	services, err := zeaburpack.DiscoverContext(
+		ctx,
+		zeaburpack.PlanOptions{
+			SubmoduleName: &submoduleName,
+			Path:          &path,
+			AccessToken:   githubToken,
+			GitProvider:   zeaburpack.GitProvider(gitProvider),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if format == "" || format == "table" {
+		printServices(services, os.Stdout)
+		return nil
+	}
+
+	return printStructured(services)
+}
+
+// printServices prints the services in a table.
+func printServices(services []zeaburpack.Service, w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tPATH\tKIND\tPLAN\tFRAMEWORK")
+
+	for _, s := range services {
+		planType := string(s.PlanType)
+		if s.Error != "" {
+			planType += " (error: " + strings.SplitN(s.Error, "\n", 2)[0] + ")"
+		}
+
+		framework := s.PlanMeta["framework"]
+		if framework == "" {
+			framework = "-"
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Name, s.Path, s.Kind, planType, framework)
+	}
+
+	_ = tw.Flush()
+}
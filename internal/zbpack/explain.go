@@ -0,0 +1,88 @@
+package zbpack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/spf13/cobra"
+	zbplan "github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/zeaburpack"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <directory path>",
+	Short: "Explain why zbpack chooses the build plan of a project",
+	Long: "Explain prints every identifier zbpack tried in order, the decision made on it, " +
+		"and where each value of the build plan comes from (configuration, environment variable, " +
+		"file detection or default).",
+	Args: cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		return explain(args[0])
+	},
+}
+
+// explain is used to print the decision trace of the planner.
+func explain(path string) error {
+	submoduleName, err := GetSubmoduleName(path)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("using submoduleName: %s", submoduleName)
+
+	var githubToken *string
+	githubTokenStr := os.Getenv("GITHUB_ACCESS_TOKEN")
+	if githubTokenStr != "" {
+		githubToken = &githubTokenStr
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	_, _, trace, planErr := zeaburpack.PlanWithTraceContext(
+		ctx,
+		zeaburpack.PlanOptions{
+			SubmoduleName: &submoduleName,
+			Path:          &path,
+			AccessToken:   githubToken,
+			GitProvider:   zeaburpack.GitProvider(gitProvider),
+		},
+	)
+
+	if format == "" || format == "table" {
+		printTrace(trace, os.Stdout)
+	} else if err := printStructured(trace); err != nil {
+		return err
+	}
+
+	return planErr
+}
+
+// printTrace prints the trace in a human-readable format.
+func printTrace(trace *zbplan.Trace, w io.Writer) {
+	var b strings.Builder
+
+	b.WriteString("Identifiers:\n")
+	for i, it := range trace.Identifiers {
+		fmt.Fprintf(&b, "  %2d. %-10s %s\n", i+1, it.PlanType, it.Decision)
+		if len(it.Files) > 0 {
+			fmt.Fprintf(&b, "      files: %s\n", strings.Join(it.Files, ", "))
+		}
+	}
+
+	fmt.Fprintf(&b, "\nPlan: %s\n", trace.PlanType)
+	for _, f := range trace.Fields {
+		source := string(f.Source)
+		if f.Origin != "" {
+			source += " (" + f.ConfigKey + " from " + f.Origin + ")"
+		}
+		fmt.Fprintf(&b, "  %-16s = %-40q <- %s\n", f.Key, f.Value, source)
+	}
+
+	_, _ = io.WriteString(w, b.String())
+}
@@ -3,6 +3,8 @@ package zbpack
 import (
 	"path/filepath"
 	"strings"
+
+	"github.com/zeabur/zbpack/internal/source"
 )
 
 var usageTemplate = `Usage:{{if .Runnable}}
@@ -42,13 +44,25 @@ func GetSubmoduleName(path string) (submoduleName string, err error) {
 		return userSubmoduleName, nil
 	}
 
-	var absPath string
-	if strings.HasPrefix(path, "https://") {
-		absPath = path
-	} else {
-		absPath, err = filepath.Abs(path)
+	// https://example.com/acme/app.git#main:services/api -> api
+	if source.IsGitURL(path) {
+		remote, _, subpath := source.ParseGitURL(path)
+		if subpath != "" {
+			return filepath.Base(subpath), nil
+		}
+		return strings.TrimSuffix(filepath.Base(strings.TrimSuffix(remote, "/")), ".git"), nil
+	}
+
+	// archive:///srv/uploads/app.tar.gz -> app
+	if file, ok := strings.CutPrefix(path, "archive://"); ok || source.IsArchiveFile(path) {
+		if !ok {
+			file = path
+		}
+		return source.TrimArchiveExt(filepath.Base(file)), nil
 	}
 
+	absPath, err := filepath.Abs(path)
+
 	submoduleName = filepath.Base(absPath)
 	if prefix, _, ok := strings.Cut(submoduleName, "#"); ok {
 		return prefix, nil
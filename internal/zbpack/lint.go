@@ -0,0 +1,133 @@
+package zbpack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/zeabur/zbpack/pkg/lint"
+	"github.com/zeabur/zbpack/pkg/types"
+	"github.com/zeabur/zbpack/pkg/zeaburpack"
+)
+
+// severities option is the severities of the lint rules in the form of "rule=severity".
+var severities []string
+
+var lintCmd = &cobra.Command{
+	Use:   "lint <directory or Dockerfile path>",
+	Short: "Check the Dockerfile of a project against the best practices",
+	Long: "Lint checks the Dockerfile of the project, or the Dockerfile zbpack generates for it, " +
+		"for unpinned base images, a missing EXPOSE, ADD of remote URLs, the root user, secrets in ENV, " +
+		"apt without cleanup and more. It fails if any finding is an error.",
+	Args: cobra.ExactArgs(1),
+	// The lint errors are not the errors of the usage.
+	SilenceUsage: true,
+	RunE: func(_ *cobra.Command, args []string) error {
+		return lintDockerfile(args[0])
+	},
+}
+
+func init() {
+	lintCmd.Flags().StringArrayVar(&severities, "severity", nil, "severity of a rule in the form of rule=severity (error, warning, info or off), for example, root-user=error. can be specified multiple times.")
+}
+
+// lintDockerfile is used to lint the Dockerfile and print the findings.
+func lintDockerfile(path string) error {
+	severity, err := parseSeverities(severities)
+	if err != nil {
+		return err
+	}
+
+	var result zeaburpack.LintResult
+	if stat, statErr := os.Stat(path); statErr == nil && stat.Mode().IsRegular() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read Dockerfile: %w", err)
+		}
+
+		findings, err := lint.Lint(string(content), lint.Options{Severity: severity})
+		if err != nil {
+			return err
+		}
+		result = zeaburpack.LintResult{PlanType: types.PlanTypeDocker, Findings: findings}
+	} else {
+		submoduleName, err := GetSubmoduleName(path)
+		if err != nil {
+			return err
+		}
+
+		var githubToken *string
+		githubTokenStr := os.Getenv("GITHUB_ACCESS_TOKEN")
+		if githubTokenStr != "" {
+			githubToken = &githubTokenStr
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		result, err = zeaburpack.Lint(
+			ctx,
+			zeaburpack.PlanOptions{
+				SubmoduleName: &submoduleName,
+				Path:          &path,
+				AccessToken:   githubToken,
+				GitProvider:   zeaburpack.GitProvider(gitProvider),
+			},
+			severity,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if format == "" || format == "table" {
+		printFindings(result.Findings, os.Stdout)
+	} else if err := printStructured(result); err != nil {
+		return err
+	}
+
+	if lint.HasErrors(result.Findings) {
+		return fmt.Errorf("the Dockerfile has lint errors")
+	}
+	return nil
+}
+
+// parseSeverities parses the severities in the form of "rule=severity".
+func parseSeverities(values []string) (map[string]lint.Severity, error) {
+	severity := make(map[string]lint.Severity, len(values))
+	for _, value := range values {
+		rule, s, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid severity %q: must be in the form of rule=severity", value)
+		}
+
+		parsed, err := lint.ParseSeverity(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid severity of %s: %w", rule, err)
+		}
+		severity[strings.TrimSpace(rule)] = parsed
+	}
+	return severity, nil
+}
+
+// printFindings prints the findings in a table.
+func printFindings(findings []lint.Finding, w io.Writer) {
+	if len(findings) == 0 {
+		_, _ = fmt.Fprintln(w, "No problems found.")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "LINE\tSEVERITY\tRULE\tMESSAGE")
+
+	for _, f := range findings {
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", f.Line, f.Severity, f.Rule, f.Message)
+	}
+
+	_ = tw.Flush()
+}
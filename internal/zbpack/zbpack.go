@@ -2,14 +2,19 @@
 package zbpack
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
+	"os/signal"
 	"strings"
 
+	"github.com/goccy/go-yaml"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+	"github.com/zeabur/zbpack/pkg/plugin"
 	"github.com/zeabur/zbpack/pkg/zeaburpack"
 )
 
@@ -18,6 +23,28 @@ var (
 	info bool
 	// dockerfile option is used to generate a Dockerfile.
 	dockerfile bool
+	// format option is used to specify the output format of the project information.
+	format string
+	// plugins option is the external-process plugins to load.
+	plugins []string
+	// gitProvider option is the hosting service of the remote repository to plan.
+	gitProvider string
+	// builder option is the backend to build the image with.
+	builder string
+	// exportDir option is the output directory of the export builder.
+	exportDir string
+	// metadataFile option is the path to write the build report to.
+	metadataFile string
+	// platforms option is the platforms to build the image for.
+	platforms []string
+	// resultImage option is the name of the image to build.
+	resultImage string
+	// push option is used to push the image to the registry.
+	push bool
+	// cacheFrom option is the caches to import the layers from.
+	cacheFrom []string
+	// cacheTo option is the caches to export the layers to.
+	cacheTo []string
 	// userSubmoduleName option is used to specify the submodule name of this project manually
 	userSubmoduleName string
 	cmd               = &cobra.Command{
@@ -31,6 +58,9 @@ var (
 			}
 			return nil
 		},
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			return loadPlugins()
+		},
 		RunE: func(_ *cobra.Command, args []string) error {
 			return run(args)
 		},
@@ -41,7 +71,21 @@ func init() {
 	cmd.PersistentFlags().BoolVarP(&info, "info", "i", false, "only print project information")
 	cmd.PersistentFlags().BoolVarP(&dockerfile, "dockerfile", "d", false, "output dockerfile")
 	cmd.PersistentFlags().StringVar(&userSubmoduleName, "submodule", "", "submodule (service) name. by default, it is picked from the directory name.")
+	cmd.PersistentFlags().StringVarP(&format, "format", "f", "table", "output format of the project information (table, json, yaml)")
+	cmd.PersistentFlags().StringVar(&gitProvider, "git-provider", "", "hosting service of the repository URL (github, gitlab, gitea, bitbucket, git). by default, it is detected from the host.")
+	cmd.Flags().StringVar(&builder, "builder", "", "backend to build the image with (buildkit, buildx, podman, export). by default, the first available one of buildkit, buildx and podman.")
+	cmd.Flags().StringVar(&exportDir, "export-dir", "", "directory to write the build context and Dockerfile to, with --builder export")
+	cmd.Flags().StringVarP(&resultImage, "tag", "t", "", "name of the image to build. by default, it is the submodule name.")
+	cmd.Flags().BoolVar(&push, "push", false, "push the image to the registry instead of loading it to the local image store")
+	cmd.Flags().StringArrayVar(&cacheFrom, "cache-from", nil, "cache to import the layers from, for example, type=local,src=.cache, type=gha or an image reference of the registry cache. can be specified multiple times.")
+	cmd.Flags().StringArrayVar(&cacheTo, "cache-to", nil, "cache to export the layers to, for example, type=local,dest=.cache,mode=max, type=inline, type=gha or type=registry,ref=<image>,mode=max. can be specified multiple times.")
+	cmd.Flags().StringSliceVar(&platforms, "platform", nil, "platforms to build the image for, for example, linux/amd64,linux/arm64. an image of multiple platforms must be pushed as a manifest list.")
+	cmd.Flags().StringVar(&metadataFile, "metadata-file", "", "write the build report (image, digest, size, plan, Dockerfile hash, duration and cache statistics) as JSON to the file")
+	cmd.PersistentFlags().StringArrayVar(&plugins, "plugin", nil, "path to an external plugin executable. can be specified multiple times.")
 	cmd.SetUsageTemplate(usageTemplate)
+	cmd.AddCommand(explainCmd)
+	cmd.AddCommand(discoverCmd)
+	cmd.AddCommand(lintCmd)
 }
 
 // Execute is used to execute zbpack command-line interface.
@@ -49,6 +93,22 @@ func Execute() error {
 	return cmd.Execute()
 }
 
+// loadPlugins loads and registers the plugins specified with --plugin.
+// They are tried before the built-in language identifiers.
+func loadPlugins() error {
+	for _, path := range plugins {
+		p, err := plugin.Load(path)
+		if err != nil {
+			return fmt.Errorf("load plugin: %w", err)
+		}
+
+		log.Printf("loaded plugin %s for plan type %s", path, p.PlanType())
+		zeaburpack.RegisterPlugin(p, zeaburpack.PriorityBuiltin+1)
+	}
+
+	return nil
+}
+
 // run is command-line entrypoint.
 func run(args []string) error {
 	path := args[0]
@@ -65,29 +125,29 @@ func run(args []string) error {
 
 // build is used to build Docker image and show build plan.
 func build(path string) error {
-	// before start, check if buildctl is installed and buildkitd is running
-	err := exec.Command("buildctl", "debug", "workers").Run()
-	if err != nil {
-		red := "\033[31m"
-		blue := "\033[34m"
-		reset := "\033[0m"
-		gray := "\033[90m"
-
-		print(red, "buildctl is not installed or buildkitd is not running.\n", reset)
-		print("Learn more: https://github.com/moby/buildkit#quick-start\n\n", reset)
-		print(gray, "Or you can simply run the following command to run buildkitd in a container:\n", reset)
-		print(blue, "docker run -d --name buildkitd --privileged moby/buildkit:latest\n\n", reset)
-		print(gray, "And then install buildctl if you haven't:\n", reset)
-		print(blue, "docker cp buildkitd:/usr/bin/buildctl /usr/local/bin\n\n", reset)
-		print(gray, "After that, you can run zbpack again with the following command:\n", reset)
-		print(blue, "BUILDKIT_HOST=docker-container://buildkitd zbpack <...>\n", reset)
-
-		return nil
+	var b zeaburpack.Builder
+	if builder != "" {
+		var err error
+		if b, err = zeaburpack.NewBuilder(builder, exportDir); err != nil {
+			return err
+		}
+		if err := b.Available(); err != nil {
+			if builder == zeaburpack.BuilderBuildKit || builder == zeaburpack.BuilderBuildctl {
+				printBuildKitHint()
+			}
+			return err
+		}
+	} else if exportDir != "" {
+		b = zeaburpack.ExportBuilder{Dir: exportDir}
 	}
 
-	// TODO support online repositories
-	if strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://") {
-		return fmt.Errorf("zbpack does not support building from online repositories yet")
+	cacheImports, err := parseCaches(cacheFrom)
+	if err != nil {
+		return err
+	}
+	cacheExports, err := parseCaches(cacheTo)
+	if err != nil {
+		return err
 	}
 
 	submoduleName, err := GetSubmoduleName(path)
@@ -112,14 +172,75 @@ func build(path string) error {
 
 	log.Printf("environment variables to pass: %+v", userVarsToBuild)
 
-	return zeaburpack.Build(
+	var githubToken *string
+	githubTokenStr := os.Getenv("GITHUB_ACCESS_TOKEN")
+	if githubTokenStr != "" {
+		githubToken = &githubTokenStr
+	}
+
+	// stop the build on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := zeaburpack.BuildContext(
+		ctx,
 		&zeaburpack.BuildOptions{
 			Path:          &path,
+			AccessToken:   githubToken,
+			GitProvider:   zeaburpack.GitProvider(gitProvider),
 			Interactive:   lo.ToPtr(true),
 			SubmoduleName: &submoduleName,
 			UserVars:      &userVarsToBuild,
+			Builder:       b,
+			MetadataFile:  metadataFile,
+			ResultImage:   &resultImage,
+			PushImage:     push,
+			Platforms:     platforms,
+			CacheImports:  cacheImports,
+			CacheExports:  cacheExports,
 		},
 	)
+	if errors.Is(err, zeaburpack.ErrNoBuilder) {
+		printBuildKitHint()
+	}
+	if err != nil {
+		return err
+	}
+
+	if result.Digest != "" {
+		log.Printf("image digest: %s", result.Digest)
+	}
+
+	return nil
+}
+
+// parseCaches parses the values of --cache-from or --cache-to.
+func parseCaches(values []string) ([]zeaburpack.Cache, error) {
+	caches := make([]zeaburpack.Cache, 0, len(values))
+	for _, value := range values {
+		cache, err := zeaburpack.ParseCache(value)
+		if err != nil {
+			return nil, err
+		}
+		caches = append(caches, cache)
+	}
+	return caches, nil
+}
+
+// printBuildKitHint prints how to run buildkitd.
+func printBuildKitHint() {
+	red := "\033[31m"
+	blue := "\033[34m"
+	reset := "\033[0m"
+	gray := "\033[90m"
+
+	print(red, "buildkitd is not running.\n", reset)
+	print("Learn more: https://github.com/moby/buildkit#quick-start\n\n", reset)
+	print(gray, "Or you can simply run the following command to run buildkitd in a container:\n", reset)
+	print(blue, "docker run -d --name buildkitd --privileged moby/buildkit:latest\n\n", reset)
+	print(gray, "After that, you can run zbpack again with the following command:\n", reset)
+	print(blue, "BUILDKIT_HOST=docker-container://buildkitd zbpack <...>\n\n", reset)
+	print(gray, "Alternatively, build with Docker or Podman with --builder buildx or --builder podman.\n", reset)
 }
 
 // plan is used to analyze and print project information.
@@ -137,15 +258,51 @@ func plan(path string) error {
 		githubToken = &githubTokenStr
 	}
 
-	t, m := zeaburpack.Plan(
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	info, planErr := zeaburpack.GetPlanInfoContext(
+		ctx,
 		zeaburpack.PlanOptions{
 			SubmoduleName: &submoduleName,
 			Path:          &path,
 			AccessToken:   githubToken,
+			GitProvider:   zeaburpack.GitProvider(gitProvider),
+
+			IncludeRunnerUps: true,
 		},
 	)
 
-	zeaburpack.PrintPlanAndMeta(t, m, os.Stderr)
+	if format == "" || format == "table" {
+		zeaburpack.PrintPlanAndMeta(info.PlanType, info.PlanMeta, os.Stderr)
+		zeaburpack.PrintRunnerUps(info.RunnerUps, os.Stderr)
+	} else if err := printStructured(info); err != nil {
+		return err
+	}
+
+	return planErr
+}
+
+// printStructured prints v to stdout in the JSON or YAML format.
+func printStructured(v any) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(v); err != nil {
+			return fmt.Errorf("encode to JSON: %w", err)
+		}
+	case "yaml":
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("encode to YAML: %w", err)
+		}
+		if _, err := os.Stdout.Write(out); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
 
 	return nil
 }
@@ -171,6 +328,7 @@ func PlanAndOutputDockerfile(path string) error {
 			SubmoduleName: &submoduleName,
 			Path:          &path,
 			AccessToken:   githubToken,
+			GitProvider:   zeaburpack.GitProvider(gitProvider),
 		},
 	)
 }
@@ -0,0 +1,178 @@
+// Package lint checks Dockerfiles against the best practices and policies,
+// for example, pinned base images and non-root users.
+package lint
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// Severity is how serious a finding is.
+type Severity string
+
+// The severities of the findings.
+const (
+	// SeverityError is a problem which should fail the check.
+	SeverityError Severity = "error"
+	// SeverityWarning is a problem which should be fixed.
+	SeverityWarning Severity = "warning"
+	// SeverityInfo is a suggestion.
+	SeverityInfo Severity = "info"
+	// SeverityOff disables the rule.
+	SeverityOff Severity = "off"
+)
+
+// ParseSeverity parses the severity, case-insensitively.
+func ParseSeverity(s string) (Severity, error) {
+	severity := Severity(strings.ToLower(strings.TrimSpace(s)))
+	switch severity {
+	case SeverityError, SeverityWarning, SeverityInfo, SeverityOff:
+		return severity, nil
+	}
+	return "", fmt.Errorf("unknown severity %q (error, warning, info or off)", s)
+}
+
+// Finding is a problem found in the Dockerfile.
+type Finding struct {
+	// Rule is the ID of the rule, for example, "root-user".
+	Rule string `json:"rule" yaml:"rule"`
+	// Severity is the severity of the rule.
+	Severity Severity `json:"severity" yaml:"severity"`
+	// Line is the line of the instruction in the Dockerfile, starting from 1.
+	Line int `json:"line" yaml:"line"`
+	// Message describes the problem.
+	Message string `json:"message" yaml:"message"`
+}
+
+// Options is the options for Lint.
+type Options struct {
+	// Severity overrides the default severities of the rules by the
+	// rule ID. Set a rule to SeverityOff to disable it.
+	Severity map[string]Severity
+}
+
+// Lint parses the Dockerfile and checks it with the rules. The findings
+// are sorted by the line.
+//
+// It returns an error if the Dockerfile cannot be parsed, or a rule or
+// a severity in the options is invalid.
+func Lint(dockerfile string, opt Options) ([]Finding, error) {
+	for id, severity := range opt.Severity {
+		if _, ok := findRule(id); !ok {
+			return nil, fmt.Errorf("unknown lint rule %q", id)
+		}
+		if parsed, err := ParseSeverity(string(severity)); err != nil || parsed != severity {
+			return nil, fmt.Errorf("invalid severity %q of lint rule %q", severity, id)
+		}
+	}
+
+	parsed, err := parser.Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		return nil, fmt.Errorf("parse Dockerfile: %w", err)
+	}
+
+	df := newDockerfile(parsed.AST)
+
+	findings := []Finding{}
+	for _, r := range rules {
+		severity := r.Severity
+		if s, ok := opt.Severity[r.ID]; ok {
+			severity = s
+		}
+		if severity == SeverityOff {
+			continue
+		}
+
+		for _, problem := range r.check(df) {
+			findings = append(findings, Finding{
+				Rule:     r.ID,
+				Severity: severity,
+				Line:     problem.line,
+				Message:  problem.message,
+			})
+		}
+	}
+
+	slices.SortStableFunc(findings, func(a, b Finding) int {
+		return cmp.Compare(a.Line, b.Line)
+	})
+	return findings, nil
+}
+
+// HasErrors reports whether any of the findings is an error.
+func HasErrors(findings []Finding) bool {
+	return slices.ContainsFunc(findings, func(f Finding) bool {
+		return f.Severity == SeverityError
+	})
+}
+
+// dockerfile is the parsed Dockerfile split into the stages.
+type dockerfile struct {
+	stages []stage
+}
+
+// stage is a build stage, from a FROM instruction to the next one.
+type stage struct {
+	// from is the FROM instruction.
+	from *parser.Node
+	// image is the base image or stage.
+	image string
+	// name is the name of the stage, if any.
+	name string
+	// instructions is the instructions after FROM.
+	instructions []*parser.Node
+}
+
+func newDockerfile(ast *parser.Node) dockerfile {
+	var df dockerfile
+
+	for _, child := range ast.Children {
+		if strings.EqualFold(child.Value, "FROM") {
+			s := stage{from: child}
+			if child.Next != nil {
+				s.image = child.Next.Value
+				if as := child.Next.Next; as != nil && strings.EqualFold(as.Value, "AS") && as.Next != nil {
+					s.name = as.Next.Value
+				}
+			}
+			df.stages = append(df.stages, s)
+			continue
+		}
+
+		// The instructions before the first FROM, such as ARG, are global.
+		if len(df.stages) > 0 {
+			last := &df.stages[len(df.stages)-1]
+			last.instructions = append(last.instructions, child)
+		}
+	}
+
+	return df
+}
+
+// runtime returns the last stage, which is the image to run.
+func (df dockerfile) runtime() (stage, bool) {
+	if len(df.stages) == 0 {
+		return stage{}, false
+	}
+	return df.stages[len(df.stages)-1], true
+}
+
+// isStage reports whether the name is a stage before the stage at index i.
+func (df dockerfile) isStage(name string, i int) bool {
+	return slices.ContainsFunc(df.stages[:i], func(s stage) bool {
+		return s.name != "" && strings.EqualFold(s.name, name)
+	})
+}
+
+// args returns the arguments of the instruction.
+func args(node *parser.Node) []string {
+	var values []string
+	for n := node.Next; n != nil; n = n.Next {
+		values = append(values, n.Value)
+	}
+	return values
+}
@@ -0,0 +1,187 @@
+package lint_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeabur/zbpack/pkg/lint"
+)
+
+// rulesOf returns the rule and the line of the findings.
+func rulesOf(findings []lint.Finding) map[string]int {
+	result := make(map[string]int)
+	for _, f := range findings {
+		result[f.Rule] = f.Line
+	}
+	return result
+}
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name       string
+		dockerfile string
+		expected   map[string]int
+	}{
+		{
+			name: "clean",
+			dockerfile: `FROM golang:1.22-alpine AS builder
+RUN apk add --no-cache git
+RUN go build -o /server .
+
+FROM gcr.io/distroless/static-debian12:nonroot
+COPY --from=builder /server /server
+EXPOSE 8080
+CMD ["/server"]`,
+			expected: map[string]int{},
+		},
+		{
+			name: "unpinned base images",
+			dockerfile: `FROM node AS build
+FROM build
+FROM ubuntu:latest
+FROM localhost:5000/app@sha256:0123
+USER app
+EXPOSE 8080`,
+			expected: map[string]int{lint.RuleUnpinnedBaseImage: 3},
+		},
+		{
+			name: "stage and argument images",
+			dockerfile: `ARG BASE=node:20
+FROM $BASE AS build
+FROM build
+USER node
+EXPOSE 8080`,
+			expected: map[string]int{},
+		},
+		{
+			name:       "missing expose and root user",
+			dockerfile: "FROM python:3.12-slim\nCMD python main.py",
+			expected:   map[string]int{lint.RuleMissingExpose: 1, lint.RuleRootUser: 1},
+		},
+		{
+			name:       "explicit root user",
+			dockerfile: "FROM python:3.12-slim\nUSER app\nUSER 0:0\nEXPOSE 8080",
+			expected:   map[string]int{lint.RuleRootUser: 3},
+		},
+		{
+			name: "remote add",
+			dockerfile: `FROM alpine:3.20
+ADD https://example.com/a.tar.gz /tmp/
+ADD --checksum=sha256:0123 https://example.com/b.tar.gz /tmp/
+ADD local.tar.gz /tmp/
+USER nobody
+EXPOSE 8080`,
+			expected: map[string]int{lint.RuleRemoteAdd: 2},
+		},
+		{
+			name: "secret in env",
+			dockerfile: `FROM alpine:3.20
+ARG NPM_TOKEN
+ENV PORT=8080 API_KEY="abc"
+ENV GITHUB_TOKEN=$NPM_TOKEN
+ENV DB_PASSWORD hunter2
+USER nobody
+EXPOSE 8080`,
+			expected: map[string]int{lint.RuleSecretInEnv: 5},
+		},
+		{
+			name: "not secrets in env",
+			dockerfile: `FROM python:3.12-slim
+ENV TOKENIZERS_PARALLELISM=false
+ENV PASSWORD_FILE=/run/secrets/db
+ENV SECRET_ROTATION_DAYS=30 CSRF_TOKEN_ENABLED=true
+ENV PASSWORDLESS_LOGIN=magic-link
+USER nobody
+EXPOSE 8080`,
+			expected: map[string]int{},
+		},
+		{
+			name: "package managers",
+			dockerfile: `FROM debian:12
+RUN apt-get update && apt-get install -y curl
+RUN apt-get update && apt-get -y install curl && rm -rf /var/lib/apt/lists/*
+RUN --mount=type=cache,target=/var/lib/apt,sharing=locked apt update && apt install -y curl
+RUN apt-get update
+RUN apk add curl
+USER nobody
+EXPOSE 8080`,
+			expected: map[string]int{lint.RuleAptWithoutCleanup: 2, lint.RuleApkWithoutNoCache: 6},
+		},
+		{
+			name:       "multiple cmd",
+			dockerfile: "FROM alpine:3.20\nUSER nobody\nEXPOSE 8080\nCMD a\nCMD b\nENTRYPOINT c",
+			expected:   map[string]int{lint.RuleMultipleCmd: 5},
+		},
+		{
+			name:       "output only",
+			dockerfile: "FROM node:20 AS build\nRUN npm run build\nFROM scratch\nCOPY --from=build /src/dist /",
+			expected:   map[string]int{},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			findings, err := lint.Lint(tc.dockerfile, lint.Options{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, rulesOf(findings))
+		})
+	}
+}
+
+func TestLint_Severity(t *testing.T) {
+	t.Parallel()
+
+	const dockerfile = "FROM python:3.12-slim\nENV SECRET_KEY=abc\nCMD python main.py"
+
+	t.Run("default", func(t *testing.T) {
+		t.Parallel()
+
+		findings, err := lint.Lint(dockerfile, lint.Options{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []lint.Finding{
+			{Rule: lint.RuleMissingExpose, Severity: lint.SeverityInfo, Line: 1, Message: "the runtime stage does not EXPOSE a port"},
+			{Rule: lint.RuleRootUser, Severity: lint.SeverityWarning, Line: 1, Message: "the runtime stage has no USER, so the application runs as root"},
+			{Rule: lint.RuleSecretInEnv, Severity: lint.SeverityError, Line: 2, Message: "ENV SECRET_KEY looks like a secret; pass it at runtime or with a secret mount instead"},
+		}, findings)
+		assert.True(t, lint.HasErrors(findings))
+	})
+
+	t.Run("overridden", func(t *testing.T) {
+		t.Parallel()
+
+		findings, err := lint.Lint(dockerfile, lint.Options{Severity: map[string]lint.Severity{
+			lint.RuleRootUser:      lint.SeverityError,
+			lint.RuleSecretInEnv:   lint.SeverityWarning,
+			lint.RuleMissingExpose: lint.SeverityOff,
+		}})
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]int{lint.RuleRootUser: 1, lint.RuleSecretInEnv: 2}, rulesOf(findings))
+		assert.Equal(t, lint.SeverityError, findings[0].Severity)
+		assert.Equal(t, lint.SeverityWarning, findings[1].Severity)
+	})
+
+	t.Run("unknown rule", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := lint.Lint(dockerfile, lint.Options{Severity: map[string]lint.Severity{"no-such-rule": lint.SeverityOff}})
+		assert.ErrorContains(t, err, "no-such-rule")
+	})
+}
+
+func TestParseSeverity(t *testing.T) {
+	t.Parallel()
+
+	severity, err := lint.ParseSeverity(" Warning ")
+	require.NoError(t, err)
+	assert.Equal(t, lint.SeverityWarning, severity)
+
+	_, err = lint.ParseSeverity("fatal")
+	assert.Error(t, err)
+}
@@ -0,0 +1,382 @@
+package lint
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// Rule is a check of the Dockerfile.
+type Rule struct {
+	// ID is the identifier of the rule, for example, "root-user".
+	ID string `json:"id" yaml:"id"`
+	// Description describes what the rule checks.
+	Description string `json:"description" yaml:"description"`
+	// Severity is the default severity of the rule.
+	Severity Severity `json:"severity" yaml:"severity"`
+
+	check func(df dockerfile) []problem
+}
+
+// problem is a problem found by a rule.
+type problem struct {
+	line    int
+	message string
+}
+
+// The IDs of the rules.
+const (
+	RuleUnpinnedBaseImage = "unpinned-base-image"
+	RuleMissingExpose     = "missing-expose"
+	RuleRemoteAdd         = "remote-add"
+	RuleRootUser          = "root-user"
+	RuleSecretInEnv       = "secret-in-env"
+	RuleAptWithoutCleanup = "apt-without-cleanup"
+	RuleApkWithoutNoCache = "apk-without-no-cache"
+	RuleMultipleCmd       = "multiple-cmd"
+)
+
+var rules = []Rule{
+	{
+		ID:          RuleUnpinnedBaseImage,
+		Description: "The base image has no tag, or is tagged latest, so the build is not reproducible.",
+		Severity:    SeverityWarning,
+		check:       checkUnpinnedBaseImage,
+	},
+	{
+		ID:          RuleMissingExpose,
+		Description: "The runtime stage does not EXPOSE the port the application listens to.",
+		Severity:    SeverityInfo,
+		check:       checkMissingExpose,
+	},
+	{
+		ID:          RuleRemoteAdd,
+		Description: "ADD downloads a remote URL without --checksum, so the content is not verified.",
+		Severity:    SeverityWarning,
+		check:       checkRemoteAdd,
+	},
+	{
+		ID:          RuleRootUser,
+		Description: "The application runs as root.",
+		Severity:    SeverityWarning,
+		check:       checkRootUser,
+	},
+	{
+		ID:          RuleSecretInEnv,
+		Description: "ENV sets a secret, which is stored in the image.",
+		Severity:    SeverityError,
+		check:       checkSecretInEnv,
+	},
+	{
+		ID:          RuleAptWithoutCleanup,
+		Description: "apt installs packages without removing the package lists in the same RUN.",
+		Severity:    SeverityWarning,
+		check:       checkAptWithoutCleanup,
+	},
+	{
+		ID:          RuleApkWithoutNoCache,
+		Description: "apk adds packages without --no-cache, so the package index is stored in the image.",
+		Severity:    SeverityInfo,
+		check:       checkApkWithoutNoCache,
+	},
+	{
+		ID:          RuleMultipleCmd,
+		Description: "A stage has more than one CMD or ENTRYPOINT, and only the last one takes effect.",
+		Severity:    SeverityWarning,
+		check:       checkMultipleCmd,
+	},
+}
+
+// Rules returns the rules with their default severities.
+func Rules() []Rule {
+	out := make([]Rule, len(rules))
+	copy(out, rules)
+	return out
+}
+
+// findRule finds the rule by the ID.
+func findRule(id string) (Rule, bool) {
+	for _, r := range rules {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+func checkUnpinnedBaseImage(df dockerfile) []problem {
+	var problems []problem
+
+	for i, s := range df.stages {
+		image := s.image
+		// The stages, the images from the build arguments and the
+		// empty image cannot be pinned.
+		if image == "" || image == "scratch" || strings.Contains(image, "$") || df.isStage(image, i) {
+			continue
+		}
+		if strings.Contains(image, "@") {
+			continue
+		}
+
+		name := image[strings.LastIndex(image, "/")+1:]
+		_, tag, hasTag := strings.Cut(name, ":")
+		switch {
+		case !hasTag:
+			problems = append(problems, problem{s.from.StartLine, "base image " + image + " has no tag, which means latest; pin it to a version"})
+		case tag == "latest":
+			problems = append(problems, problem{s.from.StartLine, "base image " + image + " is tagged latest; pin it to a version"})
+		}
+	}
+
+	return problems
+}
+
+func checkMissingExpose(df dockerfile) []problem {
+	runtime, ok := df.runtime()
+	if !ok || runtime.image == "scratch" {
+		return nil
+	}
+
+	for _, node := range runtime.instructions {
+		if strings.EqualFold(node.Value, "EXPOSE") {
+			return nil
+		}
+	}
+
+	return []problem{{runtime.from.StartLine, "the runtime stage does not EXPOSE a port"}}
+}
+
+func checkRemoteAdd(df dockerfile) []problem {
+	var problems []problem
+
+	for _, s := range df.stages {
+		for _, node := range s.instructions {
+			if !strings.EqualFold(node.Value, "ADD") || hasFlag(node, "--checksum") {
+				continue
+			}
+
+			sources := args(node)
+			if len(sources) > 0 {
+				sources = sources[:len(sources)-1]
+			}
+			for _, src := range sources {
+				if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+					problems = append(problems, problem{node.StartLine, "ADD downloads " + src + " without --checksum; add --checksum or download it in RUN and verify it"})
+				}
+			}
+		}
+	}
+
+	return problems
+}
+
+func checkRootUser(df dockerfile) []problem {
+	runtime, ok := df.runtime()
+	// The empty image has no process, and the nonroot variants of the
+	// distroless images run as a non-root user.
+	if !ok || runtime.image == "scratch" || strings.HasSuffix(runtime.image, ":nonroot") {
+		return nil
+	}
+
+	var user *parser.Node
+	for _, node := range runtime.instructions {
+		if strings.EqualFold(node.Value, "USER") {
+			user = node
+		}
+	}
+
+	if user == nil {
+		return []problem{{runtime.from.StartLine, "the runtime stage has no USER, so the application runs as root"}}
+	}
+	if name := strings.Join(args(user), " "); isRoot(name) {
+		return []problem{{user.StartLine, "USER " + name + " runs the application as root"}}
+	}
+	return nil
+}
+
+// isRoot reports whether the user in USER is root.
+func isRoot(user string) bool {
+	name, _, _ := strings.Cut(user, ":")
+	return name == "root" || name == "0"
+}
+
+// secretKeyPattern matches the names of the environment variables which
+// usually hold a secret. The words are matched as whole "_"-delimited
+// segments, so that TOKENIZERS_PARALLELISM is not a secret.
+var secretKeyPattern = regexp.MustCompile(`(?i)(^|_)(PASSWORD|PASSWD|SECRET|TOKEN|API_?KEY|PRIVATE_?KEY|ACCESS_?KEY|CREDENTIALS?)(_|$)`)
+
+// isSecretEnv reports whether the environment variable looks like it
+// holds a literal secret.
+func isSecretEnv(key, value string) bool {
+	if !secretKeyPattern.MatchString(key) {
+		return false
+	}
+	// PASSWORD_FILE is the path to the secret, such as a Docker secret.
+	if strings.HasSuffix(strings.ToUpper(key), "_FILE") {
+		return false
+	}
+	// A value from a build argument or another variable is not a literal secret.
+	if value == "" || strings.HasPrefix(value, "$") {
+		return false
+	}
+	// The booleans and the numbers are the options, not the secrets.
+	if _, err := strconv.ParseBool(value); err == nil {
+		return false
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return false
+	}
+	return true
+}
+
+func checkSecretInEnv(df dockerfile) []problem {
+	var problems []problem
+
+	for _, s := range df.stages {
+		for _, node := range s.instructions {
+			if !strings.EqualFold(node.Value, "ENV") {
+				continue
+			}
+
+			for _, pair := range envPairs(node) {
+				key, value := pair[0], strings.Trim(pair[1], `"'`)
+				if !isSecretEnv(key, value) {
+					continue
+				}
+				problems = append(problems, problem{node.StartLine, "ENV " + key + " looks like a secret; pass it at runtime or with a secret mount instead"})
+			}
+		}
+	}
+
+	return problems
+}
+
+var (
+	aptInstallPattern = regexp.MustCompile(`\bapt(-get)?\s+([^&|;]*\s)?install\b`)
+	apkAddPattern     = regexp.MustCompile(`\bapk\s+([^&|;]*\s)?add\b`)
+)
+
+func checkAptWithoutCleanup(df dockerfile) []problem {
+	var problems []problem
+
+	for _, node := range runInstructions(df) {
+		script := runScript(node)
+		if !aptInstallPattern.MatchString(script) {
+			continue
+		}
+		// The package lists in a cache mount are not stored in the image.
+		if strings.Contains(script, "/var/lib/apt/lists") || hasCacheMount(node, "/var/lib/apt/lists") {
+			continue
+		}
+		problems = append(problems, problem{node.StartLine, "apt installs packages without rm -rf /var/lib/apt/lists/* in the same RUN"})
+	}
+
+	return problems
+}
+
+func checkApkWithoutNoCache(df dockerfile) []problem {
+	var problems []problem
+
+	for _, node := range runInstructions(df) {
+		script := runScript(node)
+		if !apkAddPattern.MatchString(script) {
+			continue
+		}
+		if strings.Contains(script, "--no-cache") || strings.Contains(script, "/var/cache/apk") || hasCacheMount(node, "/var/cache/apk") {
+			continue
+		}
+		problems = append(problems, problem{node.StartLine, "apk adds packages without --no-cache"})
+	}
+
+	return problems
+}
+
+func checkMultipleCmd(df dockerfile) []problem {
+	var problems []problem
+
+	for _, s := range df.stages {
+		seen := make(map[string]bool)
+		for _, node := range s.instructions {
+			instruction := strings.ToUpper(node.Value)
+			if instruction != "CMD" && instruction != "ENTRYPOINT" {
+				continue
+			}
+			if seen[instruction] {
+				problems = append(problems, problem{node.StartLine, "the stage has more than one " + instruction + ", and only the last one takes effect"})
+			}
+			seen[instruction] = true
+		}
+	}
+
+	return problems
+}
+
+// envPairs returns the keys and the values of the ENV instruction,
+// which is parsed as the triples of the key, the value and the separator.
+func envPairs(node *parser.Node) [][2]string {
+	var pairs [][2]string
+	for n := node.Next; n != nil && n.Next != nil; {
+		pairs = append(pairs, [2]string{n.Value, n.Next.Value})
+		if n.Next.Next == nil {
+			break
+		}
+		n = n.Next.Next.Next
+	}
+	return pairs
+}
+
+// runInstructions returns the RUN instructions of all stages.
+func runInstructions(df dockerfile) []*parser.Node {
+	var nodes []*parser.Node
+	for _, s := range df.stages {
+		for _, node := range s.instructions {
+			if strings.EqualFold(node.Value, "RUN") {
+				nodes = append(nodes, node)
+			}
+		}
+	}
+	return nodes
+}
+
+// runScript returns the command of the RUN instruction with its heredocs.
+func runScript(node *parser.Node) string {
+	script := strings.Join(args(node), " ")
+	for _, heredoc := range node.Heredocs {
+		script += "\n" + heredoc.Content
+	}
+	return script
+}
+
+// hasFlag reports whether the instruction has the flag, with or without a value.
+func hasFlag(node *parser.Node, name string) bool {
+	for _, flag := range node.Flags {
+		if flag == name || strings.HasPrefix(flag, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCacheMount reports whether the RUN instruction mounts a cache
+// on the target or its parent directory.
+func hasCacheMount(node *parser.Node, target string) bool {
+	for _, flag := range node.Flags {
+		mount, ok := strings.CutPrefix(flag, "--mount=")
+		if !ok || !strings.Contains(mount, "type=cache") {
+			continue
+		}
+		for _, option := range strings.Split(mount, ",") {
+			key, value, _ := strings.Cut(option, "=")
+			value = strings.TrimSuffix(value, "/")
+			if key != "target" && key != "dst" && key != "destination" || value == "" {
+				continue
+			}
+			if target == value || strings.HasPrefix(target, value+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
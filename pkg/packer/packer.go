@@ -2,6 +2,8 @@
 package packer
 
 import (
+	"context"
+
 	"github.com/zeabur/zbpack/pkg/plan"
 	"github.com/zeabur/zbpack/pkg/types"
 )
@@ -32,3 +34,53 @@ type packerV2Wrapper struct {
 func (p *packerV2Wrapper) Match(ctx plan.MatchContext) bool {
 	return p.Packer.Match(ctx.Source)
 }
+
+// ContextPacker is a packer which stops generating the Dockerfile when
+// ctx is done, for example, a packer calling an external process.
+type ContextPacker interface {
+	GenerateDockerfileContext(ctx context.Context, meta types.PlanMeta) (string, error)
+}
+
+// GenerateDockerfileContext generates a Dockerfile with ctx if the packer
+// implements ContextPacker, or with GenerateDockerfile otherwise.
+func GenerateDockerfileContext(ctx context.Context, p V2, meta types.PlanMeta) (string, error) {
+	if cp, ok := p.(ContextPacker); ok {
+		return cp.GenerateDockerfileContext(ctx, meta)
+	}
+
+	return p.GenerateDockerfile(meta)
+}
+
+// BuildPlanPacker is a packer which generates a Dockerfile from
+// the typed types.BuildPlan.
+//
+// Every built-in packer implements it. The other packers, such as
+// the plugins, read the PlanMeta converted with types.BuildPlan.PlanMeta.
+type BuildPlanPacker interface {
+	GenerateDockerfileFromBuildPlan(types.BuildPlan) (string, error)
+}
+
+// AsBuildPlanPacker returns the packer as a BuildPlanPacker,
+// including the packers wrapped by WrapV2.
+func AsBuildPlanPacker(p V2) (BuildPlanPacker, bool) {
+	if w, ok := p.(*packerV2Wrapper); ok {
+		if bpp, ok := w.Packer.(BuildPlanPacker); ok {
+			return bpp, true
+		}
+	}
+
+	bpp, ok := p.(BuildPlanPacker)
+	return bpp, ok
+}
+
+// GenerateDockerfileFromBuildPlan generates a Dockerfile from the typed build plan.
+//
+// If the packer does not implement BuildPlanPacker, the build plan
+// is converted to PlanMeta with types.BuildPlan.PlanMeta.
+func GenerateDockerfileFromBuildPlan(p V2, bp types.BuildPlan) (string, error) {
+	if bpp, ok := AsBuildPlanPacker(p); ok {
+		return bpp.GenerateDockerfileFromBuildPlan(bp)
+	}
+
+	return p.GenerateDockerfile(bp.PlanMeta())
+}
@@ -0,0 +1,112 @@
+package plan
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+
+	"github.com/spf13/cast"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+// Candidate is a plan which an identifier can provide for the project.
+type Candidate struct {
+	PlanType types.PlanType `json:"planType" yaml:"planType"`
+	PlanMeta types.PlanMeta `json:"planMeta" yaml:"planMeta"`
+	// Score is the confidence of the identifier. See MatchResult.
+	Score float64 `json:"score" yaml:"score"`
+	// Reasons is the reasons of the score. See MatchResult.
+	Reasons []string `json:"reasons" yaml:"reasons"`
+	// Selected indicates if this candidate is the plan Plan returns.
+	Selected bool `json:"selected" yaml:"selected"`
+}
+
+func (b planner) PlanCandidates() ([]Candidate, error) {
+	// The candidates are planned in the same planning as the selected one.
+	b.Context = withPlanning(b.goContext())
+	opt := b.NewPlannerOptions
+	matchContext := MatchContext{
+		Source:        opt.Source,
+		Config:        opt.Config,
+		SubmoduleName: opt.SubmoduleName,
+		Context:       opt.goContext(),
+	}
+
+	// The selected candidate is the same as Plan.
+	identified, err := b.identify(false)
+	selected := identified.index
+	if selected == -1 {
+		if errors.Is(err, ErrNoIdentifierMatched) {
+			return []Candidate{}, err
+		}
+		return nil, err
+	}
+
+	forcedPlanType, forcedErr := Cast(opt.Config.Get(ConfigKeyPlanType), cast.ToStringE).Take()
+	isForced := func(identifier IdentifierV2) bool {
+		return forcedErr == nil && identifier.PlanType() == types.PlanType(forcedPlanType)
+	}
+	forcedResult := MatchResult{Score: 1, Reasons: []string{"specified by " + ConfigKeyPlanType}}
+
+	result := forcedResult
+	if !isForced(b.identifiers[selected]) {
+		result = GetMatchResult(b.identifiers[selected], matchContext)
+	}
+	candidates := []Candidate{{
+		PlanType: identified.planType,
+		PlanMeta: identified.planMeta,
+		Score:    result.Score,
+		Reasons:  result.Reasons,
+		Selected: true,
+	}}
+
+	// The runner-ups are the other matched identifiers.
+	var runnerUps []Candidate
+	for i, identifier := range b.identifiers {
+		if i == selected {
+			continue
+		}
+		if err := opt.goContext().Err(); err != nil {
+			return nil, err
+		}
+
+		var result MatchResult
+		if isForced(identifier) {
+			result = forcedResult
+		} else if identifier.Match(matchContext) {
+			result = GetMatchResult(identifier, matchContext)
+		} else {
+			continue
+		}
+
+		pm := identifier.PlanMeta(opt)
+		if v, ok := pm["__INTERNAL_STATE"]; ok && v == "CONTINUE" {
+			continue
+		}
+
+		runnerUps = append(runnerUps, Candidate{
+			PlanType: identifier.PlanType(),
+			PlanMeta: pm,
+			Score:    result.Score,
+			Reasons:  result.Reasons,
+		})
+	}
+
+	// The runner-ups are ranked by score. The identifiers with the same
+	// score keep their priority.
+	slices.SortStableFunc(runnerUps, func(a, b Candidate) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+
+	candidates = append(candidates, runnerUps...)
+
+	// err is *AmbiguousMatchError if more than one identifier provides
+	// the plan type specified in the configuration.
+	if err == nil {
+		shared := b.sharedOptions()
+		for i := range candidates {
+			candidates[i].PlanMeta = shared.applyToPlanMeta(candidates[i].PlanMeta)
+		}
+	}
+	return candidates, err
+}
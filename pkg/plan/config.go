@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/iancoleman/strcase"
 	"github.com/moznion/go-optional"
@@ -40,16 +42,42 @@ type ViperProjectConfiguration struct {
 	root *viper.Viper
 	// submodule is the configuration for the `zbpack.[submodule].json`.
 	submodule *viper.Viper
+	// submoduleFile is the filename of the submodule configuration.
+	submoduleFile string
 	// extra is the manual overridden value of this configuration.
 	extra map[string]any
+
+	// sources is the configuration files loaded.
+	sources []string
+	// warnings is the problems encountered when loading the configuration files.
+	warnings []string
 }
 
 // Get returns the value of the given key. If the key is not present, it returns None.
 func (vpc *ViperProjectConfiguration) Get(key string) optional.Option[any] {
+	val, _ := vpc.GetWithSource(key)
+	return val
+}
+
+// Configuration sources returned by GetWithSource.
+const (
+	// ConfigSourceExtra means the value is set with Set.
+	ConfigSourceExtra = "extra"
+	// ConfigSourceEnvPrefix is the prefix of the environment variable source,
+	// for example, "env:ZBPACK_BUILD_COMMAND".
+	ConfigSourceEnvPrefix = "env:"
+)
+
+// GetWithSource returns the value of the given key and where it comes from.
+//
+// The source is ConfigSourceExtra, ConfigSourceEnvPrefix with the name of
+// environment variable, or the name of the configuration file.
+// If the key is not present, it returns None and an empty source.
+func (vpc *ViperProjectConfiguration) GetWithSource(key string) (optional.Option[any], string) {
 	/* extra */
 
 	if val, ok := vpc.extra[key]; ok {
-		return optional.Some(val)
+		return optional.Some(val), ConfigSourceExtra
 	}
 
 	/* env */
@@ -57,27 +85,27 @@ func (vpc *ViperProjectConfiguration) Get(key string) optional.Option[any] {
 	// ZOLA_VERSION {"zola_version: "1.2.3"}
 	if key == "zolaVersion" || key == "zola_version" {
 		if val, ok := os.LookupEnv("ZOLA_VERSION"); ok {
-			return optional.Some[any](val)
+			return optional.Some[any](val), ConfigSourceEnvPrefix + "ZOLA_VERSION"
 		}
 	}
 
 	// key.a.b.c -> ZBPACK_KEY_A_B_C
 	envKey := "ZBPACK_" + strcase.ToScreamingSnake(key)
 	if val, ok := os.LookupEnv(envKey); ok {
-		return optional.Some[any](val)
+		return optional.Some[any](val), ConfigSourceEnvPrefix + envKey
 	}
 
 	/* zbpack.json */
 
 	if vpc.submodule != nil && vpc.submodule.IsSet(key) {
-		return optional.Some(vpc.submodule.Get(key))
+		return optional.Some(vpc.submodule.Get(key)), vpc.submoduleFile
 	}
 
 	if vpc.root != nil && vpc.root.IsSet(key) {
-		return optional.Some(vpc.root.Get(key))
+		return optional.Some(vpc.root.Get(key)), "zbpack.json"
 	}
 
-	return optional.None[any]()
+	return optional.None[any](), ""
 }
 
 // Set sets the value of the given key. The value set here has the highest priority.
@@ -94,28 +122,73 @@ func (vpc *ViperProjectConfiguration) Set(key string, val any) {
 // If the configuration file is not found, it will print a warning and
 // return a default configuration.
 func NewProjectConfigurationFromFs(fs afero.Fs, submoduleName string) ProjectConfiguration {
+	config, _ := NewProjectConfigurationFromFsE(fs, submoduleName)
+	return config
+}
+
+// NewProjectConfigurationFromFsE is the same as NewProjectConfigurationFromFs,
+// but it also returns the *ConfigParseError of the configuration files which
+// exist but cannot be parsed. The returned configuration is always usable.
+func NewProjectConfigurationFromFsE(fs afero.Fs, submoduleName string) (ProjectConfiguration, error) {
 	vpc := &ViperProjectConfiguration{
 		root:      nil,
 		submodule: nil,
 	}
 
+	var errs []error
+
 	root, err := loadConfigToViper(fs, "zbpack.json")
 	if err != nil && !errors.Is(err, afero.ErrFileNotFound) {
 		log.Printf("Failed to read the root configuration file (%s).", err)
+		vpc.warnings = append(vpc.warnings, fmt.Sprintf("failed to read the root configuration file: %s", err))
+		errs = append(errs, &ConfigParseError{File: "zbpack.json", Err: err})
 	} else {
 		vpc.root = root
+		if root != nil {
+			vpc.sources = append(vpc.sources, "zbpack.json")
+		}
 	}
 
 	if submoduleName != "" {
-		submodule, err := loadConfigToViper(fs, fmt.Sprintf("zbpack.%s.json", submoduleName))
+		filename := fmt.Sprintf("zbpack.%s.json", submoduleName)
+		submodule, err := loadConfigToViper(fs, filename)
 		if err != nil && !errors.Is(err, afero.ErrFileNotFound) {
 			log.Printf("Failed to read the submodule configuration file (%s).", err)
+			vpc.warnings = append(vpc.warnings, fmt.Sprintf("failed to read the submodule configuration file: %s", err))
+			errs = append(errs, &ConfigParseError{File: filename, Err: err})
 		} else {
 			vpc.submodule = submodule
+			vpc.submoduleFile = filename
+			if submodule != nil {
+				vpc.sources = append(vpc.sources, filename)
+			}
 		}
 	}
 
-	return vpc
+	return vpc, errors.Join(errs...)
+}
+
+// Sources returns where this configuration is loaded from: the configuration
+// files, and the "ZBPACK_*" environment variables in the form of "env:ZBPACK_KEY".
+func (vpc *ViperProjectConfiguration) Sources() []string {
+	sources := slices.Clone(vpc.sources)
+
+	var envSources []string
+	for _, env := range os.Environ() {
+		key, _, _ := strings.Cut(env, "=")
+		// ZBPACK_VAR_* are the variables passed to the build, not the configuration.
+		if strings.HasPrefix(key, "ZBPACK_") && !strings.HasPrefix(key, "ZBPACK_VAR_") {
+			envSources = append(envSources, ConfigSourceEnvPrefix+key)
+		}
+	}
+	slices.Sort(envSources)
+
+	return append(sources, envSources...)
+}
+
+// Warnings returns the problems encountered when loading the configuration files.
+func (vpc *ViperProjectConfiguration) Warnings() []string {
+	return slices.Clone(vpc.warnings)
 }
 
 func loadConfigToViper(fs afero.Fs, filename string) (*viper.Viper, error) {
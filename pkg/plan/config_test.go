@@ -147,3 +147,31 @@ func TestSet(t *testing.T) {
 
 	assert.Equal(t, optional.Some[any]("uwu"), config.Get("owo"))
 }
+
+func TestProjectConfiguration_Sources(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "zbpack.json", []byte(`{"build_command": "make"}`), 0644)
+	_ = afero.WriteFile(fs, "zbpack.api.json", []byte(`I'm not JSON'`), 0644)
+	t.Setenv("ZBPACK_START_COMMAND", "./server")
+	t.Setenv("ZBPACK_VAR_FOO", "bar")
+
+	config := plan.NewProjectConfigurationFromFs(fs, "api").(*plan.ViperProjectConfiguration)
+
+	assert.Equal(t, []string{"zbpack.json", "env:ZBPACK_START_COMMAND"}, config.Sources())
+	assert.Len(t, config.Warnings(), 1)
+	assert.Contains(t, config.Warnings()[0], "zbpack.api.json")
+}
+
+func TestProjectConfigurationE_Malformed(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "zbpack.json", []byte(`I'm not JSON'`), 0644)
+
+	config, err := plan.NewProjectConfigurationFromFsE(fs, "")
+
+	var parseErr *plan.ConfigParseError
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, "zbpack.json", parseErr.File)
+	assert.True(t, config.Get("laravel").IsNone())
+}
@@ -0,0 +1,38 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/samber/lo"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+// ErrNoIdentifierMatched is returned when no identifier matches the project.
+var ErrNoIdentifierMatched = errors.New("no identifier matched")
+
+// AmbiguousMatchError is returned when more than one identifier can provide
+// the plan and the planner cannot tell which one to use.
+type AmbiguousMatchError struct {
+	// PlanTypes is the plan types of the candidate identifiers.
+	PlanTypes []types.PlanType
+}
+
+func (e *AmbiguousMatchError) Error() string {
+	return fmt.Sprintf("ambiguous match: %v", lo.Uniq(e.PlanTypes))
+}
+
+// ConfigParseError is returned when a configuration file exists but cannot be read or parsed.
+type ConfigParseError struct {
+	// File is the name of the configuration file.
+	File string
+	Err  error
+}
+
+func (e *ConfigParseError) Error() string {
+	return fmt.Sprintf("parse config file %s: %s", e.File, e.Err)
+}
+
+func (e *ConfigParseError) Unwrap() error {
+	return e.Err
+}
@@ -2,6 +2,7 @@
 package plan
 
 import (
+	"context"
 	"github.com/spf13/afero"
 
 	"github.com/zeabur/zbpack/pkg/types"
@@ -26,6 +27,10 @@ type MatchContext struct {
 	Source        afero.Fs
 	Config        ImmutableProjectConfiguration
 	SubmoduleName string
+
+	// Context is done when the planning is canceled. It is never nil
+	// when the planner calls the identifier.
+	Context context.Context
 }
 
 // WrapV2 wraps an Identifier to an IdentifierV2.
@@ -42,3 +47,90 @@ type identifierV2Wrapper struct {
 func (i *identifierV2Wrapper) Match(ctx MatchContext) bool {
 	return i.Identifier.Match(ctx.Source)
 }
+
+// BuildPlanIdentifier is an identifier which produces the typed
+// types.BuildPlan directly.
+//
+// PlanMeta of such an identifier should return the same plan
+// converted by types.BuildPlan.PlanMeta.
+//
+// Every built-in identifier implements it. The plans of the other
+// identifiers, such as the plugins, are converted from PlanMeta with
+// types.NewBuildPlan.
+type BuildPlanIdentifier interface {
+	BuildPlan(NewPlannerOptions) types.BuildPlan
+}
+
+// GetBuildPlan gets the typed build plan from the identifier.
+//
+// If the identifier does not implement BuildPlanIdentifier,
+// its PlanMeta is converted with types.NewBuildPlan.
+func GetBuildPlan(i IdentifierV2, opt NewPlannerOptions) types.BuildPlan {
+	if bpi, ok := asBuildPlanIdentifier(i); ok {
+		return bpi.BuildPlan(opt)
+	}
+
+	return types.NewBuildPlan(i.PlanType(), i.PlanMeta(opt))
+}
+
+// identifierPlan plans with the identifier. It returns the typed build
+// plan too if the identifier implements BuildPlanIdentifier, so that
+// the identifier plans only once.
+func identifierPlan(i IdentifierV2, opt NewPlannerOptions) (types.PlanMeta, *types.BuildPlan) {
+	if bpi, ok := asBuildPlanIdentifier(i); ok {
+		bp := bpi.BuildPlan(opt)
+		return bp.PlanMeta(), &bp
+	}
+
+	return i.PlanMeta(opt), nil
+}
+
+func asBuildPlanIdentifier(i IdentifierV2) (BuildPlanIdentifier, bool) {
+	if w, ok := i.(*identifierV2Wrapper); ok {
+		if bpi, ok := w.Identifier.(BuildPlanIdentifier); ok {
+			return bpi, true
+		}
+	}
+
+	bpi, ok := i.(BuildPlanIdentifier)
+	return bpi, ok
+}
+
+// DefaultMatchScore is the score of a matched identifier
+// which does not implement ScoredIdentifier.
+const DefaultMatchScore = 0.5
+
+// MatchResult is how confident an identifier matches the project.
+type MatchResult struct {
+	// Score is the confidence in [0, 1]. Higher is more confident.
+	Score float64
+	// Reasons is the human-readable reasons of the score,
+	// for example, "found requirements.txt".
+	Reasons []string
+}
+
+// ScoredIdentifier is an identifier which can tell how confident
+// it matches the project.
+//
+// MatchScore is only called when Match returns true.
+type ScoredIdentifier interface {
+	MatchScore(MatchContext) MatchResult
+}
+
+// GetMatchResult gets the match score of the matched identifier.
+//
+// If the identifier does not implement ScoredIdentifier,
+// it returns DefaultMatchScore.
+func GetMatchResult(i IdentifierV2, ctx MatchContext) MatchResult {
+	if w, ok := i.(*identifierV2Wrapper); ok {
+		if si, ok := w.Identifier.(ScoredIdentifier); ok {
+			return si.MatchScore(ctx)
+		}
+	}
+
+	if si, ok := i.(ScoredIdentifier); ok {
+		return si.MatchScore(ctx)
+	}
+
+	return MatchResult{Score: DefaultMatchScore, Reasons: []string{"matched"}}
+}
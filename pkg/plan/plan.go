@@ -2,6 +2,11 @@
 package plan
 
 import (
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+
 	"github.com/samber/lo"
 	"github.com/spf13/afero"
 	"github.com/spf13/cast"
@@ -10,15 +15,64 @@ import (
 
 // Planner is the interface for planners.
 type Planner interface {
+	// Plan returns the plan type and meta. If no identifier matches,
+	// it falls back to the static plan.
 	Plan() (types.PlanType, types.PlanMeta)
 }
 
+// ErrorPlanner is a Planner which reports why the plan is the fallback one.
+type ErrorPlanner interface {
+	Planner
+
+	// PlanE is the same as Plan, but returns ErrNoIdentifierMatched if no
+	// identifier matches, and *AmbiguousMatchError if the plan type specified
+	// in the configuration is provided by more than one identifier.
+	PlanE() (types.PlanType, types.PlanMeta, error)
+}
+
+// TracingPlanner is a Planner which explains how the plan is determined.
+type TracingPlanner interface {
+	Planner
+
+	// PlanWithTrace is the same as PlanE, but also records every
+	// identifier decision and where each plan meta value comes from.
+	PlanWithTrace() (types.PlanType, types.PlanMeta, *Trace, error)
+}
+
+// CandidatePlanner is a Planner which returns the runner-up plans.
+type CandidatePlanner interface {
+	Planner
+
+	// PlanCandidates returns the plans of all the matched identifiers.
+	// The first candidate is the one Plan returns, and the rest are
+	// the runner-ups ranked by their scores.
+	// It returns the errors described in PlanE.
+	PlanCandidates() ([]Candidate, error)
+}
+
+// BuildPlanPlanner is a Planner which returns the typed build plan.
+type BuildPlanPlanner interface {
+	Planner
+
+	// PlanBuildPlan is the same as PlanE, but returns the typed build plan.
+	// The identifiers implementing BuildPlanIdentifier produce it directly,
+	// and the plans of the others are converted with types.NewBuildPlan.
+	PlanBuildPlan() (types.BuildPlan, error)
+}
+
 type planner struct {
 	NewPlannerOptions
 
 	identifiers []IdentifierV2
 }
 
+var (
+	_ ErrorPlanner     = (*planner)(nil)
+	_ TracingPlanner   = (*planner)(nil)
+	_ CandidatePlanner = (*planner)(nil)
+	_ BuildPlanPlanner = (*planner)(nil)
+)
+
 // NewPlannerOptions is the options for NewPlanner.
 type NewPlannerOptions struct {
 	Source        afero.Fs
@@ -26,6 +80,19 @@ type NewPlannerOptions struct {
 	SubmoduleName string
 
 	AWSConfig *AWSConfig
+
+	// Context stops the planner from trying the rest identifiers when
+	// it is done, and is passed to the identifiers with MatchContext.
+	// nil means context.Background().
+	Context context.Context
+}
+
+// goContext returns the context.Context of the planning.
+func (o NewPlannerOptions) goContext() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
 }
 
 // AWSConfig is the AWS configuration for fetching projects from S3 bucket.
@@ -33,9 +100,19 @@ type AWSConfig struct {
 	Region          string
 	AccessKeyID     string
 	SecretAccessKey string
+
+	// SessionToken is the session token of the temporary credentials, optional.
+	SessionToken string
+	// Endpoint is the endpoint of an S3-compatible storage, such as
+	// MinIO, Cloudflare R2 or Ceph. Empty to use Amazon S3.
+	Endpoint string
+	// UsePathStyle makes the requests in the path-style addressing,
+	// which most S3-compatible storages require.
+	UsePathStyle bool
 }
 
-// NewPlanner creates a new Planner.
+// NewPlanner creates a new Planner. The returned Planner also implements
+// ErrorPlanner, TracingPlanner, CandidatePlanner and BuildPlanPlanner.
 func NewPlanner(opt *NewPlannerOptions, identifiers ...IdentifierV2) Planner {
 	return &planner{
 		NewPlannerOptions: *opt,
@@ -53,43 +130,279 @@ func Continue() types.PlanMeta {
 	return continuePlanMeta
 }
 
+// ContinueBuildPlan is the Continue of BuildPlanIdentifier.
+func ContinueBuildPlan() types.BuildPlan {
+	return types.BuildPlan{Extra: Continue()}
+}
+
 const (
 	// ConfigKeyPlanType is the key to specify plan type explicitly.
 	// (ZBPACK_PLAN_TYPE)
 	ConfigKeyPlanType = "plan_type"
+
+	// ConfigKeyCacheMounts is the key to mount the caches of the package
+	// managers in the generated Dockerfile with RUN --mount=type=cache,
+	// so that the dependencies are not downloaded again in every build.
+	// (ZBPACK_CACHE_MOUNTS)
+	ConfigKeyCacheMounts = "cache_mounts"
+
+	// ConfigKeyHardening is the key to harden the generated Dockerfile:
+	// the application runs as a non-root user in a slim runtime image,
+	// and the image declares its STOPSIGNAL. (ZBPACK_HARDENING)
+	ConfigKeyHardening = "hardening"
+
+	// ConfigKeyHealthcheckPath is the HTTP path the HEALTHCHECK of the
+	// hardened image probes, for example, "/healthz". The HEALTHCHECK is
+	// not added if it is empty. (ZBPACK_HEALTHCHECK_PATH)
+	ConfigKeyHealthcheckPath = "healthcheck.path"
+
+	// ConfigKeyHealthcheckPort is the port the HEALTHCHECK probes. By
+	// default, the PORT environment variable, or 8080 if it is not set.
+	// (ZBPACK_HEALTHCHECK_PORT)
+	ConfigKeyHealthcheckPort = "healthcheck.port"
 )
 
 func (b planner) Plan() (types.PlanType, types.PlanMeta) {
-	planType, planTypeErr := Cast(b.NewPlannerOptions.Config.Get(ConfigKeyPlanType), cast.ToStringE).Take()
+	// The plan returned with the errors is the fallback plan.
+	planType, planMeta, _, _ := b.plan(false)
+	return planType, planMeta
+}
+
+func (b planner) PlanE() (types.PlanType, types.PlanMeta, error) {
+	planType, planMeta, _, err := b.plan(false)
+	return planType, planMeta, err
+}
+
+func (b planner) PlanWithTrace() (types.PlanType, types.PlanMeta, *Trace, error) {
+	return b.plan(true)
+}
+
+func (b planner) PlanBuildPlan() (types.BuildPlan, error) {
+	result, err := b.identify(false)
+
+	bp := types.NewBuildPlan(result.planType, result.planMeta)
+	if result.buildPlan != nil {
+		bp = *result.buildPlan
+	}
+	if err == nil {
+		bp = b.sharedOptions().applyToBuildPlan(bp)
+	}
+
+	return bp, err
+}
+
+// plan identifies the plan, and then applies the options shared by
+// all plan types in the configuration.
+func (b planner) plan(withTrace bool) (types.PlanType, types.PlanMeta, *Trace, error) {
+	result, err := b.identify(withTrace)
+	planMeta := result.planMeta
+	if err == nil {
+		planMeta = b.sharedOptions().applyToPlanMeta(planMeta)
+	}
+
+	return result.planType, planMeta, result.trace, err
+}
+
+// sharedOptions is the options shared by all plan types in the configuration.
+type sharedOptions struct {
+	cacheMounts     bool
+	hardening       bool
+	healthcheckPath string
+	healthcheckPort int
+}
+
+func (b planner) sharedOptions() sharedOptions {
+	var o sharedOptions
+
+	o.cacheMounts = Cast(b.Config.Get(ConfigKeyCacheMounts), ToWeakBoolE).TakeOr(false)
+	o.hardening = Cast(b.Config.Get(ConfigKeyHardening), ToWeakBoolE).TakeOr(false)
+	if o.hardening {
+		if path, err := Cast(b.Config.Get(ConfigKeyHealthcheckPath), cast.ToStringE).Take(); err == nil {
+			o.healthcheckPath = path
+		}
+		if port, err := Cast(b.Config.Get(ConfigKeyHealthcheckPort), cast.ToIntE).Take(); err == nil && port > 0 {
+			o.healthcheckPort = port
+		}
+	}
+
+	return o
+}
+
+// applyToPlanMeta returns a copy of planMeta with the shared options.
+// It returns planMeta as is if none of them is set.
+func (o sharedOptions) applyToPlanMeta(planMeta types.PlanMeta) types.PlanMeta {
+	if o.cacheMounts {
+		planMeta = lo.Ternary(planMeta != nil, maps.Clone(planMeta), types.PlanMeta{})
+		planMeta[types.FlagCacheMounts] = "true"
+	}
+
+	if o.hardening {
+		planMeta = lo.Ternary(planMeta != nil, maps.Clone(planMeta), types.PlanMeta{})
+		planMeta[types.FlagHardening] = "true"
+
+		if o.healthcheckPath != "" {
+			planMeta[types.PlanMetaKeyHealthcheckPath] = o.healthcheckPath
+		}
+		if o.healthcheckPort > 0 {
+			planMeta[types.PlanMetaKeyHealthcheckPort] = strconv.Itoa(o.healthcheckPort)
+		}
+	}
+
+	return planMeta
+}
+
+// applyToBuildPlan returns bp with the shared options.
+func (o sharedOptions) applyToBuildPlan(bp types.BuildPlan) types.BuildPlan {
+	// The flags are cloned, so that bp does not share them with the
+	// build plan the identifier returns.
+	bp.Flags = maps.Clone(bp.Flags)
+
+	if o.cacheMounts {
+		bp.SetFlag(types.FlagCacheMounts, true)
+	}
+
+	if o.hardening {
+		bp.SetFlag(types.FlagHardening, true)
+
+		if o.healthcheckPath != "" {
+			bp.HealthcheckPath = o.healthcheckPath
+		}
+		if o.healthcheckPort > 0 {
+			bp.HealthcheckPort = o.healthcheckPort
+		}
+	}
+
+	return bp
+}
+
+// identification is the result of identify.
+type identification struct {
+	planType types.PlanType
+	planMeta types.PlanMeta
+	// buildPlan is the typed build plan of the selected identifier,
+	// or nil if it does not implement BuildPlanIdentifier.
+	buildPlan *types.BuildPlan
+	trace     *Trace
+	// index is the index of the selected identifier, or -1 if the
+	// plan is the fallback one.
+	index int
+}
+
+// identify finds the identifier of the project and returns its plan.
+func (b planner) identify(withTrace bool) (identification, error) {
+	opt := b.NewPlannerOptions
+	// The identifiers share the values of this planning with PlanningValue.
+	opt.Context = withPlanning(opt.goContext())
+
+	var trace *Trace
+	var tracingSrc *tracingFs
+	var tracingConfig *tracingConfiguration
+	if withTrace {
+		trace = &Trace{Identifiers: []IdentifierTrace{}, Fields: []FieldProvenance{}}
+		tracingSrc = &tracingFs{Fs: opt.Source}
+		tracingConfig = &tracingConfiguration{inner: opt.Config}
+		opt.Source = tracingSrc
+		opt.Config = tracingConfig
+	}
+
+	// record records the decision on the identifier. If the identifier is
+	// selected, it also records where the plan meta values come from.
+	record := func(identifier IdentifierV2, decision IdentifierDecision, meta types.PlanMeta) {
+		if trace == nil {
+			return
+		}
+
+		files := tracingSrc.reset()
+		lookups := tracingConfig.reset()
+		trace.Identifiers = append(trace.Identifiers, IdentifierTrace{
+			PlanType: identifier.PlanType(),
+			Decision: decision,
+			Files:    files,
+		})
+
+		if decision == IdentifierDecisionForced || decision == IdentifierDecisionMatched {
+			trace.PlanType = identifier.PlanType()
+			// The identifier plans again without tracing, to find the
+			// values which come from the looked-up configuration.
+			trace.Fields = fieldProvenances(identifier, b.NewPlannerOptions, meta, lookups)
+		}
+	}
+
+	// skipRest records the identifiers after the selected one as skipped.
+	skipRest := func(selected int) {
+		if trace == nil {
+			return
+		}
+
+		for _, identifier := range b.identifiers[selected+1:] {
+			trace.Identifiers = append(trace.Identifiers, IdentifierTrace{
+				PlanType: identifier.PlanType(),
+				Decision: IdentifierDecisionSkipped,
+			})
+		}
+	}
+
+	planType, planTypeErr := Cast(opt.Config.Get(ConfigKeyPlanType), cast.ToStringE).Take()
 
 	if planTypeErr == nil {
 		// find a identifier that matches the specified plan type
-		identifier, ok := lo.Find(b.identifiers, func(i IdentifierV2) bool {
+		candidates := lo.Filter(b.identifiers, func(i IdentifierV2, _ int) bool {
 			return i.PlanType() == types.PlanType(planType)
 		})
 
-		// if found, return the plan type and meta of this identifier
-		if ok {
-			return identifier.PlanType(), identifier.PlanMeta(b.NewPlannerOptions)
+		// if found, return the plan type and meta of the first identifier
+		if len(candidates) > 0 {
+			selected := slices.IndexFunc(b.identifiers, func(i IdentifierV2) bool {
+				return i.PlanType() == types.PlanType(planType)
+			})
+			identifier := b.identifiers[selected]
+			pm, bp := identifierPlan(identifier, opt)
+			record(identifier, IdentifierDecisionForced, pm)
+			result := identification{identifier.PlanType(), pm, bp, trace, selected}
+
+			if len(candidates) > 1 {
+				return result, &AmbiguousMatchError{
+					PlanTypes: lo.Map(candidates, func(i IdentifierV2, _ int) types.PlanType { return i.PlanType() }),
+				}
+			}
+
+			return result, nil
 		}
 	}
 
-	for _, identifier := range b.identifiers {
-		if identifier.Match(MatchContext{
-			Source:        b.Source,
-			Config:        b.Config,
-			SubmoduleName: b.SubmoduleName,
+	fallback := identification{types.PlanTypeStatic, types.PlanMeta{}, nil, trace, -1}
+
+	for i, identifier := range b.identifiers {
+		if err := opt.goContext().Err(); err != nil {
+			return fallback, err
+		}
+
+		if !identifier.Match(MatchContext{
+			Source:        opt.Source,
+			Config:        opt.Config,
+			SubmoduleName: opt.SubmoduleName,
+			Context:       opt.goContext(),
 		}) {
-			pt, pm := identifier.PlanType(), identifier.PlanMeta(b.NewPlannerOptions)
+			record(identifier, IdentifierDecisionNotMatched, nil)
+			continue
+		}
 
-			// If the planner returns a Continue flag, we find the next matched.
-			if v, ok := pm["__INTERNAL_STATE"]; ok && v == "CONTINUE" {
-				continue
-			}
+		pm, bp := identifierPlan(identifier, opt)
 
-			return pt, pm
+		// If the planner returns a Continue flag, we find the next matched.
+		if v, ok := pm["__INTERNAL_STATE"]; ok && v == "CONTINUE" {
+			record(identifier, IdentifierDecisionContinue, nil)
+			continue
 		}
+
+		record(identifier, IdentifierDecisionMatched, pm)
+		skipRest(i)
+		return identification{identifier.PlanType(), pm, bp, trace, i}, nil
+	}
+
+	if trace != nil {
+		trace.PlanType = types.PlanTypeStatic
 	}
 
-	return types.PlanTypeStatic, types.PlanMeta{}
+	return fallback, ErrNoIdentifierMatched
 }
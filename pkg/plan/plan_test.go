@@ -1,9 +1,11 @@
 package plan_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/spf13/afero"
+	"github.com/spf13/cast"
 	"github.com/stretchr/testify/assert"
 	"github.com/zeabur/zbpack/pkg/plan"
 	"github.com/zeabur/zbpack/pkg/types"
@@ -64,3 +66,537 @@ func TestPlan_DefaultStatic(t *testing.T) {
 	assert.Equal(t, types.PlanTypeStatic, planType)
 	assert.Equal(t, types.PlanMeta{}, planMeta)
 }
+
+type fileIdentifier struct {
+	planType types.PlanType
+	file     string
+}
+
+func (fi fileIdentifier) PlanType() types.PlanType {
+	return fi.planType
+}
+
+func (fi fileIdentifier) Match(ctx plan.MatchContext) bool {
+	_, err := ctx.Source.Stat(fi.file)
+	return err == nil
+}
+
+func (fi fileIdentifier) PlanMeta(opt plan.NewPlannerOptions) types.PlanMeta {
+	meta := types.PlanMeta{"version": "1.0", "entry": "main"}
+	if _, err := opt.Source.Stat(fi.file); err == nil {
+		meta["entry"] = fi.file
+	}
+	if cmd, err := plan.Cast(opt.Config.Get("build_command"), cast.ToStringE).Take(); err == nil {
+		meta["build"] = "RUN " + cmd
+	}
+	return meta
+}
+
+func TestPlanWithTrace(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "app.py", []byte(""), 0o644)
+	_ = afero.WriteFile(fs, "zbpack.json", []byte(`{"build_command": "make"}`), 0o644)
+	config := plan.NewProjectConfigurationFromFs(fs, "")
+
+	executor := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: config,
+		},
+		fileIdentifier{planType: types.PlanTypeGo, file: "go.mod"},
+		alwaysMatchIdentifier{plan.Continue()},
+		fileIdentifier{planType: types.PlanTypePython, file: "app.py"},
+		fileIdentifier{planType: types.PlanTypeNodejs, file: "package.json"},
+	)
+
+	planType, planMeta, trace, err := executor.(plan.TracingPlanner).PlanWithTrace()
+	assert.NoError(t, err)
+	assert.Equal(t, types.PlanTypePython, planType)
+	assert.Equal(t, types.PlanTypePython, trace.PlanType)
+	assert.Equal(t, "app.py", planMeta["entry"])
+
+	decisions := make([]plan.IdentifierDecision, 0, len(trace.Identifiers))
+	for _, it := range trace.Identifiers {
+		decisions = append(decisions, it.Decision)
+	}
+	assert.Equal(t, []plan.IdentifierDecision{
+		plan.IdentifierDecisionNotMatched,
+		plan.IdentifierDecisionContinue,
+		plan.IdentifierDecisionMatched,
+		plan.IdentifierDecisionSkipped,
+	}, decisions)
+	assert.Equal(t, []string{"go.mod"}, trace.Identifiers[0].Files)
+
+	assert.Equal(t, []plan.FieldProvenance{
+		{Key: "build", Value: "RUN make", Source: plan.FieldSourceConfig, ConfigKey: "build_command", Origin: "zbpack.json"},
+		{Key: "entry", Value: "app.py", Source: plan.FieldSourceDetected},
+		{Key: "version", Value: "1.0", Source: plan.FieldSourceDefault},
+	}, trace.Fields)
+}
+
+// flagIdentifier returns a flag from the configuration,
+// and another flag detected from the files.
+type flagIdentifier struct{}
+
+func (flagIdentifier) PlanType() types.PlanType {
+	return types.PlanTypeNodejs
+}
+
+func (flagIdentifier) Match(plan.MatchContext) bool {
+	return true
+}
+
+func (flagIdentifier) PlanMeta(opt plan.NewPlannerOptions) types.PlanMeta {
+	meta := types.PlanMeta{}
+	if _, err := opt.Source.Stat("package.json"); err == nil {
+		meta["needNode"] = "true"
+	}
+	if plan.Cast(opt.Config.Get("serverless"), plan.ToWeakBoolE).TakeOr(false) {
+		meta["serverless"] = "true"
+	}
+	return meta
+}
+
+func TestPlanWithTrace_BooleanConfig(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "package.json", []byte("{}"), 0o644)
+	_ = afero.WriteFile(fs, "zbpack.json", []byte(`{"serverless": true}`), 0o644)
+
+	executor := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: plan.NewProjectConfigurationFromFs(fs, ""),
+		},
+		flagIdentifier{},
+	)
+
+	_, _, trace, err := executor.(plan.TracingPlanner).PlanWithTrace()
+	assert.NoError(t, err)
+
+	// needNode is also "true", but it does not come from the configuration.
+	assert.Equal(t, []plan.FieldProvenance{
+		{Key: "needNode", Value: "true", Source: plan.FieldSourceDetected},
+		{Key: "serverless", Value: "true", Source: plan.FieldSourceConfig, ConfigKey: "serverless", Origin: "zbpack.json"},
+	}, trace.Fields)
+}
+
+func TestPlanWithTrace_Forced(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	config := plan.NewProjectConfigurationFromFs(fs, "")
+	config.Set(plan.ConfigKeyPlanType, "nodejs")
+
+	executor := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: config,
+		},
+		fileIdentifier{planType: types.PlanTypePython, file: "app.py"},
+		fileIdentifier{planType: types.PlanTypeNodejs, file: "package.json"},
+	)
+
+	planType, _, trace, err := executor.(plan.TracingPlanner).PlanWithTrace()
+	assert.NoError(t, err)
+	assert.Equal(t, types.PlanTypeNodejs, planType)
+	assert.Len(t, trace.Identifiers, 1)
+	assert.Equal(t, plan.IdentifierDecisionForced, trace.Identifiers[0].Decision)
+}
+
+func TestPlanE_NoIdentifierMatched(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	config := plan.NewProjectConfigurationFromFs(fs, "")
+
+	executor := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: config,
+		},
+		fileIdentifier{planType: types.PlanTypePython, file: "app.py"},
+	)
+
+	planType, planMeta, err := executor.(plan.ErrorPlanner).PlanE()
+	assert.ErrorIs(t, err, plan.ErrNoIdentifierMatched)
+	assert.Equal(t, types.PlanTypeStatic, planType)
+	assert.Equal(t, types.PlanMeta{}, planMeta)
+}
+
+func TestPlanE_AmbiguousMatch(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	config := plan.NewProjectConfigurationFromFs(fs, "")
+	config.Set(plan.ConfigKeyPlanType, "python")
+
+	executor := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: config,
+		},
+		fileIdentifier{planType: types.PlanTypePython, file: "app.py"},
+		fileIdentifier{planType: types.PlanTypePython, file: "main.py"},
+	)
+
+	planType, _, err := executor.(plan.ErrorPlanner).PlanE()
+
+	var ambiguousErr *plan.AmbiguousMatchError
+	assert.ErrorAs(t, err, &ambiguousErr)
+	assert.Equal(t, []types.PlanType{types.PlanTypePython, types.PlanTypePython}, ambiguousErr.PlanTypes)
+	assert.Equal(t, types.PlanTypePython, planType)
+
+	// Plan falls back to the first candidate.
+	planType, _ = executor.Plan()
+	assert.Equal(t, types.PlanTypePython, planType)
+}
+
+type scoredIdentifier struct {
+	fileIdentifier
+	score float64
+}
+
+func (si scoredIdentifier) MatchScore(plan.MatchContext) plan.MatchResult {
+	return plan.MatchResult{Score: si.score, Reasons: []string{"found " + si.file}}
+}
+
+func TestPlanCandidates(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "app.py", []byte(""), 0o644)
+	_ = afero.WriteFile(fs, "package.json", []byte("{}"), 0o644)
+	_ = afero.WriteFile(fs, "index.html", []byte(""), 0o644)
+
+	executor := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: plan.NewProjectConfigurationFromFs(fs, ""),
+		},
+		scoredIdentifier{fileIdentifier{planType: types.PlanTypeStatic, file: "index.html"}, 0.2},
+		alwaysMatchIdentifier{plan.Continue()},
+		fileIdentifier{planType: types.PlanTypeGo, file: "go.mod"},
+		fileIdentifier{planType: types.PlanTypePython, file: "app.py"},
+		scoredIdentifier{fileIdentifier{planType: types.PlanTypeNodejs, file: "package.json"}, 0.9},
+	)
+
+	candidates, err := executor.(plan.CandidatePlanner).PlanCandidates()
+	assert.NoError(t, err)
+
+	planTypes := make([]types.PlanType, 0, len(candidates))
+	for _, c := range candidates {
+		planTypes = append(planTypes, c.PlanType)
+	}
+	// The first matched identifier is still selected,
+	// and the runner-ups are ranked by score.
+	assert.Equal(t, []types.PlanType{types.PlanTypeStatic, types.PlanTypeNodejs, types.PlanTypePython}, planTypes)
+	assert.True(t, candidates[0].Selected)
+	assert.False(t, candidates[1].Selected)
+	assert.Equal(t, 0.9, candidates[1].Score)
+	assert.Equal(t, []string{"found package.json"}, candidates[1].Reasons)
+	assert.Equal(t, plan.DefaultMatchScore, candidates[2].Score)
+	assert.Equal(t, "app.py", candidates[2].PlanMeta["entry"])
+
+	planType, _ := executor.Plan()
+	assert.Equal(t, planType, candidates[0].PlanType)
+}
+
+func TestPlanCandidates_Forced(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "app.py", []byte(""), 0o644)
+	_ = afero.WriteFile(fs, "zbpack.json", []byte(`{"plan_type": "go"}`), 0o644)
+
+	executor := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: plan.NewProjectConfigurationFromFs(fs, ""),
+		},
+		fileIdentifier{planType: types.PlanTypePython, file: "app.py"},
+		fileIdentifier{planType: types.PlanTypeGo, file: "go.mod"},
+	)
+
+	candidates, err := executor.(plan.CandidatePlanner).PlanCandidates()
+	assert.NoError(t, err)
+	assert.Len(t, candidates, 2)
+	assert.Equal(t, types.PlanTypeGo, candidates[0].PlanType)
+	assert.True(t, candidates[0].Selected)
+	assert.Equal(t, types.PlanTypePython, candidates[1].PlanType)
+}
+
+// continueIdentifier matches any project, but continues to the next one.
+type continueIdentifier struct {
+	planType types.PlanType
+}
+
+func (ci continueIdentifier) PlanType() types.PlanType {
+	return ci.planType
+}
+
+func (ci continueIdentifier) Match(plan.MatchContext) bool {
+	return true
+}
+
+func (ci continueIdentifier) PlanMeta(plan.NewPlannerOptions) types.PlanMeta {
+	return plan.Continue()
+}
+
+func TestPlanCandidates_ForcedContinue(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "app.py", []byte(""), 0o644)
+	_ = afero.WriteFile(fs, "zbpack.json", []byte(`{"plan_type": "go"}`), 0o644)
+
+	executor := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: plan.NewProjectConfigurationFromFs(fs, ""),
+		},
+		fileIdentifier{planType: types.PlanTypePython, file: "app.py"},
+		continueIdentifier{planType: types.PlanTypeGo},
+	)
+
+	planType, planMeta, err := executor.(plan.ErrorPlanner).PlanE()
+	assert.NoError(t, err)
+
+	// The selected candidate agrees with PlanE.
+	candidates, err := executor.(plan.CandidatePlanner).PlanCandidates()
+	assert.NoError(t, err)
+	assert.Len(t, candidates, 2)
+	assert.True(t, candidates[0].Selected)
+	assert.Equal(t, planType, candidates[0].PlanType)
+	assert.Equal(t, planMeta, candidates[0].PlanMeta)
+	assert.Equal(t, types.PlanTypePython, candidates[1].PlanType)
+}
+
+func TestPlanCandidates_NoIdentifierMatched(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	executor := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: plan.NewProjectConfigurationFromFs(fs, ""),
+		},
+		fileIdentifier{planType: types.PlanTypeGo, file: "go.mod"},
+	)
+
+	candidates, err := executor.(plan.CandidatePlanner).PlanCandidates()
+	assert.ErrorIs(t, err, plan.ErrNoIdentifierMatched)
+	assert.Empty(t, candidates)
+}
+
+func TestPlanE_Canceled(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	config := plan.NewProjectConfigurationFromFs(fs, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	executor := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source:  fs,
+			Config:  config,
+			Context: ctx,
+		},
+		alwaysMatchIdentifier{types.PlanMeta{"__INTERNAL_STATE": "TestPassed"}},
+	)
+
+	planType, _, err := executor.(plan.ErrorPlanner).PlanE()
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, types.PlanTypeStatic, planType)
+
+	_, err = executor.(plan.CandidatePlanner).PlanCandidates()
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPlan_CacheMounts(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "zbpack.json", []byte(`{"cache_mounts": true}`), 0o644)
+	config := plan.NewProjectConfigurationFromFs(fs, "")
+
+	meta := types.PlanMeta{"entry": "main"}
+	executor := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: config,
+		},
+		alwaysMatchIdentifier{meta},
+	)
+
+	_, planMeta := executor.Plan()
+	assert.Equal(t, types.PlanMeta{"entry": "main", "cacheMounts": "true"}, planMeta)
+	assert.NotContains(t, meta, "cacheMounts", "the meta of the identifier is not modified")
+}
+
+func TestPlan_Hardening(t *testing.T) {
+	t.Parallel()
+
+	t.Run("with health check", func(t *testing.T) {
+		t.Parallel()
+
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "zbpack.json", []byte(`{"hardening": true, "healthcheck": {"path": "/healthz", "port": 3000}}`), 0o644)
+		config := plan.NewProjectConfigurationFromFs(fs, "")
+
+		executor := plan.NewPlanner(
+			&plan.NewPlannerOptions{
+				Source: fs,
+				Config: config,
+			},
+			alwaysMatchIdentifier{types.PlanMeta{"entry": "main"}},
+		)
+
+		_, planMeta := executor.Plan()
+		assert.Equal(t, types.PlanMeta{
+			"entry":           "main",
+			"hardening":       "true",
+			"healthcheckPath": "/healthz",
+			"healthcheckPort": "3000",
+		}, planMeta)
+	})
+
+	t.Run("health check without hardening", func(t *testing.T) {
+		t.Parallel()
+
+		fs := afero.NewMemMapFs()
+		_ = afero.WriteFile(fs, "zbpack.json", []byte(`{"healthcheck": {"path": "/healthz"}}`), 0o644)
+		config := plan.NewProjectConfigurationFromFs(fs, "")
+
+		executor := plan.NewPlanner(
+			&plan.NewPlannerOptions{
+				Source: fs,
+				Config: config,
+			},
+			alwaysMatchIdentifier{types.PlanMeta{"entry": "main"}},
+		)
+
+		_, planMeta := executor.Plan()
+		assert.Equal(t, types.PlanMeta{"entry": "main"}, planMeta)
+	})
+}
+
+// buildPlanIdentifier is an identifier producing the typed build plan.
+// Its PlanMeta panics, so the planner must plan with BuildPlan.
+type buildPlanIdentifier struct {
+	calls *int
+}
+
+func (bi buildPlanIdentifier) PlanType() types.PlanType {
+	return types.PlanTypeGo
+}
+
+func (bi buildPlanIdentifier) Match(plan.MatchContext) bool {
+	return true
+}
+
+func (bi buildPlanIdentifier) PlanMeta(plan.NewPlannerOptions) types.PlanMeta {
+	panic("PlanMeta should not be called")
+}
+
+func (bi buildPlanIdentifier) BuildPlan(plan.NewPlannerOptions) types.BuildPlan {
+	*bi.calls++
+	return types.BuildPlan{
+		Version:        types.BuildPlanVersion,
+		Type:           types.PlanTypeGo,
+		RuntimeVersion: "1.25",
+		Entry:          "main",
+		Flags:          map[string]bool{"cgo": false},
+	}
+}
+
+func TestPlanBuildPlan(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "zbpack.json", []byte(`{"hardening": "1", "healthcheck": {"path": "/healthz"}}`), 0o644)
+	config := plan.NewProjectConfigurationFromFs(fs, "")
+
+	var calls int
+	executor := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: config,
+		},
+		buildPlanIdentifier{&calls},
+	)
+
+	bp, err := executor.(plan.BuildPlanPlanner).PlanBuildPlan()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls, "the identifier plans only once")
+	assert.Equal(t, types.BuildPlan{
+		Version:         types.BuildPlanVersion,
+		Type:            types.PlanTypeGo,
+		RuntimeVersion:  "1.25",
+		Entry:           "main",
+		HealthcheckPath: "/healthz",
+		Flags:           map[string]bool{"cgo": false, "hardening": true},
+	}, bp)
+
+	planType, planMeta, err := executor.(plan.ErrorPlanner).PlanE()
+	assert.NoError(t, err)
+	assert.Equal(t, bp.Type, planType)
+	assert.Equal(t, bp.PlanMeta(), planMeta)
+}
+
+func TestPlanBuildPlan_FromPlanMeta(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "package.json", []byte("{}"), 0o644)
+
+	executor := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: plan.NewProjectConfigurationFromFs(fs, ""),
+		},
+		fileIdentifier{planType: types.PlanTypeNodejs, file: "package.json"},
+	)
+
+	bp, err := executor.(plan.BuildPlanPlanner).PlanBuildPlan()
+	assert.NoError(t, err)
+
+	planType, planMeta, err := executor.(plan.ErrorPlanner).PlanE()
+	assert.NoError(t, err)
+	assert.Equal(t, types.NewBuildPlan(planType, planMeta), bp)
+}
+
+// continueBuildPlanIdentifier is a BuildPlanIdentifier which matches any
+// project, but continues to the next one.
+type continueBuildPlanIdentifier struct {
+	continueIdentifier
+}
+
+func (ci continueBuildPlanIdentifier) BuildPlan(plan.NewPlannerOptions) types.BuildPlan {
+	return plan.ContinueBuildPlan()
+}
+
+func TestPlanBuildPlan_Continue(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "package.json", []byte("{}"), 0o644)
+
+	executor := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: plan.NewProjectConfigurationFromFs(fs, ""),
+		},
+		continueBuildPlanIdentifier{continueIdentifier{planType: types.PlanTypeDotnet}},
+		fileIdentifier{planType: types.PlanTypeNodejs, file: "package.json"},
+	)
+
+	bp, err := executor.(plan.BuildPlanPlanner).PlanBuildPlan()
+	assert.NoError(t, err)
+	assert.Equal(t, types.PlanTypeNodejs, bp.Type)
+}
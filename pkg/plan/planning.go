@@ -0,0 +1,50 @@
+package plan
+
+import (
+	"context"
+	"sync"
+)
+
+type planningKey struct{}
+
+// planning is the state shared by the identifiers in one planning.
+type planning struct {
+	values sync.Map
+}
+
+// withPlanning returns ctx with a new planning state, or ctx as is if
+// it already has one.
+func withPlanning(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(planningKey{}).(*planning); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, planningKey{}, &planning{})
+}
+
+// PlanningValue returns the value of key shared by the identifiers in
+// the same planning, for example, the listing of the source. The value
+// is created with create on the first call, and is not kept if create
+// returns an error.
+//
+// ctx is MatchContext.Context or NewPlannerOptions.Context. If it is not
+// from a planner, create is called every time.
+func PlanningValue[T any](ctx context.Context, key any, create func() (T, error)) (T, error) {
+	if ctx == nil {
+		return create()
+	}
+	p, ok := ctx.Value(planningKey{}).(*planning)
+	if !ok {
+		return create()
+	}
+
+	if v, ok := p.values.Load(key); ok {
+		return v.(T), nil
+	}
+
+	v, err := create()
+	if err != nil {
+		return v, err
+	}
+	actual, _ := p.values.LoadOrStore(key, v)
+	return actual.(T), nil
+}
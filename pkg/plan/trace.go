@@ -0,0 +1,266 @@
+package plan
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/moznion/go-optional"
+	"github.com/spf13/afero"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+// IdentifierDecision is the decision the planner made on an identifier.
+type IdentifierDecision string
+
+//revive:disable:exported
+const (
+	// IdentifierDecisionForced means the identifier is selected by the plan_type configuration.
+	IdentifierDecisionForced IdentifierDecision = "forced"
+	// IdentifierDecisionMatched means the identifier matched and is selected.
+	IdentifierDecisionMatched IdentifierDecision = "matched"
+	// IdentifierDecisionNotMatched means Match of the identifier returned false.
+	IdentifierDecisionNotMatched IdentifierDecision = "not-matched"
+	// IdentifierDecisionContinue means the identifier matched but its PlanMeta returned Continue().
+	IdentifierDecisionContinue IdentifierDecision = "continue"
+	// IdentifierDecisionSkipped means the identifier is not tried since another one has been selected.
+	IdentifierDecisionSkipped IdentifierDecision = "skipped"
+)
+
+//revive:enable:exported
+
+// IdentifierTrace records how the planner handles an identifier.
+type IdentifierTrace struct {
+	PlanType types.PlanType     `json:"planType" yaml:"planType"`
+	Decision IdentifierDecision `json:"decision" yaml:"decision"`
+	// Files is the files the identifier checked or read.
+	Files []string `json:"files,omitempty" yaml:"files,omitempty"`
+}
+
+// FieldSource is the kind of where a plan meta value comes from.
+type FieldSource string
+
+//revive:disable:exported
+const (
+	// FieldSourceConfig means the value comes from zbpack.json or zbpack.[submodule].json.
+	FieldSourceConfig FieldSource = "config"
+	// FieldSourceEnv means the value comes from an environment variable, for example, ZBPACK_BUILD_COMMAND.
+	FieldSourceEnv FieldSource = "env"
+	// FieldSourceDetected means the value is detected from the files of the project.
+	FieldSourceDetected FieldSource = "detected"
+	// FieldSourceDefault means the value is the default value of the identifier.
+	FieldSourceDefault FieldSource = "default"
+)
+
+//revive:enable:exported
+
+// FieldProvenance records where a plan meta value comes from.
+type FieldProvenance struct {
+	Key    string      `json:"key" yaml:"key"`
+	Value  string      `json:"value" yaml:"value"`
+	Source FieldSource `json:"source" yaml:"source"`
+	// ConfigKey is the configuration key which provides this value.
+	// Only available when Source is FieldSourceConfig or FieldSourceEnv.
+	ConfigKey string `json:"configKey,omitempty" yaml:"configKey,omitempty"`
+	// Origin is the configuration file or the environment variable which provides this value.
+	// Only available when Source is FieldSourceConfig or FieldSourceEnv.
+	Origin string `json:"origin,omitempty" yaml:"origin,omitempty"`
+}
+
+// Trace records how the planner determines the plan.
+type Trace struct {
+	PlanType    types.PlanType    `json:"planType" yaml:"planType"`
+	Identifiers []IdentifierTrace `json:"identifiers" yaml:"identifiers"`
+	Fields      []FieldProvenance `json:"fields" yaml:"fields"`
+}
+
+// SourcedConfiguration is a configuration which can tell where a value comes from.
+type SourcedConfiguration interface {
+	GetWithSource(key string) (optional.Option[any], string)
+}
+
+// configLookup is a value looked up from the configuration.
+type configLookup struct {
+	key    string
+	origin string
+}
+
+// tracingConfiguration records the values looked up from the configuration.
+type tracingConfiguration struct {
+	inner ImmutableProjectConfiguration
+
+	mu      sync.Mutex
+	lookups []configLookup
+}
+
+func (c *tracingConfiguration) Get(key string) optional.Option[any] {
+	var val optional.Option[any]
+	var origin string
+
+	if sc, ok := c.inner.(SourcedConfiguration); ok {
+		val, origin = sc.GetWithSource(key)
+	} else {
+		val = c.inner.Get(key)
+	}
+
+	if val.IsSome() {
+		c.mu.Lock()
+		c.lookups = append(c.lookups, configLookup{key: key, origin: origin})
+		c.mu.Unlock()
+	}
+
+	return val
+}
+
+func (c *tracingConfiguration) reset() []configLookup {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lookups := c.lookups
+	c.lookups = nil
+	return lookups
+}
+
+// tracingFs records the files opened or stated.
+type tracingFs struct {
+	afero.Fs
+
+	mu    sync.Mutex
+	files []string
+}
+
+func (fs *tracingFs) record(name string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if !slices.Contains(fs.files, name) {
+		fs.files = append(fs.files, name)
+	}
+}
+
+func (fs *tracingFs) reset() []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	files := fs.files
+	fs.files = nil
+	return files
+}
+
+func (fs *tracingFs) Open(name string) (afero.File, error) {
+	fs.record(name)
+	return fs.Fs.Open(name)
+}
+
+func (fs *tracingFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	fs.record(name)
+	return fs.Fs.OpenFile(name, flag, perm)
+}
+
+func (fs *tracingFs) Stat(name string) (os.FileInfo, error) {
+	fs.record(name)
+	return fs.Fs.Stat(name)
+}
+
+// traceSentinel replaces a configuration value to find the plan meta
+// values which come from it.
+const traceSentinel = "\x00zbpack-trace\x00"
+
+// maskedConfiguration is the configuration with the value of key
+// replaced by traceSentinel.
+type maskedConfiguration struct {
+	inner ImmutableProjectConfiguration
+	key   string
+}
+
+func (c maskedConfiguration) Get(key string) optional.Option[any] {
+	if key == c.key {
+		return optional.Some[any](traceSentinel)
+	}
+	return c.inner.Get(key)
+}
+
+// fieldProvenances determines where each value of meta, the plan meta
+// of the identifier, comes from.
+//
+// A value is from a configuration lookup if the identifier returns
+// another value when the looked-up value is replaced. Otherwise, it is a
+// default value if the identifier returns the same value for an empty
+// project, or a detected value.
+func fieldProvenances(identifier IdentifierV2, opt NewPlannerOptions, meta types.PlanMeta, lookups []configLookup) []FieldProvenance {
+	// sources is the lookup which provides each value.
+	sources := make(map[string]configLookup)
+	var masked []string
+	for _, l := range lookups {
+		if l.key == ConfigKeyPlanType || slices.Contains(masked, l.key) {
+			continue
+		}
+		masked = append(masked, l.key)
+
+		maskedOpt := opt
+		maskedOpt.Config = maskedConfiguration{inner: opt.Config, key: l.key}
+		maskedMeta := safePlanMeta(identifier, maskedOpt)
+		if maskedMeta == nil {
+			continue
+		}
+
+		for k, v := range meta {
+			if _, ok := sources[k]; !ok && maskedMeta[k] != v {
+				sources[k] = l
+			}
+		}
+	}
+
+	defaults := defaultPlanMeta(identifier, opt.SubmoduleName)
+
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	fields := make([]FieldProvenance, 0, len(keys))
+	for _, k := range keys {
+		field := FieldProvenance{Key: k, Value: meta[k], Source: FieldSourceDetected}
+
+		if l, ok := sources[k]; ok {
+			field.ConfigKey = l.key
+			field.Origin = l.origin
+			field.Source = FieldSourceConfig
+			if strings.HasPrefix(field.Origin, ConfigSourceEnvPrefix) {
+				field.Source = FieldSourceEnv
+			}
+		} else if v, ok := defaults[k]; ok && v == meta[k] {
+			field.Source = FieldSourceDefault
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// defaultPlanMeta returns the plan meta of the identifier for an empty project.
+// It returns nil if the identifier panics on an empty project.
+func defaultPlanMeta(identifier IdentifierV2, submoduleName string) types.PlanMeta {
+	fs := afero.NewMemMapFs()
+
+	return safePlanMeta(identifier, NewPlannerOptions{
+		Source:        fs,
+		Config:        NewProjectConfigurationFromFs(fs, ""),
+		SubmoduleName: submoduleName,
+	})
+}
+
+// safePlanMeta returns the plan meta of the identifier,
+// or nil if the identifier panics.
+func safePlanMeta(identifier IdentifierV2, opt NewPlannerOptions) (meta types.PlanMeta) {
+	defer func() {
+		if recover() != nil {
+			meta = nil
+		}
+	}()
+
+	return identifier.PlanMeta(opt)
+}
@@ -0,0 +1,351 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/zeabur/zbpack/pkg/packer"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+const (
+	// DefaultTimeout is the default time limit of a plugin request.
+	DefaultTimeout = 30 * time.Second
+
+	// maxFiles is the maximum number of files listed in a request.
+	maxFiles = 10000
+	// maxContentSize is the maximum size of a file sent in Request.Contents.
+	maxContentSize = 1 << 20
+)
+
+// skippedDirs are not listed in Request.Files.
+var skippedDirs = []string{".git", "node_modules", ".zeabur"}
+
+// Plugin is an external-process plugin.
+//
+// It implements plan.IdentifierV2, plan.ScoredIdentifier, packer.V2 and
+// packer.ContextPacker, so it can be registered with zeaburpack.RegisterIdentifier and
+// zeaburpack.RegisterPacker.
+type Plugin struct {
+	// Command is the path of the plugin executable.
+	Command string
+	// Args is the arguments passed to the plugin executable.
+	Args []string
+	// Timeout is the time limit of a request. Zero means DefaultTimeout.
+	Timeout time.Duration
+
+	info Response
+
+	// lastMatch is the last match request and its response, so that
+	// Match and MatchScore on the same project start the plugin once.
+	mu        sync.Mutex
+	lastMatch *matchCache
+}
+
+// matchCache is a match request encoded in JSON and its response.
+type matchCache struct {
+	request  []byte
+	response Response
+}
+
+// Load starts the plugin executable with ActionInfo to get the plan type
+// and the requirements of the plugin.
+func Load(command string, args ...string) (*Plugin, error) {
+	p := &Plugin{Command: command, Args: args}
+
+	info, err := p.call(context.Background(), Request{Action: ActionInfo})
+	if err != nil {
+		return nil, err
+	}
+	if info.ProtocolVersion != ProtocolVersion {
+		return nil, fmt.Errorf("plugin %s: unsupported protocol version %d (want %d)", command, info.ProtocolVersion, ProtocolVersion)
+	}
+	if info.PlanType == "" {
+		return nil, fmt.Errorf("plugin %s: no plan type", command)
+	}
+
+	p.info = info
+	return p, nil
+}
+
+// PlanType returns the plan type the plugin provides.
+func (p *Plugin) PlanType() types.PlanType {
+	return p.info.PlanType
+}
+
+// Match asks the plugin if it can handle the project.
+func (p *Plugin) Match(ctx plan.MatchContext) bool {
+	resp, err := p.match(ctx)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	return resp.Match
+}
+
+// MatchScore asks the plugin how confident it can handle the project.
+func (p *Plugin) MatchScore(ctx plan.MatchContext) plan.MatchResult {
+	resp, err := p.match(ctx)
+	if err != nil {
+		log.Println(err)
+		return plan.MatchResult{}
+	}
+
+	if resp.Score == 0 {
+		return plan.MatchResult{Score: plan.DefaultMatchScore, Reasons: resp.Reasons}
+	}
+	return plan.MatchResult{Score: resp.Score, Reasons: resp.Reasons}
+}
+
+// match asks the plugin if it can handle the project. The response is
+// reused if the request is the same as the last one.
+func (p *Plugin) match(ctx plan.MatchContext) (Response, error) {
+	req, err := p.projectRequest(ctx.Context, ActionMatch, ctx.Source, ctx.Config, ctx.SubmoduleName)
+	if err != nil {
+		return Response{}, err
+	}
+
+	encoded, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, fmt.Errorf("plugin %s: encode request: %w", p.Command, err)
+	}
+
+	p.mu.Lock()
+	last := p.lastMatch
+	p.mu.Unlock()
+	if last != nil && bytes.Equal(last.request, encoded) {
+		return last.response, nil
+	}
+
+	resp, err := p.call(ctx.Context, req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	p.mu.Lock()
+	p.lastMatch = &matchCache{request: encoded, response: resp}
+	p.mu.Unlock()
+
+	return resp, nil
+}
+
+// PlanMeta asks the plugin for the plan meta of the project.
+//
+// If the plugin fails, the plan meta contains an "error" key.
+func (p *Plugin) PlanMeta(options plan.NewPlannerOptions) types.PlanMeta {
+	req, err := p.projectRequest(options.Context, ActionPlan, options.Source, options.Config, options.SubmoduleName)
+	if err != nil {
+		log.Println(err)
+		return types.PlanMeta{"error": err.Error()}
+	}
+
+	resp, err := p.call(options.Context, req)
+	if err != nil {
+		log.Println(err)
+		return types.PlanMeta{"error": err.Error()}
+	}
+
+	if resp.PlanMeta == nil {
+		return types.PlanMeta{}
+	}
+	return resp.PlanMeta
+}
+
+// GenerateDockerfile asks the plugin for the Dockerfile of the plan meta.
+func (p *Plugin) GenerateDockerfile(meta types.PlanMeta) (string, error) {
+	return p.GenerateDockerfileContext(context.Background(), meta)
+}
+
+// GenerateDockerfileContext is the same as GenerateDockerfile, but the
+// plugin is killed when ctx is done.
+func (p *Plugin) GenerateDockerfileContext(ctx context.Context, meta types.PlanMeta) (string, error) {
+	resp, err := p.call(ctx, Request{Action: ActionDockerfile, PlanMeta: meta})
+	if err != nil {
+		return "", err
+	}
+
+	if resp.Dockerfile == "" {
+		return "", fmt.Errorf("plugin %s: empty Dockerfile", p.Command)
+	}
+	return resp.Dockerfile, nil
+}
+
+// projectRequest creates the request with the file listing and
+// the configuration the plugin asked for.
+//
+// The listing is shared by the plugins in the same planning, so the
+// source is walked only once.
+func (p *Plugin) projectRequest(ctx context.Context, action Action, src afero.Fs, config plan.ImmutableProjectConfiguration, submoduleName string) (Request, error) {
+	files, err := plan.PlanningValue(ctx, filesKey{}, func() ([]string, error) {
+		return listFiles(src)
+	})
+	if err != nil {
+		return Request{}, fmt.Errorf("plugin %s: list files: %w", p.Command, err)
+	}
+
+	req := Request{
+		Action:        action,
+		Files:         files,
+		SubmoduleName: submoduleName,
+	}
+
+	for _, f := range files {
+		if !matchAny(p.info.Files, f) {
+			continue
+		}
+
+		content, err := readFile(src, f)
+		if err != nil {
+			log.Printf("plugin %s: read %s: %v\n", p.Command, f, err)
+			continue
+		}
+
+		if req.Contents == nil {
+			req.Contents = make(map[string]string)
+		}
+		req.Contents[f] = content
+	}
+
+	for _, key := range p.info.ConfigKeys {
+		if v, err := config.Get(key).Take(); err == nil {
+			if req.Config == nil {
+				req.Config = make(map[string]any)
+			}
+			req.Config[key] = v
+		}
+	}
+
+	return req, nil
+}
+
+// call runs the plugin executable with the request. The plugin is
+// killed when ctx is done or the timeout is exceeded.
+func (p *Plugin) call(ctx context.Context, req Request) (Response, error) {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req.ProtocolVersion = ProtocolVersion
+	input, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, fmt.Errorf("plugin %s: encode request: %w", p.Command, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Command, p.Args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+	if stderr.Len() > 0 {
+		log.Printf("plugin %s: %s\n", p.Command, strings.TrimSpace(stderr.String()))
+	}
+	if runErr != nil {
+		return Response{}, fmt.Errorf("plugin %s: %s: %w", p.Command, req.Action, runErr)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return Response{}, fmt.Errorf("plugin %s: %s: decode response: %w", p.Command, req.Action, err)
+	}
+	if resp.Error != "" {
+		return Response{}, fmt.Errorf("plugin %s: %s: %s", p.Command, req.Action, resp.Error)
+	}
+
+	return resp, nil
+}
+
+// matchAny reports if name matches any of the glob patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filesKey is the key of the file listing in plan.PlanningValue.
+// The source is the same in a planning.
+type filesKey struct{}
+
+// errTooManyFiles stops listing the files when there are maxFiles files.
+var errTooManyFiles = errors.New("too many files")
+
+// listFiles returns the path of every file in src, relative to the root.
+// At most maxFiles files are listed.
+func listFiles(src afero.Fs) ([]string, error) {
+	files := make([]string, 0)
+
+	err := afero.Walk(src, ".", func(name string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name = strings.TrimPrefix(path.Clean("/"+name), "/")
+		if info.IsDir() {
+			if name != "" && slices.Contains(skippedDirs, path.Base(name)) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if len(files) >= maxFiles {
+			// afero.Walk does not support fs.SkipAll.
+			return errTooManyFiles
+		}
+		files = append(files, name)
+		return nil
+	})
+	if errors.Is(err, errTooManyFiles) {
+		err = nil
+	}
+
+	return files, err
+}
+
+// readFile reads the file in src if it is not larger than maxContentSize.
+func readFile(src afero.Fs, name string) (string, error) {
+	info, err := src.Stat(name)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > maxContentSize {
+		return "", fmt.Errorf("file is larger than %d bytes", maxContentSize)
+	}
+
+	content, err := afero.ReadFile(src, name)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+var (
+	_ plan.IdentifierV2     = (*Plugin)(nil)
+	_ plan.ScoredIdentifier = (*Plugin)(nil)
+	_ packer.V2             = (*Plugin)(nil)
+	_ packer.ContextPacker  = (*Plugin)(nil)
+)
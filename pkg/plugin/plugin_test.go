@@ -0,0 +1,227 @@
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/plugin"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+const (
+	helperEnv = "ZBPACK_TEST_PLUGIN_HELPER"
+	// helperLogEnv is the file the helper plugin appends the actions to.
+	helperLogEnv = "ZBPACK_TEST_PLUGIN_LOG"
+)
+
+// TestMain runs the test binary as an OCaml plugin when helperEnv is set.
+func TestMain(m *testing.M) {
+	if os.Getenv(helperEnv) == "1" {
+		runHelperPlugin()
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+func runHelperPlugin() {
+	var req plugin.Request
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		os.Exit(2)
+	}
+
+	if logFile := os.Getenv(helperLogEnv); logFile != "" {
+		f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			os.Exit(2)
+		}
+		_, _ = f.WriteString(string(req.Action) + "\n")
+		_ = f.Close()
+	}
+
+	var resp plugin.Response
+	switch req.Action {
+	case plugin.ActionInfo:
+		resp = plugin.Response{
+			ProtocolVersion: plugin.ProtocolVersion,
+			PlanType:        "ocaml",
+			ConfigKeys:      []string{"build_command"},
+			Files:           []string{"*.opam"},
+		}
+	case plugin.ActionMatch:
+		resp.Match = slices.Contains(req.Files, "dune-project")
+		resp.Score = 0.9
+		resp.Reasons = []string{"found dune-project"}
+	case plugin.ActionPlan:
+		resp.PlanMeta = types.PlanMeta{"opam": req.Contents["app.opam"]}
+		if cmd, ok := req.Config["build_command"].(string); ok {
+			resp.PlanMeta["buildCommand"] = cmd
+		}
+	case plugin.ActionDockerfile:
+		resp.Dockerfile = "FROM ocaml/opam\nRUN " + req.PlanMeta["buildCommand"]
+	default:
+		resp.Error = "unknown action"
+	}
+
+	_ = json.NewEncoder(os.Stdout).Encode(resp)
+}
+
+func loadHelperPlugin(t *testing.T) *plugin.Plugin {
+	t.Helper()
+	t.Setenv(helperEnv, "1")
+
+	p, err := plugin.Load(os.Args[0])
+	require.NoError(t, err)
+	return p
+}
+
+func TestPlugin(t *testing.T) {
+	p := loadHelperPlugin(t)
+	assert.Equal(t, types.PlanType("ocaml"), p.PlanType())
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "dune-project", []byte("(lang dune 3.0)"), 0o644)
+	_ = afero.WriteFile(fs, "app.opam", []byte("opam-version: \"2.0\""), 0o644)
+	_ = afero.WriteFile(fs, "node_modules/a/package.json", []byte("{}"), 0o644)
+	_ = afero.WriteFile(fs, "zbpack.json", []byte(`{"build_command": "dune build"}`), 0o644)
+	config := plan.NewProjectConfigurationFromFs(fs, "")
+
+	ctx := plan.MatchContext{Source: fs, Config: config}
+	assert.True(t, p.Match(ctx))
+	assert.Equal(t, plan.MatchResult{Score: 0.9, Reasons: []string{"found dune-project"}}, p.MatchScore(ctx))
+
+	meta := p.PlanMeta(plan.NewPlannerOptions{Source: fs, Config: config})
+	assert.Equal(t, types.PlanMeta{"opam": "opam-version: \"2.0\"", "buildCommand": "dune build"}, meta)
+
+	dockerfile, err := p.GenerateDockerfile(meta)
+	assert.NoError(t, err)
+	assert.Equal(t, "FROM ocaml/opam\nRUN dune build", dockerfile)
+}
+
+func TestPlugin_MatchOnce(t *testing.T) {
+	p := loadHelperPlugin(t)
+
+	logFile := filepath.Join(t.TempDir(), "actions.log")
+	t.Setenv(helperLogEnv, logFile)
+	matchCalls := func() int {
+		content, _ := os.ReadFile(logFile)
+		return strings.Count(string(content), string(plugin.ActionMatch)+"\n")
+	}
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "dune-project", []byte(""), 0o644)
+	ctx := plan.MatchContext{Source: fs, Config: plan.NewProjectConfigurationFromFs(fs, "")}
+
+	assert.True(t, p.Match(ctx))
+	assert.Equal(t, 0.9, p.MatchScore(ctx).Score)
+	assert.Equal(t, 1, matchCalls())
+
+	// Another project is asked again.
+	_ = fs.Remove("dune-project")
+	assert.False(t, p.Match(ctx))
+	assert.Equal(t, 2, matchCalls())
+}
+
+func TestPlugin_GenerateDockerfileContext(t *testing.T) {
+	p := loadHelperPlugin(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := p.GenerateDockerfileContext(ctx, types.PlanMeta{"buildCommand": "dune build"})
+	assert.Error(t, err)
+
+	dockerfile, err := p.GenerateDockerfileContext(context.Background(), types.PlanMeta{"buildCommand": "dune build"})
+	assert.NoError(t, err)
+	assert.Equal(t, "FROM ocaml/opam\nRUN dune build", dockerfile)
+}
+
+func TestPlugin_Planner(t *testing.T) {
+	p := loadHelperPlugin(t)
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "dune-project", []byte(""), 0o644)
+
+	planner := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: plan.NewProjectConfigurationFromFs(fs, ""),
+		},
+		p,
+	)
+
+	planType, _, err := planner.(plan.ErrorPlanner).PlanE()
+	assert.NoError(t, err)
+	assert.Equal(t, types.PlanType("ocaml"), planType)
+}
+
+func TestPlugin_ManyFiles(t *testing.T) {
+	p := loadHelperPlugin(t)
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "dune-project", []byte(""), 0o644)
+	for i := range 10001 {
+		_ = afero.WriteFile(fs, fmt.Sprintf("src/%05d.ml", i), []byte(""), 0o644)
+	}
+	config := plan.NewProjectConfigurationFromFs(fs, "")
+
+	assert.True(t, p.Match(plan.MatchContext{Source: fs, Config: config}))
+
+	meta := p.PlanMeta(plan.NewPlannerOptions{Source: fs, Config: config})
+	assert.NotContains(t, meta, "error")
+}
+
+// rootOpenCountingFs counts how many times the root directory is opened.
+type rootOpenCountingFs struct {
+	afero.Fs
+	opens *atomic.Int32
+}
+
+func (c rootOpenCountingFs) Open(name string) (afero.File, error) {
+	if name == "." {
+		c.opens.Add(1)
+	}
+	return c.Fs.Open(name)
+}
+
+func TestPlugin_ListOncePerPlanning(t *testing.T) {
+	p := loadHelperPlugin(t)
+
+	memFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(memFs, "dune-project", []byte(""), 0o644)
+	var opens atomic.Int32
+	fs := rootOpenCountingFs{Fs: memFs, opens: &opens}
+
+	planner := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source: fs,
+			Config: plan.NewProjectConfigurationFromFs(memFs, ""),
+		},
+		p,
+	)
+
+	planType, _, err := planner.(plan.ErrorPlanner).PlanE()
+	assert.NoError(t, err)
+	assert.Equal(t, types.PlanType("ocaml"), planType)
+	assert.EqualValues(t, 1, opens.Load(), "Match and PlanMeta share the listing")
+
+	// Another planning lists the files again.
+	_, _, _ = planner.(plan.ErrorPlanner).PlanE()
+	assert.EqualValues(t, 2, opens.Load())
+}
+
+func TestLoad_NotPlugin(t *testing.T) {
+	_, err := plugin.Load("false")
+	assert.Error(t, err)
+}
@@ -0,0 +1,96 @@
+// Package plugin implements the external-process plugin protocol,
+// which lets out-of-tree programs identify projects and generate
+// Dockerfiles for zbpack.
+//
+// zbpack runs the plugin executable once per request, writes a Request
+// as JSON to its standard input, and reads a Response as JSON from its
+// standard output. The standard error of the plugin is forwarded to the
+// log of zbpack. A plugin reports a failure with a non-empty
+// Response.Error or a non-zero exit code.
+//
+// The first request is always ActionInfo, which describes the plan type
+// the plugin provides and what the plugin needs from the project.
+package plugin
+
+import "github.com/zeabur/zbpack/pkg/types"
+
+// ProtocolVersion is the version of the plugin protocol.
+//
+// A plugin must answer ActionInfo with the same version.
+const ProtocolVersion = 1
+
+// Action is what zbpack requests the plugin to do.
+type Action string
+
+//revive:disable:exported
+const (
+	// ActionInfo asks for the plan type and the requirements of the plugin.
+	// The response fills ProtocolVersion, PlanType, ConfigKeys and Files.
+	ActionInfo Action = "info"
+	// ActionMatch asks if the plugin can handle the project.
+	// The response fills Match, and optionally Score and Reasons.
+	ActionMatch Action = "match"
+	// ActionPlan asks for the plan meta of the project.
+	// The response fills PlanMeta.
+	ActionPlan Action = "plan"
+	// ActionDockerfile asks for the Dockerfile of the plan meta in the request.
+	// The response fills Dockerfile.
+	ActionDockerfile Action = "dockerfile"
+)
+
+//revive:enable:exported
+
+// Request is the message zbpack writes to the standard input of the plugin.
+type Request struct {
+	ProtocolVersion int    `json:"protocolVersion"`
+	Action          Action `json:"action"`
+
+	// Files is the path of every file in the project, relative to the project root.
+	// Available in ActionMatch and ActionPlan.
+	Files []string `json:"files,omitempty"`
+	// Contents is the content of the files the plugin asked for in
+	// Response.Files of ActionInfo, keyed by the path.
+	// Available in ActionMatch and ActionPlan.
+	Contents map[string]string `json:"contents,omitempty"`
+	// Config is the values of the configuration keys the plugin asked
+	// for in Response.ConfigKeys of ActionInfo. Unset keys are absent.
+	// Available in ActionMatch and ActionPlan.
+	Config map[string]any `json:"config,omitempty"`
+	// SubmoduleName is the submodule (service) name to plan.
+	// Available in ActionMatch and ActionPlan.
+	SubmoduleName string `json:"submoduleName,omitempty"`
+
+	// PlanMeta is the plan meta returned by ActionPlan.
+	// Available in ActionDockerfile.
+	PlanMeta types.PlanMeta `json:"planMeta,omitempty"`
+}
+
+// Response is the message the plugin writes to its standard output.
+type Response struct {
+	// Error is the error message if the plugin cannot handle the request.
+	Error string `json:"error,omitempty"`
+
+	// ProtocolVersion is the protocol version the plugin speaks.
+	ProtocolVersion int `json:"protocolVersion,omitempty"`
+	// PlanType is the plan type the plugin provides, for example, "ocaml".
+	PlanType types.PlanType `json:"planType,omitempty"`
+	// ConfigKeys is the configuration keys the plugin needs,
+	// for example, "build_command".
+	ConfigKeys []string `json:"configKeys,omitempty"`
+	// Files is the glob patterns (path.Match) of the files
+	// whose content the plugin needs, for example, "*.opam".
+	Files []string `json:"files,omitempty"`
+
+	// Match indicates if the plugin can handle the project.
+	Match bool `json:"match,omitempty"`
+	// Score is the confidence of Match in [0, 1]. See plan.MatchResult.
+	Score float64 `json:"score,omitempty"`
+	// Reasons is the reasons of the score.
+	Reasons []string `json:"reasons,omitempty"`
+
+	// PlanMeta is the plan meta of the project.
+	PlanMeta types.PlanMeta `json:"planMeta,omitempty"`
+
+	// Dockerfile is the generated Dockerfile.
+	Dockerfile string `json:"dockerfile,omitempty"`
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/zeabur/zbpack/pkg/types"
 )
@@ -17,12 +18,16 @@ func TransformNix(ctx *Context) error {
 
 	ctx.Log("Transforming Nix...\n")
 
+	if len(ctx.Platforms) > 1 {
+		return transformNixMultiPlatform(ctx)
+	}
+
 	dockerTar := filepath.Join(ctx.BuildkitPath, "result")
 
 	if !ctx.PushImage {
 		// SAFE: zbpack are managed by ourselves. Besides,
 		// macOS does not contain policy.json by default.
-		skopeoCmd := exec.Command("skopeo", "copy", "--insecure-policy", "docker-archive:"+dockerTar, "docker-daemon:"+ctx.ResultImage+":latest")
+		skopeoCmd := exec.CommandContext(ctx.goContext(), "skopeo", "copy", "--insecure-policy", "docker-archive:"+dockerTar, "docker-daemon:"+ctx.ResultImage+":latest")
 		skopeoCmd.Stdout = ctx.LogWriter
 		skopeoCmd.Stderr = ctx.LogWriter
 		if err := skopeoCmd.Run(); err != nil {
@@ -31,7 +36,7 @@ func TransformNix(ctx *Context) error {
 	} else {
 		// SAFE: zbpack are managed by ourselves. Besides,
 		// macOS does not contain policy.json by default.
-		skopeoCmd := exec.Command("skopeo", "copy", "--insecure-policy", "docker-archive:"+dockerTar, "docker://"+ctx.ResultImage)
+		skopeoCmd := exec.CommandContext(ctx.goContext(), "skopeo", "copy", "--insecure-policy", "docker-archive:"+dockerTar, "docker://"+ctx.ResultImage)
 		skopeoCmd.Stdout = ctx.LogWriter
 		skopeoCmd.Stderr = ctx.LogWriter
 		if err := skopeoCmd.Run(); err != nil {
@@ -44,3 +49,48 @@ func TransformNix(ctx *Context) error {
 
 	return nil
 }
+
+// transformNixMultiPlatform pushes the Nix Docker image of each platform
+// by digest, and then pushes the manifest list of them to ResultImage
+// with "docker buildx imagetools create".
+func transformNixMultiPlatform(ctx *Context) error {
+	if !ctx.PushImage {
+		return fmt.Errorf("an image of multiple platforms cannot be loaded to Docker: push it to the registry instead")
+	}
+
+	images := make([]string, 0, len(ctx.Platforms))
+	for _, platform := range ctx.Platforms {
+		dockerTar := filepath.Join(ctx.BuildkitPath, strings.ReplaceAll(platform, "/", "_"), "result")
+		digestFile := dockerTar + ".digest"
+
+		ctx.Log("Pushing the image of %s...\n", platform)
+
+		// SAFE: zbpack are managed by ourselves. Besides,
+		// macOS does not contain policy.json by default.
+		skopeoCmd := exec.CommandContext(ctx.goContext(), "skopeo", "copy", "--insecure-policy", "--digestfile", digestFile, "docker-archive:"+dockerTar, "docker://"+ctx.ResultImage)
+		skopeoCmd.Stdout = ctx.LogWriter
+		skopeoCmd.Stderr = ctx.LogWriter
+		if err := skopeoCmd.Run(); err != nil {
+			return fmt.Errorf("run skopeo copy for %s: %w", platform, err)
+		}
+
+		digest, err := os.ReadFile(digestFile)
+		if err != nil {
+			return fmt.Errorf("read digest of %s: %w", platform, err)
+		}
+		images = append(images, ctx.ResultImage+"@"+strings.TrimSpace(string(digest)))
+
+		// remove the TAR since we have pushed it
+		_ = os.Remove(dockerTar)
+	}
+
+	args := append([]string{"buildx", "imagetools", "create", "--tag", ctx.ResultImage}, images...)
+	manifestCmd := exec.CommandContext(ctx.goContext(), "docker", args...)
+	manifestCmd.Stdout = ctx.LogWriter
+	manifestCmd.Stderr = ctx.LogWriter
+	if err := manifestCmd.Run(); err != nil {
+		return fmt.Errorf("push manifest list: %w", err)
+	}
+
+	return nil
+}
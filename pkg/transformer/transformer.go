@@ -2,6 +2,7 @@
 package transformer
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -17,6 +18,10 @@ var ErrSkip = errors.New("skip transformer")
 
 // Context is the context for the transformer.
 type Context struct {
+	// Context cancels the transformation when it is done.
+	// nil means context.Background().
+	Context context.Context
+
 	PlanType types.PlanType
 	PlanMeta types.PlanMeta
 
@@ -25,7 +30,11 @@ type Context struct {
 
 	PushImage   bool
 	ResultImage string
-	LogWriter   io.Writer
+	// Platforms is the platforms the image is built for. If there are
+	// multiple platforms, the output of each platform is in the
+	// "<os>_<arch>" directory of BuildkitPath.
+	Platforms []string
+	LogWriter io.Writer
 }
 
 // ZeaburPath returns the `.zeabur` directory of the App path.
@@ -44,6 +53,14 @@ func (c *Context) ZeaburPath() string {
 	return filepath.Join(c.AppPath, ".zeabur")
 }
 
+// goContext returns the context.Context of the transformation.
+func (c *Context) goContext() context.Context {
+	if c.Context == nil {
+		return context.Background()
+	}
+	return c.Context
+}
+
 // Log writes a log message to the log writer.
 func (c *Context) Log(format string, args ...interface{}) {
 	if c.LogWriter == nil {
@@ -63,6 +80,10 @@ func Transform(ctx *Context) error {
 	}
 
 	for tid, t := range transformers {
+		if err := ctx.goContext().Err(); err != nil {
+			return err
+		}
+
 		err := t(ctx)
 		switch true {
 		case errors.Is(err, ErrSkip):
@@ -1,9 +1,15 @@
 package transformer_test
 
 import (
+	"context"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zeabur/zbpack/pkg/transformer"
+	"github.com/zeabur/zbpack/pkg/types"
 )
 
 // GetOutputSnapshotPath returns the path to the output snapshot.
@@ -35,3 +41,31 @@ func GetInputPath(t *testing.T, name string) string {
 
 	return filepath.Join(wd, "inputs", name)
 }
+
+func TestTransform_Canceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := transformer.Transform(&transformer.Context{
+		Context:  ctx,
+		PlanType: types.PlanTypeNix,
+		AppPath:  t.TempDir(),
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestTransformNix_MultiPlatformNotPushed(t *testing.T) {
+	t.Parallel()
+
+	err := transformer.TransformNix(&transformer.Context{
+		PlanType:     types.PlanTypeNix,
+		BuildkitPath: t.TempDir(),
+		AppPath:      t.TempDir(),
+		ResultImage:  "app",
+		Platforms:    []string{"linux/amd64", "linux/arm64"},
+		LogWriter:    io.Discard,
+	})
+	assert.ErrorContains(t, err, "multiple platforms")
+}
@@ -0,0 +1,402 @@
+package types
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// BuildPlanVersion is the version of the BuildPlan schema.
+//
+// Bump it when a field of BuildPlan changes its meaning.
+const BuildPlanVersion = 1
+
+// BuildPlan is the typed and structured representation of a build plan.
+//
+// Unlike PlanMeta, the values in BuildPlan are real lists, booleans
+// and numbers, so the packers do not need to re-parse them.
+// Use NewBuildPlan and BuildPlan.PlanMeta to convert from and to
+// the PlanMeta used by the existing consumers.
+//
+// The built-in planners produce the BuildPlan directly, and the built-in
+// packers generate the Dockerfile from it. PlanMeta remains for the
+// plugins and the identifiers and packers registered out of tree.
+type BuildPlan struct {
+	// Version is the version of the BuildPlan schema. See BuildPlanVersion.
+	Version int `json:"version"`
+	// Type is the plan type.
+	Type PlanType `json:"type"`
+
+	// RuntimeVersion is the version of the language runtime or SDK,
+	// for example, the Go version or the Node.js version.
+	RuntimeVersion string `json:"runtimeVersion,omitempty"`
+	// Framework is the framework used in this project.
+	Framework string `json:"framework,omitempty"`
+	// PackageManager is the package manager used in this project.
+	PackageManager string `json:"packageManager,omitempty"`
+	// Entry is the entry point (file, binary or project) of the application.
+	Entry string `json:"entry,omitempty"`
+	// AppDir is the directory of the application relative to the project root.
+	AppDir string `json:"appDir,omitempty"`
+
+	// InstallCommand is the command to install the dependencies.
+	InstallCommand string `json:"installCommand,omitempty"`
+	// BuildCommand is the command to build the application.
+	BuildCommand string `json:"buildCommand,omitempty"`
+	// StartCommand is the command to start the application.
+	StartCommand string `json:"startCommand,omitempty"`
+
+	// SystemPackages is the list of system packages (apt, apk, …) to install.
+	SystemPackages []string `json:"systemPackages,omitempty"`
+	// ExposedPort is the port the application listens to. 0 means unspecified.
+	ExposedPort int `json:"exposedPort,omitempty"`
+	// OutputDir is the directory of the static output, if any.
+	OutputDir string `json:"outputDir,omitempty"`
+	// Env is the environment variables to set in the image.
+	Env map[string]string `json:"env,omitempty"`
+	// Assets is the list of files to copy to the runtime image.
+	Assets []string `json:"assets,omitempty"`
+	// DependencyFiles is the list of the manifests and lockfiles to
+	// install the dependencies, relative to the project root. They are
+	// copied before the rest of the source, so the installation is
+	// cached until they change. A directory ends with "/". Empty if the
+	// dependencies cannot be installed without the rest of the source.
+	DependencyFiles []string `json:"dependencyFiles,omitempty"`
+	// HealthcheckPath is the HTTP path the HEALTHCHECK of the hardened
+	// image probes. Empty means no HEALTHCHECK.
+	HealthcheckPath string `json:"healthcheckPath,omitempty"`
+	// HealthcheckPort is the port the HEALTHCHECK probes. 0 means the
+	// PORT environment variable, or 8080 if it is not set.
+	HealthcheckPort int `json:"healthcheckPort,omitempty"`
+	// Flags is the boolean switches of this plan, for example, "cgo" or "openssl".
+	Flags map[string]bool `json:"flags,omitempty"`
+
+	// Extra is the plan-specific metadata which has no typed field.
+	Extra PlanMeta `json:"extra,omitempty"`
+}
+
+// Flag returns the value of the flag. An absent flag is false.
+func (bp BuildPlan) Flag(name string) bool {
+	return bp.Flags[name]
+}
+
+// SetFlag sets the value of the flag.
+func (bp *BuildPlan) SetFlag(name string, value bool) {
+	if bp.Flags == nil {
+		bp.Flags = make(map[string]bool)
+	}
+	bp.Flags[name] = value
+}
+
+// SetExtra sets a plan-specific metadata.
+func (bp *BuildPlan) SetExtra(key, value string) {
+	if bp.Extra == nil {
+		bp.Extra = make(PlanMeta)
+	}
+	bp.Extra[key] = value
+}
+
+// KeepKeys keeps the keys in PlanMeta even if their values are empty,
+// as NewBuildPlan does with the empty values in a PlanMeta. It is used by
+// the planners which always set these keys in PlanMeta.
+func (bp *BuildPlan) KeepKeys(keys ...string) {
+	meta := bp.PlanMeta()
+	for _, key := range keys {
+		if _, ok := meta[key]; !ok {
+			bp.SetExtra(key, "")
+		}
+	}
+}
+
+// planMetaList describes a list field which is joined with sep in PlanMeta.
+type planMetaList struct {
+	key string
+	sep string
+}
+
+// planMetaSchema describes how the typed fields of a BuildPlan are named in
+// the PlanMeta of a plan type.
+//
+// An empty key means the plan type does not have such a field.
+type planMetaSchema struct {
+	runtimeVersion string
+	packageManager string
+	entry          string
+	appDir         string
+	install        string
+	build          string
+	start          string
+	systemPackages planMetaList
+	assets         planMetaList
+	flags          []string
+
+	// required is the keys that are always present in PlanMeta, even if
+	// the value is empty.
+	required []string
+}
+
+// FlagCacheMounts is the flag shared by every plan type to mount the
+// caches of the package managers in the generated Dockerfile with
+// RUN --mount=type=cache.
+const FlagCacheMounts = "cacheMounts"
+
+// FlagHardening is the flag shared by every plan type to run the
+// application as a non-root user in a slim runtime image.
+const FlagHardening = "hardening"
+
+// The keys of the HEALTHCHECK of the hardened image in PlanMeta.
+const (
+	PlanMetaKeyHealthcheckPath = "healthcheckPath"
+	PlanMetaKeyHealthcheckPort = "healthcheckPort"
+)
+
+// Keys shared by every plan type.
+const (
+	planMetaKeyFramework = "framework"
+	planMetaKeyOutputDir = "outputDir"
+	planMetaKeyPort      = "port"
+	planMetaEnvPrefix    = "env."
+)
+
+var planMetaDependencyFiles = planMetaList{key: "dependencyFiles", sep: ":"}
+
+var planMetaSchemas = map[PlanType]planMetaSchema{
+	PlanTypeGo: {
+		runtimeVersion: "goVersion",
+		entry:          "entry",
+		build:          "buildCommand",
+		flags:          []string{"cgo"},
+		required:       []string{"goVersion", "entry"},
+	},
+	PlanTypeRust: {
+		entry:    "entry",
+		appDir:   "appDir",
+		build:    "buildCommand",
+		start:    "startCommand",
+		assets:   planMetaList{key: "assets", sep: ":"},
+		flags:    []string{"openssl"},
+		required: []string{"entry", "appDir", "buildCommand", "startCommand", "assets"},
+	},
+	PlanTypePython: {
+		runtimeVersion: "pythonVersion",
+		packageManager: "packageManager",
+		install:        "install",
+		build:          "build",
+		start:          "start",
+		systemPackages: planMetaList{key: "apt-deps", sep: " "},
+		flags:          []string{"selenium"},
+		required:       []string{"pythonVersion", "packageManager", "install"},
+	},
+	PlanTypeNodejs: {
+		runtimeVersion: "nodeVersion",
+		packageManager: "packageManager",
+		appDir:         "appDir",
+		install:        "installCmd",
+		build:          "buildCmd",
+		start:          "startCmd",
+		flags:          []string{"bun"},
+	},
+	PlanTypeBun: {
+		runtimeVersion: "bunVersion",
+		packageManager: "packageManager",
+		appDir:         "appDir",
+		install:        "installCmd",
+		build:          "buildCmd",
+		start:          "startCmd",
+		flags:          []string{"bun"},
+	},
+	PlanTypeRuby: {
+		runtimeVersion: "rubyVersion",
+		build:          "buildCmd",
+		start:          "startCmd",
+		flags:          []string{"needNode"},
+	},
+	PlanTypePHP: {
+		runtimeVersion: "phpVersion",
+		build:          "buildCommand",
+		start:          "startCommand",
+		systemPackages: planMetaList{key: "deps", sep: " "},
+		flags:          []string{"optimize"},
+	},
+	PlanTypeJava: {
+		runtimeVersion: "jdk",
+		packageManager: "type",
+	},
+	PlanTypeDeno: {
+		entry: "entry",
+		start: "startCommand",
+	},
+	PlanTypeDotnet: {
+		runtimeVersion: "sdk",
+		entry:          "entryPoint",
+		appDir:         "submoduleDir",
+	},
+	PlanTypeElixir: {
+		runtimeVersion: "ver",
+		flags:          []string{"ecto"},
+	},
+	PlanTypeStatic: {
+		runtimeVersion: "version",
+	},
+	PlanTypeDart: {
+		build: "build",
+	},
+	PlanTypeNix: {
+		entry: "package",
+	},
+	PlanTypeCustom: {
+		install:        "installCmd",
+		build:          "buildCmd",
+		start:          "startCmd",
+		systemPackages: planMetaList{key: "systemPackages", sep: " "},
+	},
+}
+
+// NewBuildPlan converts the PlanType and PlanMeta to a BuildPlan.
+//
+// The keys that have no typed field are kept in BuildPlan.Extra,
+// so BuildPlan.PlanMeta returns the same PlanMeta.
+func NewBuildPlan(planType PlanType, meta PlanMeta) BuildPlan {
+	schema := planMetaSchemas[planType]
+	rest := maps.Clone(meta)
+	if rest == nil {
+		rest = make(PlanMeta)
+	}
+
+	take := func(key string) string {
+		if key == "" {
+			return ""
+		}
+		// An empty value is left in Extra, so that the key
+		// is still present after converting back to PlanMeta.
+		v := rest[key]
+		if v != "" {
+			delete(rest, key)
+		}
+		return v
+	}
+	takeList := func(l planMetaList) []string {
+		if l.key == "" {
+			return nil
+		}
+		v, ok := rest[l.key]
+		if !ok {
+			return nil
+		}
+		delete(rest, l.key)
+		return strings.FieldsFunc(v, func(r rune) bool { return strings.ContainsRune(l.sep, r) })
+	}
+
+	bp := BuildPlan{
+		Version:        BuildPlanVersion,
+		Type:           planType,
+		RuntimeVersion: take(schema.runtimeVersion),
+		Framework:      take(planMetaKeyFramework),
+		PackageManager: take(schema.packageManager),
+		Entry:          take(schema.entry),
+		AppDir:         take(schema.appDir),
+		InstallCommand: take(schema.install),
+		BuildCommand:   take(schema.build),
+		StartCommand:   take(schema.start),
+		SystemPackages: takeList(schema.systemPackages),
+		OutputDir:      take(planMetaKeyOutputDir),
+		Assets:         takeList(schema.assets),
+
+		DependencyFiles: takeList(planMetaDependencyFiles),
+		HealthcheckPath: take(PlanMetaKeyHealthcheckPath),
+	}
+
+	if port, err := strconv.Atoi(rest[planMetaKeyPort]); err == nil {
+		bp.ExposedPort = port
+		delete(rest, planMetaKeyPort)
+	}
+	if port, err := strconv.Atoi(rest[PlanMetaKeyHealthcheckPort]); err == nil {
+		bp.HealthcheckPort = port
+		delete(rest, PlanMetaKeyHealthcheckPort)
+	}
+
+	for _, flag := range append(slices.Clone(schema.flags), FlagCacheMounts, FlagHardening) {
+		v, ok := rest[flag]
+		if !ok {
+			continue
+		}
+		if b, err := strconv.ParseBool(v); err == nil {
+			bp.SetFlag(flag, b)
+			delete(rest, flag)
+		}
+	}
+
+	for k, v := range rest {
+		if name, ok := strings.CutPrefix(k, planMetaEnvPrefix); ok {
+			if bp.Env == nil {
+				bp.Env = make(map[string]string)
+			}
+			bp.Env[name] = v
+			delete(rest, k)
+		}
+	}
+
+	if len(rest) > 0 {
+		bp.Extra = rest
+	}
+
+	return bp
+}
+
+// PlanMeta converts the BuildPlan to the PlanMeta for the existing consumers.
+func (bp BuildPlan) PlanMeta() PlanMeta {
+	schema := planMetaSchemas[bp.Type]
+	meta := maps.Clone(bp.Extra)
+	if meta == nil {
+		meta = make(PlanMeta)
+	}
+
+	put := func(key, value string) {
+		if key == "" {
+			return
+		}
+		if value != "" || slices.Contains(schema.required, key) {
+			meta[key] = value
+		}
+	}
+	putList := func(l planMetaList, values []string) {
+		if l.key == "" {
+			return
+		}
+		if values != nil || slices.Contains(schema.required, l.key) {
+			meta[l.key] = strings.Join(values, l.sep)
+		}
+	}
+
+	put(schema.runtimeVersion, bp.RuntimeVersion)
+	put(planMetaKeyFramework, bp.Framework)
+	put(schema.packageManager, bp.PackageManager)
+	put(schema.entry, bp.Entry)
+	put(schema.appDir, bp.AppDir)
+	put(schema.install, bp.InstallCommand)
+	put(schema.build, bp.BuildCommand)
+	put(schema.start, bp.StartCommand)
+	putList(schema.systemPackages, bp.SystemPackages)
+	put(planMetaKeyOutputDir, bp.OutputDir)
+	putList(schema.assets, bp.Assets)
+	putList(planMetaDependencyFiles, bp.DependencyFiles)
+	put(PlanMetaKeyHealthcheckPath, bp.HealthcheckPath)
+
+	if bp.ExposedPort != 0 {
+		meta[planMetaKeyPort] = strconv.Itoa(bp.ExposedPort)
+	}
+	if bp.HealthcheckPort != 0 {
+		meta[PlanMetaKeyHealthcheckPort] = strconv.Itoa(bp.HealthcheckPort)
+	}
+
+	for flag, v := range bp.Flags {
+		meta[flag] = strconv.FormatBool(v)
+	}
+
+	for k, v := range bp.Env {
+		meta[planMetaEnvPrefix+k] = v
+	}
+
+	return meta
+}
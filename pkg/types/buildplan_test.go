@@ -0,0 +1,163 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+func TestNewBuildPlan_Go(t *testing.T) {
+	t.Parallel()
+
+	meta := types.PlanMeta{
+		"goVersion":    "1.22",
+		"entry":        "",
+		"buildCommand": "go generate ./...",
+		"cgo":          "true",
+	}
+
+	bp := types.NewBuildPlan(types.PlanTypeGo, meta)
+
+	assert.Equal(t, types.BuildPlanVersion, bp.Version)
+	assert.Equal(t, types.PlanTypeGo, bp.Type)
+	assert.Equal(t, "1.22", bp.RuntimeVersion)
+	assert.Equal(t, "go generate ./...", bp.BuildCommand)
+	assert.True(t, bp.Flag("cgo"))
+	assert.Equal(t, meta, bp.PlanMeta())
+}
+
+func TestNewBuildPlan_Lists(t *testing.T) {
+	t.Parallel()
+
+	t.Run("rust assets", func(t *testing.T) {
+		t.Parallel()
+
+		meta := types.PlanMeta{
+			"entry":           "server",
+			"appDir":          ".",
+			"assets":          "static:templates",
+			"buildCommand":    "",
+			"startCommand":    "",
+			"preStartCommand": "",
+			"openssl":         "false",
+		}
+
+		bp := types.NewBuildPlan(types.PlanTypeRust, meta)
+
+		assert.Equal(t, []string{"static", "templates"}, bp.Assets)
+		assert.False(t, bp.Flag("openssl"))
+		assert.Equal(t, meta, bp.PlanMeta())
+	})
+
+	t.Run("python apt-deps", func(t *testing.T) {
+		t.Parallel()
+
+		meta := types.PlanMeta{
+			"pythonVersion":  "3.12",
+			"packageManager": "pip",
+			"install":        "RUN pip install -r requirements.txt",
+			"apt-deps":       "build-essential pkg-config",
+			"static-flag":    "1",
+		}
+
+		bp := types.NewBuildPlan(types.PlanTypePython, meta)
+
+		assert.Equal(t, []string{"build-essential", "pkg-config"}, bp.SystemPackages)
+		assert.Equal(t, types.PlanMeta{"static-flag": "1"}, bp.Extra)
+		assert.Equal(t, meta, bp.PlanMeta())
+	})
+}
+
+func TestNewBuildPlan_KeepEmptyKeys(t *testing.T) {
+	t.Parallel()
+
+	meta := types.PlanMeta{
+		"nodeVersion": "22",
+		"appDir":      "",
+		"buildCmd":    "",
+		"startCmd":    "node index.js",
+		"framework":   "none",
+	}
+
+	bp := types.NewBuildPlan(types.PlanTypeNodejs, meta)
+
+	assert.Equal(t, "node index.js", bp.StartCommand)
+	assert.Equal(t, meta, bp.PlanMeta())
+}
+
+func TestBuildPlan_KeepKeys(t *testing.T) {
+	t.Parallel()
+
+	bp := types.BuildPlan{
+		Type:           types.PlanTypeNodejs,
+		RuntimeVersion: "22",
+		StartCommand:   "node index.js",
+	}
+	bp.KeepKeys("nodeVersion", "buildCmd", "startCmd")
+
+	assert.Equal(t, types.PlanMeta{
+		"nodeVersion": "22",
+		"buildCmd":    "",
+		"startCmd":    "node index.js",
+	}, bp.PlanMeta())
+}
+
+func TestBuildPlan_PlanMeta(t *testing.T) {
+	t.Parallel()
+
+	bp := types.BuildPlan{
+		Type:           types.PlanTypePython,
+		RuntimeVersion: "3.12",
+		SystemPackages: []string{"libpq-dev"},
+		ExposedPort:    8080,
+		Env:            map[string]string{"FOO": "bar"},
+	}
+	bp.SetFlag("selenium", true)
+
+	assert.Equal(t, types.PlanMeta{
+		"pythonVersion":  "3.12",
+		"packageManager": "",
+		"install":        "",
+		"apt-deps":       "libpq-dev",
+		"port":           "8080",
+		"env.FOO":        "bar",
+		"selenium":       "true",
+	}, bp.PlanMeta())
+}
+
+func TestNewBuildPlan_CacheMounts(t *testing.T) {
+	t.Parallel()
+
+	meta := types.PlanMeta{
+		"nodeVersion":    "20",
+		"packageManager": "npm",
+		"cacheMounts":    "true",
+	}
+
+	bp := types.NewBuildPlan(types.PlanTypeNodejs, meta)
+
+	assert.True(t, bp.Flag(types.FlagCacheMounts))
+	assert.NotContains(t, bp.Extra, types.FlagCacheMounts)
+	assert.Equal(t, "true", bp.PlanMeta()[types.FlagCacheMounts])
+}
+
+func TestNewBuildPlan_Hardening(t *testing.T) {
+	t.Parallel()
+
+	meta := types.PlanMeta{
+		"goVersion":       "1.22",
+		"entry":           "main.go",
+		"hardening":       "true",
+		"healthcheckPath": "/healthz",
+		"healthcheckPort": "3000",
+	}
+
+	bp := types.NewBuildPlan(types.PlanTypeGo, meta)
+
+	assert.True(t, bp.Flag(types.FlagHardening))
+	assert.Equal(t, "/healthz", bp.HealthcheckPath)
+	assert.Equal(t, 3000, bp.HealthcheckPort)
+	assert.Empty(t, bp.Extra)
+	assert.Equal(t, meta, bp.PlanMeta())
+}
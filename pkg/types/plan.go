@@ -30,6 +30,7 @@ const (
 	PlanTypeSwift  PlanType = "swift"
 	PlanTypeDart   PlanType = "dart"
 	PlanTypeNix    PlanType = "nix"
+	PlanTypeCustom PlanType = "custom"
 )
 
 type DartFramework string
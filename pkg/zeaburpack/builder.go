@@ -0,0 +1,505 @@
+package zeaburpack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/util/progress/progressui"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+const (
+	// ConfigBuilderType is the builder to build the image with
+	// (buildkit, buildx, podman or export). By default, the first
+	// available one of buildkit, buildx and podman is used.
+	ConfigBuilderType = "builder.type"
+	// ConfigBuilderExportDir is the directory to write the build context
+	// and the Dockerfile to, for the export builder.
+	ConfigBuilderExportDir = "builder.export_dir"
+)
+
+// The names of the built-in builders.
+const (
+	// BuilderBuildKit builds the image with a running buildkitd.
+	BuilderBuildKit = "buildkit"
+	// BuilderBuildctl is the alias of BuilderBuildKit.
+	BuilderBuildctl = "buildctl"
+	// BuilderBuildx builds the image with "docker buildx build".
+	BuilderBuildx = "buildx"
+	// BuilderPodman builds the image with "podman build".
+	BuilderPodman = "podman"
+	// BuilderExport writes the build context and the Dockerfile
+	// to a directory without building the image.
+	BuilderExport = "export"
+)
+
+// ErrNoBuilder is the error when none of buildkitd, docker buildx
+// and podman is available on this machine.
+var ErrNoBuilder = errors.New("no builder available: run buildkitd, or install docker buildx or podman")
+
+// ErrLoadMultiPlatform is the error when an image of multiple platforms
+// is built without pushing it, which Docker cannot load.
+var ErrLoadMultiPlatform = errors.New("an image of multiple platforms cannot be loaded to Docker: push it to the registry instead")
+
+// ImageBuild is the prepared build which a Builder builds.
+type ImageBuild struct {
+	PlanType types.PlanType
+
+	// ContextDir is the directory of the build context.
+	ContextDir string
+	// DockerfileDir is the directory containing the Dockerfile and
+	// Dockerfile.dockerignore, which is outside the build context.
+	DockerfileDir string
+	// DockerIgnore is the patterns excluded from the build context.
+	DockerIgnore []string
+
+	// ResultImage is the name of the image to build.
+	ResultImage string
+	// PushImage is a flag to indicate if the image should be pushed to the registry.
+	PushImage bool
+	// OutputTar is the path to export the filesystem of the image to,
+	// as a tar file, instead of building an image. It is used by the
+	// plans whose output is transformed later, such as Nix.
+	OutputTar string
+
+	// Platforms is the platforms to build the image for, for example,
+	// "linux/amd64" and "linux/arm64". If there are multiple platforms,
+	// a manifest list is pushed. Empty to build for the platform of
+	// the builder.
+	Platforms []string
+
+	// CacheFrom and CacheTo are the caches to import the layers from
+	// and to export the layers to.
+	CacheFrom []Cache
+	CacheTo   []Cache
+
+	// PlainProgress is a flag to print the progress in the plain text
+	// instead of the interactive TTY output.
+	PlainProgress bool
+
+	// HandleSolveStatus is called with the progress of the build.
+	// Only BuildKitBuilder reports the progress.
+	HandleSolveStatus func(*client.SolveStatus)
+
+	// LogWriter is a [io.Writer] that will be written when a log is emitted.
+	LogWriter io.Writer
+}
+
+// DockerfilePath returns the path to the Dockerfile.
+func (b *ImageBuild) DockerfilePath() string {
+	return filepath.Join(b.DockerfileDir, "Dockerfile")
+}
+
+// multiPlatform returns if the image is built for multiple platforms.
+func (b *ImageBuild) multiPlatform() bool {
+	return len(b.Platforms) > 1
+}
+
+// checkLoadable returns ErrLoadMultiPlatform if the image is built
+// for multiple platforms and loaded to Docker.
+func (b *ImageBuild) checkLoadable() error {
+	if b.multiPlatform() && b.OutputTar == "" && !b.PushImage {
+		return ErrLoadMultiPlatform
+	}
+	return nil
+}
+
+// ImageBuildResult is the result of a Builder.
+type ImageBuildResult struct {
+	// Digest is the digest of the image manifest, for example,
+	// "sha256:…". It is empty if the builder does not report it.
+	Digest string
+	// Size is the total size of the image in bytes. It is 0 if the
+	// builder does not report it.
+	Size int64
+}
+
+// Builder builds the image of an ImageBuild.
+type Builder interface {
+	// Name returns the name of the builder, for example, "buildkit".
+	Name() string
+	// Available returns an error if the builder cannot be used
+	// on this machine, for example, the command is not installed.
+	Available() error
+	// Build builds the image. The build is canceled when ctx is done.
+	Build(ctx context.Context, b *ImageBuild) (ImageBuildResult, error)
+}
+
+// NewBuilder returns the built-in builder with the name.
+// exportDir is the output directory of the export builder.
+func NewBuilder(name string, exportDir string) (Builder, error) {
+	switch name {
+	case BuilderBuildKit, BuilderBuildctl:
+		return BuildKitBuilder{}, nil
+	case BuilderBuildx:
+		return BuildxBuilder{}, nil
+	case BuilderPodman:
+		return PodmanBuilder{}, nil
+	case BuilderExport:
+		if exportDir == "" {
+			return nil, errors.New("export builder requires an output directory")
+		}
+		return ExportBuilder{Dir: exportDir}, nil
+	default:
+		return nil, fmt.Errorf("unknown builder: %s", name)
+	}
+}
+
+// DetectBuilder returns the first available builder of buildkitd,
+// docker buildx and podman, or ErrNoBuilder if none is available.
+func DetectBuilder() (Builder, error) {
+	for _, b := range []Builder{BuildKitBuilder{}, BuildxBuilder{}, PodmanBuilder{}} {
+		if b.Available() == nil {
+			return b, nil
+		}
+	}
+
+	return nil, ErrNoBuilder
+}
+
+// BuildxBuilder builds the image with "docker buildx build",
+// which uses the BuildKit embedded in Docker or the current
+// buildx builder instance.
+type BuildxBuilder struct{}
+
+// Name returns "buildx".
+func (BuildxBuilder) Name() string {
+	return BuilderBuildx
+}
+
+// Available checks if docker buildx is installed.
+func (BuildxBuilder) Available() error {
+	if err := exec.Command("docker", "buildx", "version").Run(); err != nil {
+		return fmt.Errorf("docker buildx is not installed: %w", err)
+	}
+	return nil
+}
+
+// Build builds the image with "docker buildx build".
+func (BuildxBuilder) Build(ctx context.Context, b *ImageBuild) (ImageBuildResult, error) {
+	if err := b.checkLoadable(); err != nil {
+		return ImageBuildResult{}, err
+	}
+
+	// the digest of the image is written to the metadata file
+	metadataFile := filepath.Join(b.DockerfileDir, "metadata.json")
+
+	buildxCmd := exec.CommandContext(ctx, "docker", buildxArgs(b, metadataFile)...)
+	buildxCmd.Stdout = b.LogWriter
+	buildxCmd.Stderr = b.LogWriter
+	if err := buildxCmd.Run(); err != nil {
+		return ImageBuildResult{}, fmt.Errorf("run docker buildx build: %w", err)
+	}
+
+	var metadata struct {
+		Digest     string               `json:"containerimage.digest"`
+		Descriptor *ocispecs.Descriptor `json:"containerimage.descriptor"`
+	}
+	if content, err := os.ReadFile(metadataFile); err == nil {
+		_ = json.Unmarshal(content, &metadata)
+	}
+	result := ImageBuildResult{Digest: metadata.Digest}
+
+	switch {
+	case b.OutputTar != "":
+	case b.PushImage && metadata.Descriptor != nil:
+		// read the pushed manifests from the registry
+		read := func(ctx context.Context, desc ocispecs.Descriptor) ([]byte, error) {
+			ref := b.ResultImage + "@" + desc.Digest.String()
+			return exec.CommandContext(ctx, "docker", "buildx", "imagetools", "inspect", "--raw", ref).Output()
+		}
+		result.Size, _ = imageSize(ctx, read, *metadata.Descriptor)
+	case !b.PushImage:
+		result.Size, _ = localImageSize(ctx, "docker", b.ResultImage)
+	}
+
+	return result, nil
+}
+
+// localImageSize returns the size of the local image with
+// "<runtime> image inspect".
+func localImageSize(ctx context.Context, runtime, image string) (int64, error) {
+	output, err := exec.CommandContext(ctx, runtime, "image", "inspect", "--format", "{{.Size}}", image).Output()
+	if err != nil {
+		return 0, fmt.Errorf("inspect image: %w", err)
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+}
+
+func buildxArgs(b *ImageBuild, metadataFile string) []string {
+	args := []string{
+		"buildx", "build",
+		"--file", b.DockerfilePath(),
+		"--metadata-file", metadataFile,
+	}
+
+	switch {
+	case b.OutputTar != "":
+		args = append(args, "--output", "type=tar,dest="+b.OutputTar)
+	case b.PushImage:
+		args = append(args, "--tag", b.ResultImage, "--push")
+	default:
+		args = append(args, "--tag", b.ResultImage, "--load")
+	}
+
+	if len(b.Platforms) > 0 {
+		args = append(args, "--platform", strings.Join(b.Platforms, ","))
+	}
+
+	for _, cache := range b.CacheFrom {
+		args = append(args, "--cache-from", cache.buildxValue(false))
+	}
+	for _, cache := range b.CacheTo {
+		args = append(args, "--cache-to", cache.buildxValue(true))
+	}
+
+	return append(args, "--progress", string(progressMode(b)), b.ContextDir)
+}
+
+// PodmanBuilder builds the image with "podman build".
+type PodmanBuilder struct{}
+
+// Name returns "podman".
+func (PodmanBuilder) Name() string {
+	return BuilderPodman
+}
+
+// Available checks if podman is installed.
+func (PodmanBuilder) Available() error {
+	if err := exec.Command("podman", "version").Run(); err != nil {
+		return fmt.Errorf("podman is not installed: %w", err)
+	}
+	return nil
+}
+
+// Build builds the image with "podman build", and pushes it
+// with "podman push" if PushImage is set. The digest is only
+// reported if the image is pushed.
+//
+// The image of multiple platforms is built as a manifest list,
+// which is pushed with "podman manifest push".
+func (PodmanBuilder) Build(ctx context.Context, b *ImageBuild) (ImageBuildResult, error) {
+	for _, cache := range append(slices.Clone(b.CacheFrom), b.CacheTo...) {
+		if cache.Type != CacheTypeRegistry {
+			return ImageBuildResult{}, fmt.Errorf("podman does not support the %s cache: use the registry cache instead", cache.Type)
+		}
+	}
+
+	podmanCmd := exec.CommandContext(ctx, "podman", podmanArgs(b)...)
+	podmanCmd.Stdout = b.LogWriter
+	podmanCmd.Stderr = b.LogWriter
+	if err := podmanCmd.Run(); err != nil {
+		return ImageBuildResult{}, fmt.Errorf("run podman build: %w", err)
+	}
+
+	if b.OutputTar != "" {
+		return ImageBuildResult{}, nil
+	}
+
+	result := ImageBuildResult{}
+	result.Size, _ = localImageSize(ctx, "podman", b.ResultImage)
+	if !b.PushImage {
+		return result, nil
+	}
+
+	digestFile := filepath.Join(b.DockerfileDir, "digest")
+	pushArgs := []string{"push", "--digestfile", digestFile, b.ResultImage}
+	if b.multiPlatform() {
+		pushArgs = []string{"manifest", "push", "--all", "--digestfile", digestFile, b.ResultImage, "docker://" + b.ResultImage}
+	}
+	pushCmd := exec.CommandContext(ctx, "podman", pushArgs...)
+	pushCmd.Stdout = b.LogWriter
+	pushCmd.Stderr = b.LogWriter
+	if err := pushCmd.Run(); err != nil {
+		return ImageBuildResult{}, fmt.Errorf("run podman push: %w", err)
+	}
+
+	digest, _ := os.ReadFile(digestFile)
+	result.Digest = strings.TrimSpace(string(digest))
+	return result, nil
+}
+
+func podmanArgs(b *ImageBuild) []string {
+	args := []string{
+		"build",
+		"--file", b.DockerfilePath(),
+		// podman does not read Dockerfile.dockerignore by itself
+		"--ignorefile", b.DockerfilePath() + ".dockerignore",
+	}
+
+	switch {
+	case b.OutputTar != "":
+		args = append(args, "--output", "type=tar,dest="+b.OutputTar)
+	case b.multiPlatform():
+		// podman builds the images of the platforms into a manifest list
+		args = append(args, "--manifest", b.ResultImage)
+	default:
+		args = append(args, "--tag", b.ResultImage)
+	}
+
+	if len(b.Platforms) > 0 {
+		args = append(args, "--platform", strings.Join(b.Platforms, ","))
+	}
+
+	// podman only supports the registry cache, which is checked by Build
+	for _, cache := range b.CacheFrom {
+		args = append(args, "--layers", "--cache-from", cache.Ref)
+	}
+	for _, cache := range b.CacheTo {
+		args = append(args, "--layers", "--cache-to", cache.Ref)
+	}
+
+	return append(args, b.ContextDir)
+}
+
+// ExportBuilder writes the build context, the Dockerfile and the
+// .dockerignore to Dir without building the image, so that the image
+// can be built elsewhere, for example, with "docker build Dir".
+//
+// The files excluded by the .dockerignore patterns are not copied.
+type ExportBuilder struct {
+	Dir string
+}
+
+// Name returns "export".
+func (ExportBuilder) Name() string {
+	return BuilderExport
+}
+
+// Available always returns nil, since it does not need any tool.
+func (ExportBuilder) Available() error {
+	return nil
+}
+
+// Build copies the build context and writes the Dockerfile to Dir.
+func (e ExportBuilder) Build(ctx context.Context, b *ImageBuild) (ImageBuildResult, error) {
+	return ImageBuildResult{}, e.export(ctx, b)
+}
+
+func (e ExportBuilder) export(ctx context.Context, b *ImageBuild) error {
+	dir, err := filepath.Abs(e.Dir)
+	if err != nil {
+		return fmt.Errorf("resolve export directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create export directory: %w", err)
+	}
+
+	err = walkContext(b.ContextDir, b.DockerIgnore, func(rel string, d fs.DirEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		src := filepath.Join(b.ContextDir, filepath.FromSlash(rel))
+		dst := filepath.Join(dir, filepath.FromSlash(rel))
+
+		// the export directory may be in the build context
+		if src == dir {
+			return filepath.SkipDir
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(dst, 0o755)
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(src)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+				return err
+			}
+			return os.Symlink(target, dst)
+		case d.Type().IsRegular():
+			return copyFile(src, dst)
+		default:
+			return nil
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("copy build context: %w", err)
+	}
+
+	for _, name := range []string{"Dockerfile", "Dockerfile.dockerignore"} {
+		if err := copyFile(filepath.Join(b.DockerfileDir, name), filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	// "docker build Dir" reads the .dockerignore in the context
+	if err := copyFile(filepath.Join(b.DockerfileDir, "Dockerfile.dockerignore"), filepath.Join(dir, ".dockerignore")); err != nil {
+		return fmt.Errorf("write .dockerignore: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(b.LogWriter, "Exported the build context and the Dockerfile to %s\n", dir)
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+func progressMode(b *ImageBuild) progressui.DisplayMode {
+	if b.PlainProgress {
+		return progressui.PlainMode
+	}
+	return progressui.TtyMode
+}
+
+// getBuilder returns the builder of the build, which is
+// BuildOptions.Builder, the builder in the project configuration,
+// or the first available one on this machine.
+func getBuilder(opt *BuildOptions, builderType, exportDir string) (Builder, error) {
+	if opt.Builder != nil {
+		return opt.Builder, nil
+	}
+
+	if builderType == "" {
+		return DetectBuilder()
+	}
+
+	builder, err := NewBuilder(strings.ToLower(builderType), exportDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := builder.Available(); err != nil {
+		return nil, err
+	}
+	return builder, nil
+}
@@ -0,0 +1,200 @@
+package zeaburpack
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBuilder(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{BuilderBuildKit, BuilderBuildx, BuilderPodman} {
+		b, err := NewBuilder(name, "")
+		require.NoError(t, err)
+		assert.Equal(t, name, b.Name())
+	}
+
+	b, err := NewBuilder(BuilderBuildctl, "")
+	require.NoError(t, err)
+	assert.Equal(t, BuildKitBuilder{}, b)
+
+	b, err = NewBuilder(BuilderExport, "out")
+	require.NoError(t, err)
+	assert.Equal(t, ExportBuilder{Dir: "out"}, b)
+
+	_, err = NewBuilder(BuilderExport, "")
+	require.Error(t, err)
+	_, err = NewBuilder("kaniko", "")
+	require.Error(t, err)
+}
+
+func TestGetBuilder(t *testing.T) {
+	t.Parallel()
+
+	b, err := getBuilder(&BuildOptions{Builder: PodmanBuilder{}}, BuilderExport, "out")
+	require.NoError(t, err)
+	assert.Equal(t, PodmanBuilder{}, b, "BuildOptions.Builder takes precedence")
+
+	b, err = getBuilder(&BuildOptions{}, "Export", "out")
+	require.NoError(t, err)
+	assert.Equal(t, ExportBuilder{Dir: "out"}, b)
+}
+
+func TestBuilderArgs(t *testing.T) {
+	t.Parallel()
+
+	b := &ImageBuild{
+		ContextDir:    "/app",
+		DockerfileDir: "/tmp/123",
+		ResultImage:   "app",
+		CacheFrom:     []Cache{{Type: CacheTypeRegistry, Ref: "registry.example.com/app:cache"}},
+		PlainProgress: true,
+	}
+
+	assert.Equal(t, []string{
+		"buildx", "build",
+		"--file", "/tmp/123/Dockerfile",
+		"--metadata-file", "/tmp/123/metadata.json",
+		"--tag", "app", "--load",
+		"--cache-from", "type=registry,ref=registry.example.com/app:cache",
+		"--progress", "plain", "/app",
+	}, buildxArgs(b, "/tmp/123/metadata.json"))
+
+	assert.Equal(t, []string{
+		"build",
+		"--file", "/tmp/123/Dockerfile",
+		"--ignorefile", "/tmp/123/Dockerfile.dockerignore",
+		"--tag", "app",
+		"--layers", "--cache-from", "registry.example.com/app:cache",
+		"/app",
+	}, podmanArgs(b))
+
+	pushed := &ImageBuild{ContextDir: "/app", DockerfileDir: "/tmp/123", ResultImage: "app", PushImage: true}
+	assert.Contains(t, buildxArgs(pushed, ""), "--push")
+
+	nix := &ImageBuild{ContextDir: "/app", DockerfileDir: "/tmp/123", ResultImage: "app", OutputTar: "/tmp/out.tar"}
+	assert.Contains(t, buildxArgs(nix, ""), "type=tar,dest=/tmp/out.tar")
+	assert.Contains(t, podmanArgs(nix), "type=tar,dest=/tmp/out.tar")
+}
+
+func TestExportBuilder(t *testing.T) {
+	t.Parallel()
+
+	contextDir := t.TempDir()
+	for name, content := range map[string]string{
+		"index.js":                "console.log(1)",
+		"node_modules/a/index.js": "module.exports = 1",
+		"src/lib.js":              "",
+	} {
+		p := filepath.Join(contextDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+
+	dockerfileDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dockerfileDir, "Dockerfile"), []byte("FROM node:20\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dockerfileDir, "Dockerfile.dockerignore"), []byte("node_modules"), 0o644))
+
+	// the export directory in the build context should not be copied into itself
+	exportDir := filepath.Join(contextDir, "out")
+	_, err := ExportBuilder{Dir: exportDir}.Build(context.Background(), &ImageBuild{
+		ContextDir:    contextDir,
+		DockerfileDir: dockerfileDir,
+		DockerIgnore:  []string{"node_modules"},
+		LogWriter:     io.Discard,
+	})
+	require.NoError(t, err)
+
+	var files []string
+	require.NoError(t, filepath.WalkDir(exportDir, func(p string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(exportDir, p)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	}))
+	assert.ElementsMatch(t, []string{
+		".dockerignore", "Dockerfile", "Dockerfile.dockerignore", "index.js", "src/lib.js",
+	}, files)
+
+	dockerfile, err := os.ReadFile(filepath.Join(exportDir, "Dockerfile"))
+	require.NoError(t, err)
+	assert.Equal(t, "FROM node:20\n", string(dockerfile))
+}
+
+func TestBuildKitSolveOpt(t *testing.T) {
+	t.Parallel()
+
+	b := &ImageBuild{
+		ContextDir:    t.TempDir(),
+		DockerfileDir: t.TempDir(),
+		ResultImage:   "app",
+		CacheFrom:     []Cache{{Type: CacheTypeRegistry, Ref: "registry.example.com/app:cache"}},
+		CacheTo:       []Cache{{Type: CacheTypeRegistry, Ref: "registry.example.com/app:cache"}},
+		LogWriter:     io.Discard,
+	}
+
+	solveOpt, err := buildkitSolveOpt(context.Background(), b)
+	require.NoError(t, err)
+	assert.Equal(t, "dockerfile.v0", solveOpt.Frontend)
+	assert.Contains(t, solveOpt.LocalMounts, "context")
+	assert.Contains(t, solveOpt.LocalMounts, "dockerfile")
+	require.Len(t, solveOpt.Exports, 1)
+	assert.Equal(t, "docker", solveOpt.Exports[0].Type)
+	assert.Equal(t, map[string]string{"name": "app"}, solveOpt.Exports[0].Attrs)
+	assert.NotNil(t, solveOpt.Exports[0].Output, "the image should be streamed to docker load")
+	assert.Equal(t, "registry", solveOpt.CacheImports[0].Type)
+	assert.Equal(t, map[string]string{"ref": "registry.example.com/app:cache"}, solveOpt.CacheExports[0].Attrs)
+
+	b.PushImage = true
+	solveOpt, err = buildkitSolveOpt(context.Background(), b)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "app", "push": "true"}, solveOpt.Exports[0].Attrs)
+
+	b.OutputTar = filepath.Join(t.TempDir(), "out.tar")
+	solveOpt, err = buildkitSolveOpt(context.Background(), b)
+	require.NoError(t, err)
+	assert.Equal(t, "tar", solveOpt.Exports[0].Type)
+
+	_, err = buildkitSolveOpt(context.Background(), &ImageBuild{ContextDir: "/not/exist", DockerfileDir: b.DockerfileDir})
+	require.Error(t, err)
+}
+
+func TestBuilderPlatforms(t *testing.T) {
+	t.Parallel()
+
+	platforms := []string{"linux/amd64", "linux/arm64"}
+	b := &ImageBuild{ContextDir: t.TempDir(), DockerfileDir: t.TempDir(), ResultImage: "app", Platforms: platforms, LogWriter: io.Discard}
+
+	assert.ErrorIs(t, b.checkLoadable(), ErrLoadMultiPlatform)
+	_, err := BuildKitBuilder{}.Build(context.Background(), b)
+	require.ErrorIs(t, err, ErrLoadMultiPlatform)
+	_, err = BuildxBuilder{}.Build(context.Background(), b)
+	require.ErrorIs(t, err, ErrLoadMultiPlatform)
+
+	// podman loads the images of the platforms into a local manifest list
+	assert.Contains(t, podmanArgs(b), "--manifest")
+	assert.NotContains(t, podmanArgs(b), "--tag")
+
+	b.PushImage = true
+	assert.NoError(t, b.checkLoadable())
+
+	args := buildxArgs(b, "")
+	assert.Contains(t, args, "--platform")
+	assert.Contains(t, args, "linux/amd64,linux/arm64")
+
+	solveOpt, err := buildkitSolveOpt(context.Background(), b)
+	require.NoError(t, err)
+	assert.Equal(t, "linux/amd64,linux/arm64", solveOpt.FrontendAttrs["platform"])
+
+	// a single platform is loadable
+	single := &ImageBuild{ResultImage: "app", Platforms: platforms[:1]}
+	assert.NoError(t, single.checkLoadable())
+	assert.Contains(t, podmanArgs(single), "--tag")
+}
@@ -0,0 +1,274 @@
+package zeaburpack
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/content/proxy"
+	"github.com/docker/cli/cli/config"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/auth/authprovider"
+	"github.com/moby/buildkit/util/progress/progressui"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/tonistiigi/fsutil"
+	"golang.org/x/sync/errgroup"
+
+	// the connection helpers of BUILDKIT_HOST, for example,
+	// docker-container://buildkitd
+	_ "github.com/moby/buildkit/client/connhelper/dockercontainer"
+	_ "github.com/moby/buildkit/client/connhelper/kubepod"
+	_ "github.com/moby/buildkit/client/connhelper/podmancontainer"
+	_ "github.com/moby/buildkit/client/connhelper/ssh"
+)
+
+// BuildKitBuilder builds the image with buildkitd through the BuildKit
+// client, like "buildctl build". The image is loaded to Docker with
+// "docker load" unless it is pushed to the registry.
+type BuildKitBuilder struct {
+	// Address is the address of buildkitd. If empty, BUILDKIT_HOST
+	// or the default socket is used.
+	Address string
+}
+
+// Name returns "buildkit".
+func (BuildKitBuilder) Name() string {
+	return BuilderBuildKit
+}
+
+func (k BuildKitBuilder) address() string {
+	if k.Address != "" {
+		return k.Address
+	}
+	return os.Getenv("BUILDKIT_HOST")
+}
+
+// Available checks if buildkitd is running and has workers.
+func (k BuildKitBuilder) Available() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c, err := client.New(ctx, k.address())
+	if err != nil {
+		return fmt.Errorf("connect to buildkitd: %w", err)
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+
+	workers, err := c.ListWorkers(ctx)
+	if err != nil {
+		return fmt.Errorf("buildkitd is not running: %w", err)
+	}
+	if len(workers) == 0 {
+		return fmt.Errorf("buildkitd has no workers")
+	}
+	return nil
+}
+
+// Build solves the Dockerfile with buildkitd. The progress is printed
+// to LogWriter and passed to HandleSolveStatus, and the image is
+// streamed to "docker load" without buffering it in memory.
+func (k BuildKitBuilder) Build(ctx context.Context, b *ImageBuild) (ImageBuildResult, error) {
+	if err := b.checkLoadable(); err != nil {
+		return ImageBuildResult{}, err
+	}
+
+	c, err := client.New(ctx, k.address())
+	if err != nil {
+		return ImageBuildResult{}, fmt.Errorf("connect to buildkitd: %w", err)
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+
+	solveOpt, err := buildkitSolveOpt(ctx, b)
+	if err != nil {
+		return ImageBuildResult{}, err
+	}
+
+	display, err := progressui.NewDisplay(b.LogWriter, progressMode(b))
+	if err != nil {
+		// the log writer is not a TTY
+		display, err = progressui.NewDisplay(b.LogWriter, progressui.PlainMode)
+		if err != nil {
+			return ImageBuildResult{}, fmt.Errorf("create progress display: %w", err)
+		}
+	}
+
+	statusCh := make(chan *client.SolveStatus)
+	displayCh := make(chan *client.SolveStatus)
+
+	var resp *client.SolveResponse
+	eg, solveCtx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		var err error
+		resp, err = c.Solve(solveCtx, nil, solveOpt, statusCh)
+		return err
+	})
+	eg.Go(func() error {
+		defer close(displayCh)
+		for status := range statusCh {
+			if b.HandleSolveStatus != nil {
+				b.HandleSolveStatus(status)
+			}
+			displayCh <- status
+		}
+		return nil
+	})
+	eg.Go(func() error {
+		// not canceled with ctx, so that the last status is displayed
+		_, err := display.UpdateFrom(context.TODO(), displayCh)
+		return err
+	})
+
+	if err := eg.Wait(); err != nil {
+		return ImageBuildResult{}, fmt.Errorf("solve: %w", err)
+	}
+
+	result := ImageBuildResult{Digest: resp.ExporterResponse[exptypes.ExporterImageDigestKey]}
+
+	// the size is only reported if the manifest is in the content store
+	if desc, err := exporterDescriptor(resp.ExporterResponse); err == nil {
+		store := proxy.NewContentStore(c.ContentClient())
+		read := func(ctx context.Context, desc ocispecs.Descriptor) ([]byte, error) {
+			return content.ReadBlob(ctx, store, desc)
+		}
+		if size, err := imageSize(ctx, read, desc); err == nil {
+			result.Size = size
+		}
+	}
+
+	return result, nil
+}
+
+// exporterDescriptor returns the descriptor of the exported image,
+// which is a base64-encoded JSON in the exporter response.
+func exporterDescriptor(resp map[string]string) (ocispecs.Descriptor, error) {
+	encoded, ok := resp[exptypes.ExporterImageDescriptorKey]
+	if !ok {
+		return ocispecs.Descriptor{}, fmt.Errorf("no image descriptor")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return ocispecs.Descriptor{}, fmt.Errorf("decode image descriptor: %w", err)
+	}
+
+	var desc ocispecs.Descriptor
+	if err := json.Unmarshal(decoded, &desc); err != nil {
+		return ocispecs.Descriptor{}, fmt.Errorf("unmarshal image descriptor: %w", err)
+	}
+	return desc, nil
+}
+
+// buildkitSolveOpt returns the options to solve the Dockerfile of b.
+func buildkitSolveOpt(ctx context.Context, b *ImageBuild) (client.SolveOpt, error) {
+	contextFS, err := fsutil.NewFS(b.ContextDir)
+	if err != nil {
+		return client.SolveOpt{}, fmt.Errorf("open build context: %w", err)
+	}
+	dockerfileFS, err := fsutil.NewFS(b.DockerfileDir)
+	if err != nil {
+		return client.SolveOpt{}, fmt.Errorf("open Dockerfile directory: %w", err)
+	}
+
+	dockerConfig := config.LoadDefaultConfigFile(b.LogWriter)
+	solveOpt := client.SolveOpt{
+		Frontend: "dockerfile.v0",
+		LocalMounts: map[string]fsutil.FS{
+			"context":    contextFS,
+			"dockerfile": dockerfileFS,
+		},
+		Session: []session.Attachable{
+			authprovider.NewDockerAuthProvider(authprovider.DockerAuthProviderConfig{
+				AuthConfigProvider: authprovider.LoadAuthConfig(dockerConfig),
+			}),
+		},
+	}
+
+	if len(b.Platforms) > 0 {
+		solveOpt.FrontendAttrs = map[string]string{"platform": strings.Join(b.Platforms, ",")}
+	}
+
+	switch {
+	case b.OutputTar != "":
+		solveOpt.Exports = []client.ExportEntry{{
+			Type: client.ExporterTar,
+			Output: func(map[string]string) (io.WriteCloser, error) {
+				return os.Create(b.OutputTar)
+			},
+		}}
+	case b.PushImage:
+		solveOpt.Exports = []client.ExportEntry{{
+			Type:  client.ExporterImage,
+			Attrs: map[string]string{"name": b.ResultImage, "push": "true"},
+		}}
+	default:
+		// -> docker registry
+		solveOpt.Exports = []client.ExportEntry{{
+			Type:  client.ExporterDocker,
+			Attrs: map[string]string{"name": b.ResultImage},
+			Output: func(map[string]string) (io.WriteCloser, error) {
+				return newDockerLoader(ctx, b.LogWriter)
+			},
+		}}
+	}
+
+	for _, cache := range b.CacheFrom {
+		entry, err := cache.entry(false)
+		if err != nil {
+			return client.SolveOpt{}, fmt.Errorf("cache from %s: %w", cache, err)
+		}
+		solveOpt.CacheImports = append(solveOpt.CacheImports, entry)
+	}
+	for _, cache := range b.CacheTo {
+		entry, err := cache.entry(true)
+		if err != nil {
+			return client.SolveOpt{}, fmt.Errorf("cache to %s: %w", cache.buildxValue(true), err)
+		}
+		solveOpt.CacheExports = append(solveOpt.CacheExports, entry)
+	}
+
+	return solveOpt, nil
+}
+
+// dockerLoader is the standard input of "docker load".
+// Close waits for the image to be loaded.
+type dockerLoader struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func newDockerLoader(ctx context.Context, logWriter io.Writer) (*dockerLoader, error) {
+	cmd := exec.CommandContext(ctx, "docker", "load")
+	cmd.Stdout = logWriter
+	cmd.Stderr = logWriter
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("create docker load pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("run docker load: %w", err)
+	}
+
+	return &dockerLoader{WriteCloser: stdin, cmd: cmd}, nil
+}
+
+func (l *dockerLoader) Close() error {
+	closeErr := l.WriteCloser.Close()
+	if err := l.cmd.Wait(); err != nil {
+		return fmt.Errorf("run docker load: %w", err)
+	}
+	return closeErr
+}
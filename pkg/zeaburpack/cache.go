@@ -0,0 +1,252 @@
+package zeaburpack
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/moby/buildkit/client"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+	"github.com/zeabur/zbpack/pkg/plan"
+)
+
+const (
+	// ConfigCacheFrom is the caches to import the layers from, in the form
+	// of --cache-from, for example, "type=local,src=.cache". It is a
+	// string or a list of strings.
+	ConfigCacheFrom = "cache.from"
+	// ConfigCacheTo is the caches to export the layers to, in the form
+	// of --cache-to, for example, "type=local,dest=.cache,mode=max".
+	// It is a string or a list of strings.
+	ConfigCacheTo = "cache.to"
+)
+
+// The types of the build cache.
+const (
+	// CacheTypeRegistry stores the cache as an image in the registry.
+	CacheTypeRegistry = "registry"
+	// CacheTypeLocal stores the cache in a local directory.
+	CacheTypeLocal = "local"
+	// CacheTypeInline embeds the cache into the built image, which is
+	// imported with the registry cache of the image. It can only be
+	// exported.
+	CacheTypeInline = "inline"
+	// CacheTypeGHA stores the cache in the GitHub Actions cache. The
+	// URL and the token are read from the environment of GitHub Actions.
+	CacheTypeGHA = "gha"
+)
+
+// Cache is a backend of the build cache to import the layers from,
+// or to export the layers to.
+type Cache struct {
+	// Type is the type of the backend: registry, local, inline or gha.
+	Type string
+	// Ref is the image reference of the registry cache.
+	Ref string
+	// Dir is the directory of the local cache.
+	Dir string
+	// Mode is the layers to export: "min" (default) exports the layers
+	// of the result image, and "max" exports the layers of all stages.
+	Mode string
+	// Scope is the scope of the GitHub Actions cache, which separates
+	// the caches of the images. By default, "buildkit".
+	Scope string
+}
+
+// ParseCache parses the cache in the form of --cache-from and --cache-to
+// of docker buildx, for example, "type=local,dest=.cache,mode=max".
+// A value without "=" is the image reference of a registry cache.
+func ParseCache(value string) (Cache, error) {
+	if !strings.Contains(value, "=") {
+		return Cache{Type: CacheTypeRegistry, Ref: value}, nil
+	}
+
+	fields, err := csv.NewReader(strings.NewReader(value)).Read()
+	if err != nil {
+		return Cache{}, fmt.Errorf("parse cache %q: %w", value, err)
+	}
+
+	cache := Cache{Type: CacheTypeRegistry}
+	for _, field := range fields {
+		key, val, ok := strings.Cut(field, "=")
+		if !ok {
+			return Cache{}, fmt.Errorf("parse cache %q: invalid field %q", value, field)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "type":
+			cache.Type = val
+		case "ref":
+			cache.Ref = val
+		case "src", "dest":
+			cache.Dir = val
+		case "mode":
+			cache.Mode = val
+		case "scope":
+			cache.Scope = val
+		default:
+			return Cache{}, fmt.Errorf("parse cache %q: unknown field %q", value, key)
+		}
+	}
+
+	return cache, nil
+}
+
+// validate checks if the cache can be imported, or exported if export is set.
+func (c Cache) validate(export bool) error {
+	switch c.Type {
+	case CacheTypeRegistry:
+		if c.Ref == "" {
+			return fmt.Errorf("registry cache requires ref")
+		}
+	case CacheTypeLocal:
+		if c.Dir == "" {
+			return fmt.Errorf("local cache requires a directory")
+		}
+	case CacheTypeInline:
+		if !export {
+			return fmt.Errorf("inline cache cannot be imported: import it with type=registry,ref=<image>")
+		}
+	case CacheTypeGHA:
+	default:
+		return fmt.Errorf("unknown cache type %q", c.Type)
+	}
+
+	switch c.Mode {
+	case "", "min", "max":
+	default:
+		return fmt.Errorf("unknown cache mode %q", c.Mode)
+	}
+
+	return nil
+}
+
+// attrs returns the attributes of the cache for BuildKit.
+// The directory is src to import, or dest to export.
+func (c Cache) attrs(export bool) map[string]string {
+	attrs := map[string]string{}
+
+	if c.Ref != "" {
+		attrs["ref"] = c.Ref
+	}
+	if c.Dir != "" {
+		attrs[lo.Ternary(export, "dest", "src")] = c.Dir
+	}
+	if c.Mode != "" && export {
+		attrs["mode"] = c.Mode
+	}
+	if c.Scope != "" {
+		attrs["scope"] = c.Scope
+	}
+
+	return attrs
+}
+
+// String returns the cache in the form of --cache-from of docker buildx.
+func (c Cache) String() string {
+	return c.buildxValue(false)
+}
+
+// buildxValue returns the cache in the form of --cache-from of
+// docker buildx, or --cache-to if export is set.
+func (c Cache) buildxValue(export bool) string {
+	attrs := c.attrs(export)
+
+	value := "type=" + c.Type
+	for _, key := range []string{"ref", "src", "dest", "mode", "scope"} {
+		if v, ok := attrs[key]; ok {
+			value += "," + key + "=" + v
+		}
+	}
+	return value
+}
+
+// entry returns the cache entry of the BuildKit client to import
+// the cache, or to export it if export is set. The URL and the token
+// of the GitHub Actions cache are read from the environment, like
+// buildctl.
+func (c Cache) entry(export bool) (client.CacheOptionsEntry, error) {
+	if err := c.validate(export); err != nil {
+		return client.CacheOptionsEntry{}, err
+	}
+
+	entry := client.CacheOptionsEntry{Type: c.Type, Attrs: c.attrs(export)}
+	if c.Type != CacheTypeGHA {
+		return entry, nil
+	}
+
+	if url, ok := os.LookupEnv("ACTIONS_CACHE_URL"); ok {
+		entry.Attrs["url"] = url
+	} else if url, ok := os.LookupEnv("ACTIONS_RESULTS_URL"); ok {
+		entry.Attrs["url"] = url
+	}
+	if v2, _ := cast.ToBoolE(os.Getenv("ACTIONS_CACHE_SERVICE_V2")); v2 {
+		entry.Attrs["version"] = "2"
+		if url, ok := os.LookupEnv("ACTIONS_RESULTS_URL"); ok {
+			entry.Attrs["url_v2"] = url
+		}
+	}
+
+	token, ok := os.LookupEnv("ACTIONS_RUNTIME_TOKEN")
+	if !ok {
+		return client.CacheOptionsEntry{}, fmt.Errorf("gha cache requires $ACTIONS_RUNTIME_TOKEN, which is only set in GitHub Actions")
+	}
+	entry.Attrs["token"] = token
+
+	return entry, nil
+}
+
+// parseCaches parses the caches in the form of --cache-from or --cache-to.
+// The empty values are skipped.
+func parseCaches(values []string) ([]Cache, error) {
+	caches := make([]Cache, 0, len(values))
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		cache, err := ParseCache(value)
+		if err != nil {
+			return nil, err
+		}
+		caches = append(caches, cache)
+	}
+	return caches, nil
+}
+
+// getCaches returns the caches to import and to export, from BuildOptions
+// and the project configuration.
+func getCaches(opt *BuildOptions, config plan.ImmutableProjectConfiguration) (from, to []Cache, err error) {
+	fromValues := plan.Cast(config.Get(ConfigCacheFrom), cast.ToStringSliceE).TakeOr(nil)
+	if opt.CacheFrom != nil {
+		fromValues = append(fromValues, *opt.CacheFrom)
+	}
+	toValues := plan.Cast(config.Get(ConfigCacheTo), cast.ToStringSliceE).TakeOr(nil)
+	if opt.CacheTo != nil {
+		toValues = append(toValues, *opt.CacheTo)
+	}
+
+	if from, err = parseCaches(fromValues); err != nil {
+		return nil, nil, err
+	}
+	if to, err = parseCaches(toValues); err != nil {
+		return nil, nil, err
+	}
+	from = append(from, opt.CacheImports...)
+	to = append(to, opt.CacheExports...)
+
+	for _, cache := range from {
+		if err := cache.validate(false); err != nil {
+			return nil, nil, fmt.Errorf("cache from %s: %w", cache, err)
+		}
+	}
+	for _, cache := range to {
+		if err := cache.validate(true); err != nil {
+			return nil, nil, fmt.Errorf("cache to %s: %w", cache.buildxValue(true), err)
+		}
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,111 @@
+package zeaburpack
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeabur/zbpack/pkg/plan"
+)
+
+func TestParseCache(t *testing.T) {
+	t.Parallel()
+
+	testmap := map[string]Cache{
+		"registry.example.com/app:cache":                      {Type: CacheTypeRegistry, Ref: "registry.example.com/app:cache"},
+		"type=registry,ref=registry.example.com/app,mode=max": {Type: CacheTypeRegistry, Ref: "registry.example.com/app", Mode: "max"},
+		"type=local,src=.cache":                               {Type: CacheTypeLocal, Dir: ".cache"},
+		"type=local,dest=.cache,mode=max":                     {Type: CacheTypeLocal, Dir: ".cache", Mode: "max"},
+		"type=inline":                                         {Type: CacheTypeInline},
+		"type=gha,scope=web":                                  {Type: CacheTypeGHA, Scope: "web"},
+	}
+
+	for value, expected := range testmap {
+		cache, err := ParseCache(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, cache, value)
+	}
+
+	_, err := ParseCache("type=local,dir")
+	assert.Error(t, err)
+	_, err = ParseCache("type=local,unknown=1")
+	assert.Error(t, err)
+}
+
+func TestCacheBuildxValue(t *testing.T) {
+	t.Parallel()
+
+	local := Cache{Type: CacheTypeLocal, Dir: ".cache", Mode: "max"}
+	assert.Equal(t, "type=local,src=.cache", local.buildxValue(false))
+	assert.Equal(t, "type=local,dest=.cache,mode=max", local.buildxValue(true))
+
+	registry := Cache{Type: CacheTypeRegistry, Ref: "registry.example.com/app:cache"}
+	assert.Equal(t, "type=registry,ref=registry.example.com/app:cache", registry.String())
+}
+
+func TestCacheEntry(t *testing.T) {
+	t.Setenv("ACTIONS_CACHE_URL", "https://cache.example.com/")
+	t.Setenv("ACTIONS_RUNTIME_TOKEN", "token")
+
+	entry, err := Cache{Type: CacheTypeGHA, Scope: "web", Mode: "max"}.entry(true)
+	require.NoError(t, err)
+	assert.Equal(t, CacheTypeGHA, entry.Type)
+	assert.Equal(t, map[string]string{
+		"url":   "https://cache.example.com/",
+		"token": "token",
+		"scope": "web",
+		"mode":  "max",
+	}, entry.Attrs)
+
+	entry, err = Cache{Type: CacheTypeInline}.entry(true)
+	require.NoError(t, err)
+	assert.Empty(t, entry.Attrs)
+
+	_, err = Cache{Type: CacheTypeInline}.entry(false)
+	assert.Error(t, err)
+	_, err = Cache{Type: CacheTypeLocal}.entry(false)
+	assert.Error(t, err)
+	_, err = Cache{Type: CacheTypeLocal, Dir: ".cache", Mode: "all"}.entry(true)
+	assert.Error(t, err)
+}
+
+func TestGetCaches(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "zbpack.json", []byte(`{"cache": {"from": "type=local,src=.cache", "to": ["type=local,dest=.cache,mode=max", "type=inline"]}}`), 0o644))
+	config := plan.NewProjectConfigurationFromFs(fs, "")
+
+	from, to, err := getCaches(&BuildOptions{
+		CacheFrom:    lo.ToPtr("registry.example.com/app:cache"),
+		CacheExports: []Cache{{Type: CacheTypeGHA}},
+	}, config)
+	require.NoError(t, err)
+	assert.Equal(t, []Cache{
+		{Type: CacheTypeLocal, Dir: ".cache"},
+		{Type: CacheTypeRegistry, Ref: "registry.example.com/app:cache"},
+	}, from)
+	assert.Equal(t, []Cache{
+		{Type: CacheTypeLocal, Dir: ".cache", Mode: "max"},
+		{Type: CacheTypeInline},
+		{Type: CacheTypeGHA},
+	}, to)
+
+	_, _, err = getCaches(&BuildOptions{CacheImports: []Cache{{Type: CacheTypeInline}}}, config)
+	assert.Error(t, err)
+}
+
+func TestPodmanBuilder_UnsupportedCache(t *testing.T) {
+	t.Parallel()
+
+	_, err := PodmanBuilder{}.Build(context.Background(), &ImageBuild{
+		ResultImage: "app",
+		CacheTo:     []Cache{{Type: CacheTypeLocal, Dir: ".cache"}},
+		LogWriter:   io.Discard,
+	})
+	assert.ErrorContains(t, err, "podman does not support the local cache")
+}
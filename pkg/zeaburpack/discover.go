@@ -0,0 +1,120 @@
+package zeaburpack
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/spf13/afero"
+	"github.com/zeabur/zbpack/internal/discover"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+// Service is a deployable unit discovered in a repository, with its build plan.
+type Service struct {
+	// Name is the service name, which is used as the submodule name.
+	Name string `json:"name" yaml:"name"`
+	// Path is the directory of the service relative to the repository root.
+	Path string `json:"path" yaml:"path"`
+	// Kind is how the service is discovered, for example, "pnpm-workspace" or "go-cmd".
+	Kind string `json:"kind" yaml:"kind"`
+	// Subpath is the directory to plan and build the service in.
+	// Pass it to PlanOptions.Subpath with Name as the submodule name.
+	// An empty Subpath means the repository root.
+	Subpath string `json:"subpath,omitempty" yaml:"subpath,omitempty"`
+	// Config is the configuration set when planning the service.
+	// Put it in zbpack.<name>.json to get the same plan with Plan and Build.
+	Config map[string]any `json:"config,omitempty" yaml:"config,omitempty"`
+
+	PlanType types.PlanType `json:"planType" yaml:"planType"`
+	PlanMeta types.PlanMeta `json:"planMeta" yaml:"planMeta"`
+	// Error is the error when planning this service, if any.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Discover finds every deployable unit in the repository specified in opt
+// (workspace packages, Go commands in "cmd/*", Cargo workspace members,
+// .NET projects in a .sln, and Python subprojects), and plans each of them.
+//
+// If the repository is not a monorepo, it returns the repository itself
+// as the only service. PlanOptions.SubmoduleName is the name of it; by
+// default, the base name of the path.
+//
+// It returns *SourceFetchError if the source cannot be accessed.
+// The planning errors of each service are reported in Service.Error.
+func Discover(opt PlanOptions) ([]Service, error) {
+	return DiscoverContext(context.Background(), opt)
+}
+
+// DiscoverContext is the same as Discover, but fetches the remote source
+// and plans the services with ctx. If ctx is done before all the services
+// are planned, it returns ctx.Err().
+func DiscoverContext(ctx context.Context, opt PlanOptions) ([]Service, error) {
+	src, cleanup, err := getPlanSource(ctx, &opt)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+
+	rootName := lo.FromPtrOr(opt.SubmoduleName, "")
+	if rootName == "" {
+		rootName = path.Base(strings.TrimSuffix(*opt.Path, "/"))
+	}
+
+	units := discover.Find(src, rootName)
+	services := make([]Service, 0, len(units))
+
+	for _, unit := range units {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		service := Service{
+			Name:    unit.Name,
+			Path:    unit.Path,
+			Kind:    string(unit.Kind),
+			Subpath: unit.Subpath,
+			Config:  unit.Config,
+		}
+
+		unitSrc := src
+		if unit.Subpath != "" {
+			unitSrc = afero.NewBasePathFs(src, unit.Subpath)
+		}
+
+		config, configErr := plan.NewProjectConfigurationFromFsE(unitSrc, unit.Name)
+		for key, value := range unit.Config {
+			config.Set(key, value)
+		}
+
+		planner := plan.NewPlanner(
+			&plan.NewPlannerOptions{
+				Source:        unitSrc,
+				Config:        config,
+				SubmoduleName: unit.Name,
+				Context:       ctx,
+			},
+			SupportedIdentifiers(config)...,
+		)
+
+		service.PlanType, service.PlanMeta, err = planner.(plan.ErrorPlanner).PlanE()
+		if configErr != nil {
+			err = configErr
+		}
+		if err != nil {
+			service.Error = err.Error()
+		}
+
+		services = append(services, service)
+	}
+
+	// The identifiers ignore most errors of the source, so the last
+	// plans may be incomplete if ctx is done while planning.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return services, nil
+}
@@ -1,10 +1,12 @@
 package zeaburpack
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/zeabur/zbpack/internal/static"
+	"github.com/zeabur/zbpack/pkg/packer"
 	"github.com/zeabur/zbpack/pkg/types"
 )
 
@@ -14,6 +16,13 @@ type GenerateDockerfileOptions struct {
 	PlanType types.PlanType
 	// PlanMeta is the plan meta generated by the planner.
 	PlanMeta types.PlanMeta
+	// BuildPlan is the typed build plan. If set, it takes precedence
+	// over PlanType and PlanMeta.
+	BuildPlan *types.BuildPlan
+
+	// Context stops the packers which call external processes, such as
+	// the plugins, when it is done. nil means context.Background().
+	Context context.Context
 }
 
 // InjectLabels injects language and framework labels into the Dockerfile.
@@ -61,18 +70,37 @@ func InjectLabels(dockerfile string, planType types.PlanType, planMeta types.Pla
 }
 
 // GenerateDockerfile calls the packers to generate a Dockerfile based on the plan type and meta.
+//
+// The packers implementing packer.BuildPlanPacker generate the Dockerfile
+// from the typed build plan, which is converted from PlanType and PlanMeta
+// if BuildPlan is not set.
 func GenerateDockerfile(opt *GenerateDockerfileOptions) (string, error) {
 	planType := opt.PlanType
 	planMeta := opt.PlanMeta
+	bp := types.NewBuildPlan(planType, planMeta)
+	if opt.BuildPlan != nil {
+		bp = *opt.BuildPlan
+		planType = bp.Type
+		planMeta = bp.PlanMeta()
+	}
+
+	ctx := opt.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
 	var dockerfile string
 	var err error
 
 	// find the packer
 	found := false
-	for _, packer := range SupportedPackers() {
-		if packer.PlanType() == planType {
-			dockerfile, err = packer.GenerateDockerfile(planMeta)
+	for _, p := range SupportedPackers() {
+		if p.PlanType() == planType {
+			if bpp, ok := packer.AsBuildPlanPacker(p); ok {
+				dockerfile, err = bpp.GenerateDockerfileFromBuildPlan(bp)
+			} else {
+				dockerfile, err = packer.GenerateDockerfileContext(ctx, p, planMeta)
+			}
 			found = true
 			break
 		}
@@ -87,6 +115,8 @@ func GenerateDockerfile(opt *GenerateDockerfileOptions) (string, error) {
 		return "", err
 	}
 
+	dockerfile = HardenDockerfile(dockerfile, bp)
+
 	// Inject language and framework labels
 	dockerfile = InjectLabels(dockerfile, planType, planMeta)
 
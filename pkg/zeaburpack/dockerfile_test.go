@@ -138,3 +138,30 @@ func TestGenerateDockerfile(t *testing.T) {
 		t.Error("Framework label not found in generated Dockerfile")
 	}
 }
+
+func TestGenerateDockerfile_Custom(t *testing.T) {
+	opt := &GenerateDockerfileOptions{
+		PlanType: types.PlanTypeCustom,
+		PlanMeta: types.PlanMeta{
+			"baseImage":    "node:22",
+			"buildCmd":     "npm run build",
+			"startCmd":     "npm start",
+			"runtimeImage": "node:22-slim",
+		},
+	}
+
+	dockerfile, err := GenerateDockerfile(opt)
+	if err != nil {
+		t.Fatalf("generateDockerfile failed: %v", err)
+	}
+
+	// The labels are injected after the first FROM statement only
+	if !strings.Contains(dockerfile, "FROM node:22 AS build\n"+`LABEL "language"="custom"`) {
+		t.Errorf("Language label not found after the first FROM statement:\n%s", dockerfile)
+	}
+
+	injected := InjectDockerfile(dockerfile, nil, map[string]string{"PORT": "8080"})
+	if strings.Count(injected, `ENV PORT="8080"`) != 2 {
+		t.Errorf("Environment variables are not injected into every stage:\n%s", injected)
+	}
+}
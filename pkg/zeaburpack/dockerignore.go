@@ -0,0 +1,209 @@
+package zeaburpack
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+	"github.com/spf13/afero"
+	"github.com/spf13/cast"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+const (
+	// ConfigBuildContextIgnore is the patterns, in the .dockerignore syntax,
+	// to exclude from the build context in addition to the defaults.
+	// Prefix a pattern with "!" to include the files excluded by default.
+	// (ZBPACK_BUILD_CONTEXT_IGNORE, separated by commas)
+	ConfigBuildContextIgnore = "build_context.ignore"
+	// ConfigBuildContextGitignore makes the patterns in .gitignore
+	// also excluded from the build context.
+	ConfigBuildContextGitignore = "build_context.gitignore"
+)
+
+// defaultDockerIgnore is the patterns excluded from the build context
+// of every project.
+var defaultDockerIgnore = []string{".next", "node_modules", ".zeabur"}
+
+// planDockerIgnore is the patterns excluded from the build context
+// of the projects of the plan type, which are usually the build outputs
+// and the local environments.
+var planDockerIgnore = map[types.PlanType][]string{
+	types.PlanTypeRust:   {"target"},
+	types.PlanTypePython: {".venv", "**/__pycache__"},
+	types.PlanTypeDotnet: {"**/bin", "**/obj"},
+	types.PlanTypeJava:   {"target", "build", ".gradle"},
+	types.PlanTypeElixir: {"_build", "deps"},
+	types.PlanTypeDart:   {".dart_tool"},
+}
+
+// getDockerIgnore returns the patterns to exclude from the build context.
+//
+// The patterns are, in order, the zbpack defaults, the defaults of the
+// plan type, .gitignore if enabled with ConfigBuildContextGitignore,
+// the project's .dockerignore, and ConfigBuildContextIgnore. Since the
+// last matching pattern wins, the later ones can include the files
+// excluded by the former ones with "!".
+func getDockerIgnore(src afero.Fs, config plan.ImmutableProjectConfiguration, planType types.PlanType) []string {
+	patterns := append([]string{}, defaultDockerIgnore...)
+	patterns = append(patterns, planDockerIgnore[planType]...)
+
+	if plan.Cast(config.Get(ConfigBuildContextGitignore), plan.ToWeakBoolE).TakeOr(false) {
+		if content, err := afero.ReadFile(src, ".gitignore"); err == nil {
+			patterns = append(patterns, convertGitignore(content)...)
+		}
+	}
+
+	if content, err := afero.ReadFile(src, ".dockerignore"); err == nil {
+		dockerignore, err := ignorefile.ReadAll(bytes.NewReader(content))
+		if err != nil {
+			log.Printf("failed to parse .dockerignore: %v\n", err)
+		}
+		patterns = append(patterns, dockerignore...)
+	}
+
+	if v, err := config.Get(ConfigBuildContextIgnore).Take(); err == nil {
+		if s, ok := v.(string); ok {
+			patterns = append(patterns, strings.Split(s, ",")...)
+		} else if list, err := cast.ToStringSliceE(v); err == nil {
+			patterns = append(patterns, list...)
+		} else {
+			log.Printf("%s is not a string or a list of strings: %v\n", ConfigBuildContextIgnore, err)
+		}
+	}
+
+	return patterns
+}
+
+// convertGitignore converts the patterns in .gitignore to the .dockerignore syntax.
+//
+// Unlike .dockerignore, a pattern without a slash in .gitignore matches
+// the files in any directory, and a leading slash anchors the pattern
+// to the root. The directory-only patterns ("dir/") are treated as the
+// normal patterns.
+func convertGitignore(content []byte) []string {
+	var patterns []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		negate := strings.HasPrefix(line, "!")
+		line = strings.TrimPrefix(line, "!")
+		line = strings.TrimSuffix(line, "/")
+
+		if anchored, ok := strings.CutPrefix(line, "/"); ok {
+			line = anchored
+		} else if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		if line == "" || line == "**/" {
+			continue
+		}
+
+		if negate {
+			line = "!" + line
+		}
+		patterns = append(patterns, line)
+	}
+
+	return patterns
+}
+
+// contextSize is the size of the build context.
+type contextSize struct {
+	Files int
+	Bytes int64
+}
+
+func (s contextSize) String() string {
+	const unit = 1024
+	size := float64(s.Bytes)
+	suffix := "B"
+	for _, u := range []string{"KiB", "MiB", "GiB"} {
+		if size < unit {
+			break
+		}
+		size /= unit
+		suffix = u
+	}
+
+	if suffix == "B" {
+		return fmt.Sprintf("%d files, %d B", s.Files, s.Bytes)
+	}
+	return fmt.Sprintf("%d files, %.1f %s", s.Files, size, suffix)
+}
+
+// getContextSize returns the size of the files in dir which are not
+// excluded by the .dockerignore patterns.
+func getContextSize(dir string, patterns []string) (contextSize, error) {
+	var size contextSize
+
+	err := walkContext(dir, patterns, func(_ string, d fs.DirEntry) error {
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size.Files++
+		size.Bytes += info.Size()
+		return nil
+	})
+
+	return size, err
+}
+
+// walkContext calls fn for each file and directory in dir which is not
+// excluded by the .dockerignore patterns. rel is the slash-separated path
+// relative to dir.
+func walkContext(dir string, patterns []string, fn func(rel string, d fs.DirEntry) error) error {
+	matcher, err := patternmatcher.New(patterns)
+	if err != nil {
+		return fmt.Errorf("parse ignore patterns: %w", err)
+	}
+
+	parents := map[string]patternmatcher.MatchInfo{}
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		excluded, info, err := matcher.MatchesUsingParentResults(rel, parents[filepath.ToSlash(filepath.Dir(rel))])
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			parents[rel] = info
+			// An excluded directory can be skipped, unless some files
+			// in it may be included again by an exclusion pattern.
+			if excluded && !matcher.Exclusions() {
+				return filepath.SkipDir
+			}
+		}
+		if excluded {
+			return nil
+		}
+
+		return fn(rel, d)
+	})
+}
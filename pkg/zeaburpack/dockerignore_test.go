@@ -0,0 +1,75 @@
+package zeaburpack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+func TestGetDockerIgnore(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, ".gitignore", []byte("/dist\n*.log\n"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, ".dockerignore", []byte("# comment\n!node_modules\ncoverage\n"), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "zbpack.json", []byte(`{"build_context": {"ignore": ["tmp"]}}`), 0o644))
+
+	config := plan.NewProjectConfigurationFromFs(fs, "")
+	assert.Equal(t, []string{
+		".next", "node_modules", ".zeabur",
+		"target",
+		"!node_modules", "coverage",
+		"tmp",
+	}, getDockerIgnore(fs, config, types.PlanTypeRust))
+
+	require.NoError(t, afero.WriteFile(fs, "zbpack.json", []byte(`{"build_context": {"gitignore": true, "ignore": "tmp,*.bak"}}`), 0o644))
+	config = plan.NewProjectConfigurationFromFs(fs, "")
+	assert.Equal(t, []string{
+		".next", "node_modules", ".zeabur",
+		"dist", "**/*.log",
+		"!node_modules", "coverage",
+		"tmp", "*.bak",
+	}, getDockerIgnore(fs, config, types.PlanTypeNodejs))
+}
+
+func TestConvertGitignore(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{
+		"**/node_modules", "build", "docs/_site", "**/*.pyc", "!**/keep.pyc",
+	}, convertGitignore([]byte("# deps\nnode_modules/\n/build\n\ndocs/_site\n*.pyc\n!keep.pyc\n/\n")))
+}
+
+func TestGetContextSize(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"index.js":                   "console.log(1)",
+		"node_modules/a/index.js":    "module.exports = 1",
+		"node_modules/keep/index.js": "1",
+		"logs/debug.log":             "debug",
+		"src/nested/vendor/lib.go":   "package lib",
+	} {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+
+	size, err := getContextSize(dir, []string{"node_modules", "**/*.log"})
+	require.NoError(t, err)
+	assert.Equal(t, contextSize{Files: 2, Bytes: 25}, size)
+
+	size, err = getContextSize(dir, []string{"node_modules", "!node_modules/keep"})
+	require.NoError(t, err)
+	assert.Equal(t, contextSize{Files: 4, Bytes: 31}, size)
+
+	assert.Equal(t, "2 files, 25 B", contextSize{Files: 2, Bytes: 25}.String())
+	assert.Equal(t, "3 files, 1.5 MiB", contextSize{Files: 3, Bytes: 1536 * 1024}.String())
+}
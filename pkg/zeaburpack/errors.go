@@ -0,0 +1,19 @@
+package zeaburpack
+
+import "fmt"
+
+// SourceFetchError is returned when the source of the project
+// (local directory, GitHub repository, S3 bucket, …) cannot be accessed.
+type SourceFetchError struct {
+	// Source is the path or URL of the project.
+	Source string
+	Err    error
+}
+
+func (e *SourceFetchError) Error() string {
+	return fmt.Sprintf("fetch source %s: %s", e.Source, e.Err)
+}
+
+func (e *SourceFetchError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,155 @@
+package zeaburpack
+
+import (
+	"sync"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+// BuildEvent is an event emitted by Build through BuildOptions.HandleEvent.
+// It is one of the *Event types in this package.
+type BuildEvent interface {
+	buildEvent()
+}
+
+// PlanDeterminedEvent is emitted when the build plan is determined.
+type PlanDeterminedEvent struct {
+	PlanType types.PlanType
+	PlanMeta types.PlanMeta
+}
+
+// DockerfileGeneratedEvent is emitted with the Dockerfile to build,
+// after the variables and the registry are injected.
+type DockerfileGeneratedEvent struct {
+	Dockerfile string
+}
+
+// StepStartedEvent is emitted when a step of the build starts.
+// The steps are only reported by the BuildKit builder.
+type StepStartedEvent struct {
+	// ID identifies the step in the build, which is the digest
+	// of the BuildKit vertex.
+	ID string
+	// Name is the name of the step, for example, "[build 3/5] RUN npm ci".
+	Name    string
+	Started time.Time
+}
+
+// StepFinishedEvent is emitted when a step of the build finishes.
+type StepFinishedEvent struct {
+	ID   string
+	Name string
+	// Duration is the time the step takes.
+	Duration time.Duration
+	// Cached indicates if the result of the step comes from the cache.
+	Cached bool
+	// Error is the error message if the step fails.
+	Error string
+}
+
+// CacheHitEvent is emitted when a step of the build is served from the cache.
+type CacheHitEvent struct {
+	ID   string
+	Name string
+}
+
+// ImagePushedEvent is emitted when the image is pushed to the registry.
+type ImagePushedEvent struct {
+	Image string
+	// Digest is the digest of the pushed manifest, for example,
+	// "sha256:…". It is empty if the builder does not report it.
+	Digest string
+}
+
+// TransformStartedEvent is emitted when the build output starts to be
+// transformed, for example, the Nix image is imported.
+type TransformStartedEvent struct{}
+
+// FailedEvent is emitted when the build fails. It is the last event.
+type FailedEvent struct {
+	Err error
+}
+
+func (PlanDeterminedEvent) buildEvent()      {}
+func (DockerfileGeneratedEvent) buildEvent() {}
+func (StepStartedEvent) buildEvent()         {}
+func (StepFinishedEvent) buildEvent()        {}
+func (CacheHitEvent) buildEvent()            {}
+func (ImagePushedEvent) buildEvent()         {}
+func (TransformStartedEvent) buildEvent()    {}
+func (FailedEvent) buildEvent()              {}
+
+// emit passes the event to HandleEvent if set.
+func (opt *BuildOptions) emit(event BuildEvent) {
+	if opt.HandleEvent != nil {
+		(*opt.HandleEvent)(event)
+	}
+}
+
+// stepTracker converts the solve status of BuildKit to the step events.
+// BuildKit reports a vertex repeatedly as it progresses, so the events
+// already emitted for a vertex are remembered.
+type stepTracker struct {
+	emit func(BuildEvent)
+
+	mu        sync.Mutex
+	started   map[string]bool
+	cached    map[string]bool
+	completed map[string]bool
+
+	stats CacheStats
+}
+
+func newStepTracker(emit func(BuildEvent)) *stepTracker {
+	return &stepTracker{
+		emit:      emit,
+		started:   make(map[string]bool),
+		cached:    make(map[string]bool),
+		completed: make(map[string]bool),
+	}
+}
+
+// Update emits the events of the vertices in the solve status.
+func (t *stepTracker) Update(status *client.SolveStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, v := range status.Vertexes {
+		id := v.Digest.String()
+
+		// A cached vertex may be reported as completed directly.
+		started := v.Started
+		if started == nil {
+			started = v.Completed
+		}
+
+		if started != nil && !t.started[id] {
+			t.started[id] = true
+			t.emit(StepStartedEvent{ID: id, Name: v.Name, Started: *started})
+		}
+		if v.Cached && !t.cached[id] {
+			t.cached[id] = true
+			t.emit(CacheHitEvent{ID: id, Name: v.Name})
+		}
+		if v.Completed != nil && !t.completed[id] {
+			t.completed[id] = true
+			t.stats.Steps++
+			if v.Cached {
+				t.stats.Cached++
+			}
+
+			duration := v.Completed.Sub(*started)
+			t.emit(StepFinishedEvent{ID: id, Name: v.Name, Duration: duration, Cached: v.Cached, Error: v.Error})
+		}
+	}
+}
+
+// Stats returns the statistics of the build cache of the completed steps.
+func (t *stepTracker) Stats() CacheStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.stats
+}
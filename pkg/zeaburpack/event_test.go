@@ -0,0 +1,96 @@
+package zeaburpack
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+func TestStepTracker(t *testing.T) {
+	t.Parallel()
+
+	var events []BuildEvent
+	tracker := newStepTracker(func(event BuildEvent) {
+		events = append(events, event)
+	})
+
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	completed := started.Add(3 * time.Second)
+	run := &client.Vertex{Digest: digest.FromString("run"), Name: "RUN npm ci", Started: &started}
+	from := &client.Vertex{Digest: digest.FromString("from"), Name: "FROM node:20", Cached: true, Completed: &started}
+
+	tracker.Update(&client.SolveStatus{Vertexes: []*client.Vertex{run, from}})
+
+	// the vertex is reported again when it completes
+	run = &client.Vertex{Digest: run.Digest, Name: run.Name, Started: &started, Completed: &completed}
+	tracker.Update(&client.SolveStatus{Vertexes: []*client.Vertex{run}})
+	tracker.Update(&client.SolveStatus{Vertexes: []*client.Vertex{run}})
+
+	assert.Equal(t, []BuildEvent{
+		StepStartedEvent{ID: run.Digest.String(), Name: "RUN npm ci", Started: started},
+		StepStartedEvent{ID: from.Digest.String(), Name: "FROM node:20", Started: started},
+		CacheHitEvent{ID: from.Digest.String(), Name: "FROM node:20"},
+		StepFinishedEvent{ID: from.Digest.String(), Name: "FROM node:20", Cached: true},
+		StepFinishedEvent{ID: run.Digest.String(), Name: "RUN npm ci", Duration: 3 * time.Second},
+	}, events)
+	assert.Equal(t, CacheStats{Steps: 2, Cached: 1}, tracker.Stats())
+}
+
+func TestBuildContext_Events(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html></html>"), 0o644))
+
+	var events []BuildEvent
+	handleEvent := func(event BuildEvent) {
+		events = append(events, event)
+	}
+
+	_, err := BuildContext(context.Background(), &BuildOptions{
+		Path:        &dir,
+		UserVars:    &map[string]string{},
+		LogWriter:   io.Discard,
+		Builder:     ExportBuilder{Dir: filepath.Join(t.TempDir(), "out")},
+		HandleEvent: &handleEvent,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, events, 2)
+	assert.Equal(t, types.PlanTypeStatic, events[0].(PlanDeterminedEvent).PlanType)
+	assert.Contains(t, events[1].(DockerfileGeneratedEvent).Dockerfile, "FROM")
+}
+
+func TestBuildContext_FailedEvent(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var events []BuildEvent
+	handleEvent := func(event BuildEvent) {
+		events = append(events, event)
+	}
+
+	_, err := BuildContext(ctx, &BuildOptions{
+		Path:        &dir,
+		LogWriter:   io.Discard,
+		Builder:     ExportBuilder{Dir: filepath.Join(t.TempDir(), "out")},
+		HandleEvent: &handleEvent,
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.NotEmpty(t, events)
+	assert.Equal(t, FailedEvent{Err: err}, events[len(events)-1])
+}
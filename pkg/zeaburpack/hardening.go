@@ -0,0 +1,122 @@
+package zeaburpack
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+// HealthcheckOptions is the options of the HEALTHCHECK instruction.
+const HealthcheckOptions = "--interval=30s --timeout=5s --start-period=30s --retries=3"
+
+// stopSignals is the graceful stop signals of the runtime images which
+// do not stop gracefully on SIGTERM, by the substring of the image.
+var stopSignals = []struct {
+	image  string
+	signal string
+}{
+	// nginx and php-fpm finish the requests in flight on SIGQUIT,
+	// but stop immediately on SIGTERM.
+	{image: "nginx", signal: "SIGQUIT"},
+	{image: "-fpm", signal: "SIGQUIT"},
+}
+
+// HardenDockerfile adds the STOPSIGNAL and, if the health check path is
+// set, the HEALTHCHECK to the runtime (last) stage of the Dockerfile
+// generated by the packers.
+//
+// The instructions already in the runtime stage are kept, and the
+// Dockerfile is returned as is if it is not hardened. The non-root user
+// and the slim runtime image are up to the packers.
+func HardenDockerfile(dockerfile string, bp types.BuildPlan) string {
+	if !bp.Flag(types.FlagHardening) || bp.Type == types.PlanTypeDocker {
+		return dockerfile
+	}
+
+	lines := strings.Split(strings.TrimRight(dockerfile, "\n"), "\n")
+
+	runtimeStage := -1
+	var runtimeImage string
+	for i, line := range lines {
+		if from, ok := ParseFrom(line); ok {
+			runtimeStage = i
+			runtimeImage = from.Source
+		}
+	}
+	// The output-only images are not run.
+	if runtimeStage == -1 || runtimeImage == "scratch" {
+		return dockerfile
+	}
+
+	// Insert the instructions before CMD or ENTRYPOINT of the runtime
+	// stage, or at the end of the Dockerfile.
+	insertAt := len(lines)
+	hasStopSignal, hasHealthcheck := false, false
+	for i := runtimeStage + 1; i < len(lines); i++ {
+		switch instruction(lines[i]) {
+		case "STOPSIGNAL":
+			hasStopSignal = true
+		case "HEALTHCHECK":
+			hasHealthcheck = true
+		case "CMD", "ENTRYPOINT":
+			insertAt = min(insertAt, i)
+		}
+	}
+
+	var instructions []string
+	if !hasStopSignal {
+		instructions = append(instructions, "STOPSIGNAL "+stopSignal(runtimeImage))
+	}
+	// distroless images have no shell to run the probe.
+	if !hasHealthcheck && bp.HealthcheckPath != "" && !strings.Contains(runtimeImage, "distroless") {
+		instructions = append(instructions, Healthcheck(bp.HealthcheckPath, bp.HealthcheckPort))
+	}
+
+	lines = append(lines[:insertAt], append(instructions, lines[insertAt:]...)...)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Healthcheck returns the HEALTHCHECK instruction which probes the HTTP
+// path on the port. Port 0 means the PORT environment variable, or 8080
+// if it is not set.
+//
+// The probe tries wget, curl, and then the Python, Node.js or Ruby
+// runtime, so that it works in the slim images without wget and curl.
+func Healthcheck(path string, port int) string {
+	portSegment := "${PORT:-8080}"
+	if port != 0 {
+		portSegment = strconv.Itoa(port)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	probes := []string{
+		`wget -q -O /dev/null "$url"`,
+		`curl -fsS -o /dev/null "$url"`,
+		`python3 -c "import sys, urllib.request; urllib.request.urlopen(sys.argv[1], timeout=4)" "$url"`,
+		`node -e "fetch(process.argv[1]).then((r) => process.exit(r.ok ? 0 : 1), () => process.exit(1))" "$url"`,
+		`ruby -rnet/http -e "exit Net::HTTP.get_response(URI(ARGV[0])).is_a?(Net::HTTPSuccess)" "$url"`,
+		`exit 1`,
+	}
+
+	return "HEALTHCHECK " + HealthcheckOptions + ` CMD url="http://127.0.0.1:` + portSegment + path + `"; ` +
+		strings.Join(probes, " 2>/dev/null || ")
+}
+
+// stopSignal returns the signal to stop the container of the image gracefully.
+func stopSignal(image string) string {
+	for _, s := range stopSignals {
+		if strings.Contains(image, s.image) {
+			return s.signal
+		}
+	}
+	return "SIGTERM"
+}
+
+// instruction returns the upper-cased instruction of the Dockerfile line.
+func instruction(line string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+	return strings.ToUpper(name)
+}
@@ -0,0 +1,94 @@
+package zeaburpack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+func TestHardenDockerfile(t *testing.T) {
+	t.Parallel()
+
+	const dockerfile = `FROM golang:1.22-alpine AS builder
+RUN go build -o /server .
+
+FROM alpine AS runtime
+COPY --from=builder /server /server
+CMD ["/server"]
+`
+
+	t.Run("not hardened", func(t *testing.T) {
+		t.Parallel()
+
+		bp := types.BuildPlan{Type: types.PlanTypeGo}
+		assert.Equal(t, dockerfile, HardenDockerfile(dockerfile, bp))
+	})
+
+	t.Run("stop signal", func(t *testing.T) {
+		t.Parallel()
+
+		bp := types.BuildPlan{Type: types.PlanTypeGo, Flags: map[string]bool{types.FlagHardening: true}}
+		assert.Equal(t, `FROM golang:1.22-alpine AS builder
+RUN go build -o /server .
+
+FROM alpine AS runtime
+COPY --from=builder /server /server
+STOPSIGNAL SIGTERM
+CMD ["/server"]
+`, HardenDockerfile(dockerfile, bp))
+	})
+
+	t.Run("health check", func(t *testing.T) {
+		t.Parallel()
+
+		bp := types.BuildPlan{
+			Type:            types.PlanTypeGo,
+			Flags:           map[string]bool{types.FlagHardening: true},
+			HealthcheckPath: "/healthz",
+		}
+		hardened := HardenDockerfile(dockerfile, bp)
+
+		assert.Contains(t, hardened, "STOPSIGNAL SIGTERM\nHEALTHCHECK "+HealthcheckOptions+` CMD url="http://127.0.0.1:${PORT:-8080}/healthz"; wget `)
+		assert.Contains(t, hardened, "|| exit 1\nCMD [\"/server\"]\n")
+	})
+
+	t.Run("existing stop signal", func(t *testing.T) {
+		t.Parallel()
+
+		bp := types.BuildPlan{Type: types.PlanTypePython, Flags: map[string]bool{types.FlagHardening: true}}
+		hardened := HardenDockerfile("FROM python:3.12\nSTOPSIGNAL SIGKILL\nCMD python main.py", bp)
+
+		assert.Equal(t, "FROM python:3.12\nSTOPSIGNAL SIGKILL\nCMD python main.py\n", hardened)
+	})
+
+	t.Run("nginx", func(t *testing.T) {
+		t.Parallel()
+
+		bp := types.BuildPlan{Type: types.PlanTypeStatic, Flags: map[string]bool{types.FlagHardening: true}}
+		hardened := HardenDockerfile("FROM nginx:alpine AS runtime\nCOPY . /usr/share/nginx/html", bp)
+
+		assert.Equal(t, "FROM nginx:alpine AS runtime\nCOPY . /usr/share/nginx/html\nSTOPSIGNAL SIGQUIT\n", hardened)
+	})
+
+	t.Run("output only", func(t *testing.T) {
+		t.Parallel()
+
+		bp := types.BuildPlan{Type: types.PlanTypeStatic, Flags: map[string]bool{types.FlagHardening: true}}
+		assert.Equal(t, "FROM scratch AS output\nCOPY . /\n", HardenDockerfile("FROM scratch AS output\nCOPY . /\n", bp))
+	})
+
+	t.Run("custom Dockerfile", func(t *testing.T) {
+		t.Parallel()
+
+		bp := types.BuildPlan{Type: types.PlanTypeDocker, Flags: map[string]bool{types.FlagHardening: true}}
+		assert.Equal(t, dockerfile, HardenDockerfile(dockerfile, bp))
+	})
+}
+
+func TestHealthcheck(t *testing.T) {
+	t.Parallel()
+
+	assert.Contains(t, Healthcheck("healthz", 3000), `url="http://127.0.0.1:3000/healthz"`)
+	assert.Contains(t, Healthcheck("/", 0), `url="http://127.0.0.1:${PORT:-8080}/"`)
+}
@@ -1,7 +1,9 @@
 package zeaburpack
 
 import (
+	"github.com/spf13/cast"
 	"github.com/zeabur/zbpack/internal/bun"
+	"github.com/zeabur/zbpack/internal/custom"
 	"github.com/zeabur/zbpack/internal/dart"
 	"github.com/zeabur/zbpack/internal/deno"
 	"github.com/zeabur/zbpack/internal/dockerfile"
@@ -19,37 +21,45 @@ import (
 	"github.com/zeabur/zbpack/internal/static"
 	"github.com/zeabur/zbpack/internal/swift"
 	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
 )
 
-// SupportedIdentifiers returns all supported identifiers
+// SupportedIdentifiers returns all supported identifiers, including
+// the ones registered with RegisterIdentifier.
 // note that they are in the order of priority
+//
+// If plan_type is set, only the identifiers of that plan type with the
+// highest priority are returned, so a registered identifier overrides
+// the built-in one instead of making the plan ambiguous.
 func SupportedIdentifiers(config plan.ImmutableProjectConfiguration) []plan.IdentifierV2 {
-	identifiers := []plan.IdentifierV2{
-		plan.WrapV2(dart.NewIdentifier()),
-		plan.WrapV2(php.NewIdentifier()),
-		plan.WrapV2(ruby.NewIdentifier()),
-		plan.WrapV2(bun.NewIdentifier()),
-		plan.WrapV2(python.NewIdentifier()),
-		plan.WrapV2(nodejs.NewIdentifier()),
-		plan.WrapV2(golang.NewIdentifier()),
-		plan.WrapV2(java.NewIdentifier()),
-		plan.WrapV2(deno.NewIdentifier()),
-		plan.WrapV2(rust.NewIdentifier()),
-		plan.WrapV2(dotnet.NewIdentifier()),
-		plan.WrapV2(elixir.NewIdentifier()),
-		plan.WrapV2(gleam.NewIdentifier()),
-		plan.WrapV2(swift.NewIdentifier()),
-		plan.WrapV2(static.NewIdentifier()),
+	identifiers := []registeredIdentifier{
+		{plan.WrapV2(dart.NewIdentifier()), PriorityBuiltin},
+		{plan.WrapV2(php.NewIdentifier()), PriorityBuiltin},
+		{plan.WrapV2(ruby.NewIdentifier()), PriorityBuiltin},
+		{plan.WrapV2(bun.NewIdentifier()), PriorityBuiltin},
+		{plan.WrapV2(python.NewIdentifier()), PriorityBuiltin},
+		{plan.WrapV2(nodejs.NewIdentifier()), PriorityBuiltin},
+		{plan.WrapV2(golang.NewIdentifier()), PriorityBuiltin},
+		{plan.WrapV2(java.NewIdentifier()), PriorityBuiltin},
+		{plan.WrapV2(deno.NewIdentifier()), PriorityBuiltin},
+		{plan.WrapV2(rust.NewIdentifier()), PriorityBuiltin},
+		{plan.WrapV2(dotnet.NewIdentifier()), PriorityBuiltin},
+		{plan.WrapV2(elixir.NewIdentifier()), PriorityBuiltin},
+		{plan.WrapV2(gleam.NewIdentifier()), PriorityBuiltin},
+		{plan.WrapV2(swift.NewIdentifier()), PriorityBuiltin},
+		{plan.WrapV2(static.NewIdentifier()), PriorityFallback},
+		{custom.NewIdentifier(), PriorityCustom},
 	}
 
 	if !plan.Cast(config.Get("ignore_nix"), plan.ToWeakBoolE).TakeOr(false) {
-		identifiers = append([]plan.IdentifierV2{plan.WrapV2(nix.NewIdentifier())}, identifiers...)
+		identifiers = append(identifiers, registeredIdentifier{plan.WrapV2(nix.NewIdentifier()), PriorityNix})
 	}
 
 	// if ignore_dockerfile in config is true, or ZBPACK_IGNORE_DOCKERFILE is true, ignore dockerfile
 	if !plan.Cast(config.Get("ignore_dockerfile"), plan.ToWeakBoolE).TakeOr(false) {
-		identifiers = append([]plan.IdentifierV2{dockerfile.NewIdentifier()}, identifiers...)
+		identifiers = append(identifiers, registeredIdentifier{dockerfile.NewIdentifier(), PriorityDockerfile})
 	}
 
-	return identifiers
+	forcedPlanType := plan.Cast(config.Get(plan.ConfigKeyPlanType), cast.ToStringE).TakeOr("")
+	return sortIdentifiers(identifiers, types.PlanType(forcedPlanType))
 }
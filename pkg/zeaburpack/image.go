@@ -1,17 +1,17 @@
 package zeaburpack
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"math/rand"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/moby/buildkit/client"
 	"github.com/samber/lo"
 	"github.com/zeabur/zbpack/pkg/types"
 )
@@ -20,13 +20,23 @@ type buildImageOptions struct {
 	PlanType            types.PlanType
 	PlanMeta            types.PlanMeta
 	Dockerfile          string
+	DockerIgnore        []string
 	AbsPath             string
 	UserVars            map[string]string
 	ResultImage         string
 	PlainDockerProgress bool
 
-	CacheFrom *string
-	CacheTo   *string
+	// Platforms is the platforms to build the image for.
+	Platforms []string
+
+	CacheFrom []Cache
+	CacheTo   []Cache
+
+	// Builder is the backend to build the image with.
+	Builder Builder
+
+	// HandleSolveStatus is called with the progress of the build.
+	HandleSolveStatus *func(*client.SolveStatus)
 
 	// PushImage is a flag to indicate if the image should be pushed to the registry.
 	PushImage bool
@@ -42,7 +52,7 @@ var ServerlessTarPath = filepath.Join(
 	"serverless-output.tar",
 )
 
-func buildImage(opt *buildImageOptions) error {
+func buildImage(ctx context.Context, opt *buildImageOptions) (ImageBuildResult, error) {
 	if opt.LogWriter == nil {
 		opt.LogWriter = os.Stderr
 	}
@@ -52,77 +62,55 @@ func buildImage(opt *buildImageOptions) error {
 
 	err := os.MkdirAll(path.Join(tempDir, buildID), 0o755)
 	if err != nil {
-		return fmt.Errorf("create temp dir: %w", err)
+		return ImageBuildResult{}, fmt.Errorf("create temp dir: %w", err)
 	}
 
 	dockerfilePath := path.Join(tempDir, buildID, "Dockerfile")
 	err = os.WriteFile(dockerfilePath, []byte(opt.Dockerfile), 0o644)
 	if err != nil {
-		return fmt.Errorf("write Dockerfile: %w", err)
-	}
-
-	dockerIgnore := []string{".next", "node_modules", ".zeabur"}
-	dockerIgnorePath := path.Join(tempDir, buildID, ".dockerignore")
-	err = os.WriteFile(dockerIgnorePath, []byte(strings.Join(dockerIgnore, "\n")), 0o644)
-	if err != nil {
-		return fmt.Errorf("write .dockerignore: %w", err)
+		return ImageBuildResult{}, fmt.Errorf("write Dockerfile: %w", err)
 	}
 
-	buildKitCmd := []string{
-		"build",
-		"--frontend", "dockerfile.v0",
-		"--local", "context=" + opt.AbsPath,
-		"--local", "dockerfile=" + path.Dir(dockerfilePath),
+	dockerIgnore := opt.DockerIgnore
+	if dockerIgnore == nil {
+		dockerIgnore = defaultDockerIgnore
 	}
 
-	if opt.PlanType == types.PlanTypeNix {
-		buildKitCmd = append(buildKitCmd, "--output", "type=tar,dest="+ServerlessTarPath)
-	} else {
-		t := "image"
-		if !opt.PushImage {
-			// -> docker registry
-			t = "docker"
-		}
-
-		o := "type=" + t + ",name=" + opt.ResultImage
-		if opt.PushImage {
-			o += ",push=true"
-		}
-		buildKitCmd = append(buildKitCmd, "--output", o)
-	}
-
-	if opt.CacheFrom != nil && len(*opt.CacheFrom) > 0 {
-		buildKitCmd = append(buildKitCmd, "--import-cache", "type=registry,ref="+*opt.CacheFrom)
-	}
-
-	if opt.CacheTo != nil && len(*opt.CacheTo) > 0 {
-		buildKitCmd = append(buildKitCmd, "--export-cache", "type=registry,ref="+*opt.CacheTo)
+	// BuildKit reads "<Dockerfile>.dockerignore" next to the Dockerfile
+	// instead of the .dockerignore in the context, so the project's
+	// .dockerignore is merged into it.
+	dockerIgnorePath := path.Join(tempDir, buildID, "Dockerfile.dockerignore")
+	err = os.WriteFile(dockerIgnorePath, []byte(strings.Join(dockerIgnore, "\n")), 0o644)
+	if err != nil {
+		return ImageBuildResult{}, fmt.Errorf("write .dockerignore: %w", err)
 	}
 
-	if opt.PlainDockerProgress {
-		buildKitCmd = append(buildKitCmd, "--progress", "plain")
+	if size, err := getContextSize(opt.AbsPath, dockerIgnore); err == nil {
+		_, _ = fmt.Fprintf(opt.LogWriter, "Build context: %s\n", size)
 	} else {
-		buildKitCmd = append(buildKitCmd, "--progress", "tty")
+		_, _ = fmt.Fprintf(opt.LogWriter, "Failed to calculate the build context size: %s\n", err)
 	}
 
-	buildctlCmd := exec.Command("buildctl", buildKitCmd...)
-	buildctlCmd.Stderr = opt.LogWriter
-	output, err := buildctlCmd.Output()
-	if err != nil {
-		return fmt.Errorf("run buildctl build: %w", err)
+	build := &ImageBuild{
+		PlanType:      opt.PlanType,
+		ContextDir:    opt.AbsPath,
+		DockerfileDir: path.Dir(dockerfilePath),
+		DockerIgnore:  dockerIgnore,
+		ResultImage:   opt.ResultImage,
+		PushImage:     opt.PushImage,
+		Platforms:     opt.Platforms,
+		CacheFrom:     opt.CacheFrom,
+		CacheTo:       opt.CacheTo,
+		PlainProgress: opt.PlainDockerProgress,
+		LogWriter:     opt.LogWriter,
 	}
-
-	if !strings.Contains(buildctlCmd.String(), "type=docker") {
-		return nil // buildctl have handled push
+	if opt.HandleSolveStatus != nil {
+		build.HandleSolveStatus = *opt.HandleSolveStatus
 	}
-
-	dockerLoadCmd := exec.Command("docker", "load")
-	dockerLoadCmd.Stdin = bytes.NewReader(output)
-	dockerLoadCmd.Stdout = opt.LogWriter
-	dockerLoadCmd.Stderr = opt.LogWriter
-	if err := dockerLoadCmd.Run(); err != nil {
-		return fmt.Errorf("run docker load: %w", err)
+	if opt.PlanType == types.PlanTypeNix {
+		build.OutputTar = ServerlessTarPath
 	}
 
-	return nil
+	_, _ = fmt.Fprintf(opt.LogWriter, "Building with %s\n", opt.Builder.Name())
+	return opt.Builder.Build(ctx, build)
 }
@@ -0,0 +1,103 @@
+package zeaburpack
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+	"github.com/zeabur/zbpack/pkg/lint"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+// ConfigLintSeverity is the severities of the lint rules by the rule ID,
+// for example, {"root-user": "error", "missing-expose": "off"}.
+const ConfigLintSeverity = "lint.severity"
+
+// LintResult is the findings of the Dockerfile of a project.
+type LintResult struct {
+	// PlanType is the plan type of the project. The Dockerfile of the
+	// project is linted if it is PlanTypeDocker, or the generated one
+	// otherwise.
+	PlanType types.PlanType `json:"planType" yaml:"planType"`
+	// Findings is the problems found in the Dockerfile.
+	Findings []lint.Finding `json:"findings" yaml:"findings"`
+}
+
+// Lint plans the project specified in opt, and lints its Dockerfile,
+// which is the Dockerfile of the project or the generated one. The lines
+// of the findings in the generated Dockerfile are the lines in the output
+// of PlanAndOutputDockerfile.
+//
+// The severities in the "lint.severity" configuration of the project
+// override the defaults, and the severities in severity override them.
+//
+// It returns the errors described in PlanE, and an error if the
+// Dockerfile cannot be generated or linted.
+func Lint(ctx context.Context, opt PlanOptions, severity map[string]lint.Severity) (LintResult, error) {
+	submoduleName := lo.FromPtrOr(opt.SubmoduleName, "")
+
+	src, cleanup, err := getPlanSource(ctx, &opt)
+	defer cleanup()
+	if err != nil {
+		return LintResult{}, err
+	}
+
+	config, err := plan.NewProjectConfigurationFromFsE(src, submoduleName)
+	if err != nil {
+		return LintResult{}, err
+	}
+
+	lintOpt := lint.Options{Severity: make(map[string]lint.Severity)}
+	if configured, err := plan.Cast(config.Get(ConfigLintSeverity), cast.ToStringMapStringE).Take(); err == nil {
+		for id, s := range configured {
+			parsed, err := lint.ParseSeverity(s)
+			if err != nil {
+				return LintResult{}, fmt.Errorf("%s of %s: %w", ConfigLintSeverity, id, err)
+			}
+			lintOpt.Severity[id] = parsed
+		}
+	}
+	for id, s := range severity {
+		lintOpt.Severity[id] = s
+	}
+
+	planner := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source:        src,
+			Config:        config,
+			SubmoduleName: submoduleName,
+			Context:       ctx,
+		},
+		SupportedIdentifiers(config)...,
+	)
+
+	bp, err := planner.(plan.BuildPlanPlanner).PlanBuildPlan()
+	planType := bp.Type
+	if err != nil {
+		return LintResult{PlanType: planType}, err
+	}
+
+	// The Dockerfile of the project is linted as is, so that the lines
+	// of the findings are the lines in the file.
+	dockerfile := bp.PlanMeta()["content"]
+	if planType != types.PlanTypeDocker {
+		dockerfile, err = GenerateDockerfile(
+			&GenerateDockerfileOptions{
+				BuildPlan: &bp,
+				Context:   ctx,
+			},
+		)
+		if err != nil {
+			return LintResult{PlanType: planType}, fmt.Errorf("generate Dockerfile: %w", err)
+		}
+	}
+
+	findings, err := lint.Lint(dockerfile, lintOpt)
+	if err != nil {
+		return LintResult{PlanType: planType}, err
+	}
+
+	return LintResult{PlanType: planType, Findings: findings}, nil
+}
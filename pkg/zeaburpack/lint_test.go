@@ -0,0 +1,68 @@
+package zeaburpack_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeabur/zbpack/pkg/lint"
+	"github.com/zeabur/zbpack/pkg/types"
+	"github.com/zeabur/zbpack/pkg/zeaburpack"
+)
+
+func TestLint_ProjectDockerfile(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(path, "Dockerfile"), []byte("FROM ubuntu:latest\nENV API_TOKEN=abc\nCMD [\"/app\"]\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "zbpack.json"), []byte(`{"lint": {"severity": {"missing-expose": "off", "root-user": "error"}}}`), 0o644))
+
+	submoduleName := "app"
+	result, err := zeaburpack.Lint(context.Background(), zeaburpack.PlanOptions{Path: &path, SubmoduleName: &submoduleName}, map[string]lint.Severity{
+		lint.RuleSecretInEnv: lint.SeverityWarning,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, types.PlanTypeDocker, result.PlanType)
+	assert.Equal(t, []lint.Finding{
+		{Rule: lint.RuleUnpinnedBaseImage, Severity: lint.SeverityWarning, Line: 1, Message: "base image ubuntu:latest is tagged latest; pin it to a version"},
+		{Rule: lint.RuleRootUser, Severity: lint.SeverityError, Line: 1, Message: "the runtime stage has no USER, so the application runs as root"},
+		{Rule: lint.RuleSecretInEnv, Severity: lint.SeverityWarning, Line: 2, Message: "ENV API_TOKEN looks like a secret; pass it at runtime or with a secret mount instead"},
+	}, result.Findings)
+}
+
+func TestLint_GeneratedDockerfile(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(path, "go.mod"), []byte("module example.com/app\n\ngo 1.22\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644))
+
+	result, err := zeaburpack.Lint(context.Background(), zeaburpack.PlanOptions{Path: &path}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, types.PlanTypeGo, result.PlanType)
+	assert.Contains(t, result.Findings, lint.Finding{Rule: lint.RuleRootUser, Severity: lint.SeverityWarning, Line: 14, Message: "the runtime stage has no USER, so the application runs as root"})
+
+	// The hardened Dockerfile runs as a non-root user.
+	require.NoError(t, os.WriteFile(filepath.Join(path, "zbpack.json"), []byte(`{"hardening": true}`), 0o644))
+
+	result, err = zeaburpack.Lint(context.Background(), zeaburpack.PlanOptions{Path: &path}, nil)
+	require.NoError(t, err)
+	for _, f := range result.Findings {
+		assert.NotEqual(t, lint.RuleRootUser, f.Rule)
+	}
+}
+
+func TestLint_InvalidSeverity(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(path, "Dockerfile"), []byte("FROM alpine:3.20\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "zbpack.json"), []byte(`{"lint": {"severity": {"root-user": "fatal"}}}`), 0o644))
+
+	_, err := zeaburpack.Lint(context.Background(), zeaburpack.PlanOptions{Path: &path}, nil)
+	assert.ErrorContains(t, err, "lint.severity of root-user")
+}
@@ -6,11 +6,16 @@ import (
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/codeclysm/extract/v4"
+	"github.com/moby/buildkit/client"
 	"github.com/samber/lo"
 	"github.com/spf13/afero"
+	"github.com/spf13/cast"
+	"github.com/zeabur/zbpack/internal/source"
 	"github.com/zeabur/zbpack/pkg/plan"
 	"github.com/zeabur/zbpack/pkg/transformer"
 	"github.com/zeabur/zbpack/pkg/types"
@@ -28,13 +33,35 @@ type BuildOptions struct {
 	// the build plan is determined.
 	HandlePlanDetermined *func(types.PlanType, types.PlanMeta)
 
+	// HandleSolveStatus is a callback function that will be called with
+	// the progress of the build, such as the started and completed steps
+	// and their logs. It is only called by the BuildKit builder.
+	HandleSolveStatus *func(*client.SolveStatus)
+
+	// HandleEvent is a callback function that will be called with the
+	// events of the build, such as the determined plan, the started and
+	// finished steps and the pushed image. See BuildEvent for the events.
+	HandleEvent *func(BuildEvent)
+
 	// LogWriter is a [io.Writer] that will be written when a log is emitted.
 	// nil to use the default log writer.
 	LogWriter io.Writer
 
-	// Path is the path to the project directory.
+	// Path is the path to the project directory, the URL of a Git
+	// repository in the form of "<url>#<ref>:<subpath>", or the path to
+	// a zip, tar, tar.gz or tar.zst archive (optionally as an archive:// URL),
+	// which is extracted to a temporary directory as the build context.
 	Path *string
 
+	// AccessToken is the token to fetch the Git repository over HTTPS,
+	// only used when Path is a Git URL.
+	AccessToken *string
+
+	// GitProvider is the hosting service of the repository when Path is
+	// a Git URL, which decides the username to send with AccessToken.
+	// By default, it is detected from the host.
+	GitProvider GitProvider
+
 	// ResultImage is the name of the image that will be built.
 	ResultImage *string
 
@@ -44,19 +71,79 @@ type BuildOptions struct {
 	// Interactive is a flag to indicate if the build should be interactive.
 	Interactive *bool
 
+	// CacheFrom and CacheTo are the caches to import the layers from
+	// and to export the layers to, in the form of --cache-from and
+	// --cache-to of docker buildx, for example, "type=local,src=.cache".
+	// An image reference is a registry cache.
 	CacheFrom *string
 	CacheTo   *string
 
+	// CacheImports and CacheExports are the caches to import the layers
+	// from and to export the layers to, in addition to CacheFrom, CacheTo
+	// and cache.from and cache.to in the project configuration.
+	CacheImports []Cache
+	CacheExports []Cache
+
 	// ProxyRegistry is the registry to be used for the image.
 	// See referenceConstructor for more details.
 	ProxyRegistry *string
 
 	// PushImage is a flag to indicate if the image should be pushed to the registry.
 	PushImage bool
+
+	// Platforms is the platforms to build the image for, for example,
+	// "linux/amd64" and "linux/arm64". An image of multiple platforms is
+	// pushed as a manifest list, so PushImage is required for it.
+	// Empty to build for the platform of the builder.
+	Platforms []string
+
+	// Builder is the backend to build the image with. If nil, the builder
+	// in the project configuration (builder.type), or the first available
+	// one of buildkitd, docker buildx and podman is used.
+	Builder Builder
+
+	// MetadataFile is the path to write the build report to, which is
+	// the BuildResult in JSON. Empty to not write it.
+	MetadataFile string
 }
 
 // Build will analyze the project, determine the plan and build the image.
-func Build(opt *BuildOptions) error {
+//
+// Besides the errors of the build, it returns *plan.ConfigParseError if
+// a configuration file cannot be parsed, and *plan.AmbiguousMatchError if
+// the planner cannot tell which identifier to use.
+func Build(opt *BuildOptions) (*BuildResult, error) {
+	return BuildContext(context.Background(), opt)
+}
+
+// BuildContext is the same as Build, but the build is canceled when ctx
+// is done: fetching the source, the build processes and the transformer
+// are stopped, and ctx.Err() is returned.
+func BuildContext(ctx context.Context, opt *BuildOptions) (*BuildResult, error) {
+	start := time.Now()
+
+	result := &BuildResult{}
+	err := build(ctx, opt, result)
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		err = ctxErr
+	}
+	if err != nil {
+		opt.emit(FailedEvent{Err: err})
+		return nil, err
+	}
+
+	result.Duration = time.Since(start)
+	if opt.MetadataFile != "" {
+		if err := writeBuildReport(opt.MetadataFile, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// build builds the image and fills the result.
+func build(ctx context.Context, opt *BuildOptions, result *BuildResult) error {
 	// clean up the buildkit output directory after the build
 	defer func() {
 		_ = os.RemoveAll(path.Join(os.TempDir(), "zbpack/buildkit"))
@@ -71,6 +158,53 @@ func Build(opt *BuildOptions) error {
 		opt.LogWriter = os.Stderr
 	}
 
+	// fetch the remote repository to a temporary build context
+	if opt.Path != nil && source.IsGitURL(*opt.Path) {
+		remote := *opt.Path
+		opt.Log("Fetching %s ...\n", remote)
+
+		gitFs, err := getGitSourceFromURL(ctx, remote, opt.GitProvider, opt.AccessToken)
+		if err != nil {
+			opt.Log("Failed to fetch git repository: %s\n", err)
+			return &SourceFetchError{Source: remote, Err: err}
+		}
+		defer func() {
+			_ = gitFs.Close()
+		}()
+
+		if opt.ResultImage == nil || *opt.ResultImage == "" {
+			img := gitRepositoryName(remote)
+			opt.ResultImage = &img
+		}
+		opt.Path = lo.ToPtr(gitFs.Dir())
+	}
+
+	// extract the archive to a temporary build context
+	if opt.Path != nil {
+		if file, ok := getArchivePath(*opt.Path, wd); ok {
+			archive := *opt.Path
+
+			dir, err := os.MkdirTemp("", "zbpack-archive-*")
+			if err != nil {
+				return fmt.Errorf("create temporary directory: %w", err)
+			}
+			defer func() {
+				_ = os.RemoveAll(dir)
+			}()
+
+			if err := extractArchive(file, dir); err != nil {
+				opt.Log("Failed to extract archive: %s\n", err)
+				return &SourceFetchError{Source: archive, Err: err}
+			}
+
+			if opt.ResultImage == nil || *opt.ResultImage == "" {
+				img := source.TrimArchiveExt(filepath.Base(file))
+				opt.ResultImage = &img
+			}
+			opt.Path = &dir
+		}
+	}
+
 	if opt.Path == nil || *opt.Path == "" {
 		opt.Path = &wd
 	} else if !strings.HasPrefix(*opt.Path, "/") {
@@ -96,39 +230,45 @@ func Build(opt *BuildOptions) error {
 		opt.UserVars = &emptyUserVars
 	}
 
-	if strings.HasPrefix(*opt.Path, "https://") {
-		opt.Log("Build from git repository is not supported yet\n")
-		return fmt.Errorf("build from git repository is not supported yet")
-	}
-
 	var dockerfile string
 	var t types.PlanType
 	var m types.PlanMeta
 
+	src := afero.NewBasePathFs(afero.NewOsFs(), *opt.Path)
+	submoduleName := lo.FromPtrOr(opt.SubmoduleName, "")
+	config, err := plan.NewProjectConfigurationFromFsE(src, submoduleName)
+	if err != nil {
+		opt.Log("Failed to read the configuration: %s\n", err)
+		return err
+	}
+
 	if os.Getenv("DOCKERFILE") != "" {
 		dockerfile = os.Getenv("DOCKERFILE")
 		t = types.PlanTypeDocker
 		m = types.PlanMeta{"content": dockerfile}
 	} else {
-		src := afero.NewBasePathFs(afero.NewOsFs(), *opt.Path)
-		submoduleName := lo.FromPtrOr(opt.SubmoduleName, "")
-		config := plan.NewProjectConfigurationFromFs(src, submoduleName)
-
 		planner := plan.NewPlanner(
 			&plan.NewPlannerOptions{
 				Source:        src,
 				Config:        config,
 				SubmoduleName: submoduleName,
+				Context:       ctx,
 			},
 			SupportedIdentifiers(config)...,
 		)
 
-		t, m = planner.Plan()
+		var bp types.BuildPlan
+		bp, err = planner.(plan.BuildPlanPlanner).PlanBuildPlan()
+		if err != nil {
+			opt.Log("Failed to plan the project: %s\n", err)
+			return err
+		}
+		t, m = bp.Type, bp.PlanMeta()
 
 		dockerfile, err = GenerateDockerfile(
 			&GenerateDockerfileOptions{
-				PlanType: t,
-				PlanMeta: m,
+				BuildPlan: &bp,
+				Context:   ctx,
 			},
 		)
 		if err != nil {
@@ -142,30 +282,70 @@ func Build(opt *BuildOptions) error {
 	if opt.HandlePlanDetermined != nil {
 		(*opt.HandlePlanDetermined)(t, m)
 	}
+	opt.emit(PlanDeterminedEvent{PlanType: t, PlanMeta: m})
 
 	// Remove .zeabur directory if exists
 	_ = os.RemoveAll(path.Join(*opt.Path, ".zeabur"))
 
 	// Inject dockerfile to contain the variables, registry, etc.
 	newDockerfile := InjectDockerfile(dockerfile, opt.ProxyRegistry, *opt.UserVars)
+	opt.emit(DockerfileGeneratedEvent{Dockerfile: newDockerfile})
 
-	err = buildImage(
+	result.PlanType = t
+	result.PlanMeta = m
+	result.DockerfileHash = dockerfileHash(newDockerfile)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	cacheFrom, cacheTo, err := getCaches(opt, config)
+	if err != nil {
+		opt.Log("Invalid build cache: %s\n", err)
+		return err
+	}
+
+	builder, err := getBuilder(
+		opt,
+		plan.Cast(config.Get(ConfigBuilderType), cast.ToStringE).TakeOr(""),
+		plan.Cast(config.Get(ConfigBuilderExportDir), cast.ToStringE).TakeOr(""),
+	)
+	if err != nil {
+		opt.Log("Failed to find a builder: %s\n", err)
+		return err
+	}
+
+	// convert the solve status to the step events
+	steps := newStepTracker(opt.emit)
+	handleSolveStatus := func(status *client.SolveStatus) {
+		if opt.HandleSolveStatus != nil {
+			(*opt.HandleSolveStatus)(status)
+		}
+		steps.Update(status)
+	}
+
+	imageResult, err := buildImage(
+		ctx,
 		&buildImageOptions{
 			PlanType: t,
 			PlanMeta: m,
 
 			Dockerfile:          newDockerfile,
+			DockerIgnore:        getDockerIgnore(src, config, t),
 			AbsPath:             *opt.Path,
 			UserVars:            *opt.UserVars,
 			PlainDockerProgress: opt.Interactive == nil || !*opt.Interactive,
+			Platforms:           opt.Platforms,
 
 			ResultImage: *opt.ResultImage,
 			PushImage:   opt.PushImage,
 
-			CacheFrom: opt.CacheFrom,
-			CacheTo:   opt.CacheTo,
+			CacheFrom: cacheFrom,
+			CacheTo:   cacheTo,
 
-			LogWriter: opt.LogWriter,
+			Builder:           builder,
+			HandleSolveStatus: &handleSolveStatus,
+			LogWriter:         opt.LogWriter,
 		},
 	)
 	if err != nil {
@@ -173,6 +353,22 @@ func Build(opt *BuildOptions) error {
 		return err
 	}
 
+	result.Image = *opt.ResultImage
+	result.Platforms = opt.Platforms
+	result.Digest = imageResult.Digest
+	result.Size = imageResult.Size
+	result.Cache = steps.Stats()
+
+	// no image is built, so there is nothing to transform
+	if _, ok := builder.(ExportBuilder); ok {
+		return nil
+	}
+
+	// the Nix image is pushed by the transformer
+	if opt.PushImage && t != types.PlanTypeNix {
+		opt.emit(ImagePushedEvent{Image: *opt.ResultImage, Digest: imageResult.Digest})
+	}
+
 	dockerBuildOutput := path.Join(os.TempDir(), "zbpack/buildkit")
 	// decompress TAR to the output directory
 	func() {
@@ -196,7 +392,7 @@ func Build(opt *BuildOptions) error {
 			_ = os.Remove(ServerlessTarPath)
 		}(tarFile)
 
-		err = extract.Tar(context.TODO(), tarFile, dockerBuildOutput, func(filename string) string {
+		err = extract.Tar(ctx, tarFile, dockerBuildOutput, func(filename string) string {
 			switch filename {
 			case ".git", ".github", ".vscode", ".idea", ".gitignore",
 				"Dockerfile", "LICENSE", "README.md", "Makefile",
@@ -212,14 +408,21 @@ func Build(opt *BuildOptions) error {
 		}
 	}()
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	opt.Log("Transforming build output ...\n")
+	opt.emit(TransformStartedEvent{})
 	err = transformer.Transform(&transformer.Context{
+		Context:      ctx,
 		PlanType:     t,
 		PlanMeta:     m,
 		BuildkitPath: dockerBuildOutput,
 		AppPath:      *opt.Path,
 		PushImage:    opt.PushImage,
 		ResultImage:  *opt.ResultImage,
+		Platforms:    opt.Platforms,
 		LogWriter:    opt.LogWriter,
 	})
 	if err != nil {
@@ -227,13 +430,21 @@ func Build(opt *BuildOptions) error {
 		return fmt.Errorf("transform build output: %w", err)
 	}
 
+	if opt.PushImage && t == types.PlanTypeNix {
+		opt.emit(ImagePushedEvent{Image: *opt.ResultImage})
+	}
+
 	if opt.Interactive != nil && *opt.Interactive {
 		opt.Log("\n\033[32mBuild successful\033[0m\n")
 		opt.Log("\033[90m" + "To run the image, use the following command:" + "\033[0m\n")
 		if m["outputDir"] != "" {
 			opt.Log("npx serve .zeabur/output/static\n")
 		} else {
-			opt.Log("docker run -p 8080:8080 -e PORT=8080 -it %s\n", *opt.ResultImage)
+			runtime := "docker"
+			if builder.Name() == BuilderPodman {
+				runtime = "podman"
+			}
+			opt.Log("%s run -p 8080:8080 -e PORT=8080 -it %s\n", runtime, *opt.ResultImage)
 		}
 	}
 
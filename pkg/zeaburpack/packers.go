@@ -2,6 +2,7 @@ package zeaburpack
 
 import (
 	"github.com/zeabur/zbpack/internal/bun"
+	"github.com/zeabur/zbpack/internal/custom"
 	"github.com/zeabur/zbpack/internal/dart"
 	"github.com/zeabur/zbpack/internal/deno"
 	"github.com/zeabur/zbpack/internal/dockerfile"
@@ -21,25 +22,27 @@ import (
 	"github.com/zeabur/zbpack/pkg/packer"
 )
 
-// SupportedPackers returns all supported identifiers
+// SupportedPackers returns all supported packers, including
+// the ones registered with RegisterPacker.
 func SupportedPackers() []packer.V2 {
-	return []packer.V2{
-		packer.WrapV2(nix.NewPacker()),
-		dockerfile.NewPacker(),
-		packer.WrapV2(dart.NewPacker()),
-		packer.WrapV2(php.NewPacker()),
-		packer.WrapV2(bun.NewPacker()),
-		packer.WrapV2(nodejs.NewPacker()),
-		packer.WrapV2(golang.NewPacker()),
-		packer.WrapV2(python.NewPacker()),
-		packer.WrapV2(ruby.NewPacker()),
-		packer.WrapV2(java.NewPacker()),
-		packer.WrapV2(deno.NewPacker()),
-		packer.WrapV2(rust.NewPacker()),
-		packer.WrapV2(dotnet.NewPacker()),
-		packer.WrapV2(elixir.NewPacker()),
-		packer.WrapV2(gleam.NewPacker()),
-		packer.WrapV2(swift.NewPacker()),
-		packer.WrapV2(static.NewPacker()),
-	}
+	return sortPackers([]registeredPacker{
+		{packer.WrapV2(nix.NewPacker()), PriorityBuiltin},
+		{dockerfile.NewPacker(), PriorityBuiltin},
+		{custom.NewPacker(), PriorityBuiltin},
+		{packer.WrapV2(dart.NewPacker()), PriorityBuiltin},
+		{packer.WrapV2(php.NewPacker()), PriorityBuiltin},
+		{packer.WrapV2(bun.NewPacker()), PriorityBuiltin},
+		{packer.WrapV2(nodejs.NewPacker()), PriorityBuiltin},
+		{packer.WrapV2(golang.NewPacker()), PriorityBuiltin},
+		{packer.WrapV2(python.NewPacker()), PriorityBuiltin},
+		{packer.WrapV2(ruby.NewPacker()), PriorityBuiltin},
+		{packer.WrapV2(java.NewPacker()), PriorityBuiltin},
+		{packer.WrapV2(deno.NewPacker()), PriorityBuiltin},
+		{packer.WrapV2(rust.NewPacker()), PriorityBuiltin},
+		{packer.WrapV2(dotnet.NewPacker()), PriorityBuiltin},
+		{packer.WrapV2(elixir.NewPacker()), PriorityBuiltin},
+		{packer.WrapV2(gleam.NewPacker()), PriorityBuiltin},
+		{packer.WrapV2(swift.NewPacker()), PriorityBuiltin},
+		{packer.WrapV2(static.NewPacker()), PriorityBuiltin},
+	})
 }
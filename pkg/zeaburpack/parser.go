@@ -9,6 +9,9 @@ import (
 
 // FromStatement represents a FROM statement in a Dockerfile.
 type FromStatement struct {
+	// Flags is the flags of the statement, for example,
+	// "--platform=$BUILDPLATFORM".
+	Flags  []string
 	Source string
 	Stage  mo.Option[string]
 }
@@ -24,14 +27,21 @@ func ParseFrom(line string) (FromStatement, bool) {
 		if strings.ToUpper(child.Value) == "FROM" {
 			source := child.Next.Value
 
+			var flags []string
+			if len(child.Flags) > 0 {
+				flags = child.Flags
+			}
+
 			if child.Next.Next != nil && strings.ToUpper(child.Next.Next.Value) == "AS" {
 				return FromStatement{
+					Flags:  flags,
 					Source: child.Next.Value,
 					Stage:  mo.Some(child.Next.Next.Next.Value),
 				}, true
 			}
 
 			return FromStatement{
+				Flags:  flags,
 				Source: source,
 				Stage:  mo.None[string](),
 			}, true
@@ -42,9 +52,14 @@ func ParseFrom(line string) (FromStatement, bool) {
 }
 
 func (fs FromStatement) String() string {
+	from := "FROM "
+	for _, flag := range fs.Flags {
+		from += flag + " "
+	}
+
 	if stage, ok := fs.Stage.Get(); ok {
-		return "FROM " + fs.Source + " AS " + stage
+		return from + fs.Source + " AS " + stage
 	}
 
-	return "FROM " + fs.Source
+	return from + fs.Source
 }
@@ -57,10 +57,12 @@ func TestParseFrom(t *testing.T) {
 			Stage:  mo.Some("builder"),
 		},
 		"FROM --platform=linux/amd64 alpine AS builder": {
+			Flags:  []string{"--platform=linux/amd64"},
 			Source: "alpine",
 			Stage:  mo.Some("builder"),
 		},
 		"FROM --platform=$BUILDERPLATFORM alpine:3.12 AS builder": {
+			Flags:  []string{"--platform=$BUILDERPLATFORM"},
 			Source: "alpine:3.12",
 			Stage:  mo.Some("builder"),
 		},
@@ -114,6 +116,14 @@ func TestParseFrom_String(t *testing.T) {
 			},
 			Output: "FROM alpine:3.12 AS builder",
 		},
+		{
+			Input: zeaburpack.FromStatement{
+				Flags:  []string{"--platform=$BUILDPLATFORM"},
+				Source: "alpine:3.12",
+				Stage:  mo.Some("builder"),
+			},
+			Output: "FROM --platform=$BUILDPLATFORM alpine:3.12 AS builder",
+		},
 	}
 
 	for _, tv := range testmap {
@@ -1,16 +1,18 @@
 package zeaburpack
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/samber/lo"
 	"github.com/spf13/afero"
+	"github.com/zeabur/zbpack/internal/source"
 	"github.com/zeabur/zbpack/pkg/plan"
 	"github.com/zeabur/zbpack/pkg/types"
 )
@@ -23,51 +25,356 @@ type PlanOptions struct {
 	// in Zeabur internal system, this is the name of the service.
 	SubmoduleName *string
 
-	// Path is the path to the project directory.
+	// Path is the path to the project directory. It can also be the URL
+	// of a Git repository, an S3 URL, or the path to a zip, tar, tar.gz or
+	// tar.zst archive, optionally as an archive:// URL such as
+	// "archive:///srv/uploads/app.tar.gz". Archives are read in memory.
 	Path *string
 
 	// Subpath specifies the root directory to plan in the project directory.
 	Subpath *string
 
-	// Access token for GitHub, only used when Path is a GitHub URL.
+	// Access token for GitHub, GitLab, Gitea or Bitbucket,
+	// only used when Path is the URL of a remote repository.
 	AccessToken *string
 
+	// GitProvider is the hosting service of the repository when Path is
+	// an HTTPS URL. By default, it is detected from the host of the URL,
+	// and the repositories on unknown hosts are fetched with git.
+	GitProvider GitProvider
+
 	// AWSConfig is the AWS configuration to access S3, required if Path is an S3 URL.
 	AWSConfig *plan.AWSConfig
+
+	// IncludeRunnerUps makes GetPlanInfo also return the other matched
+	// plans in PlanInfo.RunnerUps. It is slower since every identifier
+	// is evaluated.
+	IncludeRunnerUps bool
+}
+
+// PlanInfo is the build plan with the information about how it is determined.
+type PlanInfo struct {
+	// PlanType is the plan type determined by the planner.
+	PlanType types.PlanType `json:"planType" yaml:"planType"`
+	// PlanMeta is the plan meta determined by the planner.
+	PlanMeta types.PlanMeta `json:"planMeta" yaml:"planMeta"`
+	// Submodule is the submodule name used for planning.
+	Submodule string `json:"submodule" yaml:"submodule"`
+	// ConfigSources is where the project configuration is loaded from.
+	ConfigSources []string `json:"configSources" yaml:"configSources"`
+	// Warnings is the problems encountered when planning.
+	Warnings []string `json:"warnings" yaml:"warnings"`
+	// RunnerUps is the other matched plans ranked by their scores.
+	// Only available when PlanOptions.IncludeRunnerUps is set.
+	// Set plan_type to one of them to use it instead.
+	RunnerUps []plan.Candidate `json:"runnerUps,omitempty" yaml:"runnerUps,omitempty"`
 }
 
 // Plan returns the build plan and metadata.
+//
+// If the source cannot be accessed, it returns the static plan with
+// an "error" key in meta. Use PlanE to get the error instead.
 func Plan(opt PlanOptions) (types.PlanType, types.PlanMeta) {
+	info := GetPlanInfo(opt)
+	return info.PlanType, info.PlanMeta
+}
+
+// PlanE returns the build plan and metadata.
+//
+// Unlike Plan, it returns *SourceFetchError if the source cannot be accessed,
+// *plan.ConfigParseError if a configuration file cannot be parsed,
+// plan.ErrNoIdentifierMatched if no identifier matches the project and
+// *plan.AmbiguousMatchError if the planner cannot tell which identifier to use.
+func PlanE(opt PlanOptions) (types.PlanType, types.PlanMeta, error) {
+	info, err := GetPlanInfoE(opt)
+	return info.PlanType, info.PlanMeta, err
+}
+
+// PlanContext is the same as PlanE, but fetches the remote source with ctx.
+// If ctx is done before the plan is determined, it returns ctx.Err().
+func PlanContext(ctx context.Context, opt PlanOptions) (types.PlanType, types.PlanMeta, error) {
+	info, err := GetPlanInfoContext(ctx, opt)
+	return info.PlanType, info.PlanMeta, err
+}
+
+// GetPlanInfo returns the build plan and metadata, with the information
+// about where the configuration comes from and the warnings.
+func GetPlanInfo(opt PlanOptions) PlanInfo {
+	info, _ := GetPlanInfoE(opt)
+	return info
+}
+
+// GetPlanInfoE is the same as GetPlanInfo, but returns the errors
+// described in PlanE. The returned PlanInfo is the fallback plan
+// which GetPlanInfo returns.
+func GetPlanInfoE(opt PlanOptions) (PlanInfo, error) {
+	return GetPlanInfoContext(context.Background(), opt)
+}
+
+// GetPlanInfoContext is the same as GetPlanInfoE, but fetches the remote
+// source with ctx. If ctx is done before the plan is determined, it
+// returns ctx.Err().
+func GetPlanInfoContext(ctx context.Context, opt PlanOptions) (PlanInfo, error) {
+	info, err := getPlanInfo(ctx, opt)
+	// The identifiers ignore most errors of the source, so the plan
+	// may be incomplete if ctx is done while planning.
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return info, ctxErr
+	}
+	return info, err
+}
+
+func getPlanInfo(ctx context.Context, opt PlanOptions) (PlanInfo, error) {
+	submoduleName := lo.FromPtrOr(opt.SubmoduleName, "")
+	info := PlanInfo{
+		Submodule:     submoduleName,
+		ConfigSources: []string{},
+		Warnings:      []string{},
+	}
+
+	src, cleanup, err := getPlanSource(ctx, &opt)
+	defer cleanup()
+	if err != nil {
+		info.PlanType = types.PlanTypeStatic
+		info.PlanMeta = errorPlanMeta(err)
+		info.Warnings = append(info.Warnings, err.Error())
+		return info, err
+	}
+
+	config, configErr := plan.NewProjectConfigurationFromFsE(src, submoduleName)
+	if reporter, ok := config.(configReporter); ok {
+		info.ConfigSources = append(info.ConfigSources, reporter.Sources()...)
+		info.Warnings = append(info.Warnings, reporter.Warnings()...)
+	}
+
+	planner := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source:        src,
+			Config:        config,
+			SubmoduleName: submoduleName,
+			Context:       ctx,
+		},
+		SupportedIdentifiers(config)...,
+	)
+
+	if opt.IncludeRunnerUps {
+		var candidates []plan.Candidate
+		candidates, err = planner.(plan.CandidatePlanner).PlanCandidates()
+		if len(candidates) > 0 && candidates[0].Selected {
+			info.PlanType, info.PlanMeta = candidates[0].PlanType, candidates[0].PlanMeta
+			info.RunnerUps = candidates[1:]
+		} else {
+			info.PlanType, info.PlanMeta = types.PlanTypeStatic, types.PlanMeta{}
+		}
+	} else {
+		info.PlanType, info.PlanMeta, err = planner.(plan.ErrorPlanner).PlanE()
+	}
+	if configErr != nil {
+		return info, configErr
+	}
+
+	return info, err
+}
+
+// PlanWithTrace returns the build plan and metadata, with the trace of
+// every identifier decision and where each plan meta value comes from.
+//
+// It returns the errors described in PlanE.
+func PlanWithTrace(opt PlanOptions) (types.PlanType, types.PlanMeta, *plan.Trace, error) {
+	return PlanWithTraceContext(context.Background(), opt)
+}
+
+// PlanWithTraceContext is the same as PlanWithTrace, but fetches the
+// remote source and plans with ctx. If ctx is done before the plan is
+// determined, it returns ctx.Err().
+func PlanWithTraceContext(ctx context.Context, opt PlanOptions) (types.PlanType, types.PlanMeta, *plan.Trace, error) {
+	t, m, trace, err := planWithTrace(ctx, opt)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return t, m, trace, ctxErr
+	}
+	return t, m, trace, err
+}
+
+func planWithTrace(ctx context.Context, opt PlanOptions) (types.PlanType, types.PlanMeta, *plan.Trace, error) {
+	src, cleanup, err := getPlanSource(ctx, &opt)
+	defer cleanup()
+	if err != nil {
+		return types.PlanTypeStatic, errorPlanMeta(err), &plan.Trace{
+			PlanType:    types.PlanTypeStatic,
+			Identifiers: []plan.IdentifierTrace{},
+			Fields:      []plan.FieldProvenance{},
+		}, err
+	}
+
+	submoduleName := lo.FromPtrOr(opt.SubmoduleName, "")
+	config, configErr := plan.NewProjectConfigurationFromFsE(src, submoduleName)
+
+	planner := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source:        src,
+			Config:        config,
+			SubmoduleName: submoduleName,
+			Context:       ctx,
+		},
+		SupportedIdentifiers(config)...,
+	)
+
+	t, m, trace, err := planner.(plan.TracingPlanner).PlanWithTrace()
+	if configErr != nil {
+		return t, m, trace, configErr
+	}
+
+	return t, m, trace, err
+}
+
+// PlanCandidates returns the plans of all the matched identifiers.
+// The first candidate is the one Plan returns, and the rest are the
+// runner-ups ranked by their scores.
+//
+// It returns the errors described in PlanE.
+func PlanCandidates(opt PlanOptions) ([]plan.Candidate, error) {
+	return PlanCandidatesContext(context.Background(), opt)
+}
+
+// PlanCandidatesContext is the same as PlanCandidates, but fetches the
+// remote source and plans with ctx. If ctx is done before the candidates
+// are determined, it returns ctx.Err().
+func PlanCandidatesContext(ctx context.Context, opt PlanOptions) ([]plan.Candidate, error) {
+	candidates, err := planCandidates(ctx, opt)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return candidates, ctxErr
+	}
+	return candidates, err
+}
+
+func planCandidates(ctx context.Context, opt PlanOptions) ([]plan.Candidate, error) {
+	src, cleanup, err := getPlanSource(ctx, &opt)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+
+	submoduleName := lo.FromPtrOr(opt.SubmoduleName, "")
+	config, configErr := plan.NewProjectConfigurationFromFsE(src, submoduleName)
+
+	planner := plan.NewPlanner(
+		&plan.NewPlannerOptions{
+			Source:        src,
+			Config:        config,
+			SubmoduleName: submoduleName,
+			Context:       ctx,
+		},
+		SupportedIdentifiers(config)...,
+	)
+
+	candidates, err := planner.(plan.CandidatePlanner).PlanCandidates()
+	if configErr != nil {
+		return candidates, configErr
+	}
+
+	return candidates, err
+}
+
+// errorPlanMeta returns the plan meta describing the source error,
+// which is returned by Plan for compatibility.
+func errorPlanMeta(err error) types.PlanMeta {
+	var sourceErr *SourceFetchError
+	if errors.As(err, &sourceErr) && errors.Is(sourceErr.Err, errMissingAWSConfig) {
+		return types.PlanMeta{"error": "Missing AWS configuration, cannot access S3 source"}
+	}
+
+	var githubErr *githubSourceError
+	if errors.As(err, &githubErr) {
+		return types.PlanMeta{"error": "unexpected github source", "details": githubErr.Err.Error()}
+	}
+
+	return types.PlanMeta{"error": err.Error()}
+}
+
+// errMissingAWSConfig is the error when planning an S3 source without AWS configuration.
+var errMissingAWSConfig = errors.New("missing AWS configuration")
+
+// githubSourceError marks the error returned by getGitHubSourceFromURL.
+type githubSourceError struct {
+	Err error
+}
+
+func (e *githubSourceError) Error() string {
+	return "unexpected github source: " + e.Err.Error()
+}
+
+func (e *githubSourceError) Unwrap() error {
+	return e.Err
+}
+
+// getPlanSource returns the filesystem of the project specified in opt,
+// and the function to clean up the fetched source.
+//
+// If the source cannot be accessed, it returns *SourceFetchError.
+// The remote source is fetched and accessed with ctx.
+func getPlanSource(ctx context.Context, opt *PlanOptions) (afero.Fs, func(), error) {
+	cleanup := func() {}
+
+	if err := ctx.Err(); err != nil {
+		return nil, cleanup, &SourceFetchError{Source: lo.FromPtr(opt.Path), Err: err}
+	}
+
 	wd, err := os.Getwd()
 	if err != nil {
-		panic(err)
+		return nil, cleanup, &SourceFetchError{Source: lo.FromPtr(opt.Path), Err: fmt.Errorf("get working directory: %w", err)}
 	}
 
 	if opt.Path == nil || *opt.Path == "" {
 		opt.Path = &wd
-	} else if !filepath.IsAbs(*opt.Path) && !strings.HasPrefix(*opt.Path, "https://") && !strings.HasPrefix(*opt.Path, "s3://") {
+	} else if !filepath.IsAbs(*opt.Path) && !source.IsGitURL(*opt.Path) && !strings.HasPrefix(*opt.Path, "s3://") && !strings.HasPrefix(*opt.Path, archiveURLPrefix) {
 		p := path.Join(wd, *opt.Path)
 		opt.Path = &p
 	}
 
 	var src afero.Fs
-	if strings.HasPrefix(*opt.Path, "https://github.com") {
+	var remote bool
+	if provider := getGitProvider(*opt.Path, opt.GitProvider); provider != "" && provider != GitProviderGit {
+		remote = true
+
 		var err error
-		src, err = getGitHubSourceFromURL(*opt.Path, opt.AccessToken)
-		if err != nil {
+		src, err = getRepositorySourceFromURL(ctx, *opt.Path, provider, opt.AccessToken)
+		if err != nil && provider == GitProviderGitHub {
 			log.Printf("unexpected github source: %v\n", err)
-			return types.PlanTypeStatic, types.PlanMeta{"error": "unexpected github source", "details": err.Error()}
+			return nil, cleanup, &SourceFetchError{Source: *opt.Path, Err: &githubSourceError{Err: err}}
+		}
+		if err != nil {
+			return nil, cleanup, &SourceFetchError{Source: *opt.Path, Err: err}
+		}
+	} else if source.IsGitURL(*opt.Path) {
+		gitFs, err := getGitSourceFromURL(ctx, *opt.Path, opt.GitProvider, opt.AccessToken)
+		if err != nil {
+			return nil, cleanup, &SourceFetchError{Source: *opt.Path, Err: err}
+		}
+
+		src = gitFs
+		cleanup = func() {
+			if err := gitFs.Close(); err != nil {
+				log.Printf("failed to clean up git checkout: %v\n", err)
+			}
+		}
+	} else if file, ok := getArchivePath(*opt.Path, wd); ok {
+		var err error
+		src, err = getArchiveSourceFromPath(file)
+		if err != nil {
+			return nil, cleanup, &SourceFetchError{Source: *opt.Path, Err: err}
 		}
 	} else if strings.HasPrefix(*opt.Path, "s3://") {
 		if opt.AWSConfig == nil {
-			return types.PlanTypeStatic, types.PlanMeta{"error": "Missing AWS configuration, cannot access S3 source"}
+			return nil, cleanup, &SourceFetchError{Source: *opt.Path, Err: errMissingAWSConfig}
 		}
 
-		src = getS3SourceFromURL(*opt.Path, &aws.Config{
-			Region:      opt.AWSConfig.Region,
-			Credentials: credentials.NewStaticCredentialsProvider(opt.AWSConfig.AccessKeyID, opt.AWSConfig.SecretAccessKey, ""),
-		})
+		src = getS3SourceFromURL(ctx, *opt.Path, opt.AWSConfig)
+		remote = true
 	} else {
+		if _, err := os.Stat(*opt.Path); err != nil {
+			return nil, cleanup, &SourceFetchError{Source: *opt.Path, Err: err}
+		}
+
 		src = afero.NewBasePathFs(afero.NewOsFs(), *opt.Path)
 	}
 
@@ -75,29 +382,85 @@ func Plan(opt PlanOptions) (types.PlanType, types.PlanMeta) {
 		src = afero.NewBasePathFs(src, *opt.Subpath)
 	}
 
+	// The remote sources are slow to access, so cache the files read
+	// and prefetch the manifest files in parallel.
+	if remote {
+		cachedFs := source.NewCachedFs(src)
+		cachedFs.Prefetch(source.ManifestFiles...)
+		src = cachedFs
+	}
+
+	return src, cleanup, nil
+}
+
+// configReporter reports where the project configuration is loaded from,
+// and the problems encountered when loading it.
+type configReporter interface {
+	Sources() []string
+	Warnings() []string
+}
+
+// PlanBuildPlan returns the typed build plan.
+//
+// It is the typed counterpart of Plan. If the source cannot be accessed,
+// it returns the static plan with an "error" key in Extra.
+// Use PlanBuildPlanContext to get the error instead.
+func PlanBuildPlan(opt PlanOptions) types.BuildPlan {
+	bp, _ := PlanBuildPlanContext(context.Background(), opt)
+	return bp
+}
+
+// PlanBuildPlanContext is the same as PlanBuildPlan, but fetches the
+// remote source and plans with ctx, and returns the errors described
+// in PlanE. If ctx is done before the plan is determined, it returns
+// ctx.Err().
+func PlanBuildPlanContext(ctx context.Context, opt PlanOptions) (types.BuildPlan, error) {
+	bp, err := planBuildPlan(ctx, opt)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return bp, ctxErr
+	}
+	return bp, err
+}
+
+func planBuildPlan(ctx context.Context, opt PlanOptions) (types.BuildPlan, error) {
+	src, cleanup, err := getPlanSource(ctx, &opt)
+	defer cleanup()
+	if err != nil {
+		return types.NewBuildPlan(types.PlanTypeStatic, errorPlanMeta(err)), err
+	}
+
 	submoduleName := lo.FromPtrOr(opt.SubmoduleName, "")
-	config := plan.NewProjectConfigurationFromFs(src, submoduleName)
+	config, configErr := plan.NewProjectConfigurationFromFsE(src, submoduleName)
 
 	planner := plan.NewPlanner(
 		&plan.NewPlannerOptions{
 			Source:        src,
 			Config:        config,
 			SubmoduleName: submoduleName,
+			Context:       ctx,
 		},
 		SupportedIdentifiers(config)...,
 	)
 
-	t, m := planner.Plan()
-	return t, m
+	bp, err := planner.(plan.BuildPlanPlanner).PlanBuildPlan()
+	if configErr != nil {
+		return bp, configErr
+	}
+
+	return bp, err
 }
 
 // PlanAndOutputDockerfile output dockerfile.
 func PlanAndOutputDockerfile(opt PlanOptions) error {
-	t, m := Plan(opt)
+	bp, err := PlanBuildPlanContext(context.Background(), opt)
+	if err != nil {
+		log.Printf("Failed to plan: %s\n", err)
+		return err
+	}
+
 	dockerfile, err := GenerateDockerfile(
 		&GenerateDockerfileOptions{
-			PlanType: t,
-			PlanMeta: m,
+			BuildPlan: &bp,
 		},
 	)
 	if err != nil {
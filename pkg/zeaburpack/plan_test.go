@@ -0,0 +1,365 @@
+package zeaburpack_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+	"github.com/zeabur/zbpack/pkg/zeaburpack"
+)
+
+func TestPlanE_SourceFetchError(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "not-exist")
+
+	_, _, err := zeaburpack.PlanE(zeaburpack.PlanOptions{Path: &path})
+
+	var sourceErr *zeaburpack.SourceFetchError
+	require.ErrorAs(t, err, &sourceErr)
+	assert.Equal(t, path, sourceErr.Source)
+
+	// Plan keeps returning the static plan with the error.
+	planType, planMeta := zeaburpack.Plan(zeaburpack.PlanOptions{Path: &path})
+	assert.Equal(t, types.PlanTypeStatic, planType)
+	assert.Contains(t, planMeta, "error")
+}
+
+func TestPlanE_MissingAWSConfig(t *testing.T) {
+	t.Parallel()
+
+	path := "s3://bucket/project"
+
+	_, _, err := zeaburpack.PlanE(zeaburpack.PlanOptions{Path: &path})
+
+	var sourceErr *zeaburpack.SourceFetchError
+	assert.ErrorAs(t, err, &sourceErr)
+
+	_, planMeta := zeaburpack.Plan(zeaburpack.PlanOptions{Path: &path})
+	assert.Equal(t, "Missing AWS configuration, cannot access S3 source", planMeta["error"])
+}
+
+func TestPlanE_NoIdentifierMatched(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(path, "notes.txt"), []byte("hello"), 0o644))
+
+	planType, _, err := zeaburpack.PlanE(zeaburpack.PlanOptions{Path: &path})
+	assert.ErrorIs(t, err, plan.ErrNoIdentifierMatched)
+	assert.Equal(t, types.PlanTypeStatic, planType)
+}
+
+func TestPlanE_ConfigParseError(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(path, "index.html"), []byte("<html></html>"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "zbpack.json"), []byte("{"), 0o644))
+
+	planType, _, err := zeaburpack.PlanE(zeaburpack.PlanOptions{Path: &path})
+
+	var parseErr *plan.ConfigParseError
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, types.PlanTypeStatic, planType)
+}
+
+func TestDiscover(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"package.json":                 `{"private": true, "workspaces": ["apps/*"]}`,
+		"apps/web/package.json":        `{"name": "web", "scripts": {"start": "node index.js"}}`,
+		"go.mod":                       "module example.com/app\n\ngo 1.22\n",
+		"cmd/api/main.go":              "package main\n\nfunc main() {}\n",
+		"services/ml/requirements.txt": "flask\n",
+		"services/ml/app.py":           "",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte(content), 0o644))
+	}
+
+	services, err := zeaburpack.Discover(zeaburpack.PlanOptions{Path: &root})
+	require.NoError(t, err)
+
+	planTypes := make(map[string]types.PlanType)
+	for _, s := range services {
+		assert.Empty(t, s.Error, s.Name)
+		planTypes[s.Name] = s.PlanType
+	}
+	assert.Equal(t, map[string]types.PlanType{
+		"web": types.PlanTypeNodejs,
+		"api": types.PlanTypeGo,
+		"ml":  types.PlanTypePython,
+	}, planTypes)
+}
+
+func TestPlanE_GitURL(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	work := t.TempDir()
+	bare := filepath.Join(t.TempDir(), "app.git")
+	require.NoError(t, os.MkdirAll(filepath.Join(work, "services", "api"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(work, "services", "api", "go.mod"), []byte("module api\n\ngo 1.22\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(work, "services", "api", "main.go"), []byte("package main\n"), 0o644))
+	for _, args := range [][]string{
+		{"init", "--quiet", "--initial-branch=main"},
+		{"add", "."},
+		{"-c", "user.name=zbpack", "-c", "user.email=zbpack@example.com", "commit", "--quiet", "-m", "init"},
+		{"clone", "--quiet", "--bare", work, bare},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = work
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	path := "file://" + bare + "#main:services/api"
+	planType, _, err := zeaburpack.PlanE(zeaburpack.PlanOptions{Path: &path})
+	require.NoError(t, err)
+	assert.Equal(t, types.PlanTypeGo, planType)
+
+	path = "file://" + bare + "#not-exist"
+	_, _, err = zeaburpack.PlanE(zeaburpack.PlanOptions{Path: &path})
+	var sourceErr *zeaburpack.SourceFetchError
+	assert.ErrorAs(t, err, &sourceErr)
+}
+
+func TestPlanE_Archive(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "app.tar.gz")
+	f, err := os.Create(file)
+	require.NoError(t, err)
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for name, content := range map[string]string{
+		"go.mod":  "module app\n\ngo 1.22\n",
+		"main.go": "package main\n",
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	require.NoError(t, f.Close())
+
+	for _, path := range []string{file, "archive://" + file} {
+		planType, _, err := zeaburpack.PlanE(zeaburpack.PlanOptions{Path: &path})
+		require.NoError(t, err)
+		assert.Equal(t, types.PlanTypeGo, planType)
+	}
+
+	path := "archive://" + filepath.Join(t.TempDir(), "not-exist.zip")
+	_, _, err = zeaburpack.PlanE(zeaburpack.PlanOptions{Path: &path})
+	var sourceErr *zeaburpack.SourceFetchError
+	assert.ErrorAs(t, err, &sourceErr)
+}
+
+func TestPlanE_GitProvider(t *testing.T) {
+	t.Parallel()
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	// a zipball created by git archive, which has the directory entries
+	for _, file := range [][2]string{
+		{"app-v1-1a2b3c/", ""},
+		{"app-v1-1a2b3c/services/", ""},
+		{"app-v1-1a2b3c/services/api/", ""},
+		{"app-v1-1a2b3c/services/api/go.mod", "module api\n\ngo 1.22\n"},
+		{"app-v1-1a2b3c/services/api/main.go", "package main\n"},
+	} {
+		w, err := zw.Create(file[0])
+		require.NoError(t, err)
+		_, err = w.Write([]byte(file[1]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RequestURI() != "/api/v4/projects/acme%2Fapp/repository/archive.zip?sha=v1" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	path := server.URL + "/acme/app.git#v1:services/api"
+	planType, _, err := zeaburpack.PlanE(zeaburpack.PlanOptions{Path: &path, GitProvider: zeaburpack.GitProviderGitLab})
+	require.NoError(t, err)
+	assert.Equal(t, types.PlanTypeGo, planType)
+
+	path = server.URL + "/acme/app.git#v2"
+	_, _, err = zeaburpack.PlanE(zeaburpack.PlanOptions{Path: &path, GitProvider: zeaburpack.GitProviderGitLab})
+	var sourceErr *zeaburpack.SourceFetchError
+	assert.ErrorAs(t, err, &sourceErr)
+}
+
+func TestPlanContext_Canceled(t *testing.T) {
+	t.Parallel()
+
+	// The server never responds until the request is canceled.
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	path := server.URL + "/acme/app.git"
+	start := time.Now()
+	planType, _, err := zeaburpack.PlanContext(ctx, zeaburpack.PlanOptions{Path: &path, GitProvider: zeaburpack.GitProviderGitea})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, types.PlanTypeStatic, planType)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// a canceled context fails even the local source
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	dir := t.TempDir()
+	_, _, err = zeaburpack.PlanContext(ctx, zeaburpack.PlanOptions{Path: &dir})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestContextVariants_Canceled(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0o644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, _, err := zeaburpack.PlanWithTraceContext(ctx, zeaburpack.PlanOptions{Path: &dir})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = zeaburpack.PlanCandidatesContext(ctx, zeaburpack.PlanOptions{Path: &dir})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = zeaburpack.DiscoverContext(ctx, zeaburpack.PlanOptions{Path: &dir})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = zeaburpack.PlanBuildPlanContext(ctx, zeaburpack.PlanOptions{Path: &dir})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPlanBuildPlan(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.22\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "zbpack.json"), []byte(`{"cache_mounts": true}`), 0o644))
+
+	bp, err := zeaburpack.PlanBuildPlanContext(context.Background(), zeaburpack.PlanOptions{Path: &dir})
+	require.NoError(t, err)
+	assert.Equal(t, types.PlanTypeGo, bp.Type)
+	assert.Equal(t, "1.22", bp.RuntimeVersion)
+	assert.True(t, bp.Flag(types.FlagCacheMounts))
+
+	planType, planMeta, err := zeaburpack.PlanE(zeaburpack.PlanOptions{Path: &dir})
+	require.NoError(t, err)
+	assert.Equal(t, planType, bp.Type)
+	assert.Equal(t, planMeta, bp.PlanMeta())
+
+	dockerfile, err := zeaburpack.GenerateDockerfile(&zeaburpack.GenerateDockerfileOptions{BuildPlan: &bp})
+	require.NoError(t, err)
+	expected, err := zeaburpack.GenerateDockerfile(&zeaburpack.GenerateDockerfileOptions{PlanType: planType, PlanMeta: planMeta})
+	require.NoError(t, err)
+	assert.Equal(t, expected, dockerfile)
+}
+
+func TestPlanBuildPlan_Languages(t *testing.T) {
+	t.Parallel()
+
+	projects := map[types.PlanType]map[string]string{
+		types.PlanTypeNodejs: {"package.json": `{"scripts":{"build":"tsc","start":"node dist/index.js"}}`, "package-lock.json": "{}"},
+		types.PlanTypeBun:    {"package.json": `{"scripts":{"start":"bun index.ts"}}`, "bun.lockb": ""},
+		types.PlanTypePHP:    {"composer.json": `{"require":{"ext-gd":"*"}}`, "index.php": "<?php"},
+		types.PlanTypeRuby:   {"Gemfile": "gem 'rails'\ngem 'mysql2'", "package.json": "{}", "yarn.lock": ""},
+		types.PlanTypeJava:   {"pom.xml": "<project><parent><artifactId>spring-boot-starter-parent</artifactId></parent></project>"},
+		types.PlanTypeDotnet: {"app.csproj": `<Project Sdk="Microsoft.NET.Sdk.Web"><PropertyGroup><TargetFramework>net8.0</TargetFramework></PropertyGroup></Project>`},
+		types.PlanTypeDeno:   {"deno.json": `{"tasks":{"start":"deno run main.ts"}}`, "main.ts": ""},
+		types.PlanTypeElixir: {"mix.exs": `[elixir: "~> 1.13", deps: [{:phoenix, "~> 1.7"}, {:ecto_sql, "~> 3.10"}, {:postgrex, ">= 0.0.0"}]]`},
+		types.PlanTypeStatic: {"config.toml": `base_url = "https://example.com"`},
+		types.PlanTypeCustom: {"zbpack.json": `{"custom":{"base_image":"alpine","system_packages":["git"],"build":["make","make install"],"start":["./app","serve"],"port":8080}}`},
+		types.PlanTypeDocker: {"Dockerfile": "FROM alpine\n"},
+	}
+
+	for planType, files := range projects {
+		t.Run(string(planType), func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for name, content := range files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+			}
+
+			bp, err := zeaburpack.PlanBuildPlanContext(context.Background(), zeaburpack.PlanOptions{Path: &dir})
+			require.NoError(t, err)
+			assert.Equal(t, planType, bp.Type)
+
+			_, planMeta, err := zeaburpack.PlanE(zeaburpack.PlanOptions{Path: &dir})
+			require.NoError(t, err)
+			assert.Equal(t, planMeta, bp.PlanMeta())
+
+			dockerfile, err := zeaburpack.GenerateDockerfile(&zeaburpack.GenerateDockerfileOptions{BuildPlan: &bp})
+			require.NoError(t, err)
+			expected, err := zeaburpack.GenerateDockerfile(&zeaburpack.GenerateDockerfileOptions{PlanType: bp.Type, PlanMeta: planMeta})
+			require.NoError(t, err)
+			assert.Equal(t, expected, dockerfile)
+		})
+	}
+}
+
+func TestPlanBuildPlan_SourceFetchError(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "not-exist")
+
+	bp := zeaburpack.PlanBuildPlan(zeaburpack.PlanOptions{Path: &path})
+	assert.Equal(t, types.PlanTypeStatic, bp.Type)
+	assert.Contains(t, bp.Extra, "error")
+
+	_, err := zeaburpack.PlanBuildPlanContext(context.Background(), zeaburpack.PlanOptions{Path: &path})
+	var sourceErr *zeaburpack.SourceFetchError
+	assert.ErrorAs(t, err, &sourceErr)
+}
+
+func TestBuildContext_Canceled(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html></html>"), 0o644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := zeaburpack.BuildContext(ctx, &zeaburpack.BuildOptions{
+		Path:      &dir,
+		LogWriter: io.Discard,
+		Builder:   zeaburpack.ExportBuilder{Dir: filepath.Join(t.TempDir(), "out")},
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,124 @@
+package zeaburpack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/zeabur/zbpack/internal/source"
+)
+
+// GitProvider is the hosting service of a remote repository.
+type GitProvider string
+
+const (
+	// GitProviderGitHub is GitHub or GitHub Enterprise Server.
+	GitProviderGitHub GitProvider = "github"
+	// GitProviderGitLab is GitLab, including the self-managed instances.
+	GitProviderGitLab GitProvider = "gitlab"
+	// GitProviderGitea is Gitea or Forgejo, for example, Codeberg.
+	GitProviderGitea GitProvider = "gitea"
+	// GitProviderBitbucket is Bitbucket Cloud.
+	GitProviderBitbucket GitProvider = "bitbucket"
+	// GitProviderGit fetches the repository with git, which works with any host.
+	GitProviderGit GitProvider = "git"
+)
+
+// getGitProvider returns the provider of the repository at path,
+// or an empty string if path is not an HTTP(S) URL.
+//
+// If provider is specified, it is returned as is. Otherwise, the provider
+// is detected from the host, and GitProviderGit is returned for the
+// unknown hosts.
+func getGitProvider(path string, provider GitProvider) GitProvider {
+	if !strings.HasPrefix(path, "https://") && !strings.HasPrefix(path, "http://") {
+		return ""
+	}
+	if provider != "" {
+		return provider
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return GitProviderGit
+	}
+
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case host == "github.com":
+		return GitProviderGitHub
+	case host == "gitlab.com", strings.HasPrefix(host, "gitlab."):
+		return GitProviderGitLab
+	case host == "gitea.com", host == "codeberg.org", strings.HasPrefix(host, "gitea."), strings.HasPrefix(host, "forgejo."):
+		return GitProviderGitea
+	case host == "bitbucket.org":
+		return GitProviderBitbucket
+	}
+
+	return GitProviderGit
+}
+
+// gitUsername returns the username to send with the access token when
+// fetching a repository of the provider with git over HTTPS.
+func gitUsername(provider GitProvider) string {
+	switch provider {
+	case GitProviderGitLab:
+		return "oauth2"
+	case GitProviderBitbucket:
+		return "x-token-auth"
+	}
+	return "x-access-token"
+}
+
+// getRepositorySourceFromURL returns the source of the repository at
+// the URL in the form of "<url>#<ref>:<subpath>" from the archive
+// which the provider serves.
+func getRepositorySourceFromURL(ctx context.Context, rawURL string, provider GitProvider, token *string) (afero.Fs, error) {
+	remote, ref, subpath := source.ParseGitURL(rawURL)
+
+	u, err := url.Parse(remote)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository URL: %w", err)
+	}
+	baseURL := u.Scheme + "://" + u.Host
+
+	// "/acme/app.git/" -> "acme/app"
+	repoPath := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	// "acme/app/tree/main" -> "acme", "app"
+	owner, name, _ := strings.Cut(repoPath, "/")
+	name, _, _ = strings.Cut(name, "/")
+	if owner == "" || name == "" {
+		return nil, errors.New("invalid repository URL: missing owner or name")
+	}
+
+	var src afero.Fs
+	switch provider {
+	case GitProviderGitHub:
+		options := []source.GitHubFsOption{source.GitHubRef(ref), source.GitHubContext(ctx)}
+		if u.Host != "github.com" {
+			options = append(options, source.GitHubBaseURL(baseURL))
+		}
+		src, err = source.NewGitHubFs(owner, name, token, options...)
+	case GitProviderGitLab:
+		// GitLab supports nested groups, such as "acme/backend/api/-/tree/main".
+		project, _, _ := strings.Cut(repoPath, "/-/")
+		src, err = source.NewGitLabFs(project, token, source.ForgeRef(ref), source.ForgeBaseURL(baseURL), source.ForgeContext(ctx))
+	case GitProviderGitea:
+		src, err = source.NewGiteaFs(owner, name, token, source.ForgeRef(ref), source.ForgeBaseURL(baseURL), source.ForgeContext(ctx))
+	case GitProviderBitbucket:
+		src, err = source.NewBitbucketFs(owner, name, token, source.ForgeRef(ref), source.ForgeBaseURL(baseURL), source.ForgeContext(ctx))
+	default:
+		return nil, fmt.Errorf("unsupported git provider: %s", provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subpath != "" {
+		src = afero.NewBasePathFs(src, subpath)
+	}
+	return src, nil
+}
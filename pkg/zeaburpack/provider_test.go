@@ -0,0 +1,41 @@
+package zeaburpack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetGitProvider(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path     string
+		provider GitProvider
+		want     GitProvider
+	}{
+		{path: "https://github.com/acme/app", want: GitProviderGitHub},
+		{path: "https://gitlab.com/acme/backend/app.git#main", want: GitProviderGitLab},
+		{path: "https://gitlab.example.com/acme/app", want: GitProviderGitLab},
+		{path: "https://codeberg.org/acme/app", want: GitProviderGitea},
+		{path: "https://forgejo.example.com/acme/app", want: GitProviderGitea},
+		{path: "https://bitbucket.org/acme/app", want: GitProviderBitbucket},
+		{path: "https://git.example.com/acme/app.git", want: GitProviderGit},
+		{path: "https://git.example.com/acme/app", provider: GitProviderGitea, want: GitProviderGitea},
+		{path: "git@github.com:acme/app.git", want: ""},
+		{path: "/srv/app", provider: GitProviderGitLab, want: ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, getGitProvider(tt.path, tt.provider), tt.path)
+	}
+}
+
+func TestGitUsername(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "x-access-token", gitUsername(GitProviderGitHub))
+	assert.Equal(t, "oauth2", gitUsername(GitProviderGitLab))
+	assert.Equal(t, "x-token-auth", gitUsername(GitProviderBitbucket))
+	assert.Equal(t, "x-access-token", gitUsername(GitProviderGit))
+}
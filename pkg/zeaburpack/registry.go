@@ -0,0 +1,129 @@
+package zeaburpack
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+
+	"github.com/zeabur/zbpack/pkg/packer"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/plugin"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+// The priorities of the built-in identifiers and packers.
+//
+// The identifiers and packers with higher priority are tried first.
+// A registered one is tried after the built-in ones with the same priority.
+const (
+	// PriorityDockerfile is the priority of the Dockerfile identifier.
+	PriorityDockerfile = 300
+	// PriorityCustom is the priority of the custom plan declared in zbpack.json.
+	PriorityCustom = 250
+	// PriorityNix is the priority of the Nix identifier.
+	PriorityNix = 200
+	// PriorityBuiltin is the priority of the language identifiers.
+	PriorityBuiltin = 100
+	// PriorityFallback is the priority of the static identifier,
+	// which matches a project with only an index.html.
+	PriorityFallback = 0
+)
+
+type registeredIdentifier struct {
+	identifier plan.IdentifierV2
+	priority   int
+}
+
+type registeredPacker struct {
+	packer   packer.V2
+	priority int
+}
+
+var (
+	registryMu            sync.RWMutex
+	registeredIdentifiers []registeredIdentifier
+	registeredPackers     []registeredPacker
+)
+
+// RegisterIdentifier registers a third-party identifier, so that
+// SupportedIdentifiers and the planner of Plan and Build include it.
+//
+// For example, to try an identifier before the language identifiers
+// but after the Dockerfile and Nix identifiers:
+//
+//	zeaburpack.RegisterIdentifier(myIdentifier, zeaburpack.PriorityBuiltin+1)
+//
+// It is usually called in the init function of the package providing the identifier.
+func RegisterIdentifier(identifier plan.IdentifierV2, priority int) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registeredIdentifiers = append(registeredIdentifiers, registeredIdentifier{identifier, priority})
+}
+
+// RegisterPacker registers a third-party packer, so that GenerateDockerfile
+// can generate the Dockerfile of its plan type.
+//
+// If multiple packers have the same plan type, the one with higher
+// priority is used. Use a priority higher than PriorityBuiltin to
+// replace a built-in packer.
+func RegisterPacker(p packer.V2, priority int) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registeredPackers = append(registeredPackers, registeredPacker{p, priority})
+}
+
+// sortIdentifiers appends the registered identifiers to the built-in ones,
+// and sorts them by priority.
+//
+// If forcedPlanType is not empty, the identifiers of that plan type with
+// a lower priority than the first one are removed, so that the planner
+// uses the overriding identifier instead of reporting an ambiguous match.
+func sortIdentifiers(builtin []registeredIdentifier, forcedPlanType types.PlanType) []plan.IdentifierV2 {
+	registryMu.RLock()
+	all := slices.Concat(builtin, registeredIdentifiers)
+	registryMu.RUnlock()
+
+	slices.SortStableFunc(all, func(a, b registeredIdentifier) int {
+		return cmp.Compare(b.priority, a.priority)
+	})
+
+	identifiers := make([]plan.IdentifierV2, 0, len(all))
+	forcedPriority, found := 0, false
+	for _, r := range all {
+		if forcedPlanType != "" && r.identifier.PlanType() == forcedPlanType {
+			if found && r.priority < forcedPriority {
+				continue
+			}
+			forcedPriority, found = r.priority, true
+		}
+		identifiers = append(identifiers, r.identifier)
+	}
+	return identifiers
+}
+
+// sortPackers appends the registered packers to the built-in ones,
+// and sorts them by priority.
+func sortPackers(builtin []registeredPacker) []packer.V2 {
+	registryMu.RLock()
+	all := slices.Concat(builtin, registeredPackers)
+	registryMu.RUnlock()
+
+	slices.SortStableFunc(all, func(a, b registeredPacker) int {
+		return cmp.Compare(b.priority, a.priority)
+	})
+
+	packers := make([]packer.V2, 0, len(all))
+	for _, r := range all {
+		packers = append(packers, r.packer)
+	}
+	return packers
+}
+
+// RegisterPlugin registers an external-process plugin as both
+// an identifier and a packer of its plan type.
+func RegisterPlugin(p *plugin.Plugin, priority int) {
+	RegisterIdentifier(p, priority)
+	RegisterPacker(p, priority)
+}
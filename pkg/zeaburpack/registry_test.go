@@ -0,0 +1,148 @@
+package zeaburpack
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeabur/zbpack/pkg/plan"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+// snapshotRegistry returns a function which restores the registered
+// identifiers and packers to the current ones. The tests use it to
+// remove what they registered.
+func snapshotRegistry() (restore func()) {
+	registryMu.RLock()
+	identifiers := slices.Clone(registeredIdentifiers)
+	packers := slices.Clone(registeredPackers)
+	registryMu.RUnlock()
+
+	return func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+
+		registeredIdentifiers = identifiers
+		registeredPackers = packers
+	}
+}
+
+const planTypeZig types.PlanType = "zig-registry-test"
+
+type zigPacker struct{}
+
+func (zigPacker) PlanType() types.PlanType {
+	return planTypeZig
+}
+
+func (zigPacker) Match(ctx plan.MatchContext) bool {
+	_, err := ctx.Source.Stat("build.zig")
+	return err == nil
+}
+
+func (zigPacker) PlanMeta(plan.NewPlannerOptions) types.PlanMeta {
+	return types.PlanMeta{"zigVersion": "0.13.0"}
+}
+
+func (zigPacker) GenerateDockerfile(meta types.PlanMeta) (string, error) {
+	return "FROM ziglang/zig:" + meta["zigVersion"], nil
+}
+
+func TestRegistry(t *testing.T) {
+	t.Cleanup(snapshotRegistry())
+
+	RegisterIdentifier(zigPacker{}, PriorityBuiltin+1)
+	RegisterPacker(zigPacker{}, PriorityBuiltin)
+
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "build.zig", nil, 0o644)
+	_ = afero.WriteFile(fs, "index.html", nil, 0o644)
+	config := plan.NewProjectConfigurationFromFs(fs, "")
+
+	identifiers := SupportedIdentifiers(config)
+	planTypes := make([]types.PlanType, 0, len(identifiers))
+	for _, i := range identifiers {
+		planTypes = append(planTypes, i.PlanType())
+	}
+	assert.Equal(t, []types.PlanType{
+		types.PlanTypeDocker,
+		types.PlanTypeCustom,
+		types.PlanTypeNix,
+		planTypeZig,
+		types.PlanTypeDart,
+	}, planTypes[:5])
+	assert.Equal(t, types.PlanTypeStatic, planTypes[len(planTypes)-1])
+
+	planType, planMeta, err := plan.NewPlanner(
+		&plan.NewPlannerOptions{Source: fs, Config: config},
+		identifiers...,
+	).(plan.ErrorPlanner).PlanE()
+	assert.NoError(t, err)
+	assert.Equal(t, planTypeZig, planType)
+
+	dockerfile, err := GenerateDockerfile(&GenerateDockerfileOptions{
+		PlanType: planType,
+		PlanMeta: planMeta,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "FROM ziglang/zig:0.13.0\nLABEL \"language\"=\"zig-registry-test\"", dockerfile)
+}
+
+func TestSnapshotRegistry(t *testing.T) {
+	restore := snapshotRegistry()
+	RegisterIdentifier(zigPacker{}, PriorityBuiltin)
+	restore()
+
+	config := plan.NewProjectConfigurationFromFs(afero.NewMemMapFs(), "")
+	for _, i := range SupportedIdentifiers(config) {
+		assert.NotEqual(t, planTypeZig, i.PlanType())
+	}
+}
+
+// goOverride is an identifier which overrides the built-in Go identifier.
+type goOverride struct{}
+
+func (goOverride) PlanType() types.PlanType {
+	return types.PlanTypeGo
+}
+
+func (goOverride) Match(plan.MatchContext) bool {
+	return true
+}
+
+func (goOverride) PlanMeta(plan.NewPlannerOptions) types.PlanMeta {
+	return types.PlanMeta{"override": "true"}
+}
+
+func TestRegistry_OverrideForcedPlanType(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "go.mod", []byte("module example.com/app\n"), 0o644)
+	_ = afero.WriteFile(fs, "zbpack.json", []byte(`{"plan_type": "go"}`), 0o644)
+	config := plan.NewProjectConfigurationFromFs(fs, "")
+
+	t.Run("higher priority", func(t *testing.T) {
+		t.Cleanup(snapshotRegistry())
+		RegisterIdentifier(goOverride{}, PriorityBuiltin+1)
+
+		planType, planMeta, err := plan.NewPlanner(
+			&plan.NewPlannerOptions{Source: fs, Config: config},
+			SupportedIdentifiers(config)...,
+		).(plan.ErrorPlanner).PlanE()
+		assert.NoError(t, err)
+		assert.Equal(t, types.PlanTypeGo, planType)
+		assert.Equal(t, "true", planMeta["override"])
+	})
+
+	t.Run("same priority", func(t *testing.T) {
+		t.Cleanup(snapshotRegistry())
+		RegisterIdentifier(goOverride{}, PriorityBuiltin)
+
+		_, _, err := plan.NewPlanner(
+			&plan.NewPlannerOptions{Source: fs, Config: config},
+			SupportedIdentifiers(config)...,
+		).(plan.ErrorPlanner).PlanE()
+		var ambiguousErr *plan.AmbiguousMatchError
+		assert.ErrorAs(t, err, &ambiguousErr)
+	})
+}
@@ -0,0 +1,113 @@
+package zeaburpack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+// BuildResult is the result of a successful build.
+type BuildResult struct {
+	// Image is the name of the built image.
+	Image string `json:"image"`
+	// Digest is the digest of the image manifest (or the manifest list),
+	// for example, "sha256:…". It is empty if the builder does not report it.
+	Digest string `json:"digest,omitempty"`
+	// Size is the total size of the config and the layers of the image
+	// in bytes. It is 0 if the builder does not report it.
+	Size int64 `json:"size,omitempty"`
+	// Platforms is the platforms the image is built for. Empty if it
+	// is built for the platform of the builder.
+	Platforms []string `json:"platforms,omitempty"`
+
+	PlanType types.PlanType `json:"planType"`
+	PlanMeta types.PlanMeta `json:"planMeta"`
+
+	// DockerfileHash is the SHA-256 of the built Dockerfile,
+	// for example, "sha256:…".
+	DockerfileHash string `json:"dockerfileHash"`
+
+	// Duration is the time the build takes. It is in nanoseconds in JSON.
+	Duration time.Duration `json:"duration"`
+
+	// Cache is the statistics of the build cache. It is only reported
+	// by the BuildKit builder.
+	Cache CacheStats `json:"cache"`
+}
+
+// CacheStats is the statistics of the build cache.
+type CacheStats struct {
+	// Steps is the number of the completed steps.
+	Steps int `json:"steps"`
+	// Cached is the number of the steps served from the cache.
+	Cached int `json:"cached"`
+}
+
+// dockerfileHash returns the SHA-256 of the Dockerfile.
+func dockerfileHash(dockerfile string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(dockerfile)))
+}
+
+// writeBuildReport writes the result as JSON to the file.
+func writeBuildReport(path string, result *BuildResult) error {
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal build report: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write build report: %w", err)
+	}
+
+	return nil
+}
+
+// imageSize returns the total size of the configs and the layers of the
+// image described by desc. A manifest list counts the size of every
+// manifest in it. read returns the content of a descriptor.
+func imageSize(ctx context.Context, read func(context.Context, ocispecs.Descriptor) ([]byte, error), desc ocispecs.Descriptor) (int64, error) {
+	content, err := read(ctx, desc)
+	if err != nil {
+		return 0, fmt.Errorf("read %s: %w", desc.Digest, err)
+	}
+
+	switch desc.MediaType {
+	case ocispecs.MediaTypeImageIndex, "application/vnd.docker.distribution.manifest.list.v2+json":
+		var index ocispecs.Index
+		if err := json.Unmarshal(content, &index); err != nil {
+			return 0, fmt.Errorf("unmarshal index: %w", err)
+		}
+
+		var size int64
+		for _, manifest := range index.Manifests {
+			// attestation manifests are not a part of the image
+			if manifest.Annotations["vnd.docker.reference.type"] != "" {
+				continue
+			}
+
+			manifestSize, err := imageSize(ctx, read, manifest)
+			if err != nil {
+				return 0, err
+			}
+			size += manifestSize
+		}
+		return size, nil
+	default:
+		var manifest ocispecs.Manifest
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			return 0, fmt.Errorf("unmarshal manifest: %w", err)
+		}
+
+		size := manifest.Config.Size
+		for _, layer := range manifest.Layers {
+			size += layer.Size
+		}
+		return size, nil
+	}
+}
@@ -0,0 +1,111 @@
+package zeaburpack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zeabur/zbpack/pkg/types"
+)
+
+func TestImageSize(t *testing.T) {
+	t.Parallel()
+
+	blobs := map[digest.Digest][]byte{}
+	put := func(mediaType string, v any) ocispecs.Descriptor {
+		content, err := json.Marshal(v)
+		require.NoError(t, err)
+
+		d := digest.FromBytes(content)
+		blobs[d] = content
+		return ocispecs.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(content))}
+	}
+	read := func(_ context.Context, desc ocispecs.Descriptor) ([]byte, error) {
+		content, ok := blobs[desc.Digest]
+		if !ok {
+			return nil, fmt.Errorf("not found")
+		}
+		return content, nil
+	}
+
+	amd64 := put(ocispecs.MediaTypeImageManifest, ocispecs.Manifest{
+		Config: ocispecs.Descriptor{Size: 100},
+		Layers: []ocispecs.Descriptor{{Size: 1000}, {Size: 2000}},
+	})
+	arm64 := put(ocispecs.MediaTypeImageManifest, ocispecs.Manifest{
+		Config: ocispecs.Descriptor{Size: 200},
+		Layers: []ocispecs.Descriptor{{Size: 4000}},
+	})
+	attestation := put(ocispecs.MediaTypeImageManifest, ocispecs.Manifest{
+		Config: ocispecs.Descriptor{Size: 10000},
+	})
+	attestation.Annotations = map[string]string{"vnd.docker.reference.type": "attestation-manifest"}
+	index := put(ocispecs.MediaTypeImageIndex, ocispecs.Index{
+		Manifests: []ocispecs.Descriptor{amd64, arm64, attestation},
+	})
+
+	size, err := imageSize(context.Background(), read, amd64)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3100, size)
+
+	size, err = imageSize(context.Background(), read, index)
+	require.NoError(t, err)
+	assert.EqualValues(t, 7300, size)
+
+	_, err = imageSize(context.Background(), read, ocispecs.Descriptor{Digest: digest.FromString("missing")})
+	assert.Error(t, err)
+}
+
+func TestExporterDescriptor(t *testing.T) {
+	t.Parallel()
+
+	desc, err := exporterDescriptor(map[string]string{
+		// {"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:…","size":1}
+		"containerimage.descriptor": "eyJtZWRpYVR5cGUiOiJhcHBsaWNhdGlvbi92bmQub2NpLmltYWdlLm1hbmlmZXN0LnYxK2pzb24iLCJkaWdlc3QiOiJzaGEyNTY6ZTNiMGM0NDI5OGZjMWMxNDlhZmJmNGM4OTk2ZmI5MjQyN2FlNDFlNDY0OWI5MzRjYTQ5NTk5MWI3ODUyYjg1NSIsInNpemUiOjF9",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ocispecs.MediaTypeImageManifest, desc.MediaType)
+	assert.EqualValues(t, 1, desc.Size)
+
+	_, err = exporterDescriptor(map[string]string{})
+	assert.Error(t, err)
+}
+
+func TestBuildContext_Result(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html></html>"), 0o644))
+
+	metadataFile := filepath.Join(t.TempDir(), "build-report.json")
+	result, err := BuildContext(context.Background(), &BuildOptions{
+		Path:         &dir,
+		ResultImage:  lo.ToPtr("app"),
+		UserVars:     &map[string]string{},
+		LogWriter:    io.Discard,
+		Builder:      ExportBuilder{Dir: filepath.Join(t.TempDir(), "out")},
+		MetadataFile: metadataFile,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "app", result.Image)
+	assert.Equal(t, types.PlanTypeStatic, result.PlanType)
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", result.DockerfileHash)
+	assert.Positive(t, result.Duration)
+
+	content, err := os.ReadFile(metadataFile)
+	require.NoError(t, err)
+
+	var report BuildResult
+	require.NoError(t, json.Unmarshal(content, &report))
+	assert.Equal(t, *result, report)
+}
@@ -1,14 +1,19 @@
 package zeaburpack
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"io"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/spf13/afero"
 	"github.com/zeabur/zbpack/internal/source"
+	"github.com/zeabur/zbpack/pkg/plan"
 
 	"github.com/zeabur/zbpack/pkg/types"
 )
@@ -67,23 +72,139 @@ func PrintPlanAndMeta(plan types.PlanType, meta types.PlanMeta, writer io.Writer
 	_, _ = writer.Write([]byte(table))
 }
 
-// getGitHubSourceFromURL returns a GitHub source from a GitHub URL.
-func getGitHubSourceFromURL(url string, token *string) (afero.Fs, error) {
-	repoAddress, ref, _ := strings.Cut(url, "#")
-	parts := strings.Split(repoAddress, "/")
-	if len(parts) < 5 {
-		return nil, errors.New("invalid GitHub URL")
+// PrintRunnerUps prints the runner-up candidates of the build plan,
+// and how to use one of them instead.
+func PrintRunnerUps(candidates []plan.Candidate, writer io.Writer) {
+	if len(candidates) == 0 {
+		return
 	}
-	repoOwner := parts[3]
-	repoName := parts[4]
 
-	if ref != "" {
-		return source.NewGitHubFs(repoOwner, repoName, token, source.GitHubRef(ref))
+	out := fmt.Sprintf("%sOther matched plans:%s\n", yellow, reset)
+	for _, c := range candidates {
+		out += fmt.Sprintf("  %-10s score %.2f", c.PlanType, c.Score)
+		if len(c.Reasons) > 0 {
+			out += " (" + strings.Join(c.Reasons, "; ") + ")"
+		}
+		out += "\n"
+	}
+	out += fmt.Sprintf("Set %q in zbpack.json to use one of them instead.\n", plan.ConfigKeyPlanType)
+
+	_, _ = writer.Write([]byte(out))
+}
+
+// getGitSourceFromURL fetches the Git repository from a URL in the form
+// of "<url>#<ref>:<subpath>". The provider decides the username to send
+// with the token; it is detected from the host if it is empty or
+// GitProviderGit.
+func getGitSourceFromURL(ctx context.Context, url string, provider GitProvider, token *string) (*source.GitFs, error) {
+	remote, ref, subpath := source.ParseGitURL(url)
+	if provider == GitProviderGit {
+		provider = ""
+	}
+	username := gitUsername(getGitProvider(remote, provider))
+
+	return source.NewGitFs(remote, source.GitRef(ref), source.GitSubpath(subpath), source.GitToken(token), source.GitUsername(username), source.GitContext(ctx))
+}
+
+// gitRepositoryName returns the repository name in the Git URL,
+// for example, "app" of "https://example.com/acme/app.git#main".
+func gitRepositoryName(url string) string {
+	remote, _, _ := source.ParseGitURL(url)
+	remote = strings.TrimSuffix(strings.TrimSuffix(remote, "/"), ".git")
+
+	if i := strings.LastIndexAny(remote, "/:"); i != -1 {
+		return remote[i+1:]
+	}
+	return remote
+}
+
+// archiveURLPrefix is the prefix of the URL to a local archive file,
+// for example, "archive:///srv/uploads/app.tar.gz".
+const archiveURLPrefix = "archive://"
+
+// getArchivePath returns the path of the archive file if p is an
+// archive:// URL, or the path to a zip, tar, tar.gz or tar.zst file.
+// A relative path in the archive:// URL is relative to wd.
+func getArchivePath(p, wd string) (string, bool) {
+	if file, ok := strings.CutPrefix(p, archiveURLPrefix); ok {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(wd, file)
+		}
+		return file, true
+	}
+
+	if !source.IsArchiveFile(p) {
+		return "", false
 	}
+	info, err := os.Stat(p)
+	return p, err == nil && !info.IsDir()
+}
+
+// getArchiveSourceFromPath returns the filesystem of the archive file,
+// which is read into memory without extracting.
+func getArchiveSourceFromPath(file string) (afero.Fs, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
 
-	return source.NewGitHubFs(repoOwner, repoName, token)
+	return source.NewArchiveFs(f)
 }
 
-func getS3SourceFromURL(url string, cfg *aws.Config) afero.Fs {
-	return source.NewS3Fs(url, cfg)
+// extractArchive extracts the archive file to dir.
+func extractArchive(file, dir string) error {
+	src, err := getArchiveSourceFromPath(file)
+	if err != nil {
+		return err
+	}
+	dst := afero.NewBasePathFs(afero.NewOsFs(), dir)
+
+	return afero.Walk(src, "/", func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return dst.MkdirAll(name, info.Mode().Perm()|0o700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		in, err := src.Open(name)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = in.Close()
+		}()
+
+		if err := dst.MkdirAll(path.Dir(name), 0o755); err != nil {
+			return err
+		}
+		out, err := dst.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, in)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+}
+
+func getS3SourceFromURL(ctx context.Context, url string, cfg *plan.AWSConfig) afero.Fs {
+	return source.NewS3Fs(
+		url,
+		&aws.Config{
+			Region:      cfg.Region,
+			Credentials: credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken),
+		},
+		source.S3Endpoint(cfg.Endpoint),
+		source.S3UsePathStyle(cfg.UsePathStyle),
+		source.S3Context(ctx),
+	)
 }
@@ -0,0 +1,56 @@
+package zeaburpack
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitRepositoryName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "app", gitRepositoryName("https://example.com/acme/app.git#main:services/api"))
+	assert.Equal(t, "app", gitRepositoryName("https://example.com/acme/app/"))
+	assert.Equal(t, "app", gitRepositoryName("git@example.com:app.git"))
+}
+
+func TestGetArchivePath(t *testing.T) {
+	t.Parallel()
+
+	file, ok := getArchivePath("archive://uploads/app.zip", "/srv")
+	assert.True(t, ok)
+	assert.Equal(t, "/srv/uploads/app.zip", file)
+
+	_, ok = getArchivePath("/srv/not-exist.tar.gz", "/srv")
+	assert.False(t, ok)
+
+	_, ok = getArchivePath("/srv/app", "/srv")
+	assert.False(t, ok)
+}
+
+func TestExtractArchive(t *testing.T) {
+	t.Parallel()
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create("src/main.py")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("print('hello')"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	file := filepath.Join(t.TempDir(), "app.zip")
+	require.NoError(t, os.WriteFile(file, buf.Bytes(), 0o644))
+
+	dir := t.TempDir()
+	require.NoError(t, extractArchive(file, dir))
+
+	content, err := os.ReadFile(filepath.Join(dir, "src", "main.py"))
+	require.NoError(t, err)
+	assert.Equal(t, "print('hello')", string(content))
+}
@@ -0,0 +1,5 @@
+const express = require('express');
+
+const app = express();
+app.get('/', (_req, res) => res.send('Hello World!'));
+app.listen(process.env.PORT || 8080);